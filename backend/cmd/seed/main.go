@@ -6,6 +6,8 @@ import (
 	"vehicle-sales-backend/internal/config"
 	"vehicle-sales-backend/internal/database"
 	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/permissions"
 )
 
 func main() {
@@ -14,15 +16,23 @@ func main() {
 	if err != nil {
 		log.Fatal("Failed to load configuration:", err)
 	}
+	if err := money.SetDefaultCurrency(money.Currency(cfg.Dealer.Currency)); err != nil {
+		log.Fatal("Invalid CURRENCY:", err)
+	}
 
 	// Connect to database
 	if err := database.Connect(cfg); err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
-	// Run migrations
-	if err := database.Migrate(); err != nil {
-		log.Fatal("Failed to migrate database:", err)
+	// Seeding requires an up to date schema
+	if err := database.CheckSchema(); err != nil {
+		log.Fatal("Database schema check failed: ", err)
+	}
+
+	// Register permissions and built-in roles
+	if err := permissions.Sync(); err != nil {
+		log.Fatal("Failed to sync permissions:", err)
 	}
 
 	// Create admin user
@@ -106,7 +116,7 @@ func main() {
 			Year:        2023,
 			Color:       "Silver",
 			VIN:         "1HGBH41JXMN109186",
-			Price:       28500.00,
+			Price:       money.MustParse("285000000", money.IDR),
 			Mileage:     15000,
 			Status:      models.VehicleStatusAvailable,
 			Description: "Well-maintained Toyota Camry with excellent fuel economy",
@@ -117,7 +127,7 @@ func main() {
 			Year:        2022,
 			Color:       "Black",
 			VIN:         "2HGBH41JXMN109187",
-			Price:       32000.00,
+			Price:       money.MustParse("320000000", money.IDR),
 			Mileage:     22000,
 			Status:      models.VehicleStatusAvailable,
 			Description: "Reliable Honda CR-V SUV perfect for families",
@@ -128,7 +138,7 @@ func main() {
 			Year:        2023,
 			Color:       "White",
 			VIN:         "3HGBH41JXMN109188",
-			Price:       65000.00,
+			Price:       money.MustParse("650000000", money.IDR),
 			Mileage:     8000,
 			Status:      models.VehicleStatusAvailable,
 			Description: "Luxury BMW X5 with premium features and performance",
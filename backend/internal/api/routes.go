@@ -4,7 +4,7 @@ import (
 	"vehicle-sales-backend/internal/config"
 	"vehicle-sales-backend/internal/handlers"
 	"vehicle-sales-backend/internal/middleware"
-	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/permissions"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -13,12 +13,24 @@ func SetupRoutes(app *fiber.App, config *config.Config) {
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(config)
 	vehicleHandler := handlers.NewVehicleHandler()
+	vehicleCostHandler := handlers.NewVehicleCostHandler()
+	inventoryHandler := handlers.NewInventoryHandler()
 	saleHandler := handlers.NewSaleHandler()
 	testDriveHandler := handlers.NewTestDriveHandler()
 	leadHandler := handlers.NewLeadHandler()
 	userHandler := handlers.NewUserHandler()
 	dashboardHandler := handlers.NewDashboardHandler()
 	transactionHandler := handlers.NewTransactionHandler()
+	invitationHandler := handlers.NewInvitationHandler(config)
+	roleHandler := handlers.NewRoleHandler()
+	paymentHandler := handlers.NewPaymentHandler(config)
+	shiftHandler := handlers.NewShiftHandler()
+	documentHandler := handlers.NewDocumentHandler()
+	taxHandler := handlers.NewTaxHandler()
+	quoteHandler := handlers.NewQuoteHandler()
+	financingHandler := handlers.NewFinancingHandler()
+	tradeInHandler := handlers.NewTradeInHandler()
+	commissionHandler := handlers.NewCommissionHandler()
 
 	// API group
 	api := app.Group("/api/v1")
@@ -32,79 +44,195 @@ func SetupRoutes(app *fiber.App, config *config.Config) {
 		})
 	})
 
+	// Replays responses to POSTs retried with the same Idempotency-Key
+	idempotent := middleware.Idempotency(config.Server.IdempotencyTTL)
+
 	// Public routes
 	api.Post("/auth/login", authHandler.Login)
 	api.Post("/auth/register", authHandler.Register)
+	api.Post("/auth/refresh", authHandler.Refresh)
+	api.Get("/auth/invitations/:token", invitationHandler.GetInvitation)
+	api.Post("/auth/invitations/accept", invitationHandler.AcceptInvitation)
 
 	// Public vehicle routes (for customers to browse)
 	api.Get("/vehicles", vehicleHandler.GetVehicles)
 	api.Get("/vehicles/:id", vehicleHandler.GetVehicle)
 
 	// Public lead creation (for website contact forms)
-	api.Post("/leads", leadHandler.CreateLead)
+	api.Post("/leads", idempotent, leadHandler.CreateLead)
+
+	// Quotes shared with customers (authenticated by the link's token)
+	api.Get("/quotes/shared/:token", quoteHandler.GetSharedQuote)
+	api.Get("/quotes/shared/:token/quote.pdf", quoteHandler.GetSharedQuotePDF)
+	api.Post("/quotes/shared/:token/accept", idempotent, quoteHandler.AcceptSharedQuote)
+	api.Post("/quotes/shared/:token/decline", idempotent, quoteHandler.DeclineSharedQuote)
+
+	// Payment provider webhooks (authenticated by signature)
+	api.Post("/payments/webhooks/:provider", paymentHandler.HandleWebhook)
 
 	// Protected routes
-	protected := api.Group("", middleware.AuthRequired(config))
+	protected := api.Group("", middleware.AuthRequired(config), idempotent)
+
+	protected.Post("/auth/logout", authHandler.Logout)
 
 	// User profile routes
 	protected.Get("/profile", userHandler.GetProfile)
 	protected.Put("/profile", userHandler.UpdateProfile)
-	protected.Put("/profile/password", userHandler.ChangePassword)
+	protected.Put("/profile/password", userHandler.ChangeProfilePassword)
 
 	// Dashboard routes (All authenticated users)
 	protected.Get("/dashboard", dashboardHandler.GetDashboard)
-	protected.Get("/analytics", dashboardHandler.GetAnalytics)
+	protected.Get("/analytics", middleware.PermissionRequired(permissions.DashboardAnalytics), dashboardHandler.GetAnalytics)
+	protected.Get("/analytics/margins", middleware.PermissionRequired(permissions.VehicleCost), dashboardHandler.GetMargins)
 
 	// Vehicle management routes
 	vehicles := protected.Group("/vehicles")
-	vehicles.Post("/", middleware.RoleRequired(models.RoleAdmin, models.RoleSales), vehicleHandler.CreateVehicle)
-	vehicles.Put("/:id", middleware.RoleRequired(models.RoleAdmin, models.RoleSales), vehicleHandler.UpdateVehicle)
-	vehicles.Delete("/:id", middleware.RoleRequired(models.RoleAdmin), vehicleHandler.DeleteVehicle)
+	vehicles.Post("/", middleware.PermissionRequired(permissions.VehicleCreate), vehicleHandler.CreateVehicle)
+	vehicles.Put("/:id", middleware.PermissionRequired(permissions.VehicleUpdate), vehicleHandler.UpdateVehicle)
+	vehicles.Delete("/:id", middleware.PermissionRequired(permissions.VehicleDelete), vehicleHandler.DeleteVehicle)
+	vehicles.Get("/:id/costs", middleware.PermissionRequired(permissions.VehicleCost), vehicleCostHandler.GetVehicleCosts)
+	vehicles.Put("/:id/costs", middleware.PermissionRequired(permissions.VehicleCost), vehicleCostHandler.SetVehicleAcquisition)
+	vehicles.Post("/:id/expenses", middleware.PermissionRequired(permissions.VehicleCost), vehicleCostHandler.AddVehicleExpense)
+	vehicles.Delete("/:id/expenses/:expenseId", middleware.PermissionRequired(permissions.VehicleCost), vehicleCostHandler.DeleteVehicleExpense)
+	vehicles.Get("/:id/price-history", middleware.PermissionRequired(permissions.InventoryAging), inventoryHandler.GetPriceHistory)
+
+	// Inventory aging and repricing routes
+	inventory := protected.Group("/inventory")
+	inventory.Get("/aging", middleware.PermissionRequired(permissions.InventoryAging), inventoryHandler.GetAging)
+	inventory.Get("/repricing-rules", middleware.PermissionRequired(permissions.InventoryReprice), inventoryHandler.GetRepricingRules)
+	inventory.Post("/repricing-rules", middleware.PermissionRequired(permissions.InventoryReprice), inventoryHandler.CreateRepricingRule)
+	inventory.Put("/repricing-rules/:id", middleware.PermissionRequired(permissions.InventoryReprice), inventoryHandler.UpdateRepricingRule)
+	inventory.Delete("/repricing-rules/:id", middleware.PermissionRequired(permissions.InventoryReprice), inventoryHandler.DeleteRepricingRule)
 
 	// Sales management routes
 	sales := protected.Group("/sales")
-	sales.Get("/", middleware.RoleRequired(models.RoleAdmin, models.RoleSales, models.RoleCashier), saleHandler.GetSales)
-	sales.Get("/:id", middleware.RoleRequired(models.RoleAdmin, models.RoleSales, models.RoleCashier), saleHandler.GetSale)
-	sales.Post("/", middleware.RoleRequired(models.RoleAdmin, models.RoleSales), saleHandler.CreateSale)
-	sales.Put("/:id", middleware.RoleRequired(models.RoleAdmin, models.RoleSales), saleHandler.UpdateSale)
-	sales.Delete("/:id", middleware.RoleRequired(models.RoleAdmin), saleHandler.DeleteSale)
-	sales.Get("/analytics", middleware.RoleRequired(models.RoleAdmin, models.RoleSales), saleHandler.GetSalesAnalytics)
-
-	// Transaction management routes (Cashier and Admin)
+	sales.Get("/analytics", middleware.PermissionRequired(permissions.SaleAnalytics), saleHandler.GetSalesAnalytics)
+	sales.Get("/", middleware.PermissionRequired(permissions.SaleRead), saleHandler.GetSales)
+	sales.Get("/:id", middleware.PermissionRequired(permissions.SaleRead), saleHandler.GetSale)
+	sales.Get("/:id/balance", middleware.PermissionRequired(permissions.SaleRead), saleHandler.GetSaleBalance)
+	sales.Get("/:id/invoice.pdf", middleware.PermissionRequired(permissions.SaleRead), documentHandler.GetSaleInvoice)
+	sales.Get("/:id/documents", middleware.PermissionRequired(permissions.SaleRead), documentHandler.GetSaleDocuments)
+	sales.Post("/", middleware.PermissionRequired(permissions.SaleCreate), saleHandler.CreateSale)
+	sales.Put("/:id", middleware.PermissionRequired(permissions.SaleUpdate), saleHandler.UpdateSale)
+	sales.Delete("/:id", middleware.PermissionRequired(permissions.SaleDelete), saleHandler.DeleteSale)
+	sales.Post("/:id/discount/approve", middleware.PermissionRequired(permissions.SaleApprove), saleHandler.ApproveSaleDiscount)
+	sales.Post("/:id/discount/reject", middleware.PermissionRequired(permissions.SaleApprove), saleHandler.RejectSaleDiscount)
+
+	// Quote routes
+	quotes := protected.Group("/quotes")
+	quotes.Get("/", middleware.PermissionRequired(permissions.QuoteRead), quoteHandler.GetQuotes)
+	quotes.Get("/:id", middleware.PermissionRequired(permissions.QuoteRead), quoteHandler.GetQuote)
+	quotes.Get("/:id/quote.pdf", middleware.PermissionRequired(permissions.QuoteRead), quoteHandler.GetQuotePDF)
+	quotes.Post("/", middleware.PermissionRequired(permissions.QuoteManage), quoteHandler.CreateQuote)
+	quotes.Post("/:id/revisions", middleware.PermissionRequired(permissions.QuoteManage), quoteHandler.ReviseQuote)
+	quotes.Post("/:id/send", middleware.PermissionRequired(permissions.QuoteManage), quoteHandler.SendQuote)
+	quotes.Post("/:id/accept", middleware.PermissionRequired(permissions.QuoteRead), quoteHandler.AcceptQuote)
+	quotes.Post("/:id/decline", middleware.PermissionRequired(permissions.QuoteRead), quoteHandler.DeclineQuote)
+	quotes.Post("/:id/convert", middleware.PermissionRequired(permissions.SaleCreate), quoteHandler.ConvertQuote)
+
+	// Transaction management routes
 	transactions := protected.Group("/transactions")
-	transactions.Get("/", middleware.RoleRequired(models.RoleAdmin, models.RoleCashier), transactionHandler.GetTransactions)
-	transactions.Get("/:id", middleware.RoleRequired(models.RoleAdmin, models.RoleCashier), transactionHandler.GetTransaction)
-	transactions.Post("/", middleware.RoleRequired(models.RoleAdmin, models.RoleCashier), transactionHandler.CreateTransaction)
-	transactions.Put("/:id", middleware.RoleRequired(models.RoleAdmin, models.RoleCashier), transactionHandler.UpdateTransaction)
-	transactions.Post("/:id/process", middleware.RoleRequired(models.RoleAdmin, models.RoleCashier), transactionHandler.ProcessPayment)
-	transactions.Post("/:id/refund", middleware.RoleRequired(models.RoleAdmin), transactionHandler.RefundTransaction)
-	transactions.Get("/analytics", middleware.RoleRequired(models.RoleAdmin, models.RoleCashier), transactionHandler.GetTransactionAnalytics)
+	transactions.Get("/analytics", middleware.PermissionRequired(permissions.TransactionAnalytics), transactionHandler.GetTransactionAnalytics)
+	transactions.Get("/", middleware.PermissionRequired(permissions.TransactionRead), transactionHandler.GetTransactions)
+	transactions.Get("/:id", middleware.PermissionRequired(permissions.TransactionRead), transactionHandler.GetTransaction)
+	transactions.Get("/:id/receipt.pdf", middleware.PermissionRequired(permissions.TransactionRead), documentHandler.GetTransactionReceipt)
+	transactions.Get("/:id/receipt.escpos", middleware.PermissionRequired(permissions.TransactionRead), documentHandler.GetTransactionReceiptESCPOS)
+	transactions.Post("/", middleware.PermissionRequired(permissions.TransactionCreate), transactionHandler.CreateTransaction)
+	transactions.Put("/:id", middleware.PermissionRequired(permissions.TransactionUpdate), transactionHandler.UpdateTransaction)
+	transactions.Post("/:id/process", middleware.PermissionRequired(permissions.TransactionProcess), transactionHandler.ProcessPayment)
+	transactions.Post("/:id/refund", middleware.PermissionRequired(permissions.TransactionRefund), transactionHandler.RefundTransaction)
+
+	// Trade-in routes
+	tradeIns := protected.Group("/trade-ins")
+	tradeIns.Get("/", middleware.PermissionRequired(permissions.TradeInRead), tradeInHandler.GetTradeIns)
+	tradeIns.Get("/:id", middleware.PermissionRequired(permissions.TradeInRead), tradeInHandler.GetTradeIn)
+	tradeIns.Post("/", middleware.PermissionRequired(permissions.TradeInManage), tradeInHandler.CreateTradeIn)
+	tradeIns.Put("/:id", middleware.PermissionRequired(permissions.TradeInManage), tradeInHandler.UpdateTradeIn)
+	tradeIns.Post("/:id/accept", middleware.PermissionRequired(permissions.TradeInManage), tradeInHandler.AcceptTradeIn)
+	tradeIns.Post("/:id/decline", middleware.PermissionRequired(permissions.TradeInManage), tradeInHandler.DeclineTradeIn)
+	tradeIns.Post("/:id/apply", middleware.PermissionRequired(permissions.TradeInManage), tradeInHandler.ApplyTradeIn)
+
+	// Financing routes
+	financing := protected.Group("/financing")
+	financing.Post("/schedule", middleware.PermissionRequired(permissions.FinancingRead), financingHandler.CalculateSchedule)
+	financing.Get("/lenders", middleware.PermissionRequired(permissions.FinancingRead), financingHandler.GetLenders)
+	financing.Post("/lenders", middleware.PermissionRequired(permissions.FinancingManage), financingHandler.CreateLender)
+	financing.Put("/lenders/:id", middleware.PermissionRequired(permissions.FinancingManage), financingHandler.UpdateLender)
+	financing.Get("/applications", middleware.PermissionRequired(permissions.FinancingRead), financingHandler.GetApplications)
+	financing.Get("/applications/:id", middleware.PermissionRequired(permissions.FinancingRead), financingHandler.GetApplication)
+	financing.Get("/applications/:id/offers/:offerId", middleware.PermissionRequired(permissions.FinancingRead), financingHandler.GetOffer)
+	financing.Post("/applications", middleware.PermissionRequired(permissions.FinancingManage), financingHandler.CreateApplication)
+	financing.Post("/applications/:id/offers", middleware.PermissionRequired(permissions.FinancingManage), financingHandler.AddOffer)
+	financing.Post("/applications/:id/accept", middleware.PermissionRequired(permissions.FinancingManage), financingHandler.AcceptOffer)
+	financing.Post("/applications/:id/reject", middleware.PermissionRequired(permissions.FinancingManage), financingHandler.RejectApplication)
+	financing.Post("/applications/:id/cancel", middleware.PermissionRequired(permissions.FinancingManage), financingHandler.CancelApplication)
+	financing.Post("/applications/:id/disburse", middleware.PermissionRequired(permissions.FinancingDisburse), financingHandler.RecordDisbursement)
+
+	// Commission routes
+	commissions := protected.Group("/commissions")
+	commissions.Get("/statements", middleware.PermissionRequired(permissions.CommissionRead), commissionHandler.GetCommissionStatements)
+	commissions.Get("/plans", middleware.PermissionRequired(permissions.CommissionManage), commissionHandler.GetCommissionPlans)
+	commissions.Post("/plans", middleware.PermissionRequired(permissions.CommissionManage), commissionHandler.CreateCommissionPlan)
+	commissions.Put("/plans/:id", middleware.PermissionRequired(permissions.CommissionManage), commissionHandler.UpdateCommissionPlan)
+	commissions.Delete("/plans/:id", middleware.PermissionRequired(permissions.CommissionManage), commissionHandler.DeleteCommissionPlan)
+
+	// Tax and fee routes
+	taxes := protected.Group("/taxes")
+	taxes.Post("/calculate", middleware.PermissionRequired(permissions.SaleCreate), taxHandler.CalculateTaxes)
+	taxes.Get("/summary", middleware.PermissionRequired(permissions.TaxReport), taxHandler.GetTaxSummary)
+	taxes.Get("/rules", middleware.PermissionRequired(permissions.TaxManage), taxHandler.GetTaxRules)
+	taxes.Post("/rules", middleware.PermissionRequired(permissions.TaxManage), taxHandler.CreateTaxRule)
+	taxes.Put("/rules/:id", middleware.PermissionRequired(permissions.TaxManage), taxHandler.UpdateTaxRule)
+	taxes.Delete("/rules/:id", middleware.PermissionRequired(permissions.TaxManage), taxHandler.DeleteTaxRule)
+
+	// Cashier shift routes
+	shifts := protected.Group("/shifts", middleware.PermissionRequired(permissions.ShiftOperate))
+	shifts.Get("/", shiftHandler.GetShifts)
+	shifts.Get("/current", shiftHandler.GetCurrentShift)
+	shifts.Post("/", shiftHandler.OpenShift)
+	shifts.Get("/:id/report", shiftHandler.GetShiftReport)
+	shifts.Post("/:id/close", shiftHandler.CloseShift)
+	shifts.Post("/:id/z-report", middleware.PermissionRequired(permissions.ShiftFinalize), shiftHandler.FinalizeShift)
 
 	// Test drive management routes
 	testDrives := protected.Group("/test-drives")
-	testDrives.Get("/", middleware.RoleRequired(models.RoleAdmin, models.RoleSales), testDriveHandler.GetTestDrives)
-	testDrives.Get("/:id", testDriveHandler.GetTestDrive)
-	testDrives.Post("/", testDriveHandler.CreateTestDrive) // All authenticated users can book test drives
-	testDrives.Put("/:id", middleware.RoleRequired(models.RoleAdmin, models.RoleSales), testDriveHandler.UpdateTestDrive)
-	testDrives.Delete("/:id", testDriveHandler.DeleteTestDrive) // Users can cancel their own bookings
-	testDrives.Get("/analytics", middleware.RoleRequired(models.RoleAdmin, models.RoleSales), testDriveHandler.GetTestDriveAnalytics)
+	testDrives.Get("/analytics", middleware.PermissionRequired(permissions.TestDriveAnalytics), testDriveHandler.GetTestDriveAnalytics)
+	testDrives.Get("/", middleware.PermissionRequired(permissions.TestDriveRead), testDriveHandler.GetTestDrives)
+	testDrives.Get("/:id", middleware.PermissionRequired(permissions.TestDriveRead), testDriveHandler.GetTestDrive)
+	testDrives.Post("/", middleware.PermissionRequired(permissions.TestDriveCreate), testDriveHandler.CreateTestDrive)
+	testDrives.Put("/:id", middleware.PermissionRequired(permissions.TestDriveUpdate), testDriveHandler.UpdateTestDrive)
+	testDrives.Delete("/:id", middleware.PermissionRequired(permissions.TestDriveCancel), testDriveHandler.DeleteTestDrive)
 
 	// Lead management routes
 	leads := protected.Group("/leads")
-	leads.Get("/", middleware.RoleRequired(models.RoleAdmin, models.RoleSales), leadHandler.GetLeads)
-	leads.Get("/:id", middleware.RoleRequired(models.RoleAdmin, models.RoleSales), leadHandler.GetLead)
-	leads.Put("/:id", middleware.RoleRequired(models.RoleAdmin, models.RoleSales), leadHandler.UpdateLead)
-	leads.Delete("/:id", middleware.RoleRequired(models.RoleAdmin), leadHandler.DeleteLead)
-	leads.Post("/:id/assign", middleware.RoleRequired(models.RoleAdmin, models.RoleSales), leadHandler.AssignLead)
-	leads.Get("/analytics", middleware.RoleRequired(models.RoleAdmin, models.RoleSales), leadHandler.GetLeadAnalytics)
-
-	// User management routes (Admin only)
-	users := protected.Group("/users", middleware.RoleRequired(models.RoleAdmin))
+	leads.Get("/analytics", middleware.PermissionRequired(permissions.LeadAnalytics), leadHandler.GetLeadAnalytics)
+	leads.Get("/", middleware.PermissionRequired(permissions.LeadRead), leadHandler.GetLeads)
+	leads.Get("/:id", middleware.PermissionRequired(permissions.LeadRead), leadHandler.GetLead)
+	leads.Put("/:id", middleware.PermissionRequired(permissions.LeadUpdate), leadHandler.UpdateLead)
+	leads.Delete("/:id", middleware.PermissionRequired(permissions.LeadDelete), leadHandler.DeleteLead)
+	leads.Post("/:id/assign", middleware.PermissionRequired(permissions.LeadAssign), leadHandler.AssignLead)
+
+	// User management routes
+	users := protected.Group("/users", middleware.PermissionRequired(permissions.UserManage))
+	users.Get("/analytics", userHandler.GetUserAnalytics)
+	users.Get("/invitations", invitationHandler.GetInvitations)
+	users.Post("/invitations", invitationHandler.CreateInvitation)
+	users.Delete("/invitations/:id", invitationHandler.RevokeInvitation)
 	users.Get("/", userHandler.GetUsers)
 	users.Get("/:id", userHandler.GetUser)
 	users.Post("/", userHandler.CreateUser)
 	users.Put("/:id", userHandler.UpdateUser)
 	users.Delete("/:id", userHandler.DeleteUser)
 	users.Put("/:id/password", userHandler.ChangePassword)
-	users.Get("/analytics", userHandler.GetUserAnalytics)
-}
\ No newline at end of file
+
+	// Role and permission management routes
+	protected.Get("/permissions", middleware.PermissionRequired(permissions.RoleManage), roleHandler.GetPermissions)
+	roles := protected.Group("/roles", middleware.PermissionRequired(permissions.RoleManage))
+	roles.Get("/", roleHandler.GetRoles)
+	roles.Get("/:name", roleHandler.GetRole)
+	roles.Post("/", roleHandler.CreateRole)
+	roles.Put("/:name", roleHandler.UpdateRole)
+	roles.Put("/:name/permissions", roleHandler.SetRolePermissions)
+	roles.Put("/:name/discount-limit", roleHandler.SetDiscountLimit)
+	roles.Delete("/:name", roleHandler.DeleteRole)
+}
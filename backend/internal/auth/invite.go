@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"vehicle-sales-backend/internal/models"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const inviteAudience = "invitation"
+
+// InviteClaims identify an Invitation row. The row, not the token, is the
+// source of truth for whether the invitation is still redeemable.
+type InviteClaims struct {
+	InvitationID uint            `json:"invitation_id"`
+	Email        string          `json:"email"`
+	Role         models.UserRole `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// GenerateInviteToken signs an invitation so it can be redeemed without
+// authentication until it expires.
+func GenerateInviteToken(inv *models.Invitation, secretKey string) (string, error) {
+	claims := InviteClaims{
+		InvitationID: inv.ID,
+		Email:        inv.Email,
+		Role:         inv.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatUint(uint64(inv.ID), 10),
+			Audience:  jwt.ClaimStrings{inviteAudience},
+			ExpiresAt: jwt.NewNumericDate(inv.ExpiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(secretKey))
+}
+
+func ValidateInviteToken(tokenString, secretKey string) (*InviteClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &InviteClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return []byte(secretKey), nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*InviteClaims)
+	if !ok || !token.Valid || !claims.VerifyAudience(inviteAudience, true) || claims.InvitationID == 0 {
+		return nil, errors.New("invalid invitation token")
+	}
+
+	return claims, nil
+}
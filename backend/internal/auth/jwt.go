@@ -7,13 +7,16 @@ import (
 	"vehicle-sales-backend/internal/models"
 
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type JWTClaims struct {
-	UserID uint             `json:"user_id"`
-	Email  string           `json:"email"`
-	Role   models.UserRole  `json:"role"`
+	UserID       uint            `json:"user_id"`
+	Email        string          `json:"email"`
+	Role         models.UserRole `json:"role"`
+	TokenVersion int             `json:"ver"`
+	FamilyID     string          `json:"fam"`
 	jwt.RegisteredClaims
 }
 
@@ -27,20 +30,29 @@ func CheckPasswordHash(password, hash string) bool {
 	return err == nil
 }
 
-func GenerateToken(user *models.User, secretKey string) (string, error) {
+// GenerateToken issues a short-lived access token bound to the given refresh
+// token family. The token carries a unique jti so it can be denylisted.
+func GenerateToken(user *models.User, familyID, secretKey string, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+
 	claims := JWTClaims{
-		UserID: user.ID,
-		Email:  user.Email,
-		Role:   user.Role,
+		UserID:       user.ID,
+		Email:        user.Email,
+		Role:         user.Role,
+		TokenVersion: user.TokenVersion,
+		FamilyID:     familyID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			ID:        uuid.New().String(),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secretKey))
+	signed, err := token.SignedString([]byte(secretKey))
+	return signed, expiresAt, err
 }
 
 func ValidateToken(tokenString, secretKey string) (*JWTClaims, error) {
@@ -55,9 +67,9 @@ func ValidateToken(tokenString, secretKey string) (*JWTClaims, error) {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
+	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid && claims.UserID != 0 {
 		return claims, nil
 	}
 
 	return nil, errors.New("invalid token")
-}
\ No newline at end of file
+}
@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"vehicle-sales-backend/internal/config"
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+	ErrTokenRevoked        = errors.New("token has been revoked")
+)
+
+type TokenPair struct {
+	AccessToken      string
+	AccessExpiresAt  time.Time
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+}
+
+// IssueTokenPair starts a new refresh token family for the user, typically
+// on login or registration.
+func IssueTokenPair(user *models.User, cfg config.JWTConfig) (*TokenPair, error) {
+	var pair *TokenPair
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		pair, _, err = issueTokenPair(tx, user, uuid.New().String(), cfg)
+		return err
+	})
+	return pair, err
+}
+
+// RotateRefreshToken exchanges a refresh token for a new token pair in the
+// same family. Presenting a token that was already rotated revokes the whole
+// family, since it means the token leaked.
+func RotateRefreshToken(refreshToken string, cfg config.JWTConfig) (*TokenPair, error) {
+	var pair *TokenPair
+	reused := false
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var current models.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", hashToken(refreshToken)).
+			First(&current).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+
+		if current.RevokedAt != nil {
+			reused = true
+			return revokeFamily(tx, current.FamilyID)
+		}
+
+		if time.Now().After(current.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		var user models.User
+		if err := tx.Where("id = ? AND is_active = ?", current.UserID, true).First(&user).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+
+		var next *models.RefreshToken
+		var err error
+		pair, next, err = issueTokenPair(tx, &user, current.FamilyID, cfg)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		current.RevokedAt = &now
+		current.ReplacedByID = &next.ID
+		return tx.Save(&current).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, ErrRefreshTokenReused
+	}
+	return pair, nil
+}
+
+// Logout denylists the access token described by claims and, when a refresh
+// token belonging to the same user is supplied, revokes its family.
+func Logout(claims *JWTClaims, refreshToken string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := denyAccessToken(tx, claims); err != nil {
+			return err
+		}
+
+		familyID := claims.FamilyID
+		if refreshToken != "" {
+			var token models.RefreshToken
+			if err := tx.Where("token_hash = ? AND user_id = ?", hashToken(refreshToken), claims.UserID).First(&token).Error; err != nil {
+				return ErrInvalidRefreshToken
+			}
+			familyID = token.FamilyID
+		}
+
+		if familyID == "" {
+			return nil
+		}
+		return revokeFamily(tx, familyID)
+	})
+}
+
+// RevokeUserTokens invalidates every access and refresh token issued to the
+// user. It must be called in the same transaction that deactivates a user,
+// changes their role or changes their password, so that the change and the
+// revocation are saved together.
+func RevokeUserTokens(tx *gorm.DB, userID uint) error {
+	if err := tx.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("token_version", gorm.Expr("token_version + 1")).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// PruneExpiredTokens deletes refresh tokens and denylisted access tokens that
+// have expired; an expired token is rejected regardless of its row.
+func PruneExpiredTokens(now time.Time) (int64, error) {
+	var pruned int64
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("expires_at < ?", now).Delete(&models.RefreshToken{})
+		if result.Error != nil {
+			return result.Error
+		}
+		pruned += result.RowsAffected
+
+		result = tx.Where("expires_at < ?", now).Delete(&models.RevokedToken{})
+		pruned += result.RowsAffected
+		return result.Error
+	})
+	return pruned, err
+}
+
+// CheckRevocation rejects access tokens that were denylisted, belong to a
+// revoked family, or predate a change to the user's account.
+func CheckRevocation(claims *JWTClaims) error {
+	var user models.User
+	if err := database.DB.Select("id", "is_active", "token_version").First(&user, claims.UserID).Error; err != nil {
+		return ErrTokenRevoked
+	}
+
+	if !user.IsActive || user.TokenVersion != claims.TokenVersion {
+		return ErrTokenRevoked
+	}
+
+	// a lookup that fails rejects the token rather than letting it through
+	var denied int64
+	if err := database.DB.Model(&models.RevokedToken{}).Where("jti = ?", claims.ID).Count(&denied).Error; err != nil {
+		return err
+	}
+	if denied > 0 {
+		return ErrTokenRevoked
+	}
+
+	if claims.FamilyID != "" {
+		var live int64
+		err := database.DB.Model(&models.RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", claims.FamilyID).
+			Count(&live).Error
+		if err != nil {
+			return err
+		}
+		if live == 0 {
+			return ErrTokenRevoked
+		}
+	}
+
+	return nil
+}
+
+func issueTokenPair(tx *gorm.DB, user *models.User, familyID string, cfg config.JWTConfig) (*TokenPair, *models.RefreshToken, error) {
+	raw, err := randomToken()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refresh := models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(raw),
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(cfg.RefreshTokenTTL),
+	}
+	if err := tx.Create(&refresh).Error; err != nil {
+		return nil, nil, err
+	}
+
+	access, accessExpiresAt, err := GenerateToken(user, familyID, cfg.Secret, cfg.AccessTokenTTL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:      access,
+		AccessExpiresAt:  accessExpiresAt,
+		RefreshToken:     raw,
+		RefreshExpiresAt: refresh.ExpiresAt,
+	}, &refresh, nil
+}
+
+func revokeFamily(tx *gorm.DB, familyID string) error {
+	return tx.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func denyAccessToken(tx *gorm.DB, claims *JWTClaims) error {
+	if claims.ID == "" {
+		return nil
+	}
+
+	expiresAt := time.Now()
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+
+	return tx.Where(models.RevokedToken{JTI: claims.ID}).
+		Attrs(models.RevokedToken{UserID: claims.UserID, ExpiresAt: expiresAt}).
+		FirstOrCreate(&models.RevokedToken{}).Error
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,91 @@
+// Package commission works out what a salesperson earns on a sale under a
+// commission plan. The services package picks the plan and records the
+// ledger; this package only does the arithmetic.
+package commission
+
+import (
+	"errors"
+	"strings"
+
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+)
+
+// ErrCurrencyMismatch is returned when a plan pays in another currency than
+// the sale was made in.
+var ErrCurrencyMismatch = errors.New("commission plan and sale are in different currencies")
+
+// Sale is what a plan needs to know about a completed sale. MonthUnit is
+// its place among the sales the salesperson completed that month, 1 for
+// the first.
+type Sale struct {
+	GrossProfit money.Money
+	Make        string
+	MonthUnit   int
+}
+
+// Earning itemises the commission on a sale. Tier is the MinUnits of the
+// tier applied, 0 when the plan's own amounts were.
+type Earning struct {
+	Tier        int
+	PerUnit     money.Money
+	ProfitShare money.Money
+	MakeBonus   money.Money
+	Total       money.Money
+}
+
+// Calculate works out the commission plan pays on sale: the per-unit amount,
+// the share of a positive gross profit and the bonus for the vehicle's make.
+// The highest tier whose MinUnits the sale's MonthUnit reaches replaces the
+// plan's per-unit amount and rate. Tiers apply from the sale that reaches
+// them on; earlier sales that month keep what they earned.
+func Calculate(plan models.CommissionPlan, sale Sale) (Earning, error) {
+	currency := sale.GrossProfit.Currency
+	if plan.PerUnit.Currency != currency {
+		return Earning{}, ErrCurrencyMismatch
+	}
+
+	earning := Earning{
+		PerUnit:     plan.PerUnit,
+		ProfitShare: money.Zero(currency),
+		MakeBonus:   money.Zero(currency),
+	}
+	rate := plan.GrossProfitRate
+	for _, tier := range plan.Tiers {
+		if tier.MinUnits > sale.MonthUnit || tier.MinUnits <= earning.Tier {
+			continue
+		}
+		if tier.PerUnit.Currency != currency {
+			return Earning{}, ErrCurrencyMismatch
+		}
+		earning.Tier = tier.MinUnits
+		earning.PerUnit = tier.PerUnit
+		rate = tier.GrossProfitRate
+	}
+	if sale.GrossProfit.IsPositive() {
+		earning.ProfitShare = money.New(money.Scale(sale.GrossProfit.Minor, rate, 10000), currency)
+	}
+	for _, bonus := range plan.MakeBonuses {
+		if !strings.EqualFold(strings.TrimSpace(bonus.Make), strings.TrimSpace(sale.Make)) {
+			continue
+		}
+		if bonus.Bonus.Currency != currency {
+			return Earning{}, ErrCurrencyMismatch
+		}
+		earning.MakeBonus = bonus.Bonus
+		break
+	}
+
+	earning.Total = money.New(earning.PerUnit.Minor+earning.ProfitShare.Minor+earning.MakeBonus.Minor, currency)
+	return earning, nil
+}
+
+// Share returns the part of amount that refunded is of price, rounded half
+// away from zero: the commission a partial refund takes back. All of amount
+// is returned when price is not positive or refunded covers it.
+func Share(amount, refunded, price money.Money) money.Money {
+	if !price.IsPositive() || refunded.Minor >= price.Minor {
+		return amount
+	}
+	return money.New(money.Scale(amount.Minor, refunded.Minor, price.Minor), amount.Currency)
+}
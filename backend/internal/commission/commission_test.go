@@ -0,0 +1,115 @@
+package commission
+
+import (
+	"errors"
+	"testing"
+
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+)
+
+func idr(minor int64) money.Money { return money.New(minor, money.IDR) }
+
+// plan pays 500,000 and 5% of gross profit a unit, more from the fifth and
+// tenth sale of the month, and a bonus on Toyotas. Its tiers are listed out
+// of order.
+var plan = models.CommissionPlan{
+	PerUnit:         idr(500_000),
+	GrossProfitRate: 500,
+	Tiers: []models.CommissionTier{
+		{MinUnits: 10, PerUnit: idr(1_500_000), GrossProfitRate: 1000},
+		{MinUnits: 5, PerUnit: idr(1_000_000), GrossProfitRate: 750},
+	},
+	MakeBonuses: []models.CommissionMakeBonus{
+		{Make: "Honda", Bonus: idr(100_000)},
+		{Make: "Toyota", Bonus: idr(250_000)},
+	},
+}
+
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		name string
+		sale Sale
+		// tier, per unit, profit share, make bonus, total
+		want [5]int64
+	}{
+		{"first sale", Sale{idr(20_000_000), "Suzuki", 1}, [5]int64{0, 500_000, 1_000_000, 0, 1_500_000}},
+		{"below the first tier", Sale{idr(20_000_000), "Suzuki", 4}, [5]int64{0, 500_000, 1_000_000, 0, 1_500_000}},
+		{"reaching the first tier", Sale{idr(20_000_000), "Suzuki", 5}, [5]int64{5, 1_000_000, 1_500_000, 0, 2_500_000}},
+		{"between tiers", Sale{idr(20_000_000), "Suzuki", 9}, [5]int64{5, 1_000_000, 1_500_000, 0, 2_500_000}},
+		{"reaching the top tier", Sale{idr(20_000_000), "Suzuki", 10}, [5]int64{10, 1_500_000, 2_000_000, 0, 3_500_000}},
+		{"past the top tier", Sale{idr(20_000_000), "Suzuki", 25}, [5]int64{10, 1_500_000, 2_000_000, 0, 3_500_000}},
+		{"make bonus", Sale{idr(20_000_000), "Toyota", 1}, [5]int64{0, 500_000, 1_000_000, 250_000, 1_750_000}},
+		{"make bonus ignores case and spaces", Sale{idr(20_000_000), " toyota ", 1}, [5]int64{0, 500_000, 1_000_000, 250_000, 1_750_000}},
+		{"make bonus on a tier", Sale{idr(20_000_000), "Honda", 10}, [5]int64{10, 1_500_000, 2_000_000, 100_000, 3_600_000}},
+		{"profit share rounded", Sale{idr(333), "Suzuki", 1}, [5]int64{0, 500_000, 17, 0, 500_017}},
+		{"no profit", Sale{idr(0), "Suzuki", 1}, [5]int64{0, 500_000, 0, 0, 500_000}},
+		{"a loss takes no share", Sale{idr(-5_000_000), "Toyota", 1}, [5]int64{0, 500_000, 0, 250_000, 750_000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			earning, err := Calculate(plan, tt.sale)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := [5]int64{int64(earning.Tier), earning.PerUnit.Minor, earning.ProfitShare.Minor, earning.MakeBonus.Minor, earning.Total.Minor}
+			if got != tt.want {
+				t.Errorf("earning = %v, want %v", got, tt.want)
+			}
+			if earning.Total.Currency != money.IDR {
+				t.Errorf("total in %s", earning.Total.Currency)
+			}
+		})
+	}
+}
+
+func TestCalculateCurrencyMismatch(t *testing.T) {
+	usdTier := plan
+	usdTier.Tiers = []models.CommissionTier{{MinUnits: 2, PerUnit: money.New(100, money.USD)}}
+	usdBonus := plan
+	usdBonus.MakeBonuses = []models.CommissionMakeBonus{{Make: "Toyota", Bonus: money.New(100, money.USD)}}
+
+	tests := []struct {
+		name string
+		plan models.CommissionPlan
+		sale Sale
+	}{
+		{"plan", plan, Sale{money.New(100, money.USD), "Toyota", 1}},
+		{"tier reached", usdTier, Sale{idr(100), "Toyota", 2}},
+		{"make bonus", usdBonus, Sale{idr(100), "Toyota", 1}},
+	}
+	for _, tt := range tests {
+		if _, err := Calculate(tt.plan, tt.sale); !errors.Is(err, ErrCurrencyMismatch) {
+			t.Errorf("%s: err = %v, want ErrCurrencyMismatch", tt.name, err)
+		}
+	}
+
+	// a tier in another currency that is not reached does not matter
+	if _, err := Calculate(usdTier, Sale{idr(100), "Toyota", 1}); err != nil {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestShare(t *testing.T) {
+	tests := []struct {
+		name                    string
+		amount, refunded, price int64
+		want                    int64
+	}{
+		{"half refunded", 1_000_000, 50_000_000, 100_000_000, 500_000},
+		{"nothing refunded", 1_000_000, 0, 100_000_000, 0},
+		{"all refunded", 1_000_000, 100_000_000, 100_000_000, 1_000_000},
+		{"more than paid refunded", 1_000_000, 150_000_000, 100_000_000, 1_000_000},
+		{"a third rounded down", 1_000_000, 1, 3, 333_333},
+		{"two thirds rounded up", 1_000_000, 2, 3, 666_667},
+		{"half a sen rounded away from zero", 1, 1, 2, 1},
+		{"a reversal", -1_000_000, 1, 3, -333_333},
+		{"no price", 1_000_000, 50, 0, 1_000_000},
+	}
+	for _, tt := range tests {
+		got := Share(idr(tt.amount), idr(tt.refunded), idr(tt.price))
+		if got != idr(tt.want) {
+			t.Errorf("%s: Share = %v, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -11,6 +13,9 @@ type Config struct {
 	Database DatabaseConfig
 	JWT      JWTConfig
 	Server   ServerConfig
+	Dealer   DealerConfig
+	Payments PaymentsConfig
+	Receipts ReceiptsConfig
 }
 
 type DatabaseConfig struct {
@@ -23,11 +28,53 @@ type DatabaseConfig struct {
 }
 
 type JWTConfig struct {
-	Secret string
+	Secret          string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	InviteTokenTTL  time.Duration
 }
 
 type ServerConfig struct {
 	Port string
+	// IdempotencyTTL is how long responses to requests sent with an
+	// Idempotency-Key are kept for replay
+	IdempotencyTTL time.Duration
+}
+
+type PaymentsConfig struct {
+	// WebhookSecret signs and verifies provider webhooks
+	WebhookSecret string
+	// Timeout bounds each call to a payment provider
+	Timeout time.Duration
+	// FakeMode configures the bundled fake provider: succeed, decline,
+	// timeout, async or async_decline
+	FakeMode  string
+	FakeDelay time.Duration
+	// WebhookURL is where the fake provider delivers its webhooks
+	WebhookURL string
+}
+
+type ReceiptsConfig struct {
+	// PaperWidth is the thermal roll width in millimeters, 58 or 80
+	PaperWidth int
+	// Template is the default thermal receipt layout: standard or compact
+	Template string
+	// LogoPath is an optional PNG printed at the top of thermal receipts
+	LogoPath string
+	Footer   string
+}
+
+type DealerConfig struct {
+	// Currency is the ISO 4217 code assumed for amounts sent without one
+	Currency string
+	// Letterhead of invoices, receipts and credit notes
+	Name    string
+	Address string
+	Phone   string
+	Email   string
+	TaxID   string
+	// Branch prefixes document numbers, which are sequential per branch
+	Branch string
 }
 
 func Load() (*Config, error) {
@@ -47,13 +94,42 @@ func Load() (*Config, error) {
 			Name:     getEnv("DB_NAME", "vehicle_sales"),
 		},
 		JWT: JWTConfig{
-			Secret: getEnv("JWT_SECRET", "your-secret-key-here"),
+			Secret:          getEnv("JWT_SECRET", "your-secret-key-here"),
+			AccessTokenTTL:  getDurationEnv("JWT_ACCESS_TTL", 15*time.Minute),
+			RefreshTokenTTL: getDurationEnv("JWT_REFRESH_TTL", 7*24*time.Hour),
+			InviteTokenTTL:  getDurationEnv("INVITE_TTL", 72*time.Hour),
 		},
 		Server: ServerConfig{
-			Port: getEnv("PORT", "8080"),
+			Port:           getEnv("PORT", "8080"),
+			IdempotencyTTL: getDurationEnv("IDEMPOTENCY_TTL", 24*time.Hour),
 		},
+		Dealer: DealerConfig{
+			Currency: getEnv("CURRENCY", "IDR"),
+			Name:     getEnv("DEALER_NAME", "Vehicle Sales Dealership"),
+			Address:  getEnv("DEALER_ADDRESS", ""),
+			Phone:    getEnv("DEALER_PHONE", ""),
+			Email:    getEnv("DEALER_EMAIL", ""),
+			TaxID:    getEnv("DEALER_TAX_ID", ""),
+			Branch:   getEnv("BRANCH_CODE", "HQ"),
+		},
+		Payments: PaymentsConfig{
+			WebhookSecret: getEnv("PAYMENT_WEBHOOK_SECRET", "your-webhook-secret-here"),
+			Timeout:       getDurationEnv("PAYMENT_TIMEOUT", 15*time.Second),
+			FakeMode:      getEnv("PAYMENT_FAKE_MODE", "succeed"),
+			FakeDelay:     getDurationEnv("PAYMENT_FAKE_DELAY", 5*time.Second),
+		},
+	}
+
+	config.Receipts = ReceiptsConfig{
+		PaperWidth: getIntEnv("RECEIPT_PAPER_WIDTH", 80),
+		Template:   getEnv("RECEIPT_TEMPLATE", "standard"),
+		LogoPath:   getEnv("RECEIPT_LOGO", ""),
+		Footer:     getEnv("RECEIPT_FOOTER", "Thank you for your purchase"),
 	}
 
+	config.Payments.WebhookURL = getEnv("PAYMENT_WEBHOOK_URL",
+		"http://localhost:"+config.Server.Port+"/api/v1/payments/webhooks/fake")
+
 	// Build database URL
 	config.Database.URL = fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
 		config.Database.User,
@@ -71,4 +147,22 @@ func getEnv(key, fallback string) string {
 		return value
 	}
 	return fallback
-}
\ No newline at end of file
+}
+
+func getIntEnv(key string, fallback int) int {
+	if value := os.Getenv(key); value != "" {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+	}
+	return fallback
+}
+
+func getDurationEnv(key string, fallback time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return fallback
+}
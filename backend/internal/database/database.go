@@ -5,7 +5,6 @@ import (
 	"log"
 
 	"vehicle-sales-backend/internal/config"
-	"vehicle-sales-backend/internal/models"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -29,25 +28,6 @@ func Connect(config *config.Config) error {
 	return nil
 }
 
-func Migrate() error {
-	err := DB.AutoMigrate(
-		&models.User{},
-		&models.Vehicle{},
-		&models.VehicleImage{},
-		&models.TestDrive{},
-		&models.Sale{},
-		&models.Transaction{},
-		&models.Lead{},
-	)
-	
-	if err != nil {
-		return fmt.Errorf("failed to migrate database: %w", err)
-	}
-
-	log.Println("Database migration completed")
-	return nil
-}
-
 func GetDB() *gorm.DB {
 	return DB
 }
\ No newline at end of file
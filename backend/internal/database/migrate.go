@@ -0,0 +1,356 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"vehicle-sales-backend/migrations"
+)
+
+// migrationLockID is the pg_advisory_lock key that serialises migration runs
+// across replicas. It is an arbitrary constant unique to this application.
+const migrationLockID int64 = 7_310_552_004_118
+
+// MigrationsDir is where `migrate create` writes new migration files,
+// relative to the backend module root.
+const MigrationsDir = "migrations"
+
+var migrationFile = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// ErrSchemaOutdated is returned by CheckSchema when migrations are pending.
+var ErrSchemaOutdated = errors.New("database schema is out of date")
+
+// Migration is a single versioned schema change.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// MigrationState describes a known migration and whether it has been applied.
+type MigrationState struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+	Modified  bool
+}
+
+// LoadMigrations reads the embedded migration files in version order.
+func LoadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrations.FS, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		body, err := fs.ReadFile(migrations.FS, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		sum := sha256.Sum256([]byte(m.Up))
+		m.Checksum = hex.EncodeToString(sum[:])
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// MigrateUp applies every pending migration, each in its own transaction.
+func MigrateUp() error {
+	return withMigrationLock(func(ctx context.Context, conn *sql.Conn) error {
+		all, err := LoadMigrations()
+		if err != nil {
+			return err
+		}
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		pending := 0
+		for _, m := range all {
+			if row, ok := applied[m.Version]; ok {
+				if row.checksum != m.Checksum {
+					log.Printf("Warning: migration %d_%s changed after it was applied", m.Version, m.Name)
+				}
+				continue
+			}
+			pending++
+
+			log.Printf("Applying migration %d_%s", m.Version, m.Name)
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx,
+					`INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES ($1, $2, $3, now())`,
+					m.Version, m.Name, m.Checksum)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", m.Version, m.Name, err)
+			}
+		}
+
+		if pending == 0 {
+			log.Println("Database schema is up to date")
+		} else {
+			log.Printf("Applied %d migration(s)", pending)
+		}
+		return nil
+	})
+}
+
+// MigrateDown rolls back the given number of most recently applied migrations.
+func MigrateDown(steps int) error {
+	return withMigrationLock(func(ctx context.Context, conn *sql.Conn) error {
+		all, err := LoadMigrations()
+		if err != nil {
+			return err
+		}
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(all) - 1; i >= 0 && steps > 0; i-- {
+			m := all[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if m.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", m.Version, m.Name)
+			}
+
+			log.Printf("Reverting migration %d_%s", m.Version, m.Name)
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("reverting %d_%s failed: %w", m.Version, m.Name, err)
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// MigrationStatus lists every known migration with its applied state.
+func MigrationStatus() ([]MigrationState, error) {
+	ctx := context.Background()
+	conn, err := rawConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := ensureMigrationsTable(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	all, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, 0, len(all))
+	for _, m := range all {
+		state := MigrationState{Version: m.Version, Name: m.Name}
+		if row, ok := applied[m.Version]; ok {
+			appliedAt := row.appliedAt
+			state.AppliedAt = &appliedAt
+			state.Modified = row.checksum != m.Checksum
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// CheckSchema returns ErrSchemaOutdated when embedded migrations have not
+// been applied to the database. The server refuses to start in that case.
+// Applied migrations whose file has changed since are logged as a warning:
+// the database may not match what the file now says.
+func CheckSchema() error {
+	states, err := MigrationStatus()
+	if err != nil {
+		return err
+	}
+
+	var pending, modified []string
+	for _, s := range states {
+		switch {
+		case s.AppliedAt == nil:
+			pending = append(pending, fmt.Sprintf("%d_%s", s.Version, s.Name))
+		case s.Modified:
+			modified = append(modified, fmt.Sprintf("%d_%s", s.Version, s.Name))
+		}
+	}
+	if len(modified) > 0 {
+		log.Printf("Warning: migrations %s changed after they were applied; their checksums no longer match schema_migrations", strings.Join(modified, ", "))
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: pending migrations %s (run `migrate up`)", ErrSchemaOutdated, strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// CreateMigration writes an empty up/down pair numbered after the newest
+// migration in dir and returns the paths of the new files.
+func CreateMigration(dir, name string) ([]string, error) {
+	name = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), "-", "_"))
+	if !regexp.MustCompile(`^[a-z0-9_]+$`).MatchString(name) {
+		return nil, fmt.Errorf("invalid migration name %q", name)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var next int64 = 1
+	for _, entry := range entries {
+		if match := migrationFile.FindStringSubmatch(entry.Name()); match != nil {
+			if v, _ := strconv.ParseInt(match[1], 10, 64); v >= next {
+				next = v + 1
+			}
+		}
+	}
+
+	base := fmt.Sprintf("%06d_%s", next, name)
+	paths := []string{
+		filepath.Join(dir, base+".up.sql"),
+		filepath.Join(dir, base+".down.sql"),
+	}
+	for _, path := range paths {
+		if err := os.WriteFile(path, []byte("-- "+filepath.Base(path)+"\n"), 0o644); err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
+type appliedMigration struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+// withMigrationLock runs fn on a dedicated connection holding the migration
+// advisory lock, so concurrently starting replicas apply migrations once.
+func withMigrationLock(fn func(ctx context.Context, conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := rawConn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	if err := ensureMigrationsTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(ctx, conn)
+}
+
+func rawConn(ctx context.Context) (*sql.Conn, error) {
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return nil, err
+	}
+	return sqlDB.Conn(ctx)
+}
+
+func ensureMigrationsTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    BIGINT PRIMARY KEY,
+		name       TEXT NOT NULL,
+		checksum   TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	return err
+}
+
+func appliedMigrations(ctx context.Context, conn *sql.Conn) (map[int64]appliedMigration, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]appliedMigration{}
+	for rows.Next() {
+		var version int64
+		var row appliedMigration
+		if err := rows.Scan(&version, &row.checksum, &row.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = row
+	}
+	return applied, rows.Err()
+}
+
+func inTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,182 @@
+package database
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestSchema connects to TEST_DATABASE_URL in a schema of its own,
+// dropped when the test ends. The migrations are PostgreSQL-only, so the
+// test is skipped without it.
+func openTestSchema(t *testing.T) *sql.DB {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	admin, err := gorm.Open(postgres.Open(url), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("migrate_test_%d", time.Now().UnixNano())
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	separator := "?"
+	if strings.Contains(url, "?") {
+		separator = "&"
+	}
+	db, err := gorm.Open(postgres.Open(url+separator+"search_path="+schema), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return sqlDB
+}
+
+// migrate applies the up migrations in (from, to].
+func migrate(t *testing.T, db *sql.DB, all []Migration, from, to int64) {
+	t.Helper()
+	for _, m := range all {
+		if m.Version <= from || m.Version > to {
+			continue
+		}
+		if _, err := db.Exec(m.Up); err != nil {
+			t.Fatalf("migration %d_%s: %v", m.Version, m.Name, err)
+		}
+	}
+}
+
+// TestLegacySalesAreItemisedLikeTaxPrice migrates sales made before sale
+// lines existed and checks that their lines add up to the price paid, with
+// the VAT of their date included in it, and that their net revenue is the
+// price before that VAT.
+func TestLegacySalesAreItemisedLikeTaxPrice(t *testing.T) {
+	db := openTestSchema(t)
+	all, err := LoadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrate(t, db, all, 0, 14)
+
+	var customerID, vehicleID int64
+	err = db.QueryRow(`INSERT INTO users (created_at, email, password, name, role)
+		VALUES (now(), 'legacy@example.com', 'x', 'Legacy', 'customer') RETURNING id`).Scan(&customerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		soldAt  string
+		price   int64
+		vat     int64
+		revenue int64
+	}{
+		// 11% since 1 April 2022: revenue is sale_price / 1.11
+		{"ppn 11%", "2023-06-01", 111_000_000_00, 11_000_000_00, 100_000_000_00},
+		{"ppn 11% rounded", "2023-06-01", 100_000_000_00, 9_909_909_91, 90_090_090_09},
+		// 10% before
+		{"ppn 10%", "2021-06-01", 110_000_000_00, 10_000_000_00, 100_000_000_00},
+	}
+	saleIDs := make([]int64, len(tests))
+	for i, tt := range tests {
+		err := db.QueryRow(`INSERT INTO vehicles (created_at, make, model, year, vin, price_minor, price_currency, status)
+			VALUES (now(), 'Toyota', 'Avanza', 2021, $1, $2, 'IDR', 'sold') RETURNING id`,
+			fmt.Sprintf("LEGACY%011d", i), tt.price).Scan(&vehicleID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = db.QueryRow(`INSERT INTO sales (created_at, vehicle_id, customer_id, sales_person_id,
+				sale_price_minor, sale_price_currency, status, completed_at)
+			VALUES ($1, $2, $3, $3, $4, 'IDR', 'completed', $1) RETURNING id`,
+			tt.soldAt, vehicleID, customerID, tt.price).Scan(&saleIDs[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	migrate(t, db, all, 14, all[len(all)-1].Version)
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var total, vat, revenue int64
+			err := db.QueryRow(`SELECT
+					COALESCE(SUM(amount_minor) FILTER (WHERE NOT inclusive), 0),
+					COALESCE(SUM(amount_minor) FILTER (WHERE type = 'tax'), 0),
+					(SELECT net_revenue_minor FROM sales WHERE id = $1)
+				FROM sale_lines WHERE sale_id = $1`, saleIDs[i]).Scan(&total, &vat, &revenue)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != tt.price {
+				t.Errorf("lines add up to %d, want the sale price %d", total, tt.price)
+			}
+			if vat != tt.vat {
+				t.Errorf("VAT = %d, want %d", vat, tt.vat)
+			}
+			if revenue != tt.revenue {
+				t.Errorf("net revenue = %d, want %d", revenue, tt.revenue)
+			}
+		})
+	}
+}
+
+func TestCheckSchemaWarnsAboutModifiedMigrations(t *testing.T) {
+	db := openTestSchema(t)
+	all, err := LoadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrate(t, db, all, 0, all[len(all)-1].Version)
+
+	saved := DB
+	t.Cleanup(func() { DB = saved })
+	DB, err = gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	// creates schema_migrations, recorded by hand below
+	if _, err := MigrationStatus(); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range all {
+		checksum := m.Checksum
+		if m.Version == 1 {
+			checksum = "edited"
+		}
+		if _, err := db.Exec(`INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`, m.Version, m.Name, checksum); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := CheckSchema(); err != nil {
+		t.Fatal(err)
+	}
+	first := fmt.Sprintf("%d_%s", all[0].Version, all[0].Name)
+	if !bytes.Contains(logged.Bytes(), []byte("Warning: migrations "+first+" changed")) {
+		t.Errorf("no warning about %s in %q", first, logged.String())
+	}
+	if bytes.Contains(logged.Bytes(), []byte(fmt.Sprintf("%d_%s", all[1].Version, all[1].Name))) {
+		t.Errorf("unchanged migration reported in %q", logged.String())
+	}
+}
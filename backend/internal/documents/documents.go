@@ -0,0 +1,123 @@
+// Package documents lays out the dealership's printed documents: invoices,
+// payment receipts and credit notes. The services package decides what goes
+// on a document and numbers it; this package only renders it.
+package documents
+
+import (
+	"image"
+	"strings"
+	"sync"
+	"time"
+
+	"vehicle-sales-backend/internal/money"
+)
+
+// Letterhead identifies the dealership at the top of every document.
+type Letterhead struct {
+	Name    string
+	Address string
+	Phone   string
+	Email   string
+	// TaxID is the dealership's tax registration number (NPWP)
+	TaxID string
+}
+
+// Config is the document setup of this installation.
+type Config struct {
+	Letterhead Letterhead
+	// Branch is part of every document number so that branches number their
+	// documents independently, e.g. INV-JKT1-000042
+	Branch string
+
+	// Thermal receipt defaults: paper width in millimeters, template name,
+	// an optional logo and a footer line
+	PaperWidth      int
+	ReceiptTemplate string
+	Logo            image.Image
+	ReceiptFooter   string
+}
+
+var config = struct {
+	sync.RWMutex
+	Config
+}{Config: Config{Branch: "HQ", PaperWidth: 80, ReceiptTemplate: "standard"}}
+
+// Configure sets the letterhead, branch and receipt defaults used for new
+// documents.
+func Configure(c Config) {
+	config.Lock()
+	defer config.Unlock()
+	c.Branch = strings.ToUpper(strings.TrimSpace(c.Branch))
+	config.Config = c
+}
+
+// Current returns the document configuration.
+func Current() Config {
+	config.RLock()
+	defer config.RUnlock()
+	return config.Config
+}
+
+// Document is the content of an invoice, receipt or credit note.
+type Document struct {
+	Title    string
+	Number   string
+	IssuedAt time.Time
+	// Details are shown next to the number, e.g. the sale reference
+	Details []Field
+	// Party is the customer the document is addressed to, one line each,
+	// under PartyLabel ("Bill to", "Received from")
+	PartyLabel string
+	Party      []string
+	Lines      []Line
+	Totals     []Total
+	Notes      []string
+	// Code is printed as a QR code on thermal receipts, e.g. the
+	// transaction reference
+	Code string
+	// Letterhead is the one in use when the document was issued, which its
+	// PDF is rendered under; nil on content kept before it was recorded
+	Letterhead *Letterhead `json:",omitempty"`
+}
+
+type Field struct {
+	Label string
+	Value string
+}
+
+type Line struct {
+	Description string
+	Quantity    int
+	UnitPrice   money.Money
+	Amount      money.Money
+}
+
+// Total is a row of the totals block; Emphasis marks the grand total.
+type Total struct {
+	Label    string
+	Amount   money.Money
+	Emphasis bool
+}
+
+// FormatMoney renders an amount with thousands separators, e.g.
+// "IDR 285,000,000.00".
+func FormatMoney(m money.Money) string {
+	decimal := m.Decimal()
+	sign := ""
+	if strings.HasPrefix(decimal, "-") {
+		sign, decimal = "-", decimal[1:]
+	}
+	whole, fraction, hasFraction := strings.Cut(decimal, ".")
+
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+	if hasFraction {
+		grouped.WriteString("." + fraction)
+	}
+	return string(m.Currency) + " " + sign + grouped.String()
+}
@@ -0,0 +1,86 @@
+package documents
+
+import (
+	"fmt"
+
+	"vehicle-sales-backend/internal/escpos"
+)
+
+// ReceiptTemplate selects which parts of a document a thermal receipt
+// prints.
+type ReceiptTemplate struct {
+	Logo       bool
+	Letterhead bool
+	Details    bool
+	Lines      bool
+	Notes      bool
+	QR         bool
+}
+
+// ReceiptTemplates are the thermal receipt layouts that can be requested by
+// name. Compact leaves out everything but the totals, for short rolls.
+var ReceiptTemplates = map[string]ReceiptTemplate{
+	"standard": {Logo: true, Letterhead: true, Details: true, Lines: true, Notes: true, QR: true},
+	"compact":  {Details: true, QR: true},
+}
+
+// RenderESCPOS lays out a document as an ESC/POS print job for paper using
+// the named template, ending with a cut.
+func RenderESCPOS(config Config, doc Document, paper escpos.Paper, template ReceiptTemplate) []byte {
+	p := escpos.New(paper)
+
+	p.Align(escpos.AlignCenter)
+	if template.Logo && config.Logo != nil {
+		p.Image(config.Logo, paper.Dots/2)
+	}
+	p.Bold(true).Line(config.Letterhead.Name).Bold(false)
+	if template.Letterhead {
+		for _, line := range letterheadLines(config.Letterhead) {
+			p.Line(line)
+		}
+	}
+	p.Feed(1)
+	p.DoubleSize(true).Line(doc.Title).DoubleSize(false)
+	p.Line(doc.Number)
+	p.Line(doc.IssuedAt.Format("02/01/2006 15:04"))
+
+	p.Align(escpos.AlignLeft).Rule('-')
+	if template.Details {
+		for _, field := range doc.Details {
+			p.Columns(field.Label, field.Value)
+		}
+		if len(doc.Party) > 0 {
+			p.Columns(doc.PartyLabel, doc.Party[0])
+		}
+		p.Rule('-')
+	}
+	if template.Lines {
+		for _, line := range doc.Lines {
+			p.Line(line.Description)
+			p.Columns(fmt.Sprintf("  %d x %s", line.Quantity, FormatMoney(line.UnitPrice)), FormatMoney(line.Amount))
+		}
+		p.Rule('-')
+	}
+	for _, total := range doc.Totals {
+		p.Bold(total.Emphasis).Columns(total.Label, FormatMoney(total.Amount))
+	}
+	p.Bold(false).Rule('-')
+
+	p.Align(escpos.AlignCenter)
+	if template.Notes {
+		for _, note := range doc.Notes {
+			p.Line(note)
+		}
+	}
+	if template.QR && doc.Code != "" {
+		size := byte(6)
+		if paper.Columns < escpos.Paper80.Columns {
+			size = 4
+		}
+		p.QR(doc.Code, size).Line(doc.Code)
+	}
+	if config.ReceiptFooter != "" {
+		p.Line(config.ReceiptFooter)
+	}
+	return p.Feed(3).Cut().Bytes()
+}
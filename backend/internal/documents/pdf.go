@@ -0,0 +1,144 @@
+package documents
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+)
+
+const (
+	pageMargin = 15.0
+	lineHeight = 5.0
+)
+
+// RenderPDF lays out a document on A4 pages under the letterhead.
+func RenderPDF(letterhead Letterhead, doc Document) ([]byte, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	// the core fonts are cp1252 encoded
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetTitle(doc.Title+" "+doc.Number, true)
+	pdf.SetCreator(letterhead.Name, true)
+	pdf.SetCreationDate(doc.IssuedAt)
+	pdf.SetMargins(pageMargin, pageMargin, pageMargin)
+	pdf.SetAutoPageBreak(true, pageMargin+5)
+	pdf.AliasNbPages("")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-pageMargin)
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.SetTextColor(120, 120, 120)
+		pdf.CellFormat(0, lineHeight, tr(fmt.Sprintf("%s %s - page %d of {nb}", doc.Title, doc.Number, pdf.PageNo())), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+
+	width, _ := pdf.GetPageSize()
+	content := width - 2*pageMargin
+
+	// letterhead
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(content, 8, tr(letterhead.Name), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.SetTextColor(80, 80, 80)
+	for _, line := range letterheadLines(letterhead) {
+		pdf.CellFormat(content, 4.5, tr(line), "", 1, "L", false, 0, "")
+	}
+	pdf.SetTextColor(0, 0, 0)
+	pdf.Ln(2)
+	pdf.Line(pageMargin, pdf.GetY(), width-pageMargin, pdf.GetY())
+	pdf.Ln(4)
+
+	// title, number and details
+	top := pdf.GetY()
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.CellFormat(content/2, 7, tr(doc.Title), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(content/2, lineHeight, tr("No. "+doc.Number), "", 1, "L", false, 0, "")
+	pdf.CellFormat(content/2, lineHeight, tr("Date: "+doc.IssuedAt.Format("2 January 2006 15:04")), "", 1, "L", false, 0, "")
+	for _, field := range doc.Details {
+		pdf.CellFormat(content/2, lineHeight, tr(field.Label+": "+field.Value), "", 1, "L", false, 0, "")
+	}
+	left := pdf.GetY()
+
+	// customer block on the right
+	pdf.SetY(top)
+	if len(doc.Party) > 0 {
+		pdf.SetX(pageMargin + content/2)
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(content/2, lineHeight, tr(doc.PartyLabel), "", 1, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		for _, line := range doc.Party {
+			pdf.SetX(pageMargin + content/2)
+			pdf.CellFormat(content/2, lineHeight, tr(line), "", 1, "L", false, 0, "")
+		}
+	}
+	if pdf.GetY() < left {
+		pdf.SetY(left)
+	}
+	pdf.Ln(6)
+
+	// line items
+	cols := []float64{content * 0.52, content * 0.08, content * 0.2, content * 0.2}
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.SetFillColor(235, 235, 235)
+	for i, heading := range []string{"Description", "Qty", "Unit price", "Amount"} {
+		align := "R"
+		if i == 0 {
+			align = "L"
+		}
+		pdf.CellFormat(cols[i], 7, heading, "B", 0, align, true, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont("Helvetica", "", 10)
+	for _, line := range doc.Lines {
+		pdf.CellFormat(cols[0], 7, tr(line.Description), "", 0, "L", false, 0, "")
+		pdf.CellFormat(cols[1], 7, fmt.Sprint(line.Quantity), "", 0, "R", false, 0, "")
+		pdf.CellFormat(cols[2], 7, FormatMoney(line.UnitPrice), "", 0, "R", false, 0, "")
+		pdf.CellFormat(cols[3], 7, FormatMoney(line.Amount), "", 1, "R", false, 0, "")
+	}
+	pdf.Line(pageMargin, pdf.GetY(), width-pageMargin, pdf.GetY())
+	pdf.Ln(2)
+
+	// totals
+	for _, total := range doc.Totals {
+		style := ""
+		if total.Emphasis {
+			style = "B"
+		}
+		pdf.SetFont("Helvetica", style, 10)
+		pdf.CellFormat(cols[0]+cols[1]+cols[2], 6, tr(total.Label), "", 0, "R", false, 0, "")
+		pdf.CellFormat(cols[3], 6, FormatMoney(total.Amount), "", 1, "R", false, 0, "")
+	}
+
+	if len(doc.Notes) > 0 {
+		pdf.Ln(6)
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.MultiCell(content, 4.5, tr(strings.Join(doc.Notes, "\n")), "", "L", false)
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func letterheadLines(l Letterhead) []string {
+	lines := []string{}
+	if l.Address != "" {
+		lines = append(lines, l.Address)
+	}
+	contact := []string{}
+	for _, value := range []string{l.Phone, l.Email} {
+		if value != "" {
+			contact = append(contact, value)
+		}
+	}
+	if len(contact) > 0 {
+		lines = append(lines, strings.Join(contact, " | "))
+	}
+	if l.TaxID != "" {
+		lines = append(lines, "NPWP: "+l.TaxID)
+	}
+	return lines
+}
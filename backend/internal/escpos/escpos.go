@@ -0,0 +1,208 @@
+// Package escpos builds raw ESC/POS command streams for thermal receipt
+// printers. The output is sent to the printer unchanged, e.g. by the
+// cashier app over Bluetooth or USB.
+package escpos
+
+import (
+	"bytes"
+	"image"
+	"strings"
+)
+
+const (
+	esc = 0x1b
+	gs  = 0x1d
+)
+
+// Paper describes a roll width: characters per line in the default font
+// and printable dots per line.
+type Paper struct {
+	Millimeters int
+	Columns     int
+	Dots        int
+}
+
+var (
+	Paper58 = Paper{Millimeters: 58, Columns: 32, Dots: 384}
+	Paper80 = Paper{Millimeters: 80, Columns: 48, Dots: 576}
+)
+
+// PaperFor returns the paper of a roll width in millimeters.
+func PaperFor(millimeters int) (Paper, bool) {
+	switch millimeters {
+	case 58:
+		return Paper58, true
+	case 80:
+		return Paper80, true
+	}
+	return Paper{}, false
+}
+
+type Alignment byte
+
+const (
+	AlignLeft   Alignment = 0
+	AlignCenter Alignment = 1
+	AlignRight  Alignment = 2
+)
+
+// Builder accumulates commands for one print job.
+type Builder struct {
+	paper Paper
+	buf   bytes.Buffer
+}
+
+// New starts a print job, resetting the printer to its defaults.
+func New(paper Paper) *Builder {
+	b := &Builder{paper: paper}
+	b.buf.Write([]byte{esc, '@'})
+	return b
+}
+
+func (b *Builder) Paper() Paper { return b.paper }
+
+func (b *Builder) Align(a Alignment) *Builder {
+	b.buf.Write([]byte{esc, 'a', byte(a)})
+	return b
+}
+
+func (b *Builder) Bold(on bool) *Builder {
+	b.buf.Write([]byte{esc, 'E', flag(on)})
+	return b
+}
+
+// DoubleSize doubles the width and height of following text, halving the
+// characters per line.
+func (b *Builder) DoubleSize(on bool) *Builder {
+	size := byte(0x00)
+	if on {
+		size = 0x11
+	}
+	b.buf.Write([]byte{gs, '!', size})
+	return b
+}
+
+// Line prints text followed by a line feed. Characters the printer's
+// default code page cannot print are replaced with '?'.
+func (b *Builder) Line(text string) *Builder {
+	b.buf.WriteString(ascii(text))
+	b.buf.WriteByte('\n')
+	return b
+}
+
+// Columns prints left and right on one line, shortening left so that right
+// always fits.
+func (b *Builder) Columns(left, right string) *Builder {
+	left, right = ascii(left), ascii(right)
+	if len(right) > b.paper.Columns {
+		right = right[:b.paper.Columns]
+	}
+	space := b.paper.Columns - len(right) - 1
+	if space < 0 {
+		space = 0
+	}
+	if len(left) > space {
+		left = left[:space]
+	}
+	padding := b.paper.Columns - len(left) - len(right)
+	if padding < 0 {
+		padding = 0
+	}
+	return b.Line(left + strings.Repeat(" ", padding) + right)
+}
+
+// Rule prints a full-width line of ch.
+func (b *Builder) Rule(ch byte) *Builder {
+	return b.Line(strings.Repeat(string(ch), b.paper.Columns))
+}
+
+func (b *Builder) Feed(lines int) *Builder {
+	b.buf.Write([]byte{esc, 'd', byte(lines)})
+	return b
+}
+
+// QR prints data as a QR code with the given module size in dots (1-16) and
+// medium error correction.
+func (b *Builder) QR(data string, moduleSize byte) *Builder {
+	n := len(data) + 3
+	b.buf.Write([]byte{gs, '(', 'k', 4, 0, '1', 'A', '2', 0})               // model 2
+	b.buf.Write([]byte{gs, '(', 'k', 3, 0, '1', 'C', moduleSize})           // module size
+	b.buf.Write([]byte{gs, '(', 'k', 3, 0, '1', 'E', '1'})                  // error correction M
+	b.buf.Write([]byte{gs, '(', 'k', byte(n), byte(n >> 8), '1', 'P', '0'}) // store
+	b.buf.WriteString(data)
+	b.buf.Write([]byte{gs, '(', 'k', 3, 0, '1', 'Q', '0'}) // print
+	b.buf.WriteByte('\n')
+	return b
+}
+
+// Image prints img as a raster bitmap, scaled down to at most maxDots wide
+// and converted to black and white.
+func (b *Builder) Image(img image.Image, maxDots int) *Builder {
+	if maxDots <= 0 || maxDots > b.paper.Dots {
+		maxDots = b.paper.Dots
+	}
+	bits, width, height := raster(img, maxDots)
+	if height == 0 {
+		return b
+	}
+	rowBytes := (width + 7) / 8
+	b.buf.Write([]byte{gs, 'v', '0', 0, byte(rowBytes), byte(rowBytes >> 8), byte(height), byte(height >> 8)})
+	b.buf.Write(bits)
+	b.buf.WriteByte('\n')
+	return b
+}
+
+// Cut feeds the paper past the cutter and makes a partial cut.
+func (b *Builder) Cut() *Builder {
+	b.buf.Write([]byte{gs, 'V', 'B', 3})
+	return b
+}
+
+func (b *Builder) Bytes() []byte {
+	return b.buf.Bytes()
+}
+
+// raster packs img into rows of 1-bit pixels, most significant bit first,
+// with dark and opaque pixels set.
+func raster(img image.Image, maxDots int) ([]byte, int, int) {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if srcW == 0 || srcH == 0 {
+		return nil, 0, 0
+	}
+	width, height := srcW, srcH
+	if width > maxDots {
+		width = maxDots
+		height = srcH * maxDots / srcW
+	}
+
+	rowBytes := (width + 7) / 8
+	bits := make([]byte, rowBytes*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			// nearest neighbour is good enough for a logo
+			r, g, bl, a := img.At(bounds.Min.X+x*srcW/width, bounds.Min.Y+y*srcH/height).RGBA()
+			luma := (299*r + 587*g + 114*bl) / 1000
+			if a > 0x8000 && luma < 0x8000 {
+				bits[y*rowBytes+x/8] |= 0x80 >> (x % 8)
+			}
+		}
+	}
+	return bits, width, height
+}
+
+func ascii(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || (r >= 0x20 && r < 0x7f) {
+			return r
+		}
+		return '?'
+	}, s)
+}
+
+func flag(on bool) byte {
+	if on {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,180 @@
+package escpos
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// reset is what every job starts with.
+var reset = []byte{esc, '@'}
+
+// narrow is a 10 character roll, so lines are easy to read.
+var narrow = Paper{Columns: 10, Dots: 16}
+
+func job(commands ...[]byte) []byte {
+	return append(append([]byte{}, reset...), bytes.Join(commands, nil)...)
+}
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(*Builder)
+		want  []byte
+	}{
+		{"reset", func(*Builder) {}, job()},
+		{"align", func(b *Builder) { b.Align(AlignCenter) }, job([]byte{esc, 'a', 1})},
+		{"bold", func(b *Builder) { b.Bold(true).Bold(false) }, job([]byte{esc, 'E', 1, esc, 'E', 0})},
+		{"double size", func(b *Builder) { b.DoubleSize(true).DoubleSize(false) }, job([]byte{gs, '!', 0x11, gs, '!', 0})},
+		{"feed", func(b *Builder) { b.Feed(3) }, job([]byte{esc, 'd', 3})},
+		{"cut", func(b *Builder) { b.Cut() }, job([]byte{gs, 'V', 'B', 3})},
+		{"line", func(b *Builder) { b.Line("Total") }, job([]byte("Total\n"))},
+		{"line outside ascii", func(b *Builder) { b.Line("Café\t€5") }, job([]byte("Caf???5\n"))},
+		{"rule", func(b *Builder) { b.Rule('-') }, job([]byte("----------\n"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New(narrow)
+			tt.build(b)
+			if got := b.Bytes(); !bytes.Equal(got, tt.want) {
+				t.Errorf("bytes = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColumns(t *testing.T) {
+	tests := []struct {
+		name        string
+		left, right string
+		want        string
+	}{
+		{"padded", "Tax", "1.100", "Tax  1.100"},
+		{"one space kept", "Taxes", "1.100", "Taxe 1.100"},
+		// the right column always fits, with a space before it
+		{"left shortened", "Registration", "500", "Regist 500"},
+		{"empty left", "", "500", "       500"},
+		{"empty right", "Tax", "", "Tax       "},
+		{"right fills the line", "Tax", "1234567890", "1234567890"},
+		{"right too long", "Tax", "12345678901", "1234567890"},
+		{"outside ascii", "Pajak ₨", "1.100", "Paja 1.100"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := New(narrow).Columns(tt.left, tt.right).Bytes()
+			if want := job([]byte(tt.want + "\n")); !bytes.Equal(got, want) {
+				t.Errorf("bytes = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestQR(t *testing.T) {
+	got := New(narrow).QR("INV-1", 6).Bytes()
+	want := job(
+		[]byte{gs, '(', 'k', 4, 0, '1', 'A', '2', 0},
+		[]byte{gs, '(', 'k', 3, 0, '1', 'C', 6},
+		[]byte{gs, '(', 'k', 3, 0, '1', 'E', '1'},
+		[]byte{gs, '(', 'k', 8, 0, '1', 'P', '0'},
+		[]byte("INV-1"),
+		[]byte{gs, '(', 'k', 3, 0, '1', 'Q', '0', '\n'},
+	)
+	if !bytes.Equal(got, want) {
+		t.Errorf("bytes = %q, want %q", got, want)
+	}
+
+	// the stored length is little-endian: 300 bytes of data and 3 of command
+	long := New(narrow).QR(string(bytes.Repeat([]byte{'x'}, 300)), 6).Bytes()
+	store := []byte{gs, '(', 'k', 0x2f, 0x01, '1', 'P', '0'}
+	if !bytes.Contains(long, store) {
+		t.Errorf("no store command %q for 300 bytes", store)
+	}
+}
+
+// checker is a w by h image, black where x+y is even.
+func checker(w, h int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if (x+y)%2 == 0 {
+				img.Set(x, y, color.Black)
+			} else {
+				img.Set(x, y, color.White)
+			}
+		}
+	}
+	return img
+}
+
+func TestImage(t *testing.T) {
+	// 10 dots wide takes 2 bytes a row
+	got := New(narrow).Image(checker(10, 2), 0).Bytes()
+	want := job(
+		[]byte{gs, 'v', '0', 0, 2, 0, 2, 0},
+		[]byte{0b10101010, 0b10000000, 0b01010101, 0b01000000},
+		[]byte{'\n'},
+	)
+	if !bytes.Equal(got, want) {
+		t.Errorf("bytes = %08b, want %08b", got, want)
+	}
+
+	// transparent and light pixels are left blank
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 1))
+	img.Set(0, 0, color.NRGBA{A: 0})
+	img.Set(1, 0, color.NRGBA{R: 200, G: 200, B: 200, A: 255})
+	img.Set(2, 0, color.NRGBA{A: 255})
+	got = New(narrow).Image(img, 0).Bytes()
+	want = job([]byte{gs, 'v', '0', 0, 1, 0, 1, 0, 0b00100000, '\n'})
+	if !bytes.Equal(got, want) {
+		t.Errorf("bytes = %08b, want %08b", got, want)
+	}
+}
+
+func TestImageScaling(t *testing.T) {
+	tests := []struct {
+		name          string
+		img           image.Image
+		maxDots       int
+		width, height int
+	}{
+		{"fits", checker(16, 4), 0, 16, 4},
+		{"wider than the paper", checker(32, 8), 0, 16, 4},
+		{"wider than asked", checker(16, 8), 8, 8, 4},
+		{"asked for more than the paper", checker(32, 8), 64, 16, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := New(narrow).Image(tt.img, tt.maxDots).Bytes()[len(reset):]
+			rowBytes := (tt.width + 7) / 8
+			header := []byte{gs, 'v', '0', 0, byte(rowBytes), 0, byte(tt.height), 0}
+			if !bytes.HasPrefix(got, header) || len(got) != len(header)+rowBytes*tt.height+1 {
+				t.Errorf("bytes = %v, want header %v and %d bytes of bits", got, header, rowBytes*tt.height)
+			}
+		})
+	}
+
+	// an image scaled to nothing prints nothing
+	if got := New(narrow).Image(checker(100, 1), 0).Bytes(); !bytes.Equal(got, reset) {
+		t.Errorf("bytes = %v, want only the reset", got)
+	}
+	if got := New(narrow).Image(image.NewNRGBA(image.Rect(0, 0, 0, 0)), 0).Bytes(); !bytes.Equal(got, reset) {
+		t.Errorf("bytes = %v, want only the reset", got)
+	}
+}
+
+func TestPaperFor(t *testing.T) {
+	for _, tt := range []struct {
+		mm   int
+		want Paper
+		ok   bool
+	}{
+		{58, Paper58, true},
+		{80, Paper80, true},
+		{76, Paper{}, false},
+	} {
+		if got, ok := PaperFor(tt.mm); got != tt.want || ok != tt.ok {
+			t.Errorf("PaperFor(%d) = %v, %t", tt.mm, got, ok)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Package financing works out the repayment schedule of a vehicle loan. The
+// services package records applications and lender offers; this package only
+// does the arithmetic.
+package financing
+
+import (
+	"errors"
+	"math/big"
+
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+)
+
+// ErrInvalidTerms is returned for a loan without a positive principal and
+// tenor, with a negative rate or with an unknown amortization method.
+var ErrInvalidTerms = errors.New("loan needs a positive principal and tenor, a rate of zero or more and a known method")
+
+// Terms describe a loan. Rate is the annual interest rate in basis points:
+// for the flat method it is charged on the original principal for the
+// whole tenor, for the annuity method on the outstanding balance each month.
+type Terms struct {
+	Principal money.Money
+	Rate      int64
+	Months    int
+	Method    models.AmortizationMethod
+}
+
+// Installment is one monthly repayment. Balance is the principal still owed
+// after it is paid.
+type Installment struct {
+	Number    int         `json:"number"`
+	Payment   money.Money `json:"payment"`
+	Principal money.Money `json:"principal"`
+	Interest  money.Money `json:"interest"`
+	Balance   money.Money `json:"balance"`
+}
+
+// Schedule is a loan's repayments. Installment is the regular monthly
+// payment; the last one may differ from it by the rounding.
+type Schedule struct {
+	Installment   money.Money   `json:"installment"`
+	TotalInterest money.Money   `json:"total_interest"`
+	TotalPayment  money.Money   `json:"total_payment"`
+	Installments  []Installment `json:"installments"`
+}
+
+// Amortize splits a loan into equal monthly installments.
+//
+// Flat (bunga flat): the interest for the whole tenor is worked out up
+// front on the original principal, and principal and interest are each
+// repaid in equal parts.
+//
+// Annuity (bunga efektif): every installment is the same, and each month's
+// interest is charged on the balance still owed, so later installments
+// repay more principal.
+func Amortize(terms Terms) (Schedule, error) {
+	if !terms.Principal.IsPositive() || terms.Months <= 0 || terms.Rate < 0 {
+		return Schedule{}, ErrInvalidTerms
+	}
+	switch terms.Method {
+	case models.AmortizationFlat:
+		return flat(terms), nil
+	case models.AmortizationAnnuity:
+		return annuity(terms), nil
+	}
+	return Schedule{}, ErrInvalidTerms
+}
+
+func flat(terms Terms) Schedule {
+	n := int64(terms.Months)
+	principal := terms.Principal.Minor
+	interest := money.Scale(principal, terms.Rate*n, 12*10000)
+
+	installments := make([]Installment, 0, terms.Months)
+	balance := principal
+	for i := int64(1); i <= n; i++ {
+		// the last installment takes what the equal parts leave over
+		repaid, charged := principal/n, interest/n
+		if i == n {
+			repaid, charged = balance, interest-charged*(n-1)
+		}
+		balance -= repaid
+		installments = append(installments, installment(int(i), repaid, charged, balance, terms.Principal.Currency))
+	}
+	return summarise(installments, terms.Principal.Currency)
+}
+
+func annuity(terms Terms) Schedule {
+	n := terms.Months
+	principal := terms.Principal.Minor
+	payment := annuityPayment(principal, terms.Rate, n)
+
+	installments := make([]Installment, 0, n)
+	balance := principal
+	for i := 1; i <= n; i++ {
+		charged := money.Scale(balance, terms.Rate, 12*10000)
+		repaid := payment - charged
+		if i == n || repaid > balance {
+			repaid = balance
+		}
+		balance -= repaid
+		installments = append(installments, installment(i, repaid, charged, balance, terms.Principal.Currency))
+	}
+	return summarise(installments, terms.Principal.Currency)
+}
+
+// annuityPayment returns P·r / (1 - (1+r)^-n) for the monthly rate r,
+// rounded half up, or P/n when there is no interest.
+func annuityPayment(principal, rate int64, months int) int64 {
+	if rate == 0 {
+		return money.Divide(principal, int64(months))
+	}
+	r := big.NewRat(rate, 12*10000)
+	growth := new(big.Rat).Add(big.NewRat(1, 1), r)
+	compound := big.NewRat(1, 1)
+	for i := 0; i < months; i++ {
+		compound.Mul(compound, growth)
+	}
+	// P·r·(1+r)^n / ((1+r)^n - 1)
+	payment := new(big.Rat).Mul(big.NewRat(principal, 1), r)
+	payment.Mul(payment, compound)
+	payment.Quo(payment, new(big.Rat).Sub(compound, big.NewRat(1, 1)))
+
+	rounded := new(big.Int).Mul(payment.Num(), big.NewInt(2))
+	rounded.Add(rounded, payment.Denom())
+	rounded.Quo(rounded, new(big.Int).Mul(payment.Denom(), big.NewInt(2)))
+	return rounded.Int64()
+}
+
+func installment(number int, principal, interest, balance int64, currency money.Currency) Installment {
+	return Installment{
+		Number:    number,
+		Payment:   money.New(principal+interest, currency),
+		Principal: money.New(principal, currency),
+		Interest:  money.New(interest, currency),
+		Balance:   money.New(balance, currency),
+	}
+}
+
+func summarise(installments []Installment, currency money.Currency) Schedule {
+	schedule := Schedule{
+		Installment:   installments[0].Payment,
+		TotalInterest: money.Zero(currency),
+		TotalPayment:  money.Zero(currency),
+		Installments:  installments,
+	}
+	for _, i := range installments {
+		schedule.TotalInterest.Minor += i.Interest.Minor
+		schedule.TotalPayment.Minor += i.Payment.Minor
+	}
+	return schedule
+}
@@ -0,0 +1,172 @@
+package financing
+
+import (
+	"errors"
+	"testing"
+
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+)
+
+// row is an installment as number, payment, principal, interest, balance.
+type row [5]int64
+
+func TestAmortize(t *testing.T) {
+	tests := []struct {
+		name  string
+		terms Terms
+		rows  []row
+	}{
+		{
+			// 30,000 interest on 1,000,000; the last installment takes the
+			// sen the principal does not divide into
+			name:  "flat",
+			terms: Terms{Principal: money.New(1_000_000, money.IDR), Rate: 1200, Months: 3, Method: models.AmortizationFlat},
+			rows: []row{
+				{1, 343_333, 333_333, 10_000, 666_667},
+				{2, 343_333, 333_333, 10_000, 333_334},
+				{3, 343_334, 333_334, 10_000, 0},
+			},
+		},
+		{
+			// 58,333.33 interest over 7 months
+			name:  "flat with uneven interest",
+			terms: Terms{Principal: money.New(1_000_000, money.IDR), Rate: 1000, Months: 7, Method: models.AmortizationFlat},
+			rows: []row{
+				{1, 151_190, 142_857, 8_333, 857_143},
+				{2, 151_190, 142_857, 8_333, 714_286},
+				{3, 151_190, 142_857, 8_333, 571_429},
+				{4, 151_190, 142_857, 8_333, 428_572},
+				{5, 151_190, 142_857, 8_333, 285_715},
+				{6, 151_190, 142_857, 8_333, 142_858},
+				{7, 151_193, 142_858, 8_335, 0},
+			},
+		},
+		{
+			name:  "flat without interest",
+			terms: Terms{Principal: money.New(1_000_000, money.IDR), Months: 3, Method: models.AmortizationFlat},
+			rows: []row{
+				{1, 333_333, 333_333, 0, 666_667},
+				{2, 333_333, 333_333, 0, 333_334},
+				{3, 333_334, 333_334, 0, 0},
+			},
+		},
+		{
+			// 1% a month: 340,022.11 a month, interest on the balance
+			name:  "annuity",
+			terms: Terms{Principal: money.New(1_000_000, money.IDR), Rate: 1200, Months: 3, Method: models.AmortizationAnnuity},
+			rows: []row{
+				{1, 340_022, 330_022, 10_000, 669_978},
+				{2, 340_022, 333_322, 6_700, 336_656},
+				{3, 340_023, 336_656, 3_367, 0},
+			},
+		},
+		{
+			name:  "annuity without interest",
+			terms: Terms{Principal: money.New(1_000_000, money.IDR), Months: 3, Method: models.AmortizationAnnuity},
+			rows: []row{
+				{1, 333_333, 333_333, 0, 666_667},
+				{2, 333_333, 333_333, 0, 333_334},
+				{3, 333_334, 333_334, 0, 0},
+			},
+		},
+		{
+			name:  "single month",
+			terms: Terms{Principal: money.New(1_000_000, money.IDR), Rate: 1200, Months: 1, Method: models.AmortizationAnnuity},
+			rows:  []row{{1, 1_010_000, 1_000_000, 10_000, 0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := Amortize(tt.terms)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(schedule.Installments) != len(tt.rows) {
+				t.Fatalf("%d installments, want %d", len(schedule.Installments), len(tt.rows))
+			}
+			var interest, payment int64
+			for i, got := range schedule.Installments {
+				have := row{int64(got.Number), got.Payment.Minor, got.Principal.Minor, got.Interest.Minor, got.Balance.Minor}
+				if have != tt.rows[i] {
+					t.Errorf("installment %d = %v, want %v", i+1, have, tt.rows[i])
+				}
+				interest += tt.rows[i][3]
+				payment += tt.rows[i][1]
+			}
+			if schedule.Installment.Minor != tt.rows[0][1] {
+				t.Errorf("installment = %v, want %d", schedule.Installment, tt.rows[0][1])
+			}
+			if schedule.TotalInterest.Minor != interest || schedule.TotalPayment.Minor != payment {
+				t.Errorf("totals = %v interest, %v paid, want %d and %d", schedule.TotalInterest, schedule.TotalPayment, interest, payment)
+			}
+			if schedule.TotalPayment.Minor-schedule.TotalInterest.Minor != tt.terms.Principal.Minor {
+				t.Errorf("repaid %d of %d", schedule.TotalPayment.Minor-schedule.TotalInterest.Minor, tt.terms.Principal.Minor)
+			}
+		})
+	}
+}
+
+func TestAmortizeCarLoans(t *testing.T) {
+	tests := []struct {
+		name                string
+		terms               Terms
+		installment, last   int64
+		interest, totalPaid int64
+	}{
+		{
+			// 5% flat on Rp150,000,000 over three years
+			name:        "flat",
+			terms:       Terms{Principal: money.MustParse("150000000", money.IDR), Rate: 500, Months: 36, Method: models.AmortizationFlat},
+			installment: 479_166_666,
+			last:        479_166_690,
+			interest:    2_250_000_000,
+			totalPaid:   17_250_000_000,
+		},
+		{
+			// 8.5% effective on Rp150,000,000 over a year: Rp13,082,967.37
+			name:        "annuity",
+			terms:       Terms{Principal: money.MustParse("150000000", money.IDR), Rate: 850, Months: 12, Method: models.AmortizationAnnuity},
+			installment: 1_308_296_737,
+			last:        1_308_296_737,
+			interest:    699_560_844,
+			totalPaid:   15_699_560_844,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := Amortize(tt.terms)
+			if err != nil {
+				t.Fatal(err)
+			}
+			last := schedule.Installments[len(schedule.Installments)-1]
+			if schedule.Installment.Minor != tt.installment || last.Payment.Minor != tt.last || last.Balance.Minor != 0 {
+				t.Errorf("installment %v, last %v leaving %v", schedule.Installment, last.Payment, last.Balance)
+			}
+			if schedule.TotalInterest.Minor != tt.interest || schedule.TotalPayment.Minor != tt.totalPaid {
+				t.Errorf("interest %v, paid %v", schedule.TotalInterest, schedule.TotalPayment)
+			}
+		})
+	}
+}
+
+func TestAmortizeInvalidTerms(t *testing.T) {
+	valid := Terms{Principal: money.New(1_000_000, money.IDR), Rate: 1200, Months: 12, Method: models.AmortizationFlat}
+	tests := []struct {
+		name   string
+		change func(*Terms)
+	}{
+		{"no principal", func(t *Terms) { t.Principal.Minor = 0 }},
+		{"negative principal", func(t *Terms) { t.Principal.Minor = -1 }},
+		{"no tenor", func(t *Terms) { t.Months = 0 }},
+		{"negative rate", func(t *Terms) { t.Rate = -1 }},
+		{"unknown method", func(t *Terms) { t.Method = "balloon" }},
+	}
+	for _, tt := range tests {
+		terms := valid
+		tt.change(&terms)
+		if _, err := Amortize(terms); !errors.Is(err, ErrInvalidTerms) {
+			t.Errorf("%s: err = %v, want ErrInvalidTerms", tt.name, err)
+		}
+	}
+}
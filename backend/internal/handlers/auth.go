@@ -1,10 +1,15 @@
 package handlers
 
 import (
+	"errors"
+	"time"
+
 	"vehicle-sales-backend/internal/auth"
 	"vehicle-sales-backend/internal/config"
 	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/middleware"
 	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/validation"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -26,21 +31,39 @@ type RegisterRequest struct {
 	Email    string          `json:"email" validate:"required,email"`
 	Password string          `json:"password" validate:"required,min=6"`
 	Name     string          `json:"name" validate:"required"`
-	Phone    string          `json:"phone"`
-	Role     models.UserRole `json:"role"`
+	Phone    string          `json:"phone" validate:"omitempty,e164"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 type AuthResponse struct {
-	Token string       `json:"token"`
-	User  *models.User `json:"user"`
+	Token            string       `json:"token"`
+	ExpiresAt        time.Time    `json:"expires_at"`
+	RefreshToken     string       `json:"refresh_token"`
+	RefreshExpiresAt time.Time    `json:"refresh_expires_at"`
+	User             *models.User `json:"user,omitempty"`
+}
+
+func newAuthResponse(pair *auth.TokenPair, user *models.User) AuthResponse {
+	return AuthResponse{
+		Token:            pair.AccessToken,
+		ExpiresAt:        pair.AccessExpiresAt,
+		RefreshToken:     pair.RefreshToken,
+		RefreshExpiresAt: pair.RefreshExpiresAt,
+		User:             user,
+	}
 }
 
 func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	var req LoginRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
 	}
 
 	// Find user by email
@@ -58,8 +81,8 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		})
 	}
 
-	// Generate token
-	token, err := auth.GenerateToken(&user, h.config.JWT.Secret)
+	// Generate token pair
+	pair, err := auth.IssueTokenPair(&user, h.config.JWT)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to generate token",
@@ -69,23 +92,13 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	// Clear password from response
 	user.Password = ""
 
-	return c.JSON(AuthResponse{
-		Token: token,
-		User:  &user,
-	})
+	return c.JSON(newAuthResponse(pair, &user))
 }
 
 func (h *AuthHandler) Register(c *fiber.Ctx) error {
 	var req RegisterRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
-	}
-
-	// Set default role if not provided
-	if req.Role == "" {
-		req.Role = models.RoleCustomer
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
 	}
 
 	// Hash password
@@ -96,13 +109,14 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		})
 	}
 
-	// Create user
+	// Public registration only ever creates customers; staff accounts are
+	// created by an admin or through an invitation.
 	user := models.User{
 		Email:    req.Email,
 		Password: hashedPassword,
 		Name:     req.Name,
 		Phone:    req.Phone,
-		Role:     req.Role,
+		Role:     models.RoleCustomer,
 		IsActive: true,
 	}
 
@@ -112,8 +126,8 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		})
 	}
 
-	// Generate token
-	token, err := auth.GenerateToken(&user, h.config.JWT.Secret)
+	// Generate token pair
+	pair, err := auth.IssueTokenPair(&user, h.config.JWT)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to generate token",
@@ -123,8 +137,53 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 	// Clear password from response
 	user.Password = ""
 
-	return c.Status(fiber.StatusCreated).JSON(AuthResponse{
-		Token: token,
-		User:  &user,
+	return c.Status(fiber.StatusCreated).JSON(newAuthResponse(pair, &user))
+}
+
+// Refresh exchanges a refresh token for a new access/refresh token pair
+func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
+	var req RefreshRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	pair, err := auth.RotateRefreshToken(req.RefreshToken, h.config.JWT)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidRefreshToken) || errors.Is(err, auth.ErrRefreshTokenReused) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Invalid refresh token",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to refresh token",
+		})
+	}
+
+	return c.JSON(newAuthResponse(pair, nil))
+}
+
+// Logout revokes the current access token and its refresh token family
+func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	var req LogoutRequest
+	if len(c.Body()) > 0 {
+		if err := validation.BindBody(c, &req); err != nil {
+			return err
+		}
+	}
+
+	authCtx := middleware.GetAuthContext(c)
+	if err := auth.Logout(authCtx.Claims, req.RefreshToken); err != nil {
+		if errors.Is(err, auth.ErrInvalidRefreshToken) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid refresh token",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to log out",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Logged out successfully",
 	})
 }
\ No newline at end of file
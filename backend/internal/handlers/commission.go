@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"strconv"
+
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/permissions"
+	"vehicle-sales-backend/internal/services"
+	"vehicle-sales-backend/internal/validation"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type CommissionHandler struct {
+	commissions *services.CommissionService
+}
+
+func NewCommissionHandler() *CommissionHandler {
+	return &CommissionHandler{commissions: services.NewCommissionService()}
+}
+
+// CommissionTierRequest replaces the plan's per-unit amount and rate from
+// the MinUnits-th sale a salesperson completes in a month
+type CommissionTierRequest struct {
+	MinUnits        int         `json:"min_units" validate:"required,min=1"`
+	PerUnit         money.Money `json:"per_unit" validate:"min=0"`
+	GrossProfitRate int64       `json:"gross_profit_rate" validate:"min=0,max=10000"`
+}
+
+type CommissionMakeBonusRequest struct {
+	Make  string      `json:"make" validate:"required,max=100"`
+	Bonus money.Money `json:"bonus" validate:"gt=0"`
+}
+
+// CommissionPlanRequest describes a commission plan. Rates are in basis
+// points of gross profit. A plan without a salesperson applies to everyone
+// who has no plan of their own; IsActive defaults to true
+type CommissionPlanRequest struct {
+	Name            string                       `json:"name" validate:"required,max=100"`
+	SalesPersonID   *uint                        `json:"sales_person_id"`
+	IsActive        *bool                        `json:"is_active"`
+	PerUnit         money.Money                  `json:"per_unit" validate:"min=0"`
+	GrossProfitRate int64                        `json:"gross_profit_rate" validate:"min=0,max=10000"`
+	Tiers           []CommissionTierRequest      `json:"tiers" validate:"dive"`
+	MakeBonuses     []CommissionMakeBonusRequest `json:"make_bonuses" validate:"dive"`
+}
+
+// GetCommissionPlans lists commission plans, the plan for everyone first
+func (h *CommissionHandler) GetCommissionPlans(c *fiber.Ctx) error {
+	query := database.DB.Model(&models.CommissionPlan{}).
+		Preload("SalesPerson").
+		Preload("Tiers", func(db *gorm.DB) *gorm.DB { return db.Order("min_units") }).
+		Preload("MakeBonuses", func(db *gorm.DB) *gorm.DB { return db.Order("make") })
+
+	if c.Query("active") == "true" {
+		query = query.Where("is_active = ?", true)
+	}
+
+	if salesPersonID := c.Query("sales_person_id"); salesPersonID != "" {
+		query = query.Where("sales_person_id = ?", salesPersonID)
+	}
+
+	var plans []models.CommissionPlan
+	if err := query.Order("sales_person_id NULLS FIRST, name").Find(&plans).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve commission plans",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   plans,
+	})
+}
+
+// CreateCommissionPlan adds a commission plan
+func (h *CommissionHandler) CreateCommissionPlan(c *fiber.Ctx) error {
+	var req CommissionPlanRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	plan, err := h.commissions.CreatePlan(req.input())
+	if err != nil {
+		return serviceError(c, err, "Failed to create commission plan")
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"status":  "success",
+		"data":    plan,
+		"message": "Commission plan created successfully",
+	})
+}
+
+// UpdateCommissionPlan replaces a commission plan. Commission already
+// accrued is not recalculated
+func (h *CommissionHandler) UpdateCommissionPlan(c *fiber.Ctx) error {
+	var req CommissionPlanRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	plan, err := h.commissions.UpdatePlan(idParam(c), req.input())
+	if err != nil {
+		return serviceError(c, err, "Failed to update commission plan")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"data":    plan,
+		"message": "Commission plan updated successfully",
+	})
+}
+
+// DeleteCommissionPlan retires a commission plan
+func (h *CommissionHandler) DeleteCommissionPlan(c *fiber.Ctx) error {
+	if err := h.commissions.DeletePlan(idParam(c)); err != nil {
+		return serviceError(c, err, "Failed to delete commission plan")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Commission plan deleted successfully",
+	})
+}
+
+// GetCommissionStatements totals the commission accrued and reversed
+// between two dates, both inclusive, per salesperson. It defaults to the
+// current month. Salespeople only see their own statement
+func (h *CommissionHandler) GetCommissionStatements(c *fiber.Ctx) error {
+	from, to, message := reportPeriod(c)
+	if message != "" {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": message,
+		})
+	}
+
+	currency := money.Currency(c.Query("currency", string(money.DefaultCurrency())))
+	if !currency.IsValid() {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unsupported currency",
+		})
+	}
+
+	var salesPersonID *uint
+	if value := c.Query("sales_person_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"status":  "error",
+				"message": "sales_person_id must be a user ID",
+			})
+		}
+		requested := uint(id)
+		salesPersonID = &requested
+	}
+	if ownID, scoped := ownerScope(c, permissions.CommissionReadAll); scoped {
+		salesPersonID = &ownID
+	}
+
+	statements, err := h.commissions.Statements(from, to, currency, salesPersonID)
+	if err != nil {
+		return serviceError(c, err, "Failed to build commission statements")
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   statements,
+	})
+}
+
+func (req CommissionPlanRequest) input() services.CommissionPlanInput {
+	input := services.CommissionPlanInput{
+		Name:            req.Name,
+		SalesPersonID:   req.SalesPersonID,
+		IsActive:        true,
+		PerUnit:         req.PerUnit,
+		GrossProfitRate: req.GrossProfitRate,
+	}
+	if req.IsActive != nil {
+		input.IsActive = *req.IsActive
+	}
+	for _, tier := range req.Tiers {
+		input.Tiers = append(input.Tiers, services.CommissionTierInput{
+			MinUnits:        tier.MinUnits,
+			PerUnit:         tier.PerUnit,
+			GrossProfitRate: tier.GrossProfitRate,
+		})
+	}
+	for _, bonus := range req.MakeBonuses {
+		input.MakeBonuses = append(input.MakeBonuses, services.CommissionMakeBonusInput{
+			Make:  bonus.Make,
+			Bonus: bonus.Bonus,
+		})
+	}
+	return input
+}
@@ -6,8 +6,11 @@ import (
 	"vehicle-sales-backend/internal/database"
 	"vehicle-sales-backend/internal/middleware"
 	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/permissions"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 type DashboardHandler struct{}
@@ -26,11 +29,11 @@ type SummaryData struct {
 	TotalVehicles   int64   `json:"total_vehicles"`
 	AvailableVehicles int64 `json:"available_vehicles"`
 	TotalSales      int64   `json:"total_sales"`
-	TotalRevenue    float64 `json:"total_revenue"`
+	TotalRevenue    money.Money `json:"total_revenue"`
 	TotalCustomers  int64   `json:"total_customers"`
 	PendingTestDrives int64 `json:"pending_test_drives"`
 	NewLeads        int64   `json:"new_leads"`
-	MonthlyRevenue  float64 `json:"monthly_revenue"`
+	MonthlyRevenue  money.Money `json:"monthly_revenue"`
 }
 
 type ChartsData struct {
@@ -43,7 +46,7 @@ type ChartsData struct {
 type SalesChartData struct {
 	Date  string `json:"date"`
 	Sales int64  `json:"sales"`
-	Revenue float64 `json:"revenue"`
+	Revenue money.Money `json:"revenue"`
 }
 
 type VehicleStatusData struct {
@@ -58,7 +61,7 @@ type LeadConversionData struct {
 
 type MonthlyRevenueData struct {
 	Month   string  `json:"month"`
-	Revenue float64 `json:"revenue"`
+	Revenue money.Money `json:"revenue"`
 }
 
 type RecentData struct {
@@ -99,18 +102,14 @@ func (h *DashboardHandler) getSummaryData(role models.UserRole, userID uint) Sum
 
 	// Sales data
 	database.DB.Model(&models.Sale{}).Count(&summary.TotalSales)
-	database.DB.Model(&models.Sale{}).
-		Where("status = ?", models.SaleStatusCompleted).
-		Select("COALESCE(SUM(sale_price), 0)").
-		Scan(&summary.TotalRevenue)
+	summary.TotalRevenue = sumMoney(database.DB.Model(&models.Sale{}).
+		Where("status = ?", models.SaleStatusCompleted), "sale_price")
 
 	// Monthly revenue (current month)
 	now := time.Now()
 	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-	database.DB.Model(&models.Sale{}).
-		Where("status = ? AND completed_at >= ?", models.SaleStatusCompleted, startOfMonth).
-		Select("COALESCE(SUM(sale_price), 0)").
-		Scan(&summary.MonthlyRevenue)
+	summary.MonthlyRevenue = sumMoney(database.DB.Model(&models.Sale{}).
+		Where("status = ? AND completed_at >= ?", models.SaleStatusCompleted, startOfMonth), "sale_price")
 
 	// Customer count
 	database.DB.Model(&models.User{}).Where("role = ?", models.RoleCustomer).Count(&summary.TotalCustomers)
@@ -119,17 +118,18 @@ func (h *DashboardHandler) getSummaryData(role models.UserRole, userID uint) Sum
 	database.DB.Model(&models.TestDrive{}).Where("status = ?", models.TestDriveStatusPending).Count(&summary.PendingTestDrives)
 
 	// Leads
-	database.DB.Model(&models.Lead{}).Where("status = ?", "new").Count(&summary.NewLeads)
+	database.DB.Model(&models.Lead{}).Where("status = ?", models.LeadStatusNew).Count(&summary.NewLeads)
 
 	// Role-specific adjustments
 	if role == models.RoleSales {
 		// Filter data for specific sales person
 		database.DB.Model(&models.Sale{}).Where("sales_person_id = ?", userID).Count(&summary.TotalSales)
-		database.DB.Model(&models.Sale{}).
-			Where("sales_person_id = ? AND status = ?", userID, models.SaleStatusCompleted).
-			Select("COALESCE(SUM(sale_price), 0)").
-			Scan(&summary.TotalRevenue)
-		database.DB.Model(&models.Lead{}).Where("assigned_to_id = ?", userID).Count(&summary.NewLeads)
+		summary.TotalRevenue = sumMoney(database.DB.Model(&models.Sale{}).
+			Where("sales_person_id = ? AND status = ?", userID, models.SaleStatusCompleted), "sale_price")
+		// Leads assigned to the sales person that have not been contacted yet
+		database.DB.Model(&models.Lead{}).
+			Where("assigned_to_id = ? AND status = ?", userID, models.LeadStatusAssigned).
+			Count(&summary.NewLeads)
 	}
 
 	return summary
@@ -144,7 +144,6 @@ func (h *DashboardHandler) getChartsData(role models.UserRole, userID uint) Char
 		dateStr := date.Format("2006-01-02")
 		
 		var sales int64
-		var revenue float64
 		
 		query := database.DB.Model(&models.Sale{}).
 			Where("DATE(created_at) = ? AND status = ?", dateStr, models.SaleStatusCompleted)
@@ -154,7 +153,7 @@ func (h *DashboardHandler) getChartsData(role models.UserRole, userID uint) Char
 		}
 		
 		query.Count(&sales)
-		query.Select("COALESCE(SUM(sale_price), 0)").Scan(&revenue)
+		revenue := sumMoney(query, "sale_price")
 		
 		charts.SalesChart = append(charts.SalesChart, SalesChartData{
 			Date:    dateStr,
@@ -181,8 +180,7 @@ func (h *DashboardHandler) getChartsData(role models.UserRole, userID uint) Char
 	}
 
 	// Lead conversion data
-	leadStatuses := []string{"new", "contacted", "qualified", "converted", "lost"}
-	for _, status := range leadStatuses {
+	for _, status := range models.LeadStatuses {
 		var count int64
 		query := database.DB.Model(&models.Lead{}).Where("status = ?", status)
 		
@@ -192,7 +190,7 @@ func (h *DashboardHandler) getChartsData(role models.UserRole, userID uint) Char
 		
 		query.Count(&count)
 		charts.LeadConversion = append(charts.LeadConversion, LeadConversionData{
-			Status: status,
+			Status: string(status),
 			Count:  count,
 		})
 	}
@@ -204,7 +202,6 @@ func (h *DashboardHandler) getChartsData(role models.UserRole, userID uint) Char
 		startOfMonth := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location())
 		endOfMonth := startOfMonth.AddDate(0, 1, 0)
 		
-		var revenue float64
 		query := database.DB.Model(&models.Sale{}).
 			Where("status = ? AND completed_at >= ? AND completed_at < ?", 
 				models.SaleStatusCompleted, startOfMonth, endOfMonth)
@@ -213,7 +210,7 @@ func (h *DashboardHandler) getChartsData(role models.UserRole, userID uint) Char
 			query = query.Where("sales_person_id = ?", userID)
 		}
 		
-		query.Select("COALESCE(SUM(sale_price), 0)").Scan(&revenue)
+		revenue := sumMoney(query, "sale_price")
 		
 		charts.MonthlyRevenue = append(charts.MonthlyRevenue, MonthlyRevenueData{
 			Month:   monthStr,
@@ -236,6 +233,8 @@ func (h *DashboardHandler) getRecentData(role models.UserRole, userID uint) Rece
 	
 	if role == models.RoleSales {
 		salesQuery = salesQuery.Where("sales_person_id = ?", userID)
+	} else if !permissions.Has(role, permissions.SaleReadAll) {
+		salesQuery = salesQuery.Where("customer_id = ?", userID)
 	}
 	
 	salesQuery.Find(&recent.RecentSales)
@@ -246,7 +245,11 @@ func (h *DashboardHandler) getRecentData(role models.UserRole, userID uint) Rece
 		Order("created_at DESC").
 		Limit(5)
 	
-	// All roles can see test drives, but filter by relevant data
+	// Customers only see their own bookings
+	if !permissions.Has(role, permissions.TestDriveReadAll) {
+		testDriveQuery = testDriveQuery.Where("customer_id = ?", userID)
+	}
+
 	testDriveQuery.Find(&recent.RecentTestDrives)
 
 	// Recent leads
@@ -258,7 +261,10 @@ func (h *DashboardHandler) getRecentData(role models.UserRole, userID uint) Rece
 		leadQuery = leadQuery.Where("assigned_to_id = ?", userID)
 	}
 	
-	leadQuery.Find(&recent.RecentLeads)
+	// Leads are internal to the dealership
+	if permissions.Has(role, permissions.LeadRead) {
+		leadQuery.Find(&recent.RecentLeads)
+	}
 
 	return recent
 }
@@ -269,14 +275,6 @@ func (h *DashboardHandler) GetAnalytics(c *fiber.Ctx) error {
 	userRole := authCtx.Role
 	userID := authCtx.UserID
 
-	// Only admin and sales can access detailed analytics
-	if userRole != models.RoleAdmin && userRole != models.RoleSales {
-		return c.Status(403).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Access denied",
-		})
-	}
-
 	period := c.Query("period", "month") // day, week, month, year
 
 	var analytics fiber.Map
@@ -330,4 +328,130 @@ func (h *DashboardHandler) getYearlyAnalytics(role models.UserRole, userID uint)
 		"period": "yearly",
 		"data":   "Yearly analytics data",
 	}
-}
\ No newline at end of file
+}
+
+// MarginData is the gross profit of the completed sales of one make and
+// model, of one salesperson, or of all of them. Margin is in basis points
+// of Revenue
+type MarginData struct {
+	Make           string      `json:"make,omitempty"`
+	Model          string      `json:"model,omitempty"`
+	SalesPersonID  uint        `json:"sales_person_id,omitempty"`
+	SalesPerson    string      `json:"sales_person,omitempty"`
+	Sales          int64       `json:"sales"`
+	Revenue        money.Money `json:"revenue"`
+	VehicleCost    money.Money `json:"vehicle_cost"`
+	GrossProfit    money.Money `json:"gross_profit"`
+	AvgGrossProfit money.Money `json:"avg_gross_profit"`
+	Margin         int64       `json:"margin"`
+}
+
+// marginRow is a MarginData row as aggregated by the database
+type marginRow struct {
+	Make          string
+	Model         string
+	SalesPersonID uint
+	SalesPerson   string
+	Sales         int64
+	Revenue       int64
+	VehicleCost   int64
+	GrossProfit   int64
+}
+
+const marginColumns = "COUNT(*) AS sales, " +
+	"COALESCE(SUM(sales.net_revenue_minor), 0) AS revenue, " +
+	"COALESCE(SUM(sales.vehicle_cost_minor), 0) AS vehicle_cost, " +
+	"COALESCE(SUM(sales.gross_profit_minor), 0) AS gross_profit"
+
+// GetMargins reports the gross profit of the sales completed between two
+// dates, both inclusive, in total, per make and model and per salesperson.
+// It defaults to the current month and covers the default currency only.
+// Salespeople only see their own sales
+func (h *DashboardHandler) GetMargins(c *fiber.Ctx) error {
+	from, to, message := reportPeriod(c)
+	if message != "" {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": message,
+		})
+	}
+
+	currency := money.DefaultCurrency()
+	ownID, scoped := ownerScope(c, permissions.SaleReadAll)
+	completed := func() *gorm.DB {
+		query := database.DB.Model(&models.Sale{}).
+			Where("sales.status = ? AND sales.completed_at >= ? AND sales.completed_at < ?",
+				models.SaleStatusCompleted, from, to).
+			Where("sales.gross_profit_currency = ?", currency)
+		if scoped {
+			query = query.Where("sales.sales_person_id = ?", ownID)
+		}
+		return query
+	}
+
+	var total marginRow
+	var byModel, bySalesPerson []marginRow
+	queries := []*gorm.DB{
+		completed().Select(marginColumns).Scan(&total),
+		completed().
+			Select("vehicles.make, vehicles.model, " + marginColumns).
+			Joins("JOIN vehicles ON vehicles.id = sales.vehicle_id").
+			Group("vehicles.make, vehicles.model").
+			Order("gross_profit DESC, vehicles.make, vehicles.model").
+			Scan(&byModel),
+		completed().
+			Select("sales.sales_person_id, users.name AS sales_person, " + marginColumns).
+			Joins("JOIN users ON users.id = sales.sales_person_id").
+			Group("sales.sales_person_id, users.name").
+			Order("gross_profit DESC, users.name").
+			Scan(&bySalesPerson),
+	}
+	for _, query := range queries {
+		if query.Error != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Failed to retrieve margins",
+				"error":   query.Error.Error(),
+			})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data": fiber.Map{
+			"from":            from,
+			"to":              to.AddDate(0, 0, -1),
+			"currency":        currency,
+			"total":           total.data(currency),
+			"by_model":        marginData(byModel, currency),
+			"by_sales_person": marginData(bySalesPerson, currency),
+		},
+	})
+}
+
+func marginData(rows []marginRow, currency money.Currency) []MarginData {
+	data := make([]MarginData, 0, len(rows))
+	for _, row := range rows {
+		data = append(data, row.data(currency))
+	}
+	return data
+}
+
+func (row marginRow) data(currency money.Currency) MarginData {
+	data := MarginData{
+		Make:           row.Make,
+		Model:          row.Model,
+		SalesPersonID:  row.SalesPersonID,
+		SalesPerson:    row.SalesPerson,
+		Sales:          row.Sales,
+		Revenue:        money.Money{Minor: row.Revenue, Currency: currency},
+		VehicleCost:    money.Money{Minor: row.VehicleCost, Currency: currency},
+		GrossProfit:    money.Money{Minor: row.GrossProfit, Currency: currency},
+		AvgGrossProfit: money.Zero(currency),
+		Margin:         margin(row.GrossProfit, row.Revenue),
+	}
+	if row.Sales > 0 {
+		data.AvgGrossProfit.Minor = row.GrossProfit / row.Sales
+	}
+	return data
+}
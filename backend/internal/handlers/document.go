@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/documents"
+	"vehicle-sales-backend/internal/escpos"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/permissions"
+	"vehicle-sales-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type DocumentHandler struct {
+	documents *services.DocumentService
+}
+
+func NewDocumentHandler() *DocumentHandler {
+	return &DocumentHandler{documents: services.NewDocumentService()}
+}
+
+// GetSaleInvoice returns the PDF invoice of a completed sale
+func (h *DocumentHandler) GetSaleInvoice(c *fiber.Ctx) error {
+	var sale models.Sale
+	if err := scopeToCustomer(c, database.DB, permissions.SaleReadAll).First(&sale, idParam(c)).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Sale not found",
+		})
+	}
+
+	document, err := h.documents.Invoice(sale.ID)
+	if err != nil {
+		return serviceError(c, err, "Failed to retrieve invoice")
+	}
+	return h.sendPDF(c, document)
+}
+
+// GetSaleDocuments lists the invoice, receipts and credit notes issued for a
+// sale
+func (h *DocumentHandler) GetSaleDocuments(c *fiber.Ctx) error {
+	var sale models.Sale
+	if err := scopeToCustomer(c, database.DB, permissions.SaleReadAll).First(&sale, idParam(c)).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Sale not found",
+		})
+	}
+
+	documents, err := h.documents.SaleDocuments(sale.ID)
+	if err != nil {
+		return serviceError(c, err, "Failed to retrieve documents")
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   documents,
+	})
+}
+
+// GetTransactionReceipt returns the PDF receipt of a completed payment, or
+// the credit note of a refund
+func (h *DocumentHandler) GetTransactionReceipt(c *fiber.Ctx) error {
+	var transaction models.Transaction
+	if err := scopeTransactionsToCustomer(c, database.DB).First(&transaction, idParam(c)).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Transaction not found",
+		})
+	}
+
+	document, err := h.documents.Receipt(transaction.ID)
+	if err != nil {
+		return serviceError(c, err, "Failed to retrieve receipt")
+	}
+	return h.sendPDF(c, document)
+}
+
+// GetTransactionReceiptESCPOS returns the receipt or credit note of a
+// transaction as a raw ESC/POS print job for a thermal printer. The paper
+// width (58 or 80 mm) and template default to the configured ones
+func (h *DocumentHandler) GetTransactionReceiptESCPOS(c *fiber.Ctx) error {
+	config := documents.Current()
+
+	paper, ok := escpos.PaperFor(c.QueryInt("width", config.PaperWidth))
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Paper width must be 58 or 80",
+		})
+	}
+	template, ok := documents.ReceiptTemplates[c.Query("template", config.ReceiptTemplate)]
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unknown receipt template",
+		})
+	}
+
+	var transaction models.Transaction
+	if err := scopeTransactionsToCustomer(c, database.DB).First(&transaction, idParam(c)).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Transaction not found",
+		})
+	}
+
+	document, content, err := h.documents.ReceiptContent(transaction.ID)
+	if err != nil {
+		return serviceError(c, err, "Failed to retrieve receipt")
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMEOctetStream)
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+document.Number+`.bin"`)
+	return c.Send(documents.RenderESCPOS(config, content, paper, template))
+}
+
+func (h *DocumentHandler) sendPDF(c *fiber.Ctx, document *models.Document) error {
+	pdf, err := h.documents.PDF(document)
+	if err != nil {
+		return serviceError(c, err, "Failed to render the PDF")
+	}
+	c.Set(fiber.HeaderContentType, "application/pdf")
+	c.Set(fiber.HeaderContentDisposition, `inline; filename="`+document.Number+`.pdf"`)
+	return c.Send(pdf)
+}
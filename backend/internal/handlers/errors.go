@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+
+	"vehicle-sales-backend/internal/middleware"
+	"vehicle-sales-backend/internal/services"
+	"vehicle-sales-backend/internal/statemachine"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// serviceError renders an error returned by the service layer. Business rule
+// failures map to 404, 400, 409 or 403 and gateway failures to 502, each with
+// their own message; rejected status transitions map to 409 or 403. Anything
+// else is reported as a 500 with the fallback message.
+func serviceError(c *fiber.Ctx, err error, fallback string) error {
+	var terr *statemachine.Error
+	if errors.As(err, &terr) {
+		status := 409
+		if errors.Is(terr, statemachine.ErrNotPermitted) {
+			status = 403
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"status":              "error",
+			"message":             terr.Error(),
+			"current_status":      terr.From,
+			"allowed_transitions": terr.Allowed,
+		})
+	}
+
+	var serr *services.Error
+	if !errors.As(err, &serr) {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": fallback,
+			"error":   err.Error(),
+		})
+	}
+
+	status := 500
+	switch {
+	case errors.Is(serr, services.ErrNotFound):
+		status = 404
+	case errors.Is(serr, services.ErrInvalidState):
+		status = 400
+	case errors.Is(serr, services.ErrConflict):
+		status = 409
+	case errors.Is(serr, services.ErrForbidden):
+		status = 403
+	case errors.Is(serr, services.ErrGateway):
+		status = 502
+	}
+
+	body := fiber.Map{
+		"status":  "error",
+		"message": serr.Message,
+	}
+	for key, value := range serr.Details {
+		body[key] = value
+	}
+	return c.Status(status).JSON(body)
+}
+
+// idParam returns the numeric :id route parameter, or 0 when it is not a
+// valid ID so that lookups report the record as not found.
+func idParam(c *fiber.Ctx) uint {
+	id, err := c.ParamsInt("id")
+	if err != nil || id < 0 {
+		return 0
+	}
+	return uint(id)
+}
+
+// actor identifies the authenticated caller to the service layer.
+func actor(c *fiber.Ctx) services.Actor {
+	authCtx := middleware.GetAuthContext(c)
+	return services.Actor{UserID: authCtx.UserID, Role: authCtx.Role}
+}
@@ -0,0 +1,424 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/financing"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/permissions"
+	"vehicle-sales-backend/internal/services"
+	"vehicle-sales-backend/internal/validation"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type FinancingHandler struct {
+	financing *services.FinancingService
+}
+
+func NewFinancingHandler() *FinancingHandler {
+	return &FinancingHandler{financing: services.NewFinancingService()}
+}
+
+// LenderRequest describes a lender; IsActive defaults to true
+type LenderRequest struct {
+	Name         string `json:"name" validate:"required,max=100"`
+	ContactName  string `json:"contact_name" validate:"max=100"`
+	ContactPhone string `json:"contact_phone" validate:"omitempty,e164"`
+	ContactEmail string `json:"contact_email" validate:"omitempty,email"`
+	IsActive     *bool  `json:"is_active"`
+}
+
+type FinancingApplicationRequest struct {
+	SaleID          uint        `json:"sale_id" validate:"required"`
+	ApplicantIncome money.Money `json:"applicant_income" validate:"gt=0"`
+	DownPayment     money.Money `json:"down_payment" validate:"min=0"`
+	TenorMonths     int         `json:"tenor_months" validate:"required,min=1,max=120"`
+	LenderID        *uint       `json:"lender_id"`
+	Notes           string      `json:"notes"`
+}
+
+// FinancingOfferRequest is a lender's offer. InterestRate is the annual rate
+// in basis points; Principal and TenorMonths default to the application's
+type FinancingOfferRequest struct {
+	LenderID     uint                      `json:"lender_id" validate:"required"`
+	Method       models.AmortizationMethod `json:"method" validate:"required,enum"`
+	InterestRate int64                     `json:"interest_rate" validate:"min=0,max=10000"`
+	AdminFee     money.Money               `json:"admin_fee" validate:"min=0"`
+	Principal    money.Money               `json:"principal" validate:"min=0"`
+	TenorMonths  int                       `json:"tenor_months" validate:"min=0,max=120"`
+	LenderRef    string                    `json:"lender_ref" validate:"max=100"`
+	ValidUntil   *time.Time                `json:"valid_until"`
+	Notes        string                    `json:"notes"`
+}
+
+type AcceptOfferRequest struct {
+	OfferID uint `json:"offer_id" validate:"required"`
+}
+
+type FinancingDecisionRequest struct {
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+// DisbursementRequest records the lender's transfer. Amount, if given, must
+// match the financed amount; DisbursedAt defaults to now
+type DisbursementRequest struct {
+	Reference   string      `json:"reference" validate:"required,max=100"`
+	Amount      money.Money `json:"amount" validate:"min=0"`
+	DisbursedAt *time.Time  `json:"disbursed_at"`
+}
+
+type ScheduleRequest struct {
+	Principal    money.Money               `json:"principal" validate:"gt=0"`
+	InterestRate int64                     `json:"interest_rate" validate:"min=0,max=10000"`
+	TenorMonths  int                       `json:"tenor_months" validate:"required,min=1,max=120"`
+	Method       models.AmortizationMethod `json:"method" validate:"required,enum"`
+}
+
+// GetLenders lists lenders by name; ?active=true leaves out inactive ones
+func (h *FinancingHandler) GetLenders(c *fiber.Ctx) error {
+	query := database.DB.Model(&models.Lender{})
+
+	if c.Query("active") == "true" {
+		query = query.Where("is_active = ?", true)
+	}
+
+	var lenders []models.Lender
+	if err := query.Order("name").Find(&lenders).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve lenders",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   lenders,
+	})
+}
+
+// CreateLender adds a lender
+func (h *FinancingHandler) CreateLender(c *fiber.Ctx) error {
+	var req LenderRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	lender, err := h.financing.CreateLender(req.input())
+	if err != nil {
+		return serviceError(c, err, "Failed to create lender")
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"status":  "success",
+		"data":    lender,
+		"message": "Lender created successfully",
+	})
+}
+
+// UpdateLender changes a lender's details
+func (h *FinancingHandler) UpdateLender(c *fiber.Ctx) error {
+	var req LenderRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	lender, err := h.financing.UpdateLender(idParam(c), req.input())
+	if err != nil {
+		return serviceError(c, err, "Failed to update lender")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"data":    lender,
+		"message": "Lender updated successfully",
+	})
+}
+
+// GetApplications lists financing applications, newest first. Customers see
+// their own
+func (h *FinancingHandler) GetApplications(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
+	status := c.Query("status")
+	saleID := c.Query("sale_id")
+	lenderID := c.Query("lender_id")
+
+	offset := (page - 1) * limit
+
+	query := scopeToCustomer(c, database.DB.Model(&models.FinancingApplication{}), permissions.FinancingReadAll).
+		Preload("Sale").
+		Preload("Customer").
+		Preload("Lender")
+
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if saleID != "" {
+		query = query.Where("sale_id = ?", saleID)
+	}
+
+	if lenderID != "" {
+		query = query.Where("lender_id = ?", lenderID)
+	}
+
+	var applications []models.FinancingApplication
+	var total int64
+
+	query.Count(&total)
+
+	if err := query.Offset(offset).Limit(limit).Order("created_at DESC").Find(&applications).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve financing applications",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data": fiber.Map{
+			"applications": applications,
+			"pagination": fiber.Map{
+				"page":  page,
+				"limit": limit,
+				"total": total,
+				"pages": (total + int64(limit) - 1) / int64(limit),
+			},
+		},
+	})
+}
+
+// GetApplication retrieves a financing application with its offers
+func (h *FinancingHandler) GetApplication(c *fiber.Ctx) error {
+	var application models.FinancingApplication
+	if err := scopeToCustomer(c, database.DB, permissions.FinancingReadAll).
+		Preload("Sale").
+		Preload("Customer").
+		Preload("Lender").
+		Preload("Transaction").
+		Preload("Offers", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Offers.Lender").
+		First(&application, idParam(c)).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Financing application not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   application,
+	})
+}
+
+// GetOffer retrieves an offer of a financing application with its
+// repayment schedule
+func (h *FinancingHandler) GetOffer(c *fiber.Ctx) error {
+	var application models.FinancingApplication
+	if err := scopeToCustomer(c, database.DB, permissions.FinancingReadAll).
+		First(&application, idParam(c)).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Financing application not found",
+		})
+	}
+
+	var offer models.FinancingOffer
+	if err := database.DB.
+		Preload("Lender").
+		Preload("Installments", func(db *gorm.DB) *gorm.DB { return db.Order("number") }).
+		Where("application_id = ?", application.ID).
+		First(&offer, c.Params("offerId")).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Offer not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   offer,
+	})
+}
+
+// CreateApplication records a customer's application to finance a sale
+func (h *FinancingHandler) CreateApplication(c *fiber.Ctx) error {
+	var req FinancingApplicationRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	application, err := h.financing.Apply(actor(c), services.ApplyForFinancingInput{
+		SaleID:          req.SaleID,
+		ApplicantIncome: req.ApplicantIncome,
+		DownPayment:     req.DownPayment,
+		TenorMonths:     req.TenorMonths,
+		LenderID:        req.LenderID,
+		Notes:           req.Notes,
+	})
+	if err != nil {
+		return serviceError(c, err, "Failed to create financing application")
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"status":  "success",
+		"data":    application,
+		"message": "Financing application created successfully",
+	})
+}
+
+// AddOffer records a lender's offer on an application
+func (h *FinancingHandler) AddOffer(c *fiber.Ctx) error {
+	var req FinancingOfferRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	offer, err := h.financing.AddOffer(actor(c), idParam(c), services.FinancingOfferInput{
+		LenderID:     req.LenderID,
+		Method:       req.Method,
+		InterestRate: req.InterestRate,
+		AdminFee:     req.AdminFee,
+		Principal:    req.Principal,
+		TenorMonths:  req.TenorMonths,
+		LenderRef:    req.LenderRef,
+		ValidUntil:   req.ValidUntil,
+		Notes:        req.Notes,
+	})
+	if err != nil {
+		return serviceError(c, err, "Failed to add offer")
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"status":  "success",
+		"data":    offer,
+		"message": "Offer added successfully",
+	})
+}
+
+// AcceptOffer approves an application on one of its offers, opening a
+// pending financing payment for the financed amount
+func (h *FinancingHandler) AcceptOffer(c *fiber.Ctx) error {
+	var req AcceptOfferRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	application, err := h.financing.AcceptOffer(actor(c), idParam(c), req.OfferID)
+	if err != nil {
+		return serviceError(c, err, "Failed to accept offer")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"data":    application,
+		"message": "Offer accepted successfully",
+	})
+}
+
+// RejectApplication records that no lender will finance the application
+func (h *FinancingHandler) RejectApplication(c *fiber.Ctx) error {
+	var req FinancingDecisionRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	application, err := h.financing.Reject(actor(c), idParam(c), req.Reason)
+	if err != nil {
+		return serviceError(c, err, "Failed to reject financing application")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"data":    application,
+		"message": "Financing application rejected",
+	})
+}
+
+// CancelApplication withdraws an application that has not been disbursed
+func (h *FinancingHandler) CancelApplication(c *fiber.Ctx) error {
+	var req FinancingDecisionRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	application, err := h.financing.Cancel(actor(c), idParam(c), req.Reason)
+	if err != nil {
+		return serviceError(c, err, "Failed to cancel financing application")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"data":    application,
+		"message": "Financing application canceled",
+	})
+}
+
+// RecordDisbursement records the lender's payment, completing the financing
+// payment
+func (h *FinancingHandler) RecordDisbursement(c *fiber.Ctx) error {
+	var req DisbursementRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	application, err := h.financing.RecordDisbursement(actor(c), idParam(c), services.DisbursementInput{
+		Reference:   req.Reference,
+		Amount:      req.Amount,
+		DisbursedAt: req.DisbursedAt,
+	})
+	if err != nil {
+		return serviceError(c, err, "Failed to record disbursement")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"data":    application,
+		"message": "Disbursement recorded successfully",
+	})
+}
+
+// CalculateSchedule works out a loan's repayment schedule without recording
+// anything
+func (h *FinancingHandler) CalculateSchedule(c *fiber.Ctx) error {
+	var req ScheduleRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	schedule, err := h.financing.Schedule(financing.Terms{
+		Principal: req.Principal,
+		Rate:      req.InterestRate,
+		Months:    req.TenorMonths,
+		Method:    req.Method,
+	})
+	if err != nil {
+		return serviceError(c, err, "Failed to calculate schedule")
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   schedule,
+	})
+}
+
+func (req LenderRequest) input() services.LenderInput {
+	input := services.LenderInput{
+		Name:         req.Name,
+		ContactName:  req.ContactName,
+		ContactPhone: req.ContactPhone,
+		ContactEmail: req.ContactEmail,
+		IsActive:     true,
+	}
+	if req.IsActive != nil {
+		input.IsActive = *req.IsActive
+	}
+	return input
+}
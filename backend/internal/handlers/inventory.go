@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"time"
+
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/services"
+	"vehicle-sales-backend/internal/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type InventoryHandler struct {
+	inventory *services.InventoryService
+}
+
+func NewInventoryHandler() *InventoryHandler {
+	return &InventoryHandler{inventory: services.NewInventoryService()}
+}
+
+// RepricingRuleRequest describes a repricing rule. DropRate and FloorMarkup
+// are in basis points, e.g. 200 and 500 to drop 2% every EveryDays days down
+// to cost plus 5%. Make, Model and Category narrow the vehicles it applies
+// to; IsActive defaults to true
+type RepricingRuleRequest struct {
+	Name        string `json:"name" validate:"required,max=100"`
+	Make        string `json:"make" validate:"max=100"`
+	Model       string `json:"model" validate:"max=100"`
+	Category    string `json:"category" validate:"omitempty,slug,max=40"`
+	EveryDays   int    `json:"every_days" validate:"required,min=1,max=365"`
+	DropRate    int64  `json:"drop_rate" validate:"required,min=1,max=10000"`
+	FloorMarkup int64  `json:"floor_markup" validate:"min=0,max=100000"`
+	IsActive    *bool  `json:"is_active"`
+}
+
+// GetAging buckets the vehicles in stock by how long they have been there,
+// 0-30, 31-60, 61-90 and 90+ days, and lists them oldest first
+func (h *InventoryHandler) GetAging(c *fiber.Ctx) error {
+	status := models.VehicleStatus(c.Query("status"))
+	if status != "" && !status.IsValid() {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid vehicle status",
+		})
+	}
+
+	report, err := h.inventory.Aging(time.Now(), services.AgingFilter{
+		Status:   status,
+		Make:     c.Query("make"),
+		Category: c.Query("category"),
+	})
+	if err != nil {
+		return serviceError(c, err, "Failed to build aging report")
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   report,
+	})
+}
+
+// GetPriceHistory lists the price changes of a vehicle, latest first
+func (h *InventoryHandler) GetPriceHistory(c *fiber.Ctx) error {
+	changes, err := h.inventory.PriceHistory(idParam(c))
+	if err != nil {
+		return serviceError(c, err, "Failed to retrieve price history")
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   changes,
+	})
+}
+
+// GetRepricingRules lists repricing rules
+func (h *InventoryHandler) GetRepricingRules(c *fiber.Ctx) error {
+	query := database.DB.Model(&models.RepricingRule{})
+
+	if c.Query("active") == "true" {
+		query = query.Where("is_active = ?", true)
+	}
+
+	var rules []models.RepricingRule
+	if err := query.Order("name, id").Find(&rules).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve repricing rules",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   rules,
+	})
+}
+
+// CreateRepricingRule adds a repricing rule
+func (h *InventoryHandler) CreateRepricingRule(c *fiber.Ctx) error {
+	var req RepricingRuleRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	rule, err := h.inventory.CreateRule(req.input())
+	if err != nil {
+		return serviceError(c, err, "Failed to create repricing rule")
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"status":  "success",
+		"data":    rule,
+		"message": "Repricing rule created successfully",
+	})
+}
+
+// UpdateRepricingRule replaces a repricing rule. Prices it already lowered
+// are not raised again
+func (h *InventoryHandler) UpdateRepricingRule(c *fiber.Ctx) error {
+	var req RepricingRuleRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	rule, err := h.inventory.UpdateRule(idParam(c), req.input())
+	if err != nil {
+		return serviceError(c, err, "Failed to update repricing rule")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"data":    rule,
+		"message": "Repricing rule updated successfully",
+	})
+}
+
+// DeleteRepricingRule retires a repricing rule
+func (h *InventoryHandler) DeleteRepricingRule(c *fiber.Ctx) error {
+	if err := h.inventory.DeleteRule(idParam(c)); err != nil {
+		return serviceError(c, err, "Failed to delete repricing rule")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Repricing rule deleted successfully",
+	})
+}
+
+func (req RepricingRuleRequest) input() services.RepricingRuleInput {
+	input := services.RepricingRuleInput{
+		Name:        req.Name,
+		Make:        req.Make,
+		Model:       req.Model,
+		Category:    req.Category,
+		EveryDays:   req.EveryDays,
+		DropRate:    req.DropRate,
+		FloorMarkup: req.FloorMarkup,
+		IsActive:    true,
+	}
+	if req.IsActive != nil {
+		input.IsActive = *req.IsActive
+	}
+	return input
+}
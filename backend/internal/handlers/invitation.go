@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"vehicle-sales-backend/internal/auth"
+	"vehicle-sales-backend/internal/config"
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/middleware"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/validation"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type InvitationHandler struct {
+	config *config.Config
+}
+
+func NewInvitationHandler(config *config.Config) *InvitationHandler {
+	return &InvitationHandler{config: config}
+}
+
+type CreateInvitationRequest struct {
+	Email string          `json:"email" validate:"required,email"`
+	Name  string          `json:"name"`
+	Role  models.UserRole `json:"role" validate:"required,oneof=sales cashier"`
+}
+
+type AcceptInvitationRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Name     string `json:"name"`
+	Password string `json:"password" validate:"required,min=6"`
+	Phone    string `json:"phone" validate:"omitempty,e164"`
+}
+
+var errInvitationUnavailable = errors.New("invitation is no longer valid")
+
+// GetInvitations lists invitations, newest first (Admin only)
+func (h *InvitationHandler) GetInvitations(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
+	pending := c.Query("pending")
+
+	offset := (page - 1) * limit
+
+	query := database.DB.Model(&models.Invitation{}).Preload("InvitedBy")
+
+	if pending == "true" {
+		query = query.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", time.Now())
+	}
+
+	var invitations []models.Invitation
+	var total int64
+
+	query.Count(&total)
+
+	if err := query.Offset(offset).Limit(limit).Order("created_at DESC").Find(&invitations).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve invitations",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data": fiber.Map{
+			"invitations": invitations,
+			"pagination": fiber.Map{
+				"page":  page,
+				"limit": limit,
+				"total": total,
+				"pages": (total + int64(limit) - 1) / int64(limit),
+			},
+		},
+	})
+}
+
+// CreateInvitation issues a signed, expiring invitation for a staff role (Admin only)
+func (h *InvitationHandler) CreateInvitation(c *fiber.Ctx) error {
+	var req CreateInvitationRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	var existingUser models.User
+	if err := database.DB.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Email already exists",
+		})
+	}
+
+	authCtx := middleware.GetAuthContext(c)
+
+	invitation := models.Invitation{
+		Email:       req.Email,
+		Name:        req.Name,
+		Role:        req.Role,
+		InvitedByID: authCtx.UserID,
+		ExpiresAt:   time.Now().Add(h.config.JWT.InviteTokenTTL),
+	}
+
+	if err := database.DB.Create(&invitation).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to create invitation",
+			"error":   err.Error(),
+		})
+	}
+
+	token, err := auth.GenerateInviteToken(&invitation, h.config.JWT.Secret)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to sign invitation",
+		})
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"status": "success",
+		"data": fiber.Map{
+			"invitation": invitation,
+			"token":      token,
+		},
+	})
+}
+
+// RevokeInvitation cancels an invitation that has not been accepted yet (Admin only)
+func (h *InvitationHandler) RevokeInvitation(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var invitation models.Invitation
+	if err := database.DB.First(&invitation, id).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invitation not found",
+		})
+	}
+
+	if invitation.AcceptedAt != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invitation has already been accepted",
+		})
+	}
+
+	now := time.Now()
+	invitation.RevokedAt = &now
+
+	if err := database.DB.Save(&invitation).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to revoke invitation",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Invitation revoked successfully",
+	})
+}
+
+// GetInvitation lets an invitee preview an invitation before accepting it
+func (h *InvitationHandler) GetInvitation(c *fiber.Ctx) error {
+	claims, err := auth.ValidateInviteToken(c.Params("token"), h.config.JWT.Secret)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Invitation not found or expired",
+		})
+	}
+
+	var invitation models.Invitation
+	if err := database.DB.First(&invitation, claims.InvitationID).Error; err != nil || !invitationRedeemable(&invitation) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Invitation not found or expired",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"email":      invitation.Email,
+		"name":       invitation.Name,
+		"role":       invitation.Role,
+		"expires_at": invitation.ExpiresAt,
+	})
+}
+
+// AcceptInvitation creates the invited staff account with a password chosen
+// by the invitee and signs them in
+func (h *InvitationHandler) AcceptInvitation(c *fiber.Ctx) error {
+	var req AcceptInvitationRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	claims, err := auth.ValidateInviteToken(req.Token, h.config.JWT.Secret)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid or expired invitation",
+		})
+	}
+
+	hashedPassword, err := auth.HashPassword(req.Password)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to process password",
+		})
+	}
+
+	var user models.User
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var invitation models.Invitation
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&invitation, claims.InvitationID).Error; err != nil {
+			return errInvitationUnavailable
+		}
+
+		if !invitationRedeemable(&invitation) || invitation.Email != claims.Email {
+			return errInvitationUnavailable
+		}
+
+		name := req.Name
+		if name == "" {
+			name = invitation.Name
+		}
+
+		user = models.User{
+			Email:    invitation.Email,
+			Password: hashedPassword,
+			Name:     name,
+			Phone:    req.Phone,
+			Role:     invitation.Role,
+			IsActive: true,
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		invitation.AcceptedAt = &now
+		invitation.UserID = &user.ID
+		return tx.Save(&invitation).Error
+	})
+
+	if errors.Is(err, errInvitationUnavailable) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid or expired invitation",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Email already exists",
+		})
+	}
+
+	pair, err := auth.IssueTokenPair(&user, h.config.JWT)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate token",
+		})
+	}
+
+	// Clear password from response
+	user.Password = ""
+
+	return c.Status(fiber.StatusCreated).JSON(newAuthResponse(pair, &user))
+}
+
+func invitationRedeemable(invitation *models.Invitation) bool {
+	return invitation.AcceptedAt == nil &&
+		invitation.RevokedAt == nil &&
+		time.Now().Before(invitation.ExpiresAt)
+}
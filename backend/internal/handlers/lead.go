@@ -6,6 +6,9 @@ import (
 
 	"vehicle-sales-backend/internal/database"
 	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/services"
+	"vehicle-sales-backend/internal/validation"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -18,21 +21,21 @@ func NewLeadHandler() *LeadHandler {
 
 type CreateLeadRequest struct {
 	Name         string  `json:"name" validate:"required"`
-	Email        string  `json:"email" validate:"email"`
-	Phone        string  `json:"phone"`
+	Email        string  `json:"email" validate:"omitempty,email"`
+	Phone        string  `json:"phone" validate:"omitempty,e164"`
 	InterestedIn string  `json:"interested_in"`
-	Budget       float64 `json:"budget"`
+	Budget       money.Money `json:"budget" validate:"min=0"`
 	Notes        string  `json:"notes"`
 }
 
 type UpdateLeadRequest struct {
 	Name           string     `json:"name,omitempty"`
-	Email          string     `json:"email,omitempty"`
-	Phone          string     `json:"phone,omitempty"`
+	Email          string     `json:"email,omitempty" validate:"omitempty,email"`
+	Phone          string     `json:"phone,omitempty" validate:"omitempty,e164"`
 	InterestedIn   string     `json:"interested_in,omitempty"`
-	Budget         float64    `json:"budget,omitempty"`
+	Budget         money.Money `json:"budget,omitempty" validate:"min=0"`
 	AssignedToID   *uint      `json:"assigned_to_id,omitempty"`
-	Status         string     `json:"status,omitempty"`
+	Status         models.LeadStatus `json:"status,omitempty" validate:"omitempty,enum"`
 	Notes          string     `json:"notes,omitempty"`
 	LastContactAt  *time.Time `json:"last_contact_at,omitempty"`
 }
@@ -111,11 +114,8 @@ func (h *LeadHandler) GetLead(c *fiber.Ctx) error {
 // CreateLead creates a new lead
 func (h *LeadHandler) CreateLead(c *fiber.Ctx) error {
 	var req CreateLeadRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Invalid request body",
-		})
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
 	}
 
 	lead := models.Lead{
@@ -124,7 +124,7 @@ func (h *LeadHandler) CreateLead(c *fiber.Ctx) error {
 		Phone:        req.Phone,
 		InterestedIn: req.InterestedIn,
 		Budget:       req.Budget,
-		Status:       "new",
+		Status:       models.LeadStatusNew,
 		Notes:        req.Notes,
 	}
 
@@ -155,11 +155,8 @@ func (h *LeadHandler) UpdateLead(c *fiber.Ctx) error {
 	}
 
 	var req UpdateLeadRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Invalid request body",
-		})
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
 	}
 
 	// Update fields if provided
@@ -175,7 +172,7 @@ func (h *LeadHandler) UpdateLead(c *fiber.Ctx) error {
 	if req.InterestedIn != "" {
 		lead.InterestedIn = req.InterestedIn
 	}
-	if req.Budget > 0 {
+	if req.Budget.IsPositive() {
 		lead.Budget = req.Budget
 	}
 	if req.AssignedToID != nil {
@@ -190,6 +187,9 @@ func (h *LeadHandler) UpdateLead(c *fiber.Ctx) error {
 		lead.AssignedToID = req.AssignedToID
 	}
 	if req.Status != "" {
+		if err := services.LeadStates.Check(actor(c), lead.Status, req.Status); err != nil {
+			return serviceError(c, err, "Failed to update lead")
+		}
 		lead.Status = req.Status
 	}
 	if req.Notes != "" {
@@ -247,21 +247,23 @@ func (h *LeadHandler) GetLeadAnalytics(c *fiber.Ctx) error {
 	var analytics struct {
 		TotalLeads      int64   `json:"total_leads"`
 		NewLeads        int64   `json:"new_leads"`
+		AssignedLeads   int64   `json:"assigned_leads"`
 		ContactedLeads  int64   `json:"contacted_leads"`
 		QualifiedLeads  int64   `json:"qualified_leads"`
 		ConvertedLeads  int64   `json:"converted_leads"`
-		AvgBudget       float64 `json:"avg_budget"`
+		LostLeads       int64   `json:"lost_leads"`
+		AvgBudget       money.Money `json:"avg_budget"`
 	}
 
 	database.DB.Model(&models.Lead{}).Count(&analytics.TotalLeads)
-	database.DB.Model(&models.Lead{}).Where("status = ?", "new").Count(&analytics.NewLeads)
-	database.DB.Model(&models.Lead{}).Where("status = ?", "contacted").Count(&analytics.ContactedLeads)
-	database.DB.Model(&models.Lead{}).Where("status = ?", "qualified").Count(&analytics.QualifiedLeads)
-	database.DB.Model(&models.Lead{}).Where("status = ?", "converted").Count(&analytics.ConvertedLeads)
+	database.DB.Model(&models.Lead{}).Where("status = ?", models.LeadStatusNew).Count(&analytics.NewLeads)
+	database.DB.Model(&models.Lead{}).Where("status = ?", models.LeadStatusAssigned).Count(&analytics.AssignedLeads)
+	database.DB.Model(&models.Lead{}).Where("status = ?", models.LeadStatusContacted).Count(&analytics.ContactedLeads)
+	database.DB.Model(&models.Lead{}).Where("status = ?", models.LeadStatusQualified).Count(&analytics.QualifiedLeads)
+	database.DB.Model(&models.Lead{}).Where("status = ?", models.LeadStatusConverted).Count(&analytics.ConvertedLeads)
+	database.DB.Model(&models.Lead{}).Where("status = ?", models.LeadStatusLost).Count(&analytics.LostLeads)
 	
-	database.DB.Model(&models.Lead{}).
-		Select("COALESCE(AVG(budget), 0)").
-		Scan(&analytics.AvgBudget)
+	analytics.AvgBudget = avgMoney(database.DB.Model(&models.Lead{}), "budget")
 
 	return c.JSON(fiber.Map{
 		"status": "success",
@@ -285,11 +287,8 @@ func (h *LeadHandler) AssignLead(c *fiber.Ctx) error {
 		AssignedToID uint `json:"assigned_to_id" validate:"required"`
 	}
 	
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Invalid request body",
-		})
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
 	}
 
 	// Verify the assigned user is sales or admin
@@ -302,8 +301,8 @@ func (h *LeadHandler) AssignLead(c *fiber.Ctx) error {
 	}
 
 	lead.AssignedToID = &req.AssignedToID
-	if lead.Status == "new" {
-		lead.Status = "assigned"
+	if lead.Status == models.LeadStatusNew {
+		lead.Status = models.LeadStatusAssigned
 	}
 
 	if err := database.DB.Save(&lead).Error; err != nil {
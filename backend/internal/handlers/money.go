@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"math"
+
+	"vehicle-sales-backend/internal/money"
+
+	"gorm.io/gorm"
+)
+
+// sumMoney totals a money column (e.g. "sale_price") over query in the
+// default currency. Rows in other currencies are not included.
+func sumMoney(query *gorm.DB, prefix string) money.Money {
+	var minor int64
+	query.Where(prefix+"_currency = ?", money.DefaultCurrency()).
+		Select("COALESCE(SUM(" + prefix + "_minor), 0)").
+		Scan(&minor)
+	return money.New(minor, money.DefaultCurrency())
+}
+
+// avgMoney averages a money column over query in the default currency,
+// rounded to the nearest minor unit.
+func avgMoney(query *gorm.DB, prefix string) money.Money {
+	var minor int64
+	query.Where(prefix+"_currency = ?", money.DefaultCurrency()).
+		Select("COALESCE(ROUND(AVG(" + prefix + "_minor)), 0)::bigint").
+		Scan(&minor)
+	return money.New(minor, money.DefaultCurrency())
+}
+
+// margin returns profit in basis points of revenue, or 0 without revenue.
+func margin(profit, revenue int64) int64 {
+	if revenue <= 0 {
+		return 0
+	}
+	return int64(math.Round(float64(profit) * 10000 / float64(revenue)))
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/middleware"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/permissions"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ownerScope reports whether the caller may only access records they own,
+// i.e. lacks the given "read all" permission, and if so the customer ID
+// those records must belong to.
+func ownerScope(c *fiber.Ctx, readAll string) (uint, bool) {
+	if middleware.HasPermission(c, readAll) {
+		return 0, false
+	}
+	return middleware.GetAuthContext(c).UserID, true
+}
+
+// scopeToCustomer restricts a query on a table with a customer_id column
+// (sales, test_drives) to the caller's own rows when ownerScope applies.
+func scopeToCustomer(c *fiber.Ctx, query *gorm.DB, readAll string) *gorm.DB {
+	if customerID, scoped := ownerScope(c, readAll); scoped {
+		return query.Where("customer_id = ?", customerID)
+	}
+	return query
+}
+
+// scopeTransactionsToCustomer restricts a transactions query to payments
+// against the caller's own sales when ownerScope applies.
+func scopeTransactionsToCustomer(c *fiber.Ctx, query *gorm.DB) *gorm.DB {
+	if customerID, scoped := ownerScope(c, permissions.TransactionReadAll); scoped {
+		return query.Where("sale_id IN (?)",
+			database.DB.Model(&models.Sale{}).Select("id").Where("customer_id = ?", customerID))
+	}
+	return query
+}
@@ -0,0 +1,390 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"vehicle-sales-backend/internal/api"
+	"vehicle-sales-backend/internal/auth"
+	"vehicle-sales-backend/internal/config"
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/middleware"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/permissions"
+
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// The ownership tests run the real routes, middleware and handlers against
+// a throwaway SQLite database; queries these handlers make are portable.
+var (
+	app *fiber.App
+	cfg = &config.Config{
+		JWT: config.JWTConfig{
+			Secret:          "ownership-test-secret",
+			AccessTokenTTL:  time.Hour,
+			RefreshTokenTTL: time.Hour,
+		},
+		Server: config.ServerConfig{IdempotencyTTL: time.Hour},
+	}
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "handlers")
+	if err != nil {
+		log.Fatal(err)
+	}
+	code := func() int {
+		defer os.RemoveAll(dir)
+
+		dsn := filepath.Join(dir, "test.db") + "?_pragma=busy_timeout(5000)"
+		database.DB, err = gorm.Open(sqlite.Open(dsn), &gorm.Config{
+			Logger:                                   logger.Discard,
+			DisableForeignKeyConstraintWhenMigrating: true,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = database.DB.AutoMigrate(
+			&models.User{}, &models.RefreshToken{}, &models.RevokedToken{}, &models.IdempotencyKey{},
+			&models.Role{}, &models.Permission{},
+			&models.Vehicle{}, &models.VehicleImage{}, &models.TestDrive{},
+			&models.Sale{}, &models.SaleLine{}, &models.SaleApproval{}, &models.TradeIn{},
+			&models.Transaction{}, &models.FinancingApplication{}, &models.FinancingOffer{},
+		)
+		if err != nil {
+			log.Fatal(err)
+		}
+		seedRoles()
+
+		app = fiber.New(fiber.Config{ErrorHandler: middleware.ErrorHandler})
+		api.SetupRoutes(app, cfg)
+		return m.Run()
+	}()
+	os.Exit(code)
+}
+
+// seedRoles grants the built-in roles their default permissions, as
+// permissions.Sync does on PostgreSQL.
+func seedRoles() {
+	for role, granted := range permissions.Defaults {
+		r := models.Role{Name: role, DisplayName: string(role), IsSystem: true}
+		for _, name := range granted {
+			var perm models.Permission
+			if err := database.DB.Where(models.Permission{Name: name}).FirstOrCreate(&perm).Error; err != nil {
+				log.Fatal(err)
+			}
+			r.Permissions = append(r.Permissions, perm)
+		}
+		if err := database.DB.Create(&r).Error; err != nil {
+			log.Fatal(err)
+		}
+	}
+	permissions.Invalidate()
+}
+
+type fixture struct {
+	customer, otherCustomer, sales, cashier *models.User
+	tokens                                  map[uint]string
+}
+
+// newFixture creates two customers, a salesperson and a cashier, and for
+// each customer a vehicle, a test drive, a sale and a payment.
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	f := &fixture{tokens: map[uint]string{}}
+	f.customer = f.user(t, models.RoleCustomer)
+	f.otherCustomer = f.user(t, models.RoleCustomer)
+	f.sales = f.user(t, models.RoleSales)
+	f.cashier = f.user(t, models.RoleCashier)
+	return f
+}
+
+func (f *fixture) user(t *testing.T, role models.UserRole) *models.User {
+	t.Helper()
+	name := fmt.Sprintf("%s-%d", role, time.Now().UnixNano())
+	user := models.User{Email: name + "@example.com", Password: "x", Name: name, Role: role, IsActive: true}
+	if err := database.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	pair, err := auth.IssueTokenPair(&user, cfg.JWT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.tokens[user.ID] = pair.AccessToken
+	return &user
+}
+
+func (f *fixture) vehicle(t *testing.T) *models.Vehicle {
+	t.Helper()
+	vehicle := models.Vehicle{
+		Make:         "Toyota",
+		Model:        "Avanza",
+		Year:         2022,
+		VIN:          fmt.Sprintf("VIN%d", time.Now().UnixNano()),
+		Price:        money.New(250_000_000_00, "IDR"),
+		PurchaseCost: money.Zero("IDR"),
+		Status:       models.VehicleStatusAvailable,
+	}
+	if err := database.DB.Create(&vehicle).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &vehicle
+}
+
+func (f *fixture) testDrive(t *testing.T, customer *models.User) *models.TestDrive {
+	t.Helper()
+	testDrive := models.TestDrive{
+		VehicleID:     f.vehicle(t).ID,
+		CustomerID:    customer.ID,
+		ScheduledTime: time.Now().Add(24 * time.Hour),
+		Status:        models.TestDriveStatusPending,
+	}
+	if err := database.DB.Create(&testDrive).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &testDrive
+}
+
+func (f *fixture) sale(t *testing.T, customer *models.User) *models.Sale {
+	t.Helper()
+	price := money.New(250_000_000_00, "IDR")
+	sale := models.Sale{
+		VehicleID:     f.vehicle(t).ID,
+		CustomerID:    customer.ID,
+		SalesPersonID: f.sales.ID,
+		AgreedPrice:   price,
+		SalePrice:     price,
+		ListPrice:     price,
+		Discount:      money.Zero("IDR"),
+		TradeIn:       money.Zero("IDR"),
+		NetRevenue:    money.Zero("IDR"),
+		VehicleCost:   money.Zero("IDR"),
+		GrossProfit:   money.Zero("IDR"),
+		Status:        models.SaleStatusApproved,
+	}
+	if err := database.DB.Create(&sale).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &sale
+}
+
+func (f *fixture) payment(t *testing.T, sale *models.Sale) *models.Transaction {
+	t.Helper()
+	transaction := models.Transaction{
+		SaleID:        sale.ID,
+		Type:          models.TransactionTypePayment,
+		Amount:        money.New(10_000_000_00, "IDR"),
+		PaymentMethod: models.PaymentMethodBankTransfer,
+		Tendered:      money.Zero("IDR"),
+		Change:        money.Zero("IDR"),
+		Status:        models.TransactionStatusCompleted,
+		ProcessedByID: f.cashier.ID,
+	}
+	if err := database.DB.Create(&transaction).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &transaction
+}
+
+// call makes a request as user and returns the status and decoded body.
+func (f *fixture) call(t *testing.T, user *models.User, method, path, body string) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(method, "/api/v1"+path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+f.tokens[user.ID])
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatalf("%s %s: invalid JSON %q", method, path, raw)
+	}
+	return resp.StatusCode, decoded
+}
+
+// ids returns the IDs of the records listed under data[key].
+func ids(t *testing.T, body map[string]interface{}, key string) map[uint]bool {
+	t.Helper()
+	data, _ := body["data"].(map[string]interface{})
+	items, ok := data[key].([]interface{})
+	if !ok {
+		t.Fatalf("no %s in %v", key, body)
+	}
+	found := map[uint]bool{}
+	for _, item := range items {
+		found[uint(item.(map[string]interface{})["id"].(float64))] = true
+	}
+	return found
+}
+
+func dataID(body map[string]interface{}) uint {
+	data, _ := body["data"].(map[string]interface{})
+	id, _ := data["id"].(float64)
+	return uint(id)
+}
+
+func TestTestDrivesAreScopedToTheirCustomer(t *testing.T) {
+	f := newFixture(t)
+	own := f.testDrive(t, f.customer)
+	foreign := f.testDrive(t, f.otherCustomer)
+
+	if status, body := f.call(t, f.customer, "GET", fmt.Sprintf("/test-drives/%d", own.ID), ""); status != 200 || dataID(body) != own.ID {
+		t.Errorf("own test drive: got %d %v", status, body)
+	}
+	if status, _ := f.call(t, f.customer, "GET", fmt.Sprintf("/test-drives/%d", foreign.ID), ""); status != 404 {
+		t.Errorf("foreign test drive: got %d, want 404", status)
+	}
+	if status, body := f.call(t, f.sales, "GET", fmt.Sprintf("/test-drives/%d", foreign.ID), ""); status != 200 || dataID(body) != foreign.ID {
+		t.Errorf("test_drive:read_all: got %d %v", status, body)
+	}
+
+	_, body := f.call(t, f.customer, "GET", "/test-drives?limit=100", "")
+	if listed := ids(t, body, "test_drives"); !listed[own.ID] || listed[foreign.ID] {
+		t.Errorf("customer list: got %v, want %d without %d", listed, own.ID, foreign.ID)
+	}
+	// filtering by another customer does not widen the scope
+	_, body = f.call(t, f.customer, "GET", fmt.Sprintf("/test-drives?customer_id=%d", f.otherCustomer.ID), "")
+	if listed := ids(t, body, "test_drives"); len(listed) != 0 {
+		t.Errorf("customer list filtered by another customer: got %v", listed)
+	}
+	_, body = f.call(t, f.sales, "GET", "/test-drives?limit=100", "")
+	if listed := ids(t, body, "test_drives"); !listed[own.ID] || !listed[foreign.ID] {
+		t.Errorf("test_drive:read_all list: got %v, want %d and %d", listed, own.ID, foreign.ID)
+	}
+}
+
+func TestTestDrivesCanOnlyBeCanceledByTheirCustomer(t *testing.T) {
+	f := newFixture(t)
+	own := f.testDrive(t, f.customer)
+	foreign := f.testDrive(t, f.otherCustomer)
+
+	if status, _ := f.call(t, f.customer, "DELETE", fmt.Sprintf("/test-drives/%d", foreign.ID), ""); status != 404 {
+		t.Errorf("cancel foreign test drive: got %d, want 404", status)
+	}
+	var unchanged models.TestDrive
+	database.DB.First(&unchanged, foreign.ID)
+	if unchanged.Status != models.TestDriveStatusPending {
+		t.Errorf("foreign test drive is %s after a rejected cancel", unchanged.Status)
+	}
+
+	if status, body := f.call(t, f.customer, "DELETE", fmt.Sprintf("/test-drives/%d", own.ID), ""); status != 200 {
+		t.Errorf("cancel own test drive: got %d %v", status, body)
+	}
+	if status, body := f.call(t, f.sales, "DELETE", fmt.Sprintf("/test-drives/%d", foreign.ID), ""); status != 200 {
+		t.Errorf("test_drive:read_all cancel: got %d %v", status, body)
+	}
+}
+
+func TestSalesAreScopedToTheirCustomer(t *testing.T) {
+	f := newFixture(t)
+	own := f.sale(t, f.customer)
+	foreign := f.sale(t, f.otherCustomer)
+
+	if status, body := f.call(t, f.customer, "GET", fmt.Sprintf("/sales/%d", own.ID), ""); status != 200 || dataID(body) != own.ID {
+		t.Errorf("own sale: got %d %v", status, body)
+	}
+	if status, _ := f.call(t, f.customer, "GET", fmt.Sprintf("/sales/%d", foreign.ID), ""); status != 404 {
+		t.Errorf("foreign sale: got %d, want 404", status)
+	}
+	for _, reader := range []*models.User{f.sales, f.cashier} {
+		if status, body := f.call(t, reader, "GET", fmt.Sprintf("/sales/%d", foreign.ID), ""); status != 200 || dataID(body) != foreign.ID {
+			t.Errorf("%s with sale:read_all: got %d %v", reader.Role, status, body)
+		}
+	}
+
+	_, body := f.call(t, f.customer, "GET", "/sales?limit=100", "")
+	if listed := ids(t, body, "sales"); !listed[own.ID] || listed[foreign.ID] {
+		t.Errorf("customer list: got %v, want %d without %d", listed, own.ID, foreign.ID)
+	}
+	_, body = f.call(t, f.customer, "GET", fmt.Sprintf("/sales?customer_id=%d", f.otherCustomer.ID), "")
+	if listed := ids(t, body, "sales"); len(listed) != 0 {
+		t.Errorf("customer list filtered by another customer: got %v", listed)
+	}
+	_, body = f.call(t, f.sales, "GET", "/sales?limit=100", "")
+	if listed := ids(t, body, "sales"); !listed[own.ID] || !listed[foreign.ID] {
+		t.Errorf("sale:read_all list: got %v, want %d and %d", listed, own.ID, foreign.ID)
+	}
+}
+
+func TestTransactionsAreScopedToTheCustomersSales(t *testing.T) {
+	f := newFixture(t)
+	own := f.payment(t, f.sale(t, f.customer))
+	foreign := f.payment(t, f.sale(t, f.otherCustomer))
+
+	if status, body := f.call(t, f.customer, "GET", fmt.Sprintf("/transactions/%d", own.ID), ""); status != 200 || dataID(body) != own.ID {
+		t.Errorf("own transaction: got %d %v", status, body)
+	}
+	if status, _ := f.call(t, f.customer, "GET", fmt.Sprintf("/transactions/%d", foreign.ID), ""); status != 404 {
+		t.Errorf("foreign transaction: got %d, want 404", status)
+	}
+	if status, body := f.call(t, f.cashier, "GET", fmt.Sprintf("/transactions/%d", foreign.ID), ""); status != 200 || dataID(body) != foreign.ID {
+		t.Errorf("transaction:read_all: got %d %v", status, body)
+	}
+
+	_, body := f.call(t, f.customer, "GET", "/transactions?limit=100", "")
+	if listed := ids(t, body, "transactions"); !listed[own.ID] || listed[foreign.ID] {
+		t.Errorf("customer list: got %v, want %d without %d", listed, own.ID, foreign.ID)
+	}
+	_, body = f.call(t, f.customer, "GET", fmt.Sprintf("/transactions?sale_id=%d", foreign.SaleID), "")
+	if listed := ids(t, body, "transactions"); len(listed) != 0 {
+		t.Errorf("customer list filtered by another customer's sale: got %v", listed)
+	}
+	_, body = f.call(t, f.cashier, "GET", "/transactions?limit=100", "")
+	if listed := ids(t, body, "transactions"); !listed[own.ID] || !listed[foreign.ID] {
+		t.Errorf("transaction:read_all list: got %v, want %d and %d", listed, own.ID, foreign.ID)
+	}
+}
+
+func TestProfileIsTheCallersOwn(t *testing.T) {
+	f := newFixture(t)
+
+	status, body := f.call(t, f.customer, "GET", "/profile", "")
+	if status != 200 || dataID(body) != f.customer.ID {
+		t.Errorf("get profile: got %d %v, want user %d", status, body, f.customer.ID)
+	}
+
+	status, body = f.call(t, f.customer, "PUT", "/profile", `{"name":"Renamed Customer"}`)
+	if status != 200 || dataID(body) != f.customer.ID {
+		t.Errorf("update profile: got %d %v", status, body)
+	}
+	var own, other models.User
+	database.DB.First(&own, f.customer.ID)
+	database.DB.First(&other, f.otherCustomer.ID)
+	if own.Name != "Renamed Customer" || other.Name == "Renamed Customer" {
+		t.Errorf("update profile renamed %q and %q", own.Name, other.Name)
+	}
+
+	// other users' profiles are only reachable through user management
+	if status, _ := f.call(t, f.customer, "GET", fmt.Sprintf("/users/%d", f.otherCustomer.ID), ""); status != 403 {
+		t.Errorf("customer reading another user: got %d, want 403", status)
+	}
+}
+
+func TestCashiersCannotFinalizeTheirShifts(t *testing.T) {
+	f := newFixture(t)
+	if status, _ := f.call(t, f.cashier, "POST", "/shifts/1/z-report", ""); status != 403 {
+		t.Errorf("cashier Z-report: got %d, want 403", status)
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"vehicle-sales-backend/internal/config"
+	"vehicle-sales-backend/internal/payments"
+	"vehicle-sales-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type PaymentHandler struct {
+	config       *config.Config
+	transactions *services.TransactionService
+}
+
+func NewPaymentHandler(config *config.Config) *PaymentHandler {
+	return &PaymentHandler{config: config, transactions: services.NewTransactionService()}
+}
+
+// HandleWebhook receives a signed payment status notification from a
+// provider and resolves the pending transaction it refers to
+func (h *PaymentHandler) HandleWebhook(c *fiber.Ctx) error {
+	name := c.Params("provider")
+	if _, ok := payments.ByName(name); !ok {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unknown payment provider",
+		})
+	}
+
+	event, err := payments.ParseWebhook(h.config.Payments.WebhookSecret, c.Get(payments.SignatureHeader), c.Body(), time.Now())
+	if err != nil {
+		status := 400
+		if errors.Is(err, payments.ErrInvalidSignature) {
+			status = 401
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid webhook",
+			"error":   err.Error(),
+		})
+	}
+
+	transaction, err := h.transactions.ResolvePayment(name, *event)
+	if err != nil {
+		return serviceError(c, err, "Failed to process webhook")
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data": fiber.Map{
+			"transaction_id": transaction.ID,
+			"status":         transaction.Status,
+		},
+	})
+}
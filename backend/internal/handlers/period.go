@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// reportPeriod reads a report's ?from and ?to dates, both inclusive, as the
+// half-open period [from, to). It defaults to the current month. The
+// message explains what is wrong with invalid dates.
+func reportPeriod(c *fiber.Ctx) (from, to time.Time, message string) {
+	now := time.Now()
+	from = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	to = from.AddDate(0, 1, 0)
+
+	if value := c.Query("from"); value != "" {
+		date, err := time.ParseInLocation("2006-01-02", value, now.Location())
+		if err != nil {
+			return from, to, "from must be a date such as 2024-01-31"
+		}
+		from = date
+	}
+
+	if value := c.Query("to"); value != "" {
+		date, err := time.ParseInLocation("2006-01-02", value, now.Location())
+		if err != nil {
+			return from, to, "to must be a date such as 2024-01-31"
+		}
+		to = date.AddDate(0, 0, 1)
+	}
+
+	if !to.After(from) {
+		return from, to, "to must not be before from"
+	}
+	return from, to, ""
+}
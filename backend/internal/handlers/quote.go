@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/permissions"
+	"vehicle-sales-backend/internal/services"
+	"vehicle-sales-backend/internal/validation"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type QuoteHandler struct {
+	quotes *services.QuoteService
+}
+
+func NewQuoteHandler() *QuoteHandler {
+	return &QuoteHandler{quotes: services.NewQuoteService()}
+}
+
+type AddOnRequest struct {
+	Description string      `json:"description" validate:"required,max=200"`
+	Price       money.Money `json:"price" validate:"gt=0"`
+}
+
+// QuoteTermsRequest are the terms of a quote revision. ListPrice defaults to
+// the vehicle's price and ValidUntil to 14 days from now.
+type QuoteTermsRequest struct {
+	ListPrice        money.Money    `json:"list_price" validate:"gte=0"`
+	Discount         money.Money    `json:"discount" validate:"gte=0"`
+	TradeInAllowance money.Money    `json:"trade_in_allowance" validate:"gte=0"`
+	AddOns           []AddOnRequest `json:"add_ons" validate:"dive"`
+	ValidUntil       *time.Time     `json:"valid_until"`
+	Notes            string         `json:"notes"`
+}
+
+type CreateQuoteRequest struct {
+	VehicleID     uint `json:"vehicle_id" validate:"required"`
+	CustomerID    uint `json:"customer_id" validate:"required"`
+	SalesPersonID uint `json:"sales_person_id" validate:"required"`
+	QuoteTermsRequest
+}
+
+// AcceptQuoteRequest names the revision the customer agreed to
+type AcceptQuoteRequest struct {
+	Revision int `json:"revision" validate:"required,min=1"`
+}
+
+type DeclineQuoteRequest struct {
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+// GetQuotes lists quotes, newest first. Customers see their own quotes
+// once they have been sent
+func (h *QuoteHandler) GetQuotes(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
+	status := c.Query("status")
+	vehicleID := c.Query("vehicle_id")
+	customerID := c.Query("customer_id")
+	salesPersonID := c.Query("sales_person_id")
+
+	offset := (page - 1) * limit
+
+	query := scopeQuotes(c, database.DB.Model(&models.Quote{})).
+		Preload("Vehicle").
+		Preload("Customer").
+		Preload("SalesPerson")
+
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if vehicleID != "" {
+		query = query.Where("vehicle_id = ?", vehicleID)
+	}
+
+	if customerID != "" {
+		query = query.Where("customer_id = ?", customerID)
+	}
+
+	if salesPersonID != "" {
+		query = query.Where("sales_person_id = ?", salesPersonID)
+	}
+
+	var quotes []models.Quote
+	var total int64
+
+	query.Count(&total)
+
+	if err := query.Offset(offset).Limit(limit).Order("created_at DESC").Find(&quotes).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve quotes",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data": fiber.Map{
+			"quotes": quotes,
+			"pagination": fiber.Map{
+				"page":  page,
+				"limit": limit,
+				"total": total,
+				"pages": (total + int64(limit) - 1) / int64(limit),
+			},
+		},
+	})
+}
+
+// GetQuote retrieves a quote with all of its revisions, latest first
+func (h *QuoteHandler) GetQuote(c *fiber.Ctx) error {
+	var quote models.Quote
+	if err := scopeQuotes(c, database.DB).
+		Preload("Vehicle").
+		Preload("Customer").
+		Preload("SalesPerson").
+		Preload("Revisions", func(db *gorm.DB) *gorm.DB { return db.Order("revision DESC") }).
+		Preload("Revisions.Lines", linesInOrder).
+		First(&quote, idParam(c)).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Quote not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   quote,
+	})
+}
+
+// GetQuotePDF renders the latest revision of a quote as a PDF quotation
+func (h *QuoteHandler) GetQuotePDF(c *fiber.Ctx) error {
+	var quote models.Quote
+	if err := scopeQuotes(c, database.DB).First(&quote, idParam(c)).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Quote not found",
+		})
+	}
+
+	pdf, err := h.quotes.QuotePDF(quote.ID)
+	if err != nil {
+		return serviceError(c, err, "Failed to render quote")
+	}
+	return sendQuotePDF(c, &quote, pdf)
+}
+
+// CreateQuote prepares a draft quote for a customer
+func (h *QuoteHandler) CreateQuote(c *fiber.Ctx) error {
+	var req CreateQuoteRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	quote, err := h.quotes.CreateQuote(actor(c), services.CreateQuoteInput{
+		VehicleID:     req.VehicleID,
+		CustomerID:    req.CustomerID,
+		SalesPersonID: req.SalesPersonID,
+		Terms:         req.terms(),
+	})
+	if err != nil {
+		return serviceError(c, err, "Failed to create quote")
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"status":  "success",
+		"data":    quote,
+		"message": "Quote created successfully",
+	})
+}
+
+// ReviseQuote offers new terms as the quote's next revision
+func (h *QuoteHandler) ReviseQuote(c *fiber.Ctx) error {
+	var req QuoteTermsRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	quote, err := h.quotes.ReviseQuote(actor(c), idParam(c), req.terms())
+	if err != nil {
+		return serviceError(c, err, "Failed to revise quote")
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"status":  "success",
+		"data":    quote,
+		"message": "Quote revised successfully",
+	})
+}
+
+// SendQuote offers a draft quote to the customer. The response carries the
+// token of the quote's shared link
+func (h *QuoteHandler) SendQuote(c *fiber.Ctx) error {
+	quote, token, err := h.quotes.SendQuote(actor(c), idParam(c))
+	if err != nil {
+		return serviceError(c, err, "Failed to send quote")
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data": fiber.Map{
+			"quote":       quote,
+			"share_token": token,
+		},
+		"message": "Quote sent successfully",
+	})
+}
+
+// AcceptQuote records the customer's acceptance of a revision of the quote
+func (h *QuoteHandler) AcceptQuote(c *fiber.Ctx) error {
+	var req AcceptQuoteRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	var quote models.Quote
+	if err := scopeQuotes(c, database.DB).First(&quote, idParam(c)).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Quote not found",
+		})
+	}
+
+	accepted, err := h.quotes.AcceptQuote(actor(c), quote.ID, req.Revision)
+	if err != nil {
+		return serviceError(c, err, "Failed to accept quote")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"data":    accepted,
+		"message": "Quote accepted successfully",
+	})
+}
+
+// DeclineQuote records that the customer turned the quote down
+func (h *QuoteHandler) DeclineQuote(c *fiber.Ctx) error {
+	var req DeclineQuoteRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	var quote models.Quote
+	if err := scopeQuotes(c, database.DB).First(&quote, idParam(c)).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Quote not found",
+		})
+	}
+
+	declined, err := h.quotes.DeclineQuote(actor(c), quote.ID, req.Reason)
+	if err != nil {
+		return serviceError(c, err, "Failed to decline quote")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"data":    declined,
+		"message": "Quote declined",
+	})
+}
+
+// ConvertQuote turns an accepted quote into a pending sale with the same
+// line items, reserving the vehicle
+func (h *QuoteHandler) ConvertQuote(c *fiber.Ctx) error {
+	sale, err := h.quotes.ConvertQuote(actor(c), idParam(c))
+	if err != nil {
+		return serviceError(c, err, "Failed to convert quote")
+	}
+
+	database.DB.Preload("Vehicle").
+		Preload("Customer").
+		Preload("SalesPerson").
+		Preload("Lines", linesInOrder).
+		First(sale, sale.ID)
+
+	return c.Status(201).JSON(fiber.Map{
+		"status":  "success",
+		"data":    sale,
+		"message": "Quote converted into a sale",
+	})
+}
+
+// GetSharedQuote shows a sent quote to the holder of its shared link
+func (h *QuoteHandler) GetSharedQuote(c *fiber.Ctx) error {
+	quote, err := h.quotes.SharedQuote(c.Params("token"))
+	if err != nil {
+		return serviceError(c, err, "Failed to retrieve quote")
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   quote,
+	})
+}
+
+// GetSharedQuotePDF renders a sent quote for the holder of its shared link
+func (h *QuoteHandler) GetSharedQuotePDF(c *fiber.Ctx) error {
+	quote, err := h.quotes.SharedQuote(c.Params("token"))
+	if err != nil {
+		return serviceError(c, err, "Failed to retrieve quote")
+	}
+
+	pdf, err := h.quotes.QuotePDF(quote.ID)
+	if err != nil {
+		return serviceError(c, err, "Failed to render quote")
+	}
+	return sendQuotePDF(c, quote, pdf)
+}
+
+// AcceptSharedQuote lets the customer accept a quote through its shared link
+func (h *QuoteHandler) AcceptSharedQuote(c *fiber.Ctx) error {
+	var req AcceptQuoteRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	quote, err := h.quotes.AcceptSharedQuote(c.Params("token"), req.Revision)
+	if err != nil {
+		return serviceError(c, err, "Failed to accept quote")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"data":    quote,
+		"message": "Quote accepted successfully",
+	})
+}
+
+// DeclineSharedQuote lets the customer decline a quote through its shared
+// link
+func (h *QuoteHandler) DeclineSharedQuote(c *fiber.Ctx) error {
+	var req DeclineQuoteRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	quote, err := h.quotes.DeclineSharedQuote(c.Params("token"), req.Reason)
+	if err != nil {
+		return serviceError(c, err, "Failed to decline quote")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"data":    quote,
+		"message": "Quote declined",
+	})
+}
+
+// scopeQuotes restricts a quotes query to the caller's own quotes, and hides
+// drafts, when they may not read all quotes.
+func scopeQuotes(c *fiber.Ctx, query *gorm.DB) *gorm.DB {
+	if customerID, scoped := ownerScope(c, permissions.QuoteReadAll); scoped {
+		return query.Where("customer_id = ? AND status <> ?", customerID, models.QuoteStatusDraft)
+	}
+	return query
+}
+
+func sendQuotePDF(c *fiber.Ctx, quote *models.Quote, pdf []byte) error {
+	c.Set(fiber.HeaderContentType, "application/pdf")
+	c.Set(fiber.HeaderContentDisposition, `inline; filename="`+quote.Number+`-R`+strconv.Itoa(quote.Revision)+`.pdf"`)
+	return c.Send(pdf)
+}
+
+func (req QuoteTermsRequest) terms() services.QuoteTerms {
+	terms := services.QuoteTerms{
+		ListPrice:        req.ListPrice,
+		Discount:         req.Discount,
+		TradeInAllowance: req.TradeInAllowance,
+		ValidUntil:       req.ValidUntil,
+		Notes:            req.Notes,
+	}
+	for _, addOn := range req.AddOns {
+		terms.AddOns = append(terms.AddOns, services.AddOn{Description: addOn.Description, Price: addOn.Price})
+	}
+	return terms
+}
@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/permissions"
+	"vehicle-sales-backend/internal/validation"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type RoleHandler struct{}
+
+func NewRoleHandler() *RoleHandler {
+	return &RoleHandler{}
+}
+
+type CreateRoleRequest struct {
+	Name        models.UserRole `json:"name" validate:"required,slug,max=50"`
+	DisplayName string          `json:"display_name" validate:"required"`
+	Description string          `json:"description"`
+	Permissions []string        `json:"permissions"`
+	// MaxDiscountRate is in basis points of the vehicle's price; defaults to
+	// no discount without approval
+	MaxDiscountRate *int64 `json:"max_discount_rate" validate:"omitempty,min=0,max=10000"`
+}
+
+type UpdateRoleRequest struct {
+	DisplayName string `json:"display_name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// SetDiscountLimitRequest sets how large a discount, in basis points of the
+// vehicle's price, the role may give or approve; null removes the limit
+type SetDiscountLimitRequest struct {
+	MaxDiscountRate *int64 `json:"max_discount_rate" validate:"omitempty,min=0,max=10000"`
+}
+
+type SetRolePermissionsRequest struct {
+	Permissions []string `json:"permissions" validate:"required"`
+}
+
+// GetPermissions lists every permission known to the application
+func (h *RoleHandler) GetPermissions(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   permissions.Registry,
+	})
+}
+
+// GetRoles lists roles with their granted permissions
+func (h *RoleHandler) GetRoles(c *fiber.Ctx) error {
+	var roles []models.Role
+	if err := database.DB.Preload("Permissions").Order("is_system DESC, name ASC").Find(&roles).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve roles",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   roles,
+	})
+}
+
+// GetRole retrieves a single role by name
+func (h *RoleHandler) GetRole(c *fiber.Ctx) error {
+	var role models.Role
+	if err := database.DB.Preload("Permissions").Where("name = ?", c.Params("name")).First(&role).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Role not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   role,
+	})
+}
+
+// CreateRole creates a custom role such as "sales_manager"
+func (h *RoleHandler) CreateRole(c *fiber.Ctx) error {
+	var req CreateRoleRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	if permissions.RoleExists(req.Name) {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Role already exists",
+		})
+	}
+
+	perms, ok := lookupPermissions(req.Permissions)
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unknown permission",
+		})
+	}
+
+	role := models.Role{
+		Name:            req.Name,
+		DisplayName:     req.DisplayName,
+		Description:     req.Description,
+		MaxDiscountRate: req.MaxDiscountRate,
+		Permissions:     perms,
+	}
+	if role.MaxDiscountRate == nil {
+		var none int64
+		role.MaxDiscountRate = &none
+	}
+
+	if err := database.DB.Create(&role).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to create role",
+			"error":   err.Error(),
+		})
+	}
+
+	permissions.Invalidate()
+
+	return c.Status(201).JSON(fiber.Map{
+		"status": "success",
+		"data":   role,
+	})
+}
+
+// UpdateRole updates a role's display name and description
+func (h *RoleHandler) UpdateRole(c *fiber.Ctx) error {
+	var role models.Role
+	if err := database.DB.Where("name = ?", c.Params("name")).First(&role).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Role not found",
+		})
+	}
+
+	var req UpdateRoleRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	if req.DisplayName != "" {
+		role.DisplayName = req.DisplayName
+	}
+	if req.Description != "" {
+		role.Description = req.Description
+	}
+
+	if err := database.DB.Save(&role).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to update role",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   role,
+	})
+}
+
+// SetRolePermissions replaces the permissions granted to a role
+func (h *RoleHandler) SetRolePermissions(c *fiber.Ctx) error {
+	var role models.Role
+	if err := database.DB.Where("name = ?", c.Params("name")).First(&role).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Role not found",
+		})
+	}
+
+	// Admins implicitly hold every permission
+	if role.Name == models.RoleAdmin {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Admin permissions cannot be changed",
+		})
+	}
+
+	var req SetRolePermissionsRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	perms, ok := lookupPermissions(req.Permissions)
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unknown permission",
+		})
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return tx.Model(&role).Association("Permissions").Replace(perms)
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to update role permissions",
+			"error":   err.Error(),
+		})
+	}
+
+	permissions.Invalidate()
+
+	database.DB.Preload("Permissions").First(&role, role.ID)
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   role,
+	})
+}
+
+// SetDiscountLimit changes the discount a role may give without approval,
+// which is also the largest discount it may approve
+func (h *RoleHandler) SetDiscountLimit(c *fiber.Ctx) error {
+	var role models.Role
+	if err := database.DB.Where("name = ?", c.Params("name")).First(&role).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Role not found",
+		})
+	}
+
+	// Admins are never limited
+	if role.Name == models.RoleAdmin {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Admin discount limit cannot be changed",
+		})
+	}
+
+	var req SetDiscountLimitRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	if err := database.DB.Model(&role).Update("max_discount_rate", req.MaxDiscountRate).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to update discount limit",
+			"error":   err.Error(),
+		})
+	}
+	role.MaxDiscountRate = req.MaxDiscountRate
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   role,
+	})
+}
+
+// DeleteRole deletes a custom role that is no longer assigned to any user
+func (h *RoleHandler) DeleteRole(c *fiber.Ctx) error {
+	var role models.Role
+	if err := database.DB.Where("name = ?", c.Params("name")).First(&role).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Role not found",
+		})
+	}
+
+	if role.IsSystem {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Built-in roles cannot be deleted",
+		})
+	}
+
+	var assigned int64
+	database.DB.Model(&models.User{}).Where("role = ?", role.Name).Count(&assigned)
+	if assigned > 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Role is still assigned to users",
+		})
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(&role).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to delete role",
+			"error":   err.Error(),
+		})
+	}
+
+	permissions.Invalidate()
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Role deleted successfully",
+	})
+}
+
+// lookupPermissions loads the named permissions, reporting false if any name
+// is not in the registry.
+func lookupPermissions(names []string) ([]models.Permission, bool) {
+	perms := []models.Permission{}
+	if len(names) == 0 {
+		return perms, true
+	}
+
+	for _, name := range names {
+		if !permissions.IsRegistered(name) {
+			return nil, false
+		}
+	}
+
+	if err := database.DB.Where("name IN ?", names).Find(&perms).Error; err != nil {
+		return nil, false
+	}
+	return perms, true
+}
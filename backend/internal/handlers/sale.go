@@ -2,32 +2,56 @@ package handlers
 
 import (
 	"strconv"
-	"time"
 
 	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/middleware"
 	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/permissions"
+	"vehicle-sales-backend/internal/services"
+	"vehicle-sales-backend/internal/validation"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
-type SaleHandler struct{}
+type SaleHandler struct {
+	sales *services.SaleService
+}
 
 func NewSaleHandler() *SaleHandler {
-	return &SaleHandler{}
+	return &SaleHandler{sales: services.NewSaleService()}
 }
 
+// SalePrice is the price agreed with the customer. The sale's sale_price is
+// the total due once taxes and fees not included in it are added.
 type CreateSaleRequest struct {
 	VehicleID     uint    `json:"vehicle_id" validate:"required"`
 	CustomerID    uint    `json:"customer_id" validate:"required"`
 	SalesPersonID uint    `json:"sales_person_id" validate:"required"`
-	SalePrice     float64 `json:"sale_price" validate:"required,min=0"`
+	SalePrice     money.Money `json:"sale_price" validate:"required,gt=0"`
 	Notes         string  `json:"notes"`
 }
 
+// DiscountDecisionRequest approves or rejects a sale's discount; a comment
+// is required to reject it
+type DiscountDecisionRequest struct {
+	Comment string `json:"comment" validate:"max=1000"`
+}
+
 type UpdateSaleRequest struct {
-	SalePrice float64              `json:"sale_price,omitempty"`
-	Status    models.SaleStatus    `json:"status,omitempty"`
+	SalePrice money.Money          `json:"sale_price,omitempty" validate:"omitempty,gt=0"`
+	Status    models.SaleStatus    `json:"status,omitempty" validate:"omitempty,enum"`
 	Notes     string               `json:"notes,omitempty"`
+	Version   uint                 `json:"version,omitempty"`
+}
+
+func linesInOrder(db *gorm.DB) *gorm.DB {
+	return db.Order("position")
+}
+
+func approvalsInOrder(db *gorm.DB) *gorm.DB {
+	return db.Order("created_at, id")
 }
 
 // GetSales retrieves sales with filtering and pagination
@@ -45,6 +69,8 @@ func (h *SaleHandler) GetSales(c *fiber.Ctx) error {
 		Preload("Customer").
 		Preload("SalesPerson")
 
+	query = scopeToCustomer(c, query, permissions.SaleReadAll)
+
 	if status != "" {
 		query = query.Where("status = ?", status)
 	}
@@ -89,15 +115,24 @@ func (h *SaleHandler) GetSale(c *fiber.Ctx) error {
 	id := c.Params("id")
 	
 	var sale models.Sale
-	if err := database.DB.Preload("Vehicle").
+	if err := scopeToCustomer(c, database.DB, permissions.SaleReadAll).
+		Preload("Vehicle").
 		Preload("Customer").
 		Preload("SalesPerson").
+		Preload("Lines", linesInOrder).
+		Preload("Approvals", approvalsInOrder).
+		Preload("Approvals.User").
+		Preload("TradeIns").
 		First(&sale, id).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Sale not found",
 		})
 	}
+	sale.Balance, _ = h.sales.Balance(sale.ID)
+	if middleware.HasPermission(c, permissions.VehicleCost) {
+		sale.Profit = saleProfit(&sale)
+	}
 
 	return c.JSON(fiber.Map{
 		"status": "success",
@@ -105,75 +140,60 @@ func (h *SaleHandler) GetSale(c *fiber.Ctx) error {
 	})
 }
 
-// CreateSale creates a new sale
-func (h *SaleHandler) CreateSale(c *fiber.Ctx) error {
-	var req CreateSaleRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Invalid request body",
-		})
-	}
-
-	// Verify vehicle exists and is available
-	var vehicle models.Vehicle
-	if err := database.DB.First(&vehicle, req.VehicleID).Error; err != nil {
+// GetSaleBalance returns a sale's payment ledger: the amount due, paid and
+// outstanding and the transactions behind them
+func (h *SaleHandler) GetSaleBalance(c *fiber.Ctx) error {
+	var sale models.Sale
+	if err := scopeToCustomer(c, database.DB, permissions.SaleReadAll).First(&sale, idParam(c)).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{
 			"status":  "error",
-			"message": "Vehicle not found",
+			"message": "Sale not found",
 		})
 	}
 
-	if vehicle.Status != models.VehicleStatusAvailable {
-		return c.Status(400).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Vehicle is not available for sale",
-		})
+	balance, err := h.sales.Balance(sale.ID)
+	if err != nil {
+		return serviceError(c, err, "Failed to retrieve sale balance")
 	}
 
-	// Verify customer exists
-	var customer models.User
-	if err := database.DB.Where("id = ? AND role = ?", req.CustomerID, models.RoleCustomer).First(&customer).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Customer not found",
-		})
+	var transactions []models.Transaction
+	if err := database.DB.Where("sale_id = ?", sale.ID).Order("created_at").Find(&transactions).Error; err != nil {
+		return serviceError(c, err, "Failed to retrieve sale balance")
 	}
 
-	// Verify sales person exists
-	var salesPerson models.User
-	if err := database.DB.Where("id = ? AND role = ?", req.SalesPersonID, models.RoleSales).First(&salesPerson).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Sales person not found",
-		})
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data": fiber.Map{
+			"balance":      balance,
+			"transactions": transactions,
+		},
+	})
+}
+
+// CreateSale creates a new sale
+func (h *SaleHandler) CreateSale(c *fiber.Ctx) error {
+	var req CreateSaleRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
 	}
 
-	sale := models.Sale{
+	sale, err := h.sales.CreateSale(actor(c), services.CreateSaleInput{
 		VehicleID:     req.VehicleID,
 		CustomerID:    req.CustomerID,
 		SalesPersonID: req.SalesPersonID,
 		SalePrice:     req.SalePrice,
-		Status:        models.SaleStatusPending,
 		Notes:         req.Notes,
+	})
+	if err != nil {
+		return serviceError(c, err, "Failed to create sale")
 	}
 
-	if err := database.DB.Create(&sale).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Failed to create sale",
-			"error":   err.Error(),
-		})
-	}
-
-	// Update vehicle status to reserved
-	database.DB.Model(&vehicle).Update("status", models.VehicleStatusReserved)
-
 	// Load relationships for response
 	database.DB.Preload("Vehicle").
 		Preload("Customer").
 		Preload("SalesPerson").
-		First(&sale, sale.ID)
+		Preload("Lines", linesInOrder).
+		First(sale, sale.ID)
 
 	return c.Status(201).JSON(fiber.Map{
 		"status": "success",
@@ -183,60 +203,27 @@ func (h *SaleHandler) CreateSale(c *fiber.Ctx) error {
 
 // UpdateSale updates an existing sale
 func (h *SaleHandler) UpdateSale(c *fiber.Ctx) error {
-	id := c.Params("id")
-	
-	var sale models.Sale
-	if err := database.DB.First(&sale, id).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Sale not found",
-		})
-	}
-
 	var req UpdateSaleRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Invalid request body",
-		})
-	}
-
-	// Update fields if provided
-	if req.SalePrice > 0 {
-		sale.SalePrice = req.SalePrice
-	}
-	if req.Status != "" {
-		sale.Status = req.Status
-		
-		// If completed, set completed timestamp and update vehicle status
-		if req.Status == models.SaleStatusCompleted {
-			now := time.Now()
-			sale.CompletedAt = &now
-			
-			// Update vehicle status to sold
-			database.DB.Model(&models.Vehicle{}).Where("id = ?", sale.VehicleID).Update("status", models.VehicleStatusSold)
-		} else if req.Status == models.SaleStatusCanceled {
-			// If canceled, make vehicle available again
-			database.DB.Model(&models.Vehicle{}).Where("id = ?", sale.VehicleID).Update("status", models.VehicleStatusAvailable)
-		}
-	}
-	if req.Notes != "" {
-		sale.Notes = req.Notes
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
 	}
 
-	if err := database.DB.Save(&sale).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Failed to update sale",
-			"error":   err.Error(),
-		})
+	sale, err := h.sales.UpdateSale(actor(c), idParam(c), services.UpdateSaleInput{
+		SalePrice: req.SalePrice,
+		Status:    req.Status,
+		Notes:     req.Notes,
+		Version:   req.Version,
+	})
+	if err != nil {
+		return serviceError(c, err, "Failed to update sale")
 	}
 
 	// Load relationships for response
 	database.DB.Preload("Vehicle").
 		Preload("Customer").
 		Preload("SalesPerson").
-		First(&sale, sale.ID)
+		Preload("Lines", linesInOrder).
+		First(sale, sale.ID)
 
 	return c.JSON(fiber.Map{
 		"status": "success",
@@ -244,35 +231,59 @@ func (h *SaleHandler) UpdateSale(c *fiber.Ctx) error {
 	})
 }
 
-// DeleteSale deletes a sale
-func (h *SaleHandler) DeleteSale(c *fiber.Ctx) error {
-	id := c.Params("id")
-	
-	var sale models.Sale
-	if err := database.DB.First(&sale, id).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Sale not found",
-		})
+// ApproveSaleDiscount lets a sale awaiting approval go ahead at its discount
+func (h *SaleHandler) ApproveSaleDiscount(c *fiber.Ctx) error {
+	var req DiscountDecisionRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	sale, err := h.sales.ApproveDiscount(actor(c), idParam(c), req.Comment)
+	if err != nil {
+		return serviceError(c, err, "Failed to approve discount")
 	}
 
-	// Only allow deletion of pending sales
-	if sale.Status != models.SaleStatusPending {
+	database.DB.Preload("Approvals", approvalsInOrder).Preload("Approvals.User").First(sale, sale.ID)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"data":    sale,
+		"message": "Discount approved",
+	})
+}
+
+// RejectSaleDiscount turns down the discount of a sale awaiting approval,
+// canceling the sale
+func (h *SaleHandler) RejectSaleDiscount(c *fiber.Ctx) error {
+	var req DiscountDecisionRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+	if req.Comment == "" {
 		return c.Status(400).JSON(fiber.Map{
 			"status":  "error",
-			"message": "Can only delete pending sales",
+			"message": "A comment is required to reject a discount",
 		})
 	}
 
-	// Make vehicle available again
-	database.DB.Model(&models.Vehicle{}).Where("id = ?", sale.VehicleID).Update("status", models.VehicleStatusAvailable)
+	sale, err := h.sales.RejectDiscount(actor(c), idParam(c), req.Comment)
+	if err != nil {
+		return serviceError(c, err, "Failed to reject discount")
+	}
 
-	if err := database.DB.Delete(&sale).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Failed to delete sale",
-			"error":   err.Error(),
-		})
+	database.DB.Preload("Approvals", approvalsInOrder).Preload("Approvals.User").First(sale, sale.ID)
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"data":    sale,
+		"message": "Discount rejected",
+	})
+}
+
+// DeleteSale deletes a sale
+func (h *SaleHandler) DeleteSale(c *fiber.Ctx) error {
+	if err := h.sales.DeleteSale(idParam(c)); err != nil {
+		return serviceError(c, err, "Failed to delete sale")
 	}
 
 	return c.JSON(fiber.Map{
@@ -287,26 +298,87 @@ func (h *SaleHandler) GetSalesAnalytics(c *fiber.Ctx) error {
 		TotalSales     int64   `json:"total_sales"`
 		CompletedSales int64   `json:"completed_sales"`
 		PendingSales   int64   `json:"pending_sales"`
-		TotalRevenue   float64 `json:"total_revenue"`
-		AvgSalePrice   float64 `json:"avg_sale_price"`
+		AwaitingApproval int64 `json:"awaiting_approval"`
+		TotalRevenue   money.Money `json:"total_revenue"`
+		AvgSalePrice   money.Money `json:"avg_sale_price"`
+		// TotalRevenue split into what the dealership keeps and the taxes and
+		// fees collected on behalf of others
+		NetRevenue     money.Money `json:"net_revenue"`
+		TotalTaxes     money.Money `json:"total_taxes"`
+		TotalFees      money.Money `json:"total_fees"`
+		// TotalDiscounts is how far completed sales sold their vehicles
+		// below the vehicles' prices
+		TotalDiscounts money.Money `json:"total_discounts"`
+		// TotalTradeIns is the credit completed sales gave for vehicles
+		// taken in trade; TotalRevenue is net of it
+		TradeInSales   int64       `json:"trade_in_sales"`
+		TotalTradeIns  money.Money `json:"total_trade_ins"`
+		// NetRevenue less what the vehicles sold cost, for callers who may
+		// see vehicle costs. Margin is in basis points of NetRevenue
+		VehicleCosts   *money.Money `json:"vehicle_costs,omitempty"`
+		GrossProfit    *money.Money `json:"gross_profit,omitempty"`
+		Margin         *int64       `json:"margin,omitempty"`
 	}
 
 	database.DB.Model(&models.Sale{}).Count(&analytics.TotalSales)
 	database.DB.Model(&models.Sale{}).Where("status = ?", models.SaleStatusCompleted).Count(&analytics.CompletedSales)
 	database.DB.Model(&models.Sale{}).Where("status = ?", models.SaleStatusPending).Count(&analytics.PendingSales)
+	database.DB.Model(&models.Sale{}).Where("status = ?", models.SaleStatusAwaitingApproval).Count(&analytics.AwaitingApproval)
 	
-	database.DB.Model(&models.Sale{}).
-		Where("status = ?", models.SaleStatusCompleted).
-		Select("COALESCE(SUM(sale_price), 0)").
-		Scan(&analytics.TotalRevenue)
+	analytics.TotalRevenue = sumMoney(database.DB.Model(&models.Sale{}).
+		Where("status = ?", models.SaleStatusCompleted), "sale_price")
 	
+	analytics.AvgSalePrice = avgMoney(database.DB.Model(&models.Sale{}).
+		Where("status = ?", models.SaleStatusCompleted), "sale_price")
+
+	completedLines := func() *gorm.DB {
+		return database.DB.Model(&models.SaleLine{}).
+			Joins("JOIN sales ON sales.id = sale_lines.sale_id AND sales.deleted_at IS NULL").
+			Where("sales.status = ?", models.SaleStatusCompleted)
+	}
+	analytics.TotalTaxes = sumMoney(completedLines().Where("sale_lines.type = ?", models.LineTypeTax), "sale_lines.amount")
+	analytics.TotalFees = sumMoney(completedLines().Where("sale_lines.type = ?", models.LineTypeFee), "sale_lines.amount")
+	// the vehicle, discounts and add-ons less the taxes and fees included in
+	// their prices
+	agreed := sumMoney(completedLines().Where("sale_lines.type IN ?",
+		[]models.LineType{models.LineTypeVehicle, models.LineTypeDiscount, models.LineTypeAddOn}), "sale_lines.amount")
+	included := sumMoney(completedLines().Where("sale_lines.inclusive"), "sale_lines.amount")
+	analytics.NetRevenue, _ = agreed.Sub(included)
+	analytics.TotalDiscounts = sumMoney(database.DB.Model(&models.Sale{}).
+		Where("status = ?", models.SaleStatusCompleted), "discount")
 	database.DB.Model(&models.Sale{}).
-		Where("status = ?", models.SaleStatusCompleted).
-		Select("COALESCE(AVG(sale_price), 0)").
-		Scan(&analytics.AvgSalePrice)
+		Where("status = ? AND trade_in_minor > 0", models.SaleStatusCompleted).
+		Count(&analytics.TradeInSales)
+	analytics.TotalTradeIns = sumMoney(database.DB.Model(&models.Sale{}).
+		Where("status = ?", models.SaleStatusCompleted), "trade_in")
+
+	if middleware.HasPermission(c, permissions.VehicleCost) {
+		costs := sumMoney(database.DB.Model(&models.Sale{}).
+			Where("status = ?", models.SaleStatusCompleted), "vehicle_cost")
+		profit := sumMoney(database.DB.Model(&models.Sale{}).
+			Where("status = ?", models.SaleStatusCompleted), "gross_profit")
+		revenue := sumMoney(database.DB.Model(&models.Sale{}).
+			Where("status = ?", models.SaleStatusCompleted), "net_revenue")
+		rate := margin(profit.Minor, revenue.Minor)
+		analytics.VehicleCosts, analytics.GrossProfit, analytics.Margin = &costs, &profit, &rate
+	}
 
 	return c.JSON(fiber.Map{
 		"status": "success",
 		"data":   analytics,
 	})
+}
+
+// saleProfit reports the gross profit fixed when a sale completed, or nil
+// for sales that have not completed
+func saleProfit(sale *models.Sale) *models.SaleProfit {
+	if sale.Status != models.SaleStatusCompleted {
+		return nil
+	}
+	return &models.SaleProfit{
+		Revenue:     sale.NetRevenue,
+		VehicleCost: sale.VehicleCost,
+		GrossProfit: sale.GrossProfit,
+		Margin:      margin(sale.GrossProfit.Minor, sale.NetRevenue.Minor),
+	}
 }
\ No newline at end of file
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"strconv"
+
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/permissions"
+	"vehicle-sales-backend/internal/services"
+	"vehicle-sales-backend/internal/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ShiftHandler struct {
+	shifts *services.ShiftService
+}
+
+func NewShiftHandler() *ShiftHandler {
+	return &ShiftHandler{shifts: services.NewShiftService()}
+}
+
+type OpenShiftRequest struct {
+	OpeningFloat money.Money `json:"opening_float" validate:"gte=0"`
+	Notes        string      `json:"notes"`
+}
+
+type ShiftCountRequest struct {
+	PaymentMethod models.PaymentMethod `json:"payment_method" validate:"required,enum"`
+	Counted       money.Money          `json:"counted" validate:"gte=0"`
+}
+
+type CloseShiftRequest struct {
+	Counts []ShiftCountRequest `json:"counts" validate:"required,min=1,dive"`
+	Notes  string              `json:"notes"`
+}
+
+// GetShifts lists shifts, newest first. Cashiers see their own shifts only
+func (h *ShiftHandler) GetShifts(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
+	status := c.Query("status")
+	cashierID := c.Query("cashier_id")
+
+	offset := (page - 1) * limit
+
+	query := database.DB.Model(&models.Shift{}).Preload("Cashier")
+	if ownID, scoped := ownerScope(c, permissions.ShiftManage); scoped {
+		query = query.Where("cashier_id = ?", ownID)
+	}
+
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if cashierID != "" {
+		query = query.Where("cashier_id = ?", cashierID)
+	}
+
+	var shifts []models.Shift
+	var total int64
+
+	query.Count(&total)
+
+	if err := query.Offset(offset).Limit(limit).Order("opened_at DESC").Find(&shifts).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve shifts",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data": fiber.Map{
+			"shifts": shifts,
+			"pagination": fiber.Map{
+				"page":  page,
+				"limit": limit,
+				"total": total,
+				"pages": (total + int64(limit) - 1) / int64(limit),
+			},
+		},
+	})
+}
+
+// GetCurrentShift returns the caller's open shift
+func (h *ShiftHandler) GetCurrentShift(c *fiber.Ctx) error {
+	shift, err := h.shifts.CurrentShift(actor(c))
+	if err != nil {
+		return serviceError(c, err, "Failed to retrieve shift")
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   shift,
+	})
+}
+
+// OpenShift starts a shift for the caller with the cash counted into the
+// drawer
+func (h *ShiftHandler) OpenShift(c *fiber.Ctx) error {
+	var req OpenShiftRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	shift, err := h.shifts.OpenShift(actor(c), services.OpenShiftInput{
+		OpeningFloat: req.OpeningFloat,
+		Notes:        req.Notes,
+	})
+	if err != nil {
+		return serviceError(c, err, "Failed to open shift")
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"status":  "success",
+		"data":    shift,
+		"message": "Shift opened successfully",
+	})
+}
+
+// GetShiftReport returns the close-out report of a shift; for an open shift
+// it shows the takings so far
+func (h *ShiftHandler) GetShiftReport(c *fiber.Ctx) error {
+	report, err := h.shifts.Report(actor(c), idParam(c))
+	if err != nil {
+		return serviceError(c, err, "Failed to build shift report")
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   report,
+	})
+}
+
+// CloseShift closes a shift with the amounts counted per payment method and
+// returns the close-out report with the variances
+func (h *ShiftHandler) CloseShift(c *fiber.Ctx) error {
+	var req CloseShiftRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	counts := map[models.PaymentMethod]money.Money{}
+	for _, count := range req.Counts {
+		if _, ok := counts[count.PaymentMethod]; ok {
+			return c.Status(400).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Each payment method may only be counted once",
+			})
+		}
+		counts[count.PaymentMethod] = count.Counted
+	}
+
+	report, err := h.shifts.CloseShift(actor(c), idParam(c), services.CloseShiftInput{
+		Counts: counts,
+		Notes:  req.Notes,
+	})
+	if err != nil {
+		return serviceError(c, err, "Failed to close shift")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"data":    report,
+		"message": "Shift closed successfully",
+	})
+}
+
+// FinalizeShift runs the Z-report of a closed shift, locking its
+// transactions
+func (h *ShiftHandler) FinalizeShift(c *fiber.Ctx) error {
+	report, err := h.shifts.FinalizeShift(actor(c), idParam(c))
+	if err != nil {
+		return serviceError(c, err, "Failed to finalize shift")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"data":    report,
+		"message": "Z-report completed; the shift is locked",
+	})
+}
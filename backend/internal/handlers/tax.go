@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"time"
+
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/services"
+	"vehicle-sales-backend/internal/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type TaxHandler struct {
+	taxes *services.TaxService
+}
+
+func NewTaxHandler() *TaxHandler {
+	return &TaxHandler{taxes: services.NewTaxService()}
+}
+
+type TaxRuleRequest struct {
+	Code            string         `json:"code" validate:"required,max=20"`
+	Name            string         `json:"name" validate:"required"`
+	Kind            models.TaxKind `json:"kind" validate:"required,enum"`
+	Rate            int64          `json:"rate" validate:"min=0,max=10000"`
+	Amount          money.Money    `json:"amount" validate:"min=0"`
+	Inclusive       bool           `json:"inclusive"`
+	VehicleCategory string         `json:"vehicle_category" validate:"omitempty,slug,max=40"`
+	EffectiveFrom   time.Time      `json:"effective_from" validate:"required"`
+	EffectiveTo     *time.Time     `json:"effective_to"`
+}
+
+type CalculateTaxesRequest struct {
+	VehicleID uint        `json:"vehicle_id" validate:"required"`
+	SalePrice money.Money `json:"sale_price" validate:"required,gt=0"`
+	// Date prices the sale with the rules in force on it; defaults to now
+	Date *time.Time `json:"date"`
+}
+
+// GetTaxRules lists the tax and fee rules, current and past
+func (h *TaxHandler) GetTaxRules(c *fiber.Ctx) error {
+	query := database.DB.Model(&models.TaxRule{})
+
+	if code := c.Query("code"); code != "" {
+		query = query.Where("code = ?", code)
+	}
+
+	if category := c.Query("vehicle_category"); category != "" {
+		query = query.Where("vehicle_category = ?", category)
+	}
+
+	if c.Query("in_force") == "true" {
+		now := time.Now()
+		query = query.Where("effective_from <= ? AND (effective_to IS NULL OR effective_to > ?)", now, now)
+	}
+
+	var rules []models.TaxRule
+	if err := query.Order("code, vehicle_category, effective_from").Find(&rules).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve tax rules",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   rules,
+	})
+}
+
+// CreateTaxRule adds a tax or fee rule
+func (h *TaxHandler) CreateTaxRule(c *fiber.Ctx) error {
+	var req TaxRuleRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	rule, err := h.taxes.CreateRule(req.input())
+	if err != nil {
+		return serviceError(c, err, "Failed to create tax rule")
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"status":  "success",
+		"data":    rule,
+		"message": "Tax rule created successfully",
+	})
+}
+
+// UpdateTaxRule changes a tax or fee rule; sales priced earlier keep their
+// line items
+func (h *TaxHandler) UpdateTaxRule(c *fiber.Ctx) error {
+	var req TaxRuleRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	rule, err := h.taxes.UpdateRule(idParam(c), req.input())
+	if err != nil {
+		return serviceError(c, err, "Failed to update tax rule")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"data":    rule,
+		"message": "Tax rule updated successfully",
+	})
+}
+
+// DeleteTaxRule removes a tax or fee rule
+func (h *TaxHandler) DeleteTaxRule(c *fiber.Ctx) error {
+	if err := h.taxes.DeleteRule(idParam(c)); err != nil {
+		return serviceError(c, err, "Failed to delete tax rule")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Tax rule deleted successfully",
+	})
+}
+
+// CalculateTaxes itemises an agreed price for a vehicle into the vehicle,
+// taxes and fees, without creating a sale
+func (h *TaxHandler) CalculateTaxes(c *fiber.Ctx) error {
+	var req CalculateTaxesRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	at := time.Now()
+	if req.Date != nil {
+		at = *req.Date
+	}
+
+	pricing, err := h.taxes.Calculate(req.VehicleID, req.SalePrice, at)
+	if err != nil {
+		return serviceError(c, err, "Failed to calculate taxes")
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   pricing,
+	})
+}
+
+// GetTaxSummary totals the taxes and fees invoiced between two dates, both
+// inclusive, net of credit notes. It defaults to the current month
+func (h *TaxHandler) GetTaxSummary(c *fiber.Ctx) error {
+	from, to, message := reportPeriod(c)
+	if message != "" {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": message,
+		})
+	}
+
+	currency := money.Currency(c.Query("currency", string(money.DefaultCurrency())))
+	if !currency.IsValid() {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Unsupported currency",
+		})
+	}
+
+	summary, err := h.taxes.Summary(from, to, currency)
+	if err != nil {
+		return serviceError(c, err, "Failed to build tax summary")
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   summary,
+	})
+}
+
+func (req TaxRuleRequest) input() services.TaxRuleInput {
+	return services.TaxRuleInput{
+		Code:            req.Code,
+		Name:            req.Name,
+		Kind:            req.Kind,
+		Rate:            req.Rate,
+		Amount:          req.Amount,
+		Inclusive:       req.Inclusive,
+		VehicleCategory: req.VehicleCategory,
+		EffectiveFrom:   req.EffectiveFrom,
+		EffectiveTo:     req.EffectiveTo,
+	}
+}
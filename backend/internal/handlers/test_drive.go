@@ -6,6 +6,9 @@ import (
 
 	"vehicle-sales-backend/internal/database"
 	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/permissions"
+	"vehicle-sales-backend/internal/services"
+	"vehicle-sales-backend/internal/validation"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -25,7 +28,7 @@ type CreateTestDriveRequest struct {
 
 type UpdateTestDriveRequest struct {
 	ScheduledTime    time.Time                  `json:"scheduled_time,omitempty"`
-	Status           models.TestDriveStatus     `json:"status,omitempty"`
+	Status           models.TestDriveStatus     `json:"status,omitempty" validate:"omitempty,enum"`
 	Notes            string                     `json:"notes,omitempty"`
 	CustomerFeedback string                     `json:"customer_feedback,omitempty"`
 }
@@ -44,6 +47,8 @@ func (h *TestDriveHandler) GetTestDrives(c *fiber.Ctx) error {
 		Preload("Vehicle").
 		Preload("Customer")
 
+	query = scopeToCustomer(c, query, permissions.TestDriveReadAll)
+
 	if status != "" {
 		query = query.Where("status = ?", status)
 	}
@@ -88,7 +93,8 @@ func (h *TestDriveHandler) GetTestDrive(c *fiber.Ctx) error {
 	id := c.Params("id")
 	
 	var testDrive models.TestDrive
-	if err := database.DB.Preload("Vehicle").
+	if err := scopeToCustomer(c, database.DB, permissions.TestDriveReadAll).
+		Preload("Vehicle").
 		Preload("Customer").
 		First(&testDrive, id).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{
@@ -106,11 +112,18 @@ func (h *TestDriveHandler) GetTestDrive(c *fiber.Ctx) error {
 // CreateTestDrive creates a new test drive booking
 func (h *TestDriveHandler) CreateTestDrive(c *fiber.Ctx) error {
 	var req CreateTestDriveRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Invalid request body",
-		})
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	// Customers can only book test drives for themselves
+	if customerID, scoped := ownerScope(c, permissions.TestDriveReadAll); scoped {
+		if req.CustomerID != customerID {
+			return c.Status(403).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Customers can only book test drives for themselves",
+			})
+		}
 	}
 
 	// Verify vehicle exists and is available
@@ -203,11 +216,8 @@ func (h *TestDriveHandler) UpdateTestDrive(c *fiber.Ctx) error {
 	}
 
 	var req UpdateTestDriveRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Invalid request body",
-		})
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
 	}
 
 	// Update fields if provided
@@ -222,6 +232,9 @@ func (h *TestDriveHandler) UpdateTestDrive(c *fiber.Ctx) error {
 	}
 
 	if req.Status != "" {
+		if err := services.TestDriveStates.Check(actor(c), testDrive.Status, req.Status); err != nil {
+			return serviceError(c, err, "Failed to update test drive")
+		}
 		testDrive.Status = req.Status
 	}
 
@@ -257,19 +270,16 @@ func (h *TestDriveHandler) DeleteTestDrive(c *fiber.Ctx) error {
 	id := c.Params("id")
 	
 	var testDrive models.TestDrive
-	if err := database.DB.First(&testDrive, id).Error; err != nil {
+	if err := scopeToCustomer(c, database.DB, permissions.TestDriveReadAll).First(&testDrive, id).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Test drive not found",
 		})
 	}
 
-	// Only allow cancellation of pending or approved test drives
-	if testDrive.Status == models.TestDriveStatusCompleted {
-		return c.Status(400).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Cannot cancel completed test drive",
-		})
+	// Only pending or approved test drives can be canceled
+	if err := services.TestDriveStates.Check(actor(c), testDrive.Status, models.TestDriveStatusCanceled); err != nil {
+		return serviceError(c, err, "Failed to cancel test drive")
 	}
 
 	testDrive.Status = models.TestDriveStatusCanceled
@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/permissions"
+	"vehicle-sales-backend/internal/services"
+	"vehicle-sales-backend/internal/validation"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type TradeInHandler struct {
+	tradeIns *services.TradeInService
+}
+
+func NewTradeInHandler() *TradeInHandler {
+	return &TradeInHandler{tradeIns: services.NewTradeInService()}
+}
+
+type ConditionCheckRequest struct {
+	Item      string           `json:"item" validate:"required,max=100"`
+	Condition models.Condition `json:"condition" validate:"required,enum"`
+	Notes     string           `json:"notes" validate:"max=500"`
+}
+
+type TradeInPhotoRequest struct {
+	URL     string `json:"url" validate:"required,url"`
+	Caption string `json:"caption" validate:"max=200"`
+}
+
+// AppraisalRequest describes a customer's vehicle and the value offered for
+// it. CustomerID is ignored when reappraising
+type AppraisalRequest struct {
+	CustomerID   uint                    `json:"customer_id"`
+	Make         string                  `json:"make" validate:"required"`
+	Model        string                  `json:"model" validate:"required"`
+	Year         int                     `json:"year" validate:"required,min=1900,year_max"`
+	Color        string                  `json:"color"`
+	VIN          string                  `json:"vin" validate:"required,vin"`
+	LicensePlate string                  `json:"license_plate"`
+	Mileage      int                     `json:"mileage" validate:"min=0"`
+	Category     string                  `json:"category" validate:"omitempty,slug,max=40"`
+	Checklist    []ConditionCheckRequest `json:"checklist" validate:"dive"`
+	Photos       []TradeInPhotoRequest   `json:"photos" validate:"dive"`
+	OfferedValue money.Money             `json:"offered_value" validate:"gt=0"`
+	ValidUntil   *time.Time              `json:"valid_until"`
+	Notes        string                  `json:"notes"`
+}
+
+// AcceptTradeInRequest optionally names the sale to credit the trade-in to
+type AcceptTradeInRequest struct {
+	SaleID *uint `json:"sale_id"`
+}
+
+type ApplyTradeInRequest struct {
+	SaleID uint `json:"sale_id" validate:"required"`
+}
+
+type DeclineTradeInRequest struct {
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+// GetTradeIns lists trade-ins, newest first. Customers see their own
+func (h *TradeInHandler) GetTradeIns(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
+	status := c.Query("status")
+	customerID := c.Query("customer_id")
+	saleID := c.Query("sale_id")
+
+	offset := (page - 1) * limit
+
+	query := scopeToCustomer(c, database.DB.Model(&models.TradeIn{}), permissions.TradeInReadAll).
+		Preload("Customer").
+		Preload("Appraiser")
+
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if customerID != "" {
+		query = query.Where("customer_id = ?", customerID)
+	}
+
+	if saleID != "" {
+		query = query.Where("sale_id = ?", saleID)
+	}
+
+	var tradeIns []models.TradeIn
+	var total int64
+
+	query.Count(&total)
+
+	if err := query.Offset(offset).Limit(limit).Order("created_at DESC").Find(&tradeIns).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to retrieve trade-ins",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data": fiber.Map{
+			"trade_ins": tradeIns,
+			"pagination": fiber.Map{
+				"page":  page,
+				"limit": limit,
+				"total": total,
+				"pages": (total + int64(limit) - 1) / int64(limit),
+			},
+		},
+	})
+}
+
+// GetTradeIn retrieves a trade-in with its checklist and photos
+func (h *TradeInHandler) GetTradeIn(c *fiber.Ctx) error {
+	var tradeIn models.TradeIn
+	if err := scopeToCustomer(c, database.DB, permissions.TradeInReadAll).
+		Preload("Customer").
+		Preload("Appraiser").
+		Preload("Vehicle").
+		Preload("Checklist", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Photos", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		First(&tradeIn, idParam(c)).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Trade-in not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   tradeIn,
+	})
+}
+
+// CreateTradeIn records the appraisal of a customer's vehicle
+func (h *TradeInHandler) CreateTradeIn(c *fiber.Ctx) error {
+	var req AppraisalRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	tradeIn, err := h.tradeIns.Appraise(actor(c), req.input())
+	if err != nil {
+		return serviceError(c, err, "Failed to record appraisal")
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"status":  "success",
+		"data":    tradeIn,
+		"message": "Trade-in appraised successfully",
+	})
+}
+
+// UpdateTradeIn replaces the appraisal of a trade-in that has not been
+// accepted
+func (h *TradeInHandler) UpdateTradeIn(c *fiber.Ctx) error {
+	var req AppraisalRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	tradeIn, err := h.tradeIns.Reappraise(actor(c), idParam(c), req.input())
+	if err != nil {
+		return serviceError(c, err, "Failed to update appraisal")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"data":    tradeIn,
+		"message": "Trade-in reappraised successfully",
+	})
+}
+
+// AcceptTradeIn records the customer's acceptance of the offered value,
+// taking the vehicle into stock and optionally crediting a sale
+func (h *TradeInHandler) AcceptTradeIn(c *fiber.Ctx) error {
+	var req AcceptTradeInRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	tradeIn, err := h.tradeIns.Accept(actor(c), idParam(c), req.SaleID)
+	if err != nil {
+		return serviceError(c, err, "Failed to accept trade-in")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"data":    tradeIn,
+		"message": "Trade-in accepted successfully",
+	})
+}
+
+// DeclineTradeIn records that the customer turned the offered value down
+func (h *TradeInHandler) DeclineTradeIn(c *fiber.Ctx) error {
+	var req DeclineTradeInRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	tradeIn, err := h.tradeIns.Decline(actor(c), idParam(c), req.Reason)
+	if err != nil {
+		return serviceError(c, err, "Failed to decline trade-in")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"data":    tradeIn,
+		"message": "Trade-in declined",
+	})
+}
+
+// ApplyTradeIn credits an accepted trade-in to one of the customer's sales
+func (h *TradeInHandler) ApplyTradeIn(c *fiber.Ctx) error {
+	var req ApplyTradeInRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	tradeIn, err := h.tradeIns.Apply(actor(c), idParam(c), req.SaleID)
+	if err != nil {
+		return serviceError(c, err, "Failed to credit trade-in")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"data":    tradeIn,
+		"message": "Trade-in credited to the sale",
+	})
+}
+
+func (req AppraisalRequest) input() services.AppraisalInput {
+	input := services.AppraisalInput{
+		CustomerID:   req.CustomerID,
+		Make:         req.Make,
+		Model:        req.Model,
+		Year:         req.Year,
+		Color:        req.Color,
+		VIN:          req.VIN,
+		LicensePlate: req.LicensePlate,
+		Mileage:      req.Mileage,
+		Category:     req.Category,
+		OfferedValue: req.OfferedValue,
+		ValidUntil:   req.ValidUntil,
+		Notes:        req.Notes,
+	}
+	for _, check := range req.Checklist {
+		input.Checklist = append(input.Checklist, services.ConditionCheck{
+			Item:      check.Item,
+			Condition: check.Condition,
+			Notes:     check.Notes,
+		})
+	}
+	for _, photo := range req.Photos {
+		input.Photos = append(input.Photos, services.TradeInPhotoInput{URL: photo.URL, Caption: photo.Caption})
+	}
+	return input
+}
@@ -2,34 +2,52 @@ package handlers
 
 import (
 	"strconv"
-	"time"
 
 	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/middleware"
 	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/services"
+	"vehicle-sales-backend/internal/validation"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/google/uuid"
 )
 
-type TransactionHandler struct{}
+type TransactionHandler struct {
+	transactions *services.TransactionService
+	sales        *services.SaleService
+}
 
 func NewTransactionHandler() *TransactionHandler {
-	return &TransactionHandler{}
+	return &TransactionHandler{
+		transactions: services.NewTransactionService(),
+		sales:        services.NewSaleService(),
+	}
 }
 
 type CreateTransactionRequest struct {
 	SaleID        uint                          `json:"sale_id" validate:"required"`
-	Amount        float64                       `json:"amount" validate:"required,min=0"`
-	PaymentMethod models.PaymentMethod          `json:"payment_method" validate:"required"`
+	Type          models.TransactionType        `json:"type,omitempty" validate:"omitempty,enum"`
+	Amount        money.Money                   `json:"amount" validate:"required,gt=0"`
+	PaymentMethod models.PaymentMethod          `json:"payment_method" validate:"required,enum"`
+	Tendered      money.Money                   `json:"tendered,omitempty" validate:"omitempty,gt=0"`
 	Notes         string                        `json:"notes"`
 }
 
 type UpdateTransactionRequest struct {
-	Status         models.TransactionStatus      `json:"status,omitempty"`
+	Status         models.TransactionStatus      `json:"status,omitempty" validate:"omitempty,enum"`
 	TransactionRef string                        `json:"transaction_ref,omitempty"`
 	Notes          string                        `json:"notes,omitempty"`
 }
 
+type RefundTransactionRequest struct {
+	Amount        money.Money          `json:"amount,omitempty" validate:"omitempty,gt=0"`
+	PaymentMethod models.PaymentMethod `json:"payment_method,omitempty" validate:"omitempty,enum"`
+	ReasonCode    models.RefundReason  `json:"reason_code" validate:"required,enum"`
+	CancelSale    *bool                `json:"cancel_sale,omitempty"`
+	Notes         string               `json:"notes"`
+}
+
 // GetTransactions retrieves transactions with filtering and pagination
 func (h *TransactionHandler) GetTransactions(c *fiber.Ctx) error {
 	page, _ := strconv.Atoi(c.Query("page", "1"))
@@ -37,6 +55,7 @@ func (h *TransactionHandler) GetTransactions(c *fiber.Ctx) error {
 	status := c.Query("status")
 	saleID := c.Query("sale_id")
 	paymentMethod := c.Query("payment_method")
+	shiftID := c.Query("shift_id")
 
 	offset := (page - 1) * limit
 
@@ -46,6 +65,8 @@ func (h *TransactionHandler) GetTransactions(c *fiber.Ctx) error {
 		Preload("Sale.Customer").
 		Preload("ProcessedBy")
 
+	query = scopeTransactionsToCustomer(c, query)
+
 	if status != "" {
 		query = query.Where("status = ?", status)
 	}
@@ -58,6 +79,10 @@ func (h *TransactionHandler) GetTransactions(c *fiber.Ctx) error {
 		query = query.Where("payment_method = ?", paymentMethod)
 	}
 
+	if shiftID != "" {
+		query = query.Where("shift_id = ?", shiftID)
+	}
+
 	var transactions []models.Transaction
 	var total int64
 
@@ -90,10 +115,12 @@ func (h *TransactionHandler) GetTransaction(c *fiber.Ctx) error {
 	id := c.Params("id")
 	
 	var transaction models.Transaction
-	if err := database.DB.Preload("Sale").
+	if err := scopeTransactionsToCustomer(c, database.DB).
+		Preload("Sale").
 		Preload("Sale.Vehicle").
 		Preload("Sale.Customer").
 		Preload("ProcessedBy").
+		Preload("ApprovedBy").
 		First(&transaction, id).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{
 			"status":  "error",
@@ -110,51 +137,21 @@ func (h *TransactionHandler) GetTransaction(c *fiber.Ctx) error {
 // CreateTransaction creates a new transaction
 func (h *TransactionHandler) CreateTransaction(c *fiber.Ctx) error {
 	var req CreateTransactionRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Invalid request body",
-		})
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
 	}
 
-	// Get current user (cashier/admin)
-	userID := c.Locals("user_id").(uint)
-
-	// Verify sale exists and is approved
-	var sale models.Sale
-	if err := database.DB.First(&sale, req.SaleID).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Sale not found",
-		})
-	}
-
-	if sale.Status != models.SaleStatusApproved {
-		return c.Status(400).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Sale must be approved before processing payment",
-		})
-	}
-
-	// Generate transaction reference
-	transactionRef := "TXN-" + uuid.New().String()[:8]
-
-	transaction := models.Transaction{
-		SaleID:          req.SaleID,
-		Amount:          req.Amount,
-		PaymentMethod:   req.PaymentMethod,
-		Status:          models.TransactionStatusPending,
-		ProcessedByID:   userID,
-		TransactionRef:  transactionRef,
-		Notes:           req.Notes,
-	}
-
-	if err := database.DB.Create(&transaction).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Failed to create transaction",
-			"error":   err.Error(),
-		})
+	transaction, err := h.transactions.CreateTransaction(services.CreateTransactionInput{
+		SaleID:        req.SaleID,
+		Type:          req.Type,
+		Amount:        req.Amount,
+		PaymentMethod: req.PaymentMethod,
+		Tendered:      req.Tendered,
+		ProcessedByID: middleware.GetAuthContext(c).UserID,
+		Notes:         req.Notes,
+	})
+	if err != nil {
+		return serviceError(c, err, "Failed to create transaction")
 	}
 
 	// Load relationships for response
@@ -162,7 +159,8 @@ func (h *TransactionHandler) CreateTransaction(c *fiber.Ctx) error {
 		Preload("Sale.Vehicle").
 		Preload("Sale.Customer").
 		Preload("ProcessedBy").
-		First(&transaction, transaction.ID)
+		First(transaction, transaction.ID)
+	transaction.Sale.Balance, _ = h.sales.Balance(transaction.SaleID)
 
 	return c.Status(201).JSON(fiber.Map{
 		"status": "success",
@@ -172,64 +170,18 @@ func (h *TransactionHandler) CreateTransaction(c *fiber.Ctx) error {
 
 // UpdateTransaction updates an existing transaction
 func (h *TransactionHandler) UpdateTransaction(c *fiber.Ctx) error {
-	id := c.Params("id")
-	
-	var transaction models.Transaction
-	if err := database.DB.First(&transaction, id).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Transaction not found",
-		})
-	}
-
 	var req UpdateTransactionRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Invalid request body",
-		})
-	}
-
-	// Update fields if provided
-	if req.Status != "" {
-		transaction.Status = req.Status
-		
-		// If completed, set processed timestamp and update sale status
-		if req.Status == models.TransactionStatusCompleted {
-			now := time.Now()
-			transaction.ProcessedAt = &now
-			
-			// Update sale status to completed
-			database.DB.Model(&models.Sale{}).
-				Where("id = ?", transaction.SaleID).
-				Updates(map[string]interface{}{
-					"status": models.SaleStatusCompleted,
-					"completed_at": now,
-				})
-
-			// Update vehicle status to sold
-			var sale models.Sale
-			database.DB.First(&sale, transaction.SaleID)
-			database.DB.Model(&models.Vehicle{}).
-				Where("id = ?", sale.VehicleID).
-				Update("status", models.VehicleStatusSold)
-		}
-	}
-
-	if req.TransactionRef != "" {
-		transaction.TransactionRef = req.TransactionRef
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
 	}
 
-	if req.Notes != "" {
-		transaction.Notes = req.Notes
-	}
-
-	if err := database.DB.Save(&transaction).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Failed to update transaction",
-			"error":   err.Error(),
-		})
+	transaction, err := h.transactions.UpdateTransaction(actor(c), idParam(c), services.UpdateTransactionInput{
+		Status:         req.Status,
+		TransactionRef: req.TransactionRef,
+		Notes:          req.Notes,
+	})
+	if err != nil {
+		return serviceError(c, err, "Failed to update transaction")
 	}
 
 	// Load relationships for response
@@ -237,7 +189,8 @@ func (h *TransactionHandler) UpdateTransaction(c *fiber.Ctx) error {
 		Preload("Sale.Vehicle").
 		Preload("Sale.Customer").
 		Preload("ProcessedBy").
-		First(&transaction, transaction.ID)
+		First(transaction, transaction.ID)
+	transaction.Sale.Balance, _ = h.sales.Balance(transaction.SaleID)
 
 	return c.JSON(fiber.Map{
 		"status": "success",
@@ -245,68 +198,13 @@ func (h *TransactionHandler) UpdateTransaction(c *fiber.Ctx) error {
 	})
 }
 
-// ProcessPayment completes a transaction
+// ProcessPayment charges a transaction and reports the sale's remaining
+// balance. Declined payments answer 402 and payments still awaiting the
+// provider 202
 func (h *TransactionHandler) ProcessPayment(c *fiber.Ctx) error {
-	id := c.Params("id")
-	
-	var transaction models.Transaction
-	if err := database.DB.First(&transaction, id).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Transaction not found",
-		})
-	}
-
-	if transaction.Status != models.TransactionStatusPending {
-		return c.Status(400).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Transaction is not pending",
-		})
-	}
-
-	// Process payment based on method
-	switch transaction.PaymentMethod {
-	case models.PaymentMethodCash:
-		// Cash payment - mark as completed immediately
-		transaction.Status = models.TransactionStatusCompleted
-	case models.PaymentMethodCard:
-		// Card payment - simulate card processing
-		transaction.Status = models.TransactionStatusCompleted
-	case models.PaymentMethodBankTransfer:
-		// Bank transfer - might need verification
-		transaction.Status = models.TransactionStatusCompleted
-	case models.PaymentMethodFinancing:
-		// Financing - special handling required
-		transaction.Status = models.TransactionStatusCompleted
-	}
-
-	now := time.Now()
-	transaction.ProcessedAt = &now
-
-	if err := database.DB.Save(&transaction).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Failed to process payment",
-			"error":   err.Error(),
-		})
-	}
-
-	// Update sale and vehicle status if payment completed
-	if transaction.Status == models.TransactionStatusCompleted {
-		// Update sale status
-		database.DB.Model(&models.Sale{}).
-			Where("id = ?", transaction.SaleID).
-			Updates(map[string]interface{}{
-				"status": models.SaleStatusCompleted,
-				"completed_at": now,
-			})
-
-		// Update vehicle status
-		var sale models.Sale
-		database.DB.First(&sale, transaction.SaleID)
-		database.DB.Model(&models.Vehicle{}).
-			Where("id = ?", sale.VehicleID).
-			Update("status", models.VehicleStatusSold)
+	transaction, err := h.transactions.ProcessPayment(actor(c), idParam(c))
+	if err != nil {
+		return serviceError(c, err, "Failed to process payment")
 	}
 
 	// Load relationships for response
@@ -314,7 +212,23 @@ func (h *TransactionHandler) ProcessPayment(c *fiber.Ctx) error {
 		Preload("Sale.Vehicle").
 		Preload("Sale.Customer").
 		Preload("ProcessedBy").
-		First(&transaction, transaction.ID)
+		First(transaction, transaction.ID)
+	transaction.Sale.Balance, _ = h.sales.Balance(transaction.SaleID)
+
+	switch transaction.Status {
+	case models.TransactionStatusFailed:
+		return c.Status(402).JSON(fiber.Map{
+			"status":  "error",
+			"data":    transaction,
+			"message": "Payment was declined",
+		})
+	case models.TransactionStatusPending:
+		return c.Status(202).JSON(fiber.Map{
+			"status":  "success",
+			"data":    transaction,
+			"message": "Payment is awaiting confirmation from the provider",
+		})
+	}
 
 	return c.JSON(fiber.Map{
 		"status": "success",
@@ -323,53 +237,46 @@ func (h *TransactionHandler) ProcessPayment(c *fiber.Ctx) error {
 	})
 }
 
-// RefundTransaction refunds a completed transaction
+// RefundTransaction refunds part or all of a completed transaction as a new
+// refund transaction approved by the caller. Refunds still awaiting the
+// provider answer 202
 func (h *TransactionHandler) RefundTransaction(c *fiber.Ctx) error {
-	id := c.Params("id")
-	
-	var transaction models.Transaction
-	if err := database.DB.First(&transaction, id).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Transaction not found",
-		})
+	var req RefundTransactionRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
 	}
 
-	if transaction.Status != models.TransactionStatusCompleted {
-		return c.Status(400).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Only completed transactions can be refunded",
-		})
+	refund, err := h.transactions.RefundTransaction(actor(c), idParam(c), services.RefundInput{
+		Amount:        req.Amount,
+		PaymentMethod: req.PaymentMethod,
+		ReasonCode:    req.ReasonCode,
+		CancelSale:    req.CancelSale,
+		Notes:         req.Notes,
+	})
+	if err != nil {
+		return serviceError(c, err, "Failed to refund transaction")
 	}
 
-	// Update transaction status to refunded
-	transaction.Status = models.TransactionStatusRefunded
-
-	if err := database.DB.Save(&transaction).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Failed to refund transaction",
-			"error":   err.Error(),
+	// Load relationships for response
+	database.DB.Preload("Sale").
+		Preload("Sale.Vehicle").
+		Preload("Sale.Customer").
+		Preload("ProcessedBy").
+		Preload("ApprovedBy").
+		First(refund, refund.ID)
+	refund.Sale.Balance, _ = h.sales.Balance(refund.SaleID)
+
+	if refund.Status == models.TransactionStatusPending {
+		return c.Status(202).JSON(fiber.Map{
+			"status":  "success",
+			"data":    refund,
+			"message": "Refund is awaiting confirmation from the provider",
 		})
 	}
 
-	// Revert sale status
-	database.DB.Model(&models.Sale{}).
-		Where("id = ?", transaction.SaleID).
-		Updates(map[string]interface{}{
-			"status": models.SaleStatusCanceled,
-			"completed_at": nil,
-		})
-
-	// Make vehicle available again
-	var sale models.Sale
-	database.DB.First(&sale, transaction.SaleID)
-	database.DB.Model(&models.Vehicle{}).
-		Where("id = ?", sale.VehicleID).
-		Update("status", models.VehicleStatusAvailable)
-
-	return c.JSON(fiber.Map{
+	return c.Status(201).JSON(fiber.Map{
 		"status":  "success",
+		"data":    refund,
 		"message": "Transaction refunded successfully",
 	})
 }
@@ -382,12 +289,12 @@ func (h *TransactionHandler) GetTransactionAnalytics(c *fiber.Ctx) error {
 		PendingTransactions   int64   `json:"pending_transactions"`
 		FailedTransactions    int64   `json:"failed_transactions"`
 		RefundedTransactions  int64   `json:"refunded_transactions"`
-		TotalRevenue          float64 `json:"total_revenue"`
-		AvgTransactionAmount  float64 `json:"avg_transaction_amount"`
+		TotalRevenue          money.Money `json:"total_revenue"`
+		AvgTransactionAmount  money.Money `json:"avg_transaction_amount"`
 		PaymentMethods        []struct {
 			Method string `json:"method"`
 			Count  int64  `json:"count"`
-			Total  float64 `json:"total"`
+			Total  money.Money `json:"total"`
 		} `json:"payment_methods"`
 	}
 
@@ -395,17 +302,15 @@ func (h *TransactionHandler) GetTransactionAnalytics(c *fiber.Ctx) error {
 	database.DB.Model(&models.Transaction{}).Where("status = ?", models.TransactionStatusCompleted).Count(&analytics.CompletedTransactions)
 	database.DB.Model(&models.Transaction{}).Where("status = ?", models.TransactionStatusPending).Count(&analytics.PendingTransactions)
 	database.DB.Model(&models.Transaction{}).Where("status = ?", models.TransactionStatusFailed).Count(&analytics.FailedTransactions)
-	database.DB.Model(&models.Transaction{}).Where("status = ?", models.TransactionStatusRefunded).Count(&analytics.RefundedTransactions)
-	
 	database.DB.Model(&models.Transaction{}).
-		Where("status = ?", models.TransactionStatusCompleted).
-		Select("COALESCE(SUM(amount), 0)").
-		Scan(&analytics.TotalRevenue)
+		Where("status = ? OR type = ?", models.TransactionStatusRefunded, models.TransactionTypeRefund).
+		Count(&analytics.RefundedTransactions)
 	
-	database.DB.Model(&models.Transaction{}).
-		Where("status = ?", models.TransactionStatusCompleted).
-		Select("COALESCE(AVG(amount), 0)").
-		Scan(&analytics.AvgTransactionAmount)
+	analytics.TotalRevenue = sumMoney(database.DB.Model(&models.Transaction{}).
+		Where("status = ?", models.TransactionStatusCompleted), "amount")
+	
+	analytics.AvgTransactionAmount = avgMoney(database.DB.Model(&models.Transaction{}).
+		Where("status = ? AND type <> ?", models.TransactionStatusCompleted, models.TransactionTypeRefund), "amount")
 
 	// Payment method breakdown
 	paymentMethods := []models.PaymentMethod{
@@ -417,21 +322,18 @@ func (h *TransactionHandler) GetTransactionAnalytics(c *fiber.Ctx) error {
 
 	for _, method := range paymentMethods {
 		var count int64
-		var total float64
 		
 		database.DB.Model(&models.Transaction{}).
 			Where("payment_method = ? AND status = ?", method, models.TransactionStatusCompleted).
 			Count(&count)
 		
-		database.DB.Model(&models.Transaction{}).
-			Where("payment_method = ? AND status = ?", method, models.TransactionStatusCompleted).
-			Select("COALESCE(SUM(amount), 0)").
-			Scan(&total)
+		total := sumMoney(database.DB.Model(&models.Transaction{}).
+			Where("payment_method = ? AND status = ?", method, models.TransactionStatusCompleted), "amount")
 		
 		analytics.PaymentMethods = append(analytics.PaymentMethods, struct {
 			Method string `json:"method"`
 			Count  int64  `json:"count"`
-			Total  float64 `json:"total"`
+			Total  money.Money `json:"total"`
 		}{
 			Method: string(method),
 			Count:  count,
@@ -3,11 +3,16 @@ package handlers
 import (
 	"strconv"
 
+	"vehicle-sales-backend/internal/auth"
 	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/middleware"
 	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/permissions"
+	"vehicle-sales-backend/internal/validation"
 
 	"github.com/gofiber/fiber/v2"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type UserHandler struct{}
@@ -20,16 +25,16 @@ type CreateUserRequest struct {
 	Email    string           `json:"email" validate:"required,email"`
 	Password string           `json:"password" validate:"required,min=6"`
 	Name     string           `json:"name" validate:"required"`
-	Phone    string           `json:"phone"`
-	Role     models.UserRole  `json:"role" validate:"required"`
+	Phone    string           `json:"phone" validate:"omitempty,e164"`
+	Role     models.UserRole  `json:"role" validate:"required,slug"`
 }
 
 type UpdateUserRequest struct {
 	Name      string          `json:"name,omitempty"`
-	Phone     string          `json:"phone,omitempty"`
-	Role      models.UserRole `json:"role,omitempty"`
+	Phone     string          `json:"phone,omitempty" validate:"omitempty,e164"`
+	Role      models.UserRole `json:"role,omitempty" validate:"omitempty,slug"`
 	IsActive  *bool           `json:"is_active,omitempty"`
-	AvatarURL string          `json:"avatar_url,omitempty"`
+	AvatarURL string          `json:"avatar_url,omitempty" validate:"omitempty,url"`
 }
 
 type ChangePasswordRequest struct {
@@ -111,10 +116,14 @@ func (h *UserHandler) GetUser(c *fiber.Ctx) error {
 // CreateUser creates a new user (Admin only)
 func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
 	var req CreateUserRequest
-	if err := c.BodyParser(&req); err != nil {
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	if !permissions.RoleExists(req.Role) {
 		return c.Status(400).JSON(fiber.Map{
 			"status":  "error",
-			"message": "Invalid request body",
+			"message": "Unknown role",
 		})
 	}
 
@@ -172,13 +181,13 @@ func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 	}
 
 	var req UpdateUserRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Invalid request body",
-		})
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
 	}
 
+	// Role changes and deactivation invalidate existing sessions
+	revokeTokens := false
+
 	// Update fields if provided
 	if req.Name != "" {
 		user.Name = req.Name
@@ -186,17 +195,37 @@ func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 	if req.Phone != "" {
 		user.Phone = req.Phone
 	}
-	if req.Role != "" {
+	if req.Role != "" && req.Role != user.Role {
+		if !permissions.RoleExists(req.Role) {
+			return c.Status(400).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Unknown role",
+			})
+		}
 		user.Role = req.Role
+		revokeTokens = true
 	}
 	if req.IsActive != nil {
+		if user.IsActive && !*req.IsActive {
+			revokeTokens = true
+		}
 		user.IsActive = *req.IsActive
 	}
 	if req.AvatarURL != "" {
 		user.AvatarURL = req.AvatarURL
 	}
 
-	if err := database.DB.Save(&user).Error; err != nil {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// token_version is only ever bumped, never written back
+		if err := tx.Omit("token_version").Save(&user).Error; err != nil {
+			return err
+		}
+		if revokeTokens {
+			return auth.RevokeUserTokens(tx, user.ID)
+		}
+		return nil
+	})
+	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Failed to update user",
@@ -225,7 +254,14 @@ func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 	// Deactivate instead of hard delete
 	user.IsActive = false
 	
-	if err := database.DB.Save(&user).Error; err != nil {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// token_version is only ever bumped, never written back
+		if err := tx.Omit("token_version").Save(&user).Error; err != nil {
+			return err
+		}
+		return auth.RevokeUserTokens(tx, user.ID)
+	})
+	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Failed to deactivate user",
@@ -239,10 +275,24 @@ func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 	})
 }
 
-// ChangePassword changes user's password
+// ChangePassword changes a user's password by ID (Admin only)
 func (h *UserHandler) ChangePassword(c *fiber.Ctx) error {
-	id := c.Params("id")
-	
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid user ID",
+		})
+	}
+	return h.changePassword(c, uint(id))
+}
+
+// ChangeProfilePassword changes the current user's password
+func (h *UserHandler) ChangeProfilePassword(c *fiber.Ctx) error {
+	return h.changePassword(c, middleware.GetAuthContext(c).UserID)
+}
+
+func (h *UserHandler) changePassword(c *fiber.Ctx, id uint) error {
 	var user models.User
 	if err := database.DB.First(&user, id).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{
@@ -252,11 +302,8 @@ func (h *UserHandler) ChangePassword(c *fiber.Ctx) error {
 	}
 
 	var req ChangePasswordRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Invalid request body",
-		})
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
 	}
 
 	// Verify current password
@@ -278,7 +325,14 @@ func (h *UserHandler) ChangePassword(c *fiber.Ctx) error {
 
 	user.Password = string(hashedPassword)
 
-	if err := database.DB.Save(&user).Error; err != nil {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// token_version is only ever bumped, never written back
+		if err := tx.Omit("token_version").Save(&user).Error; err != nil {
+			return err
+		}
+		return auth.RevokeUserTokens(tx, user.ID)
+	})
+	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Failed to update password",
@@ -318,7 +372,7 @@ func (h *UserHandler) GetUserAnalytics(c *fiber.Ctx) error {
 
 // GetProfile returns current user's profile
 func (h *UserHandler) GetProfile(c *fiber.Ctx) error {
-	userID := c.Locals("user_id").(uint)
+	userID := middleware.GetAuthContext(c).UserID
 	
 	var user models.User
 	if err := database.DB.First(&user, userID).Error; err != nil {
@@ -336,7 +390,7 @@ func (h *UserHandler) GetProfile(c *fiber.Ctx) error {
 
 // UpdateProfile updates current user's profile
 func (h *UserHandler) UpdateProfile(c *fiber.Ctx) error {
-	userID := c.Locals("user_id").(uint)
+	userID := middleware.GetAuthContext(c).UserID
 	
 	var user models.User
 	if err := database.DB.First(&user, userID).Error; err != nil {
@@ -348,15 +402,12 @@ func (h *UserHandler) UpdateProfile(c *fiber.Ctx) error {
 
 	var req struct {
 		Name      string `json:"name,omitempty"`
-		Phone     string `json:"phone,omitempty"`
-		AvatarURL string `json:"avatar_url,omitempty"`
+		Phone     string `json:"phone,omitempty" validate:"omitempty,e164"`
+		AvatarURL string `json:"avatar_url,omitempty" validate:"omitempty,url"`
 	}
 	
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"status":  "error",
-			"message": "Invalid request body",
-		})
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
 	}
 
 	// Update fields if provided
@@ -370,7 +421,9 @@ func (h *UserHandler) UpdateProfile(c *fiber.Ctx) error {
 		user.AvatarURL = req.AvatarURL
 	}
 
-	if err := database.DB.Save(&user).Error; err != nil {
+	// write back only the profile, not a role, status or token_version
+	// changed since the user was read
+	if err := database.DB.Model(&user).Select("name", "phone", "avatar_url").Updates(&user).Error; err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Failed to update profile",
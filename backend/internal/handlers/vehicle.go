@@ -2,12 +2,16 @@ package handlers
 
 import (
 	"strconv"
+	"time"
 
 	"vehicle-sales-backend/internal/database"
 	"vehicle-sales-backend/internal/middleware"
 	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/validation"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 type VehicleHandler struct{}
@@ -19,20 +23,37 @@ func NewVehicleHandler() *VehicleHandler {
 type CreateVehicleRequest struct {
 	Make         string                `json:"make" validate:"required"`
 	Model        string                `json:"model" validate:"required"`
-	Year         int                   `json:"year" validate:"required,min=1900,max=2030"`
+	Year         int                   `json:"year" validate:"required,min=1900,year_max"`
 	Color        string                `json:"color"`
-	VIN          string                `json:"vin"`
+	VIN          string                `json:"vin" validate:"omitempty,vin"`
 	LicensePlate string                `json:"license_plate"`
-	Price        float64               `json:"price" validate:"required,min=0"`
+	Price        money.Money           `json:"price" validate:"required,gt=0"`
 	Mileage      int                   `json:"mileage" validate:"min=0"`
-	Status       models.VehicleStatus  `json:"status"`
+	Status       models.VehicleStatus  `json:"status" validate:"omitempty,enum"`
 	Description  string                `json:"description"`
+	Category     string                `json:"category" validate:"omitempty,slug,max=40"`
+}
+
+type UpdateVehicleRequest struct {
+	Make         string                `json:"make" validate:"required"`
+	Model        string                `json:"model" validate:"required"`
+	Year         int                   `json:"year" validate:"required,min=1900,year_max"`
+	Color        string                `json:"color"`
+	VIN          string                `json:"vin" validate:"omitempty,vin"`
+	LicensePlate string                `json:"license_plate"`
+	Price        money.Money           `json:"price" validate:"required,gt=0"`
+	Mileage      int                   `json:"mileage" validate:"min=0"`
+	Status       models.VehicleStatus  `json:"status" validate:"omitempty,enum"`
+	Description  string                `json:"description"`
+	Category     string                `json:"category" validate:"omitempty,slug,max=40"`
+	Version      uint                  `json:"version"`
 }
 
 func (h *VehicleHandler) GetVehicles(c *fiber.Ctx) error {
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	limit, _ := strconv.Atoi(c.Query("limit", "10"))
 	status := c.Query("status")
+	category := c.Query("category")
 	search := c.Query("search")
 
 	offset := (page - 1) * limit
@@ -43,6 +64,10 @@ func (h *VehicleHandler) GetVehicles(c *fiber.Ctx) error {
 		query = query.Where("status = ?", status)
 	}
 
+	if category != "" {
+		query = query.Where("category = ?", category)
+	}
+
 	if search != "" {
 		query = query.Where("make ILIKE ? OR model ILIKE ? OR description ILIKE ?", 
 			"%"+search+"%", "%"+search+"%", "%"+search+"%")
@@ -89,20 +114,9 @@ func (h *VehicleHandler) GetVehicle(c *fiber.Ctx) error {
 }
 
 func (h *VehicleHandler) CreateVehicle(c *fiber.Ctx) error {
-	authCtx := middleware.GetAuthContext(c)
-	
-	// Only admin and sales can create vehicles
-	if authCtx.Role != models.RoleAdmin && authCtx.Role != models.RoleSales {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": "Insufficient permissions",
-		})
-	}
-
 	var req CreateVehicleRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
 	}
 
 	// Set default status if not provided
@@ -121,6 +135,8 @@ func (h *VehicleHandler) CreateVehicle(c *fiber.Ctx) error {
 		Mileage:      req.Mileage,
 		Status:       req.Status,
 		Description:  req.Description,
+		Category:     req.Category,
+		PurchaseCost: money.Zero(req.Price.Currency),
 	}
 
 	if err := database.DB.Create(&vehicle).Error; err != nil {
@@ -133,15 +149,6 @@ func (h *VehicleHandler) CreateVehicle(c *fiber.Ctx) error {
 }
 
 func (h *VehicleHandler) UpdateVehicle(c *fiber.Ctx) error {
-	authCtx := middleware.GetAuthContext(c)
-	
-	// Only admin and sales can update vehicles
-	if authCtx.Role != models.RoleAdmin && authCtx.Role != models.RoleSales {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": "Insufficient permissions",
-		})
-	}
-
 	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -149,11 +156,9 @@ func (h *VehicleHandler) UpdateVehicle(c *fiber.Ctx) error {
 		})
 	}
 
-	var req CreateVehicleRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	var req UpdateVehicleRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
 	}
 
 	var vehicle models.Vehicle
@@ -163,37 +168,73 @@ func (h *VehicleHandler) UpdateVehicle(c *fiber.Ctx) error {
 		})
 	}
 
-	// Update fields
-	vehicle.Make = req.Make
-	vehicle.Model = req.Model
-	vehicle.Year = req.Year
-	vehicle.Color = req.Color
-	vehicle.VIN = req.VIN
-	vehicle.LicensePlate = req.LicensePlate
-	vehicle.Price = req.Price
-	vehicle.Mileage = req.Mileage
-	vehicle.Status = req.Status
-	vehicle.Description = req.Description
+	// Reject edits made against a stale copy of the vehicle
+	if req.Version != 0 && req.Version != vehicle.Version {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":           "Vehicle was modified by another request",
+			"current_version": vehicle.Version,
+		})
+	}
 
-	if err := database.DB.Save(&vehicle).Error; err != nil {
+	updates := map[string]interface{}{
+		"make":           req.Make,
+		"model":          req.Model,
+		"year":           req.Year,
+		"color":          req.Color,
+		"vin":            req.VIN,
+		"license_plate":  req.LicensePlate,
+		"price_minor":    req.Price.Minor,
+		"price_currency": req.Price.Currency,
+		"mileage":        req.Mileage,
+		"description":    req.Description,
+		"category":       req.Category,
+		"version":        gorm.Expr("version + 1"),
+	}
+	if req.Status != "" {
+		updates["status"] = req.Status
+		if models.RestartsStockAge(vehicle.Status, req.Status) {
+			updates["stocked_at"] = time.Now()
+		}
+	}
+
+	// Only write if nobody (e.g. a sale reserving the vehicle) changed it since
+	// it was read above, and keep the price history with the price
+	var rowsAffected int64
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Vehicle{}).
+			Where("id = ? AND version = ?", vehicle.ID, vehicle.Version).
+			Updates(updates)
+		rowsAffected = result.RowsAffected
+		if result.Error != nil || rowsAffected == 0 || req.Price == vehicle.Price {
+			return result.Error
+		}
+		changedBy := middleware.GetAuthContext(c).UserID
+		return tx.Create(&models.VehiclePriceChange{
+			VehicleID:   vehicle.ID,
+			Source:      models.PriceChangeManual,
+			OldPrice:    vehicle.Price,
+			NewPrice:    req.Price,
+			DaysInStock: vehicle.DaysInStock(time.Now()),
+			ChangedByID: &changedBy,
+		}).Error
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update vehicle",
 		})
 	}
+	if rowsAffected == 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Vehicle was modified by another request",
+		})
+	}
+
+	database.DB.First(&vehicle, vehicle.ID)
 
 	return c.JSON(vehicle)
 }
 
 func (h *VehicleHandler) DeleteVehicle(c *fiber.Ctx) error {
-	authCtx := middleware.GetAuthContext(c)
-	
-	// Only admin can delete vehicles
-	if authCtx.Role != models.RoleAdmin {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": "Insufficient permissions",
-		})
-	}
-
 	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
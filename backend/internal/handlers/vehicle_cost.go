@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"time"
+
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/services"
+	"vehicle-sales-backend/internal/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type VehicleCostHandler struct {
+	costs *services.VehicleCostService
+}
+
+func NewVehicleCostHandler() *VehicleCostHandler {
+	return &VehicleCostHandler{costs: services.NewVehicleCostService()}
+}
+
+// AcquisitionRequest records what was paid for a vehicle and where it came
+// from. AcquiredAt is left alone when not given
+type AcquisitionRequest struct {
+	PurchaseCost money.Money              `json:"purchase_cost" validate:"min=0"`
+	Source       models.AcquisitionSource `json:"acquisition_source" validate:"required,enum"`
+	AcquiredAt   *time.Time               `json:"acquired_at"`
+}
+
+// ExpenseRequest is a reconditioning expense; IncurredAt defaults to now
+type ExpenseRequest struct {
+	Category    models.ExpenseCategory `json:"category" validate:"required,enum"`
+	Description string                 `json:"description" validate:"required,max=200"`
+	Vendor      string                 `json:"vendor" validate:"max=100"`
+	InvoiceRef  string                 `json:"invoice_ref" validate:"max=100"`
+	Amount      money.Money            `json:"amount" validate:"gt=0"`
+	IncurredAt  *time.Time             `json:"incurred_at"`
+}
+
+// GetVehicleCosts reports what a vehicle has cost since it last came into
+// stock, with its reconditioning expenses
+func (h *VehicleCostHandler) GetVehicleCosts(c *fiber.Ctx) error {
+	costs, err := h.costs.Costs(idParam(c))
+	if err != nil {
+		return serviceError(c, err, "Failed to retrieve vehicle costs")
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   costs,
+	})
+}
+
+// SetVehicleAcquisition records what was paid for a vehicle
+func (h *VehicleCostHandler) SetVehicleAcquisition(c *fiber.Ctx) error {
+	var req AcquisitionRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	costs, err := h.costs.SetAcquisition(idParam(c), services.AcquisitionInput{
+		PurchaseCost: req.PurchaseCost,
+		Source:       req.Source,
+		AcquiredAt:   req.AcquiredAt,
+	})
+	if err != nil {
+		return serviceError(c, err, "Failed to record vehicle acquisition")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"data":    costs,
+		"message": "Vehicle acquisition recorded successfully",
+	})
+}
+
+// AddVehicleExpense records a reconditioning expense against a vehicle
+func (h *VehicleCostHandler) AddVehicleExpense(c *fiber.Ctx) error {
+	var req ExpenseRequest
+	if err := validation.BindBody(c, &req); err != nil {
+		return err
+	}
+
+	expense, err := h.costs.AddExpense(actor(c), idParam(c), services.ExpenseInput{
+		Category:    req.Category,
+		Description: req.Description,
+		Vendor:      req.Vendor,
+		InvoiceRef:  req.InvoiceRef,
+		Amount:      req.Amount,
+		IncurredAt:  req.IncurredAt,
+	})
+	if err != nil {
+		return serviceError(c, err, "Failed to record expense")
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"status":  "success",
+		"data":    expense,
+		"message": "Expense recorded successfully",
+	})
+}
+
+// DeleteVehicleExpense removes an expense recorded in error
+func (h *VehicleCostHandler) DeleteVehicleExpense(c *fiber.Ctx) error {
+	expenseID, err := c.ParamsInt("expenseId")
+	if err != nil || expenseID < 0 {
+		expenseID = 0
+	}
+
+	if err := h.costs.DeleteExpense(idParam(c), uint(expenseID)); err != nil {
+		return serviceError(c, err, "Failed to delete expense")
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  "success",
+		"message": "Expense deleted successfully",
+	})
+}
@@ -0,0 +1,78 @@
+// Package idempotency stores the responses of requests made with an
+// Idempotency-Key so that retried requests are not executed twice.
+package idempotency
+
+import (
+	"errors"
+	"time"
+
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Begin claims key within scope (usually the caller) for a new request. When
+// the key is already taken it returns the existing record and claimed is
+// false; the caller compares fingerprints and replays or rejects. Expired
+// keys are reclaimed.
+func Begin(scope, key, method, path, fingerprint string, ttl time.Duration) (record *models.IdempotencyKey, claimed bool, err error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		now := time.Now()
+		record = &models.IdempotencyKey{
+			Scope:       scope,
+			Key:         key,
+			Method:      method,
+			Path:        path,
+			Fingerprint: fingerprint,
+			ExpiresAt:   now.Add(ttl),
+		}
+		result := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+		if result.Error != nil {
+			return nil, false, result.Error
+		}
+		if result.RowsAffected == 1 {
+			return record, true, nil
+		}
+
+		existing := &models.IdempotencyKey{}
+		err := database.DB.Where("scope = ? AND key = ?", scope, key).First(existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// released between the insert and the lookup
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if existing.ExpiresAt.After(now) {
+			return existing, false, nil
+		}
+		if err := database.DB.Where("id = ? AND expires_at <= ?", existing.ID, now).Delete(&models.IdempotencyKey{}).Error; err != nil {
+			return nil, false, err
+		}
+	}
+	return nil, false, errors.New("idempotency key is contended")
+}
+
+// Complete stores the response of the request that claimed the key.
+func Complete(id uint, statusCode int, contentType string, body []byte) error {
+	now := time.Now()
+	return database.DB.Model(&models.IdempotencyKey{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status_code":   statusCode,
+		"content_type":  contentType,
+		"response_body": body,
+		"completed_at":  &now,
+	}).Error
+}
+
+// Release frees a key whose request failed in a way worth retrying.
+func Release(id uint) error {
+	return database.DB.Delete(&models.IdempotencyKey{}, id).Error
+}
+
+// Prune deletes expired keys.
+func Prune(now time.Time) (int64, error) {
+	result := database.DB.Where("expires_at < ?", now).Delete(&models.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,41 @@
+// Package jobs runs periodic background maintenance such as pruning expired
+// rows.
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Job is a task run on a fixed interval.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Start runs every job once immediately and then on its interval until ctx is
+// canceled. Failures are logged and retried on the next tick.
+func Start(ctx context.Context, jobs ...Job) {
+	for _, job := range jobs {
+		go run(ctx, job)
+	}
+}
+
+func run(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job.Run(ctx); err != nil {
+			log.Printf("Job %s failed: %v", job.Name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -14,6 +14,7 @@ type AuthContext struct {
 	UserID uint
 	Email  string
 	Role   models.UserRole
+	Claims *auth.JWTClaims
 }
 
 func AuthRequired(config *config.Config) fiber.Handler {
@@ -40,33 +41,25 @@ func AuthRequired(config *config.Config) fiber.Handler {
 			})
 		}
 
+		// Reject tokens revoked by logout, deactivation, role or password change
+		if err := auth.CheckRevocation(claims); err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Token has been revoked",
+			})
+		}
+
 		// Store auth context in locals
 		c.Locals("auth", &AuthContext{
 			UserID: claims.UserID,
 			Email:  claims.Email,
 			Role:   claims.Role,
+			Claims: claims,
 		})
 
 		return c.Next()
 	}
 }
 
-func RoleRequired(allowedRoles ...models.UserRole) fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		authCtx := c.Locals("auth").(*AuthContext)
-		
-		for _, role := range allowedRoles {
-			if authCtx.Role == role {
-				return c.Next()
-			}
-		}
-
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": "Insufficient permissions",
-		})
-	}
-}
-
 func GetAuthContext(c *fiber.Ctx) *AuthContext {
 	return c.Locals("auth").(*AuthContext)
 }
\ No newline at end of file
@@ -9,7 +9,8 @@ func CORS() fiber.Handler {
 	return cors.New(cors.Config{
 		AllowOrigins:     "*",
 		AllowMethods:     "GET,POST,PUT,DELETE,PATCH,OPTIONS",
-		AllowHeaders:     "Origin,Content-Type,Accept,Authorization",
+		AllowHeaders:     "Origin,Content-Type,Accept,Authorization,Idempotency-Key",
+		ExposeHeaders:    "Idempotent-Replayed",
 		AllowCredentials: false,
 	})
 }
\ No newline at end of file
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"errors"
+
+	"vehicle-sales-backend/internal/validation"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrorHandler renders errors returned from handlers. Validation failures
+// become a 422 listing the offending fields; everything else keeps the
+// {"error": "..."} shape.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	var verr *validation.Error
+	if errors.As(err, &verr) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(verr.Response())
+	}
+
+	code := fiber.StatusInternalServerError
+	var ferr *fiber.Error
+	if errors.As(err, &ferr) {
+		code = ferr.Code
+	}
+	return c.Status(code).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"vehicle-sales-backend/internal/idempotency"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	// IdempotencyKeyHeader carries the client's key for a POST request.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader marks a response replayed from an earlier
+	// request with the same key.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// Idempotency makes POST requests sent with an Idempotency-Key safe to
+// retry. The first response for a key is stored for ttl and replayed for
+// later requests with the same key; a duplicate arriving while the first is
+// still running gets a 409, and reusing a key for a different request a 422.
+// Server errors are not stored so the request can be retried. Keys must be
+// UUIDs. When used with AuthRequired it must run after it, as keys are
+// scoped to the caller; anonymous callers are told apart by IP address and
+// path, which for shared quotes includes the link's token. Responses that
+// carry tokens must not go through it, as they would be replayed.
+func Idempotency(ttl time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(IdempotencyKeyHeader)
+		if c.Method() != fiber.MethodPost || key == "" {
+			return c.Next()
+		}
+		if _, err := uuid.Parse(key); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Idempotency-Key must be a UUID",
+			})
+		}
+
+		scope := fmt.Sprintf("anonymous:%s:%s", c.IP(), c.Path())
+		if authCtx, ok := c.Locals("auth").(*AuthContext); ok {
+			scope = fmt.Sprintf("user:%d", authCtx.UserID)
+		}
+		sum := sha256.Sum256([]byte(c.Method() + "\n" + c.Path() + "\n" + string(c.Body())))
+		fingerprint := hex.EncodeToString(sum[:])
+
+		record, claimed, err := idempotency.Begin(scope, key, c.Method(), c.Path(), fingerprint, ttl)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to check idempotency key",
+			})
+		}
+		if !claimed {
+			if record.Fingerprint != fingerprint {
+				return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+					"error": "Idempotency-Key was already used for a different request",
+				})
+			}
+			if record.CompletedAt == nil {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "A request with this Idempotency-Key is still being processed",
+				})
+			}
+			c.Set(IdempotentReplayedHeader, "true")
+			if record.ContentType != "" {
+				c.Set(fiber.HeaderContentType, record.ContentType)
+			}
+			return c.Status(record.StatusCode).Send(record.ResponseBody)
+		}
+
+		completed := false
+		defer func() {
+			// free the key if the handler panicked
+			if !completed {
+				if err := idempotency.Release(record.ID); err != nil {
+					log.Printf("Failed to release idempotency key %d: %v", record.ID, err)
+				}
+			}
+		}()
+
+		if err := c.Next(); err != nil {
+			// render the error now so the response can be stored
+			if err := c.App().Config().ErrorHandler(c, err); err != nil {
+				return err
+			}
+		}
+
+		status := c.Response().StatusCode()
+		if status >= fiber.StatusInternalServerError {
+			return nil
+		}
+		body := append([]byte(nil), c.Response().Body()...)
+		contentType := string(c.Response().Header.ContentType())
+		if err := idempotency.Complete(record.ID, status, contentType, body); err != nil {
+			log.Printf("Failed to store response for idempotency key %d: %v", record.ID, err)
+			return nil
+		}
+		completed = true
+		return nil
+	}
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"vehicle-sales-backend/internal/permissions"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// PermissionRequired rejects callers whose role has not been granted the
+// permission. It must run after AuthRequired.
+func PermissionRequired(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !HasPermission(c, permission) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Insufficient permissions",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// HasPermission reports whether the authenticated caller's role holds the
+// permission, for checks that depend on the request body or record state.
+func HasPermission(c *fiber.Ctx, permission string) bool {
+	return permissions.Has(GetAuthContext(c).Role, permission)
+}
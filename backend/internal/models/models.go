@@ -1,8 +1,11 @@
 package models
 
 import (
+	"strings"
 	"time"
 
+	"vehicle-sales-backend/internal/money"
+
 	"gorm.io/gorm"
 )
 
@@ -15,6 +18,14 @@ const (
 	RoleCustomer UserRole = "customer"
 )
 
+func (r UserRole) IsValid() bool {
+	switch r {
+	case RoleAdmin, RoleSales, RoleCashier, RoleCustomer:
+		return true
+	}
+	return false
+}
+
 type User struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
 	CreatedAt time.Time      `json:"created_at"`
@@ -29,12 +40,115 @@ type User struct {
 	IsActive  bool     `json:"is_active" gorm:"default:true"`
 	AvatarURL string   `json:"avatar_url"`
 
+	// TokenVersion is embedded in every access token; bumping it invalidates
+	// all tokens issued before the bump.
+	TokenVersion int `json:"-" gorm:"not null;default:0"`
+
 	// Relationships
 	Sales          []Sale        `json:"sales,omitempty" gorm:"foreignKey:SalesPersonID"`
 	CustomerOrders []Sale        `json:"customer_orders,omitempty" gorm:"foreignKey:CustomerID"`
 	Transactions   []Transaction `json:"transactions,omitempty" gorm:"foreignKey:ProcessedByID"`
 }
 
+// RefreshToken is a single-use, rotating refresh token. Tokens issued from the
+// same login share a FamilyID so that reuse of a rotated token can revoke the
+// whole chain.
+type RefreshToken struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID       uint       `json:"user_id" gorm:"not null;index"`
+	TokenHash    string     `json:"-" gorm:"uniqueIndex;not null"`
+	FamilyID     string     `json:"family_id" gorm:"index;not null"`
+	ExpiresAt    time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	ReplacedByID *uint      `json:"replaced_by_id"`
+
+	// Relationships
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// RevokedToken is the access token denylist, keyed by the token's jti.
+type RevokedToken struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	JTI       string    `json:"jti" gorm:"uniqueIndex;not null"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null;index"`
+}
+
+// IdempotencyKey records a POST request made with an Idempotency-Key header
+// so that retries replay the stored response instead of repeating the
+// request. StatusCode is zero while the first request is still in flight.
+type IdempotencyKey struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Scope        string     `json:"scope" gorm:"not null;uniqueIndex:idx_idempotency_keys_scope_key"`
+	Key          string     `json:"key" gorm:"not null;uniqueIndex:idx_idempotency_keys_scope_key"`
+	Method       string     `json:"method" gorm:"not null"`
+	Path         string     `json:"path" gorm:"not null"`
+	Fingerprint  string     `json:"-" gorm:"not null"`
+	StatusCode   int        `json:"status_code"`
+	ContentType  string     `json:"-"`
+	ResponseBody []byte     `json:"-"`
+	CompletedAt  *time.Time `json:"completed_at"`
+	ExpiresAt    time.Time  `json:"expires_at" gorm:"not null;index"`
+}
+
+// Invitation lets an admin onboard staff without sharing a password. The
+// invitee redeems a signed token and chooses their own password.
+type Invitation struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+
+	Email       string     `json:"email" gorm:"not null;index"`
+	Name        string     `json:"name"`
+	Role        UserRole   `json:"role" gorm:"not null"`
+	InvitedByID uint       `json:"invited_by_id" gorm:"not null"`
+	ExpiresAt   time.Time  `json:"expires_at" gorm:"not null"`
+	AcceptedAt  *time.Time `json:"accepted_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	UserID      *uint      `json:"user_id"`
+
+	// Relationships
+	InvitedBy User  `json:"invited_by,omitempty" gorm:"foreignKey:InvitedByID"`
+	User      *User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// Role is a named set of permissions. The built-in roles (admin, sales,
+// cashier, customer) are system roles; admins can add custom ones such as
+// "sales_manager". Users reference a role by Name.
+type Role struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Name        UserRole `json:"name" gorm:"uniqueIndex;not null"`
+	DisplayName string   `json:"display_name" gorm:"not null"`
+	Description string   `json:"description"`
+	IsSystem    bool     `json:"is_system" gorm:"default:false"`
+	// MaxDiscountRate is the largest discount off a vehicle's price, in basis
+	// points, that users of this role may give without approval; nil means
+	// unlimited
+	MaxDiscountRate *int64 `json:"max_discount_rate"`
+
+	// Relationships
+	Permissions []Permission `json:"permissions,omitempty" gorm:"many2many:role_permissions"`
+}
+
+type Permission struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Name        string `json:"name" gorm:"uniqueIndex;not null"`
+	Description string `json:"description"`
+}
+
 type VehicleStatus string
 
 const (
@@ -42,8 +156,25 @@ const (
 	VehicleStatusSold      VehicleStatus = "sold"
 	VehicleStatusReserved  VehicleStatus = "reserved"
 	VehicleStatusService   VehicleStatus = "service"
+	// Intake vehicles have been taken in trade and are not yet ready to sell
+	VehicleStatusIntake    VehicleStatus = "intake"
 )
 
+func (s VehicleStatus) IsValid() bool {
+	switch s {
+	case VehicleStatusAvailable, VehicleStatusSold, VehicleStatusReserved, VehicleStatusService, VehicleStatusIntake:
+		return true
+	}
+	return false
+}
+
+// RestartsStockAge reports whether a vehicle moving from one status to
+// another restarts its days in stock: it is put up for sale after intake,
+// or comes back after being sold. Reservations and service visits do not.
+func RestartsStockAge(from, to VehicleStatus) bool {
+	return to == VehicleStatusAvailable && (from == VehicleStatusIntake || from == VehicleStatusSold)
+}
+
 type Vehicle struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
 	CreatedAt time.Time      `json:"created_at"`
@@ -56,10 +187,22 @@ type Vehicle struct {
 	Color        string        `json:"color"`
 	VIN          string        `json:"vin" gorm:"uniqueIndex"`
 	LicensePlate string        `json:"license_plate"`
-	Price        float64       `json:"price" gorm:"not null"`
+	Price        money.Money   `json:"price" gorm:"embedded;embeddedPrefix:price_"`
 	Mileage      int           `json:"mileage"`
 	Status       VehicleStatus `json:"status" gorm:"default:'available'"`
 	Description  string        `json:"description"`
+	// Category groups vehicles for tax purposes, e.g. sedan, suv or
+	// commercial; luxury tax (PPnBM) rates are set per category
+	Category     string        `json:"category"`
+	Version      uint          `json:"version" gorm:"not null;default:1"`
+	// AcquiredAt is when the vehicle last came into stock. What the
+	// dealership paid for it then and where it came from are internal and
+	// reported through VehicleCosts only
+	AcquiredAt        time.Time         `json:"acquired_at" gorm:"not null;default:CURRENT_TIMESTAMP"`
+	PurchaseCost      money.Money       `json:"-" gorm:"embedded;embeddedPrefix:purchase_cost_"`
+	AcquisitionSource AcquisitionSource `json:"-" gorm:"default:null"`
+	// StockedAt starts the vehicle's days in stock; see RestartsStockAge
+	StockedAt time.Time `json:"stocked_at" gorm:"not null;default:CURRENT_TIMESTAMP"`
 
 	// Relationships
 	Images    []VehicleImage `json:"images,omitempty" gorm:"foreignKey:VehicleID"`
@@ -67,6 +210,188 @@ type Vehicle struct {
 	Sales     []Sale         `json:"sales,omitempty" gorm:"foreignKey:VehicleID"`
 }
 
+// AcquisitionSource is where the dealership bought a vehicle.
+type AcquisitionSource string
+
+const (
+	AcquisitionTradeIn      AcquisitionSource = "trade_in"
+	AcquisitionAuction      AcquisitionSource = "auction"
+	AcquisitionDealer       AcquisitionSource = "dealer"
+	AcquisitionPrivate      AcquisitionSource = "private"
+	AcquisitionManufacturer AcquisitionSource = "manufacturer"
+)
+
+func (s AcquisitionSource) IsValid() bool {
+	switch s {
+	case AcquisitionTradeIn, AcquisitionAuction, AcquisitionDealer, AcquisitionPrivate, AcquisitionManufacturer:
+		return true
+	}
+	return false
+}
+
+type ExpenseCategory string
+
+const (
+	ExpenseCategoryParts      ExpenseCategory = "parts"
+	ExpenseCategoryLabor      ExpenseCategory = "labor"
+	ExpenseCategoryDetailing  ExpenseCategory = "detailing"
+	ExpenseCategoryInspection ExpenseCategory = "inspection"
+	ExpenseCategoryTransport  ExpenseCategory = "transport"
+	ExpenseCategoryOther      ExpenseCategory = "other"
+)
+
+func (c ExpenseCategory) IsValid() bool {
+	switch c {
+	case ExpenseCategoryParts, ExpenseCategoryLabor, ExpenseCategoryDetailing,
+		ExpenseCategoryInspection, ExpenseCategoryTransport, ExpenseCategoryOther:
+		return true
+	}
+	return false
+}
+
+// ReconditioningExpense is money spent on repairing or preparing a vehicle
+// for sale. Expenses recorded before the vehicle last came into stock belong
+// to an earlier stay and are not part of its cost.
+type ReconditioningExpense struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+
+	VehicleID    uint            `json:"vehicle_id" gorm:"not null;index"`
+	RecordedByID uint            `json:"recorded_by_id" gorm:"not null"`
+	Category     ExpenseCategory `json:"category" gorm:"not null"`
+	Description  string          `json:"description" gorm:"not null"`
+	Vendor       string          `json:"vendor"`
+	InvoiceRef   string          `json:"invoice_ref,omitempty"`
+	Amount       money.Money     `json:"amount" gorm:"embedded;embeddedPrefix:amount_"`
+	IncurredAt   time.Time       `json:"incurred_at" gorm:"not null"`
+
+	// Relationships
+	RecordedBy User `json:"recorded_by,omitempty" gorm:"foreignKey:RecordedByID"`
+}
+
+// VehicleCosts is what a vehicle has cost the dealership since it last came
+// into stock: its PurchaseCost plus the Reconditioning expenses itemised in
+// Expenses.
+type VehicleCosts struct {
+	VehicleID         uint                    `json:"vehicle_id"`
+	AcquiredAt        time.Time               `json:"acquired_at"`
+	AcquisitionSource AcquisitionSource       `json:"acquisition_source,omitempty"`
+	PurchaseCost      money.Money             `json:"purchase_cost"`
+	Reconditioning    money.Money             `json:"reconditioning"`
+	Total             money.Money             `json:"total"`
+	Expenses          []ReconditioningExpense `json:"expenses"`
+}
+
+// DaysInStock is how many whole days a vehicle has been in stock at now.
+func (v *Vehicle) DaysInStock(now time.Time) int {
+	if now.Before(v.StockedAt) {
+		return 0
+	}
+	return int(now.Sub(v.StockedAt).Hours() / 24)
+}
+
+type PriceChangeSource string
+
+const (
+	PriceChangeManual    PriceChangeSource = "manual"
+	PriceChangeRepricing PriceChangeSource = "repricing"
+)
+
+// VehiclePriceChange records a change to a vehicle's price, made by a user
+// or by a repricing rule. DaysInStock is the vehicle's age at the time.
+type VehiclePriceChange struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	VehicleID   uint              `json:"vehicle_id" gorm:"not null;index"`
+	Source      PriceChangeSource `json:"source" gorm:"not null"`
+	OldPrice    money.Money       `json:"old_price" gorm:"embedded;embeddedPrefix:old_price_"`
+	NewPrice    money.Money       `json:"new_price" gorm:"embedded;embeddedPrefix:new_price_"`
+	DaysInStock int               `json:"days_in_stock"`
+	RuleID      *uint             `json:"rule_id,omitempty"`
+	ChangedByID *uint             `json:"changed_by_id,omitempty"`
+
+	// Relationships
+	Rule      *RepricingRule `json:"rule,omitempty" gorm:"foreignKey:RuleID"`
+	ChangedBy *User          `json:"changed_by,omitempty" gorm:"foreignKey:ChangedByID"`
+}
+
+// RepricingRule lowers the price of available vehicles the longer they stay
+// in stock: by DropRate every EveryDays days, but not below FloorMarkup over
+// the vehicle's purchase cost. Rates are in basis points. Make, Model and
+// Category narrow the vehicles it applies to; the most specific active rule
+// that matches a vehicle applies.
+type RepricingRule struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+
+	Name        string `json:"name" gorm:"not null"`
+	Make        string `json:"make,omitempty"`
+	Model       string `json:"model,omitempty"`
+	Category    string `json:"category,omitempty"`
+	EveryDays   int    `json:"every_days" gorm:"not null"`
+	DropRate    int64  `json:"drop_rate" gorm:"not null"`
+	FloorMarkup int64  `json:"floor_markup" gorm:"not null"`
+	IsActive    bool   `json:"is_active" gorm:"not null"`
+}
+
+// Matches reports whether the rule applies to vehicle, ignoring whether it
+// is active.
+func (r *RepricingRule) Matches(vehicle *Vehicle) bool {
+	return (r.Make == "" || strings.EqualFold(r.Make, vehicle.Make)) &&
+		(r.Model == "" || strings.EqualFold(r.Model, vehicle.Model)) &&
+		(r.Category == "" || r.Category == vehicle.Category)
+}
+
+// Specificity is the number of filters the rule sets; the rule setting the
+// most wins when several match.
+func (r *RepricingRule) Specificity() int {
+	n := 0
+	for _, filter := range []string{r.Make, r.Model, r.Category} {
+		if filter != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// AgingBucket counts the vehicles whose days in stock fall between MinDays
+// and MaxDays, both inclusive; MaxDays is nil for the last bucket.
+type AgingBucket struct {
+	Label      string      `json:"label"`
+	MinDays    int         `json:"min_days"`
+	MaxDays    *int        `json:"max_days"`
+	Vehicles   int         `json:"vehicles"`
+	StockValue money.Money `json:"stock_value"`
+}
+
+// AgingVehicle is a vehicle in the aging report.
+type AgingVehicle struct {
+	ID          uint          `json:"id"`
+	Make        string        `json:"make"`
+	Model       string        `json:"model"`
+	Year        int           `json:"year"`
+	VIN         string        `json:"vin"`
+	Status      VehicleStatus `json:"status"`
+	Price       money.Money   `json:"price"`
+	StockedAt   time.Time     `json:"stocked_at"`
+	DaysInStock int           `json:"days_in_stock"`
+	Bucket      string        `json:"bucket"`
+}
+
+// AgingReport buckets the vehicles in stock by how long they have been
+// there. StockValue covers prices in Currency only.
+type AgingReport struct {
+	AsOf     time.Time      `json:"as_of"`
+	Currency money.Currency `json:"currency"`
+	Buckets  []AgingBucket  `json:"buckets"`
+	Vehicles []AgingVehicle `json:"vehicles"`
+}
+
 type VehicleImage struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
 	CreatedAt time.Time      `json:"created_at"`
@@ -90,6 +415,14 @@ const (
 	TestDriveStatusCanceled  TestDriveStatus = "canceled"
 )
 
+func (s TestDriveStatus) IsValid() bool {
+	switch s {
+	case TestDriveStatusPending, TestDriveStatusApproved, TestDriveStatusCompleted, TestDriveStatusCanceled:
+		return true
+	}
+	return false
+}
+
 type TestDrive struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
 	CreatedAt time.Time      `json:"created_at"`
@@ -111,12 +444,27 @@ type TestDrive struct {
 type SaleStatus string
 
 const (
-	SaleStatusPending   SaleStatus = "pending"
-	SaleStatusApproved  SaleStatus = "approved"
-	SaleStatusCompleted SaleStatus = "completed"
-	SaleStatusCanceled  SaleStatus = "canceled"
+	SaleStatusAwaitingApproval SaleStatus = "awaiting_approval"
+	SaleStatusPending          SaleStatus = "pending"
+	SaleStatusApproved         SaleStatus = "approved"
+	SaleStatusCompleted        SaleStatus = "completed"
+	SaleStatusCanceled         SaleStatus = "canceled"
 )
 
+func (s SaleStatus) IsValid() bool {
+	switch s {
+	case SaleStatusAwaitingApproval, SaleStatusPending, SaleStatusApproved, SaleStatusCompleted, SaleStatusCanceled:
+		return true
+	}
+	return false
+}
+
+// IsActive reports whether a sale in this status holds its vehicle. At most
+// one active sale may exist per vehicle.
+func (s SaleStatus) IsActive() bool {
+	return s == SaleStatusAwaitingApproval || s == SaleStatusPending || s == SaleStatusApproved
+}
+
 type Sale struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
 	CreatedAt time.Time      `json:"created_at"`
@@ -126,15 +474,421 @@ type Sale struct {
 	VehicleID      uint       `json:"vehicle_id" gorm:"not null"`
 	CustomerID     uint       `json:"customer_id" gorm:"not null"`
 	SalesPersonID  uint       `json:"sales_person_id" gorm:"not null"`
-	SalePrice      float64    `json:"sale_price" gorm:"not null"`
+	// AgreedPrice is the price negotiated with the customer. SalePrice is the
+	// total due: the agreed price plus the taxes and fees charged on top of
+	// it, less any trade-in, as itemised in Lines
+	AgreedPrice    money.Money `json:"agreed_price" gorm:"embedded;embeddedPrefix:agreed_price_"`
+	SalePrice      money.Money `json:"sale_price" gorm:"embedded;embeddedPrefix:sale_price_"`
+	// ListPrice is the vehicle's price when the sale was priced. Discount is
+	// how far the vehicle was sold below it, DiscountRate the same in basis
+	// points of ListPrice
+	ListPrice      money.Money `json:"list_price" gorm:"embedded;embeddedPrefix:list_price_"`
+	Discount       money.Money `json:"discount" gorm:"embedded;embeddedPrefix:discount_"`
+	DiscountRate   int64      `json:"discount_rate"`
+	// TradeIn is the credit given for vehicles traded in, already deducted
+	// from SalePrice
+	TradeIn        money.Money `json:"trade_in" gorm:"embedded;embeddedPrefix:trade_in_"`
 	Status         SaleStatus `json:"status" gorm:"default:'pending'"`
 	Notes          string     `json:"notes"`
 	CompletedAt    *time.Time `json:"completed_at"`
+	Version        uint       `json:"version" gorm:"not null;default:1"`
+	// QuoteID is the accepted quote the sale was converted from
+	QuoteID        *uint      `json:"quote_id,omitempty"`
+	// NetRevenue, VehicleCost and GrossProfit are fixed when the sale
+	// completes; later refunds reduce NetRevenue and GrossProfit. They are
+	// internal and reported as Profit
+	NetRevenue     money.Money `json:"-" gorm:"embedded;embeddedPrefix:net_revenue_"`
+	VehicleCost    money.Money `json:"-" gorm:"embedded;embeddedPrefix:vehicle_cost_"`
+	GrossProfit    money.Money `json:"-" gorm:"embedded;embeddedPrefix:gross_profit_"`
+
+	// Balance is filled in by handlers that report the payment ledger
+	Balance *SaleBalance `json:"balance,omitempty" gorm:"-"`
+	// Profit is filled in for completed sales by handlers whose caller may
+	// see vehicle costs
+	Profit  *SaleProfit  `json:"profit,omitempty" gorm:"-"`
 
 	// Relationships
 	Vehicle     Vehicle `json:"vehicle,omitempty" gorm:"foreignKey:VehicleID"`
 	Customer    User    `json:"customer,omitempty" gorm:"foreignKey:CustomerID"`
 	SalesPerson User    `json:"sales_person,omitempty" gorm:"foreignKey:SalesPersonID"`
+	Lines       []SaleLine `json:"lines,omitempty" gorm:"foreignKey:SaleID"`
+	Approvals   []SaleApproval `json:"approvals,omitempty" gorm:"foreignKey:SaleID"`
+	TradeIns    []TradeIn      `json:"trade_ins,omitempty" gorm:"foreignKey:SaleID"`
+}
+
+type ApprovalAction string
+
+const (
+	ApprovalActionRequested ApprovalAction = "requested"
+	ApprovalActionApproved  ApprovalAction = "approved"
+	ApprovalActionRejected  ApprovalAction = "rejected"
+	ApprovalActionWithdrawn ApprovalAction = "withdrawn"
+)
+
+// SaleApproval is an entry in a sale's discount approval history: a
+// discount above the user's limit is requested when the sale is priced, and
+// approved or rejected by someone whose limit covers it. Repricing within
+// the limit withdraws the request.
+type SaleApproval struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	SaleID       uint           `json:"sale_id" gorm:"not null;index"`
+	UserID       uint           `json:"user_id" gorm:"not null"`
+	Action       ApprovalAction `json:"action" gorm:"not null"`
+	ListPrice    money.Money    `json:"list_price" gorm:"embedded;embeddedPrefix:list_price_"`
+	Discount     money.Money    `json:"discount" gorm:"embedded;embeddedPrefix:discount_"`
+	DiscountRate int64          `json:"discount_rate"`
+	Comment      string         `json:"comment"`
+
+	// Relationships
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// SaleBalance is the payment ledger of a sale. Due is the sale price, net
+// of the TradeIn credit. Paid counts completed transactions, Pending those
+// awaiting processing, and Outstanding is Due - Paid. A sale completes when
+// Outstanding reaches zero.
+type SaleBalance struct {
+	SaleID      uint        `json:"sale_id"`
+	TradeIn     money.Money `json:"trade_in"`
+	Due         money.Money `json:"due"`
+	Paid        money.Money `json:"paid"`
+	Pending     money.Money `json:"pending"`
+	Outstanding money.Money `json:"outstanding"`
+	FullyPaid   bool        `json:"fully_paid"`
+}
+
+// SaleProfit is the front-end gross profit of a completed sale. Revenue is
+// what the dealership kept of the sale price: the vehicle, discount and
+// add-ons less the taxes and fees included in them. GrossProfit is Revenue
+// less the vehicle's cost when the sale completed, and Margin the same in
+// basis points of Revenue.
+type SaleProfit struct {
+	Revenue     money.Money `json:"revenue"`
+	VehicleCost money.Money `json:"vehicle_cost"`
+	GrossProfit money.Money `json:"gross_profit"`
+	Margin      int64       `json:"margin"`
+}
+
+type TaxKind string
+
+const (
+	TaxKindVAT    TaxKind = "vat"
+	TaxKindLuxury TaxKind = "luxury"
+	TaxKindFee    TaxKind = "fee"
+)
+
+func (k TaxKind) IsValid() bool {
+	switch k {
+	case TaxKindVAT, TaxKindLuxury, TaxKindFee:
+		return true
+	}
+	return false
+}
+
+// IsPercentage reports whether taxes of this kind are a rate of the
+// vehicle's price before tax rather than a fixed amount.
+func (k TaxKind) IsPercentage() bool {
+	return k == TaxKindVAT || k == TaxKindLuxury
+}
+
+// TaxRule is a tax or fee charged on vehicle sales between EffectiveFrom and
+// EffectiveTo. VAT (PPN) and luxury tax (PPnBM) are a Rate of the vehicle's
+// price before tax; fees such as plate registration (BBN) are a fixed
+// Amount. Inclusive rules are part of the agreed price, the others are added
+// on top of it.
+type TaxRule struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+
+	Code      string      `json:"code" gorm:"not null;index"`
+	Name      string      `json:"name" gorm:"not null"`
+	Kind      TaxKind     `json:"kind" gorm:"not null"`
+	// Rate is in basis points, 1100 for 11%
+	Rate      int64       `json:"rate"`
+	Amount    money.Money `json:"amount" gorm:"embedded;embeddedPrefix:amount_"`
+	Inclusive bool        `json:"inclusive"`
+	// VehicleCategory limits the rule to one category of vehicles; empty
+	// applies it to every vehicle
+	VehicleCategory string     `json:"vehicle_category"`
+	EffectiveFrom   time.Time  `json:"effective_from" gorm:"not null"`
+	// EffectiveTo is exclusive; nil keeps the rule in force
+	EffectiveTo     *time.Time `json:"effective_to"`
+}
+
+type LineType string
+
+const (
+	LineTypeVehicle  LineType = "vehicle"
+	LineTypeDiscount LineType = "discount"
+	LineTypeAddOn    LineType = "add_on"
+	LineTypeTradeIn  LineType = "trade_in"
+	LineTypeTax      LineType = "tax"
+	LineTypeFee      LineType = "fee"
+)
+
+// IsTaxable reports whether lines of this type make up the agreed price that
+// taxes are charged on.
+func (t LineType) IsTaxable() bool {
+	return t == LineTypeVehicle || t == LineTypeDiscount || t == LineTypeAddOn
+}
+
+// LineItem is one line of a priced sale or quote, at the value agreed with
+// the customer. Tax lines record the rule's Rate and the Base (DPP) it was
+// applied to. Inclusive tax and fee lines are already part of the taxable
+// lines and add nothing to the total; every other line adds its Amount,
+// discounts and trade-ins being negative.
+type LineItem struct {
+	Position    int         `json:"position" gorm:"not null"`
+	Type        LineType    `json:"type" gorm:"not null"`
+	Code        string      `json:"code"`
+	Description string      `json:"description" gorm:"not null"`
+	Rate        int64       `json:"rate"`
+	// Inclusive lines are part of the agreed price
+	Inclusive   bool        `json:"inclusive"`
+	Base        money.Money `json:"base" gorm:"embedded;embeddedPrefix:base_"`
+	Amount      money.Money `json:"amount" gorm:"embedded;embeddedPrefix:amount_"`
+	TaxRuleID   *uint       `json:"tax_rule_id,omitempty"`
+}
+
+// SaleLine is a line item of a sale, fixed when the sale is priced so that
+// later rule changes leave it alone.
+type SaleLine struct {
+	ID     uint `json:"id" gorm:"primaryKey"`
+	SaleID uint `json:"sale_id" gorm:"not null;index"`
+	LineItem
+}
+
+// AddsToTotal reports whether the line's Amount counts towards the total.
+func (l LineItem) AddsToTotal() bool {
+	return !l.Inclusive
+}
+
+// Pricing itemises a deal: AgreedPrice is the sum of the taxable lines, and
+// Total, what the customer pays, adds the taxes and fees not included in it
+// and deducts the trade-in allowance.
+type Pricing struct {
+	AgreedPrice money.Money `json:"agreed_price"`
+	Lines       []LineItem  `json:"lines"`
+	Taxes       money.Money `json:"taxes"`
+	Fees        money.Money `json:"fees"`
+	TradeIn     money.Money `json:"trade_in"`
+	Total       money.Money `json:"total"`
+}
+
+// TaxSummary totals the taxes and fees invoiced in a period, less those
+// credited back by credit notes issued in it. Revenue is what remains for
+// the dealership.
+type TaxSummary struct {
+	From        time.Time        `json:"from"`
+	To          time.Time        `json:"to"`
+	Currency    money.Currency   `json:"currency"`
+	Invoices    int64            `json:"invoices"`
+	CreditNotes int64            `json:"credit_notes"`
+	Lines       []TaxSummaryLine `json:"lines"`
+	Revenue     money.Money      `json:"revenue"`
+	Taxes       money.Money      `json:"taxes"`
+	Fees        money.Money      `json:"fees"`
+}
+
+// TaxSummaryLine totals one tax or fee at one rate. Net is Invoiced -
+// Credited.
+type TaxSummaryLine struct {
+	Type        LineType    `json:"type"`
+	Code        string      `json:"code"`
+	Description string      `json:"description"`
+	Rate        int64       `json:"rate"`
+	Base        money.Money `json:"base"`
+	Invoiced    money.Money `json:"invoiced"`
+	Credited    money.Money `json:"credited"`
+	Net         money.Money `json:"net"`
+}
+
+type QuoteStatus string
+
+const (
+	QuoteStatusDraft     QuoteStatus = "draft"
+	QuoteStatusSent      QuoteStatus = "sent"
+	QuoteStatusAccepted  QuoteStatus = "accepted"
+	QuoteStatusDeclined  QuoteStatus = "declined"
+	QuoteStatusExpired   QuoteStatus = "expired"
+	QuoteStatusConverted QuoteStatus = "converted"
+)
+
+func (s QuoteStatus) IsValid() bool {
+	switch s {
+	case QuoteStatusDraft, QuoteStatusSent, QuoteStatusAccepted, QuoteStatusDeclined, QuoteStatusExpired, QuoteStatusConverted:
+		return true
+	}
+	return false
+}
+
+// IsOpen reports whether a quote in this status can still be accepted.
+func (s QuoteStatus) IsOpen() bool {
+	return s == QuoteStatusDraft || s == QuoteStatusSent
+}
+
+// Quote is a priced offer of a vehicle to a customer, made before a sale
+// reserves the vehicle. Every change is a new revision. The customer accepts
+// a specific revision, and the accepted quote converts into a sale carrying
+// the same lines. Revision, ValidUntil and Total are those of the latest
+// revision.
+type Quote struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+
+	// Number is sequential per branch, e.g. QUO-HQ-000042
+	Number        string      `json:"number" gorm:"not null;uniqueIndex"`
+	VehicleID     uint        `json:"vehicle_id" gorm:"not null;index"`
+	CustomerID    uint        `json:"customer_id" gorm:"not null;index"`
+	SalesPersonID uint        `json:"sales_person_id" gorm:"not null"`
+	Status        QuoteStatus `json:"status" gorm:"not null;default:'draft'"`
+	Revision      int         `json:"revision" gorm:"not null"`
+	ValidUntil    time.Time   `json:"valid_until" gorm:"not null"`
+	Total         money.Money `json:"total" gorm:"embedded;embeddedPrefix:total_"`
+	// ShareToken lets the customer view and accept the quote without
+	// logging in; it is set when the quote is first sent
+	ShareToken    *string     `json:"-" gorm:"uniqueIndex"`
+	SentAt        *time.Time  `json:"sent_at"`
+	AcceptedAt    *time.Time  `json:"accepted_at"`
+	AcceptedRevision *int     `json:"accepted_revision,omitempty"`
+	// AcceptedByID is the user who recorded the acceptance; nil when the
+	// customer accepted through the shared link
+	AcceptedByID  *uint       `json:"accepted_by_id,omitempty"`
+	DeclinedAt    *time.Time  `json:"declined_at"`
+	DeclineReason string      `json:"decline_reason,omitempty"`
+	SaleID        *uint       `json:"sale_id,omitempty"`
+	Version       uint        `json:"version" gorm:"not null;default:1"`
+
+	// Relationships
+	Vehicle     Vehicle         `json:"vehicle,omitempty" gorm:"foreignKey:VehicleID"`
+	Customer    User            `json:"customer,omitempty" gorm:"foreignKey:CustomerID"`
+	SalesPerson User            `json:"sales_person,omitempty" gorm:"foreignKey:SalesPersonID"`
+	Revisions   []QuoteRevision `json:"revisions,omitempty" gorm:"foreignKey:QuoteID"`
+}
+
+// QuoteRevision is one version of a quote's terms and pricing. Revisions are
+// never changed once made.
+type QuoteRevision struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	QuoteID          uint        `json:"quote_id" gorm:"not null;uniqueIndex:idx_quote_revisions_quote_revision"`
+	Revision         int         `json:"revision" gorm:"not null;uniqueIndex:idx_quote_revisions_quote_revision"`
+	CreatedByID      uint        `json:"created_by_id" gorm:"not null"`
+	ListPrice        money.Money `json:"list_price" gorm:"embedded;embeddedPrefix:list_price_"`
+	Discount         money.Money `json:"discount" gorm:"embedded;embeddedPrefix:discount_"`
+	TradeInAllowance money.Money `json:"trade_in_allowance" gorm:"embedded;embeddedPrefix:trade_in_allowance_"`
+	AgreedPrice      money.Money `json:"agreed_price" gorm:"embedded;embeddedPrefix:agreed_price_"`
+	Taxes            money.Money `json:"taxes" gorm:"embedded;embeddedPrefix:taxes_"`
+	Fees             money.Money `json:"fees" gorm:"embedded;embeddedPrefix:fees_"`
+	Total            money.Money `json:"total" gorm:"embedded;embeddedPrefix:total_"`
+	ValidUntil       time.Time   `json:"valid_until" gorm:"not null"`
+	Notes            string      `json:"notes"`
+
+	// Relationships
+	Lines []QuoteLine `json:"lines,omitempty" gorm:"foreignKey:QuoteRevisionID"`
+}
+
+// QuoteLine is a line item of a quote revision.
+type QuoteLine struct {
+	ID              uint `json:"id" gorm:"primaryKey"`
+	QuoteRevisionID uint `json:"quote_revision_id" gorm:"not null;index"`
+	LineItem
+}
+
+type TradeInStatus string
+
+const (
+	TradeInStatusAppraised TradeInStatus = "appraised"
+	TradeInStatusAccepted  TradeInStatus = "accepted"
+	TradeInStatusDeclined  TradeInStatus = "declined"
+)
+
+func (s TradeInStatus) IsValid() bool {
+	switch s {
+	case TradeInStatusAppraised, TradeInStatusAccepted, TradeInStatusDeclined:
+		return true
+	}
+	return false
+}
+
+// Condition grades one item of a trade-in appraisal's checklist.
+type Condition string
+
+const (
+	ConditionGood Condition = "good"
+	ConditionFair Condition = "fair"
+	ConditionPoor Condition = "poor"
+)
+
+func (c Condition) IsValid() bool {
+	return c == ConditionGood || c == ConditionFair || c == ConditionPoor
+}
+
+// TradeIn is the appraisal of a customer's vehicle offered in part exchange.
+// Accepting the offered value takes the vehicle into inventory in intake
+// status, and applying the trade-in to one of the customer's sales deducts
+// the value from the sale price as a credit.
+type TradeIn struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+
+	CustomerID   uint          `json:"customer_id" gorm:"not null;index"`
+	AppraiserID  uint          `json:"appraiser_id" gorm:"not null"`
+	Status       TradeInStatus `json:"status" gorm:"not null;default:'appraised'"`
+	Make         string        `json:"make" gorm:"not null"`
+	Model        string        `json:"model" gorm:"not null"`
+	Year         int           `json:"year" gorm:"not null"`
+	Color        string        `json:"color"`
+	VIN          string        `json:"vin" gorm:"not null"`
+	LicensePlate string        `json:"license_plate"`
+	Mileage      int           `json:"mileage"`
+	Category     string        `json:"category"`
+	OfferedValue money.Money   `json:"offered_value" gorm:"embedded;embeddedPrefix:offered_value_"`
+	ValidUntil   *time.Time    `json:"valid_until"`
+	Notes        string        `json:"notes"`
+	AcceptedAt   *time.Time    `json:"accepted_at"`
+	DeclinedAt   *time.Time    `json:"declined_at"`
+	DeclineReason string       `json:"decline_reason,omitempty"`
+	// VehicleID is the inventory vehicle created when the trade-in was
+	// accepted
+	VehicleID    *uint         `json:"vehicle_id,omitempty"`
+	// SaleID is the sale the trade-in is credited against
+	SaleID       *uint         `json:"sale_id,omitempty" gorm:"index"`
+	AppliedAt    *time.Time    `json:"applied_at"`
+	Version      uint          `json:"version" gorm:"not null;default:1"`
+
+	// Relationships
+	Customer  User           `json:"customer,omitempty" gorm:"foreignKey:CustomerID"`
+	Appraiser User           `json:"appraiser,omitempty" gorm:"foreignKey:AppraiserID"`
+	Vehicle   *Vehicle       `json:"vehicle,omitempty" gorm:"foreignKey:VehicleID"`
+	Checklist []TradeInCheck `json:"checklist,omitempty" gorm:"foreignKey:TradeInID"`
+	Photos    []TradeInPhoto `json:"photos,omitempty" gorm:"foreignKey:TradeInID"`
+}
+
+// TradeInCheck is one item of an appraisal's condition checklist, e.g.
+// "Engine" or "Bodywork".
+type TradeInCheck struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	TradeInID uint      `json:"trade_in_id" gorm:"not null;index"`
+	Item      string    `json:"item" gorm:"not null"`
+	Condition Condition `json:"condition" gorm:"not null"`
+	Notes     string    `json:"notes"`
+}
+
+type TradeInPhoto struct {
+	ID        uint   `json:"id" gorm:"primaryKey"`
+	TradeInID uint   `json:"trade_in_id" gorm:"not null;index"`
+	URL       string `json:"url" gorm:"not null"`
+	Caption   string `json:"caption"`
 }
 
 type PaymentMethod string
@@ -146,15 +900,93 @@ const (
 	PaymentMethodFinancing    PaymentMethod = "financing"
 )
 
+// PaymentMethods lists every payment method, cash first.
+var PaymentMethods = []PaymentMethod{
+	PaymentMethodCash, PaymentMethodCard, PaymentMethodBankTransfer, PaymentMethodFinancing,
+}
+
+func (m PaymentMethod) IsValid() bool {
+	switch m {
+	case PaymentMethodCash, PaymentMethodCard, PaymentMethodBankTransfer, PaymentMethodFinancing:
+		return true
+	}
+	return false
+}
+
 type TransactionStatus string
 
 const (
 	TransactionStatusPending   TransactionStatus = "pending"
 	TransactionStatusCompleted TransactionStatus = "completed"
 	TransactionStatusFailed    TransactionStatus = "failed"
+	// Refunded marks payments refunded in full before refunds were recorded
+	// as separate transactions.
 	TransactionStatusRefunded  TransactionStatus = "refunded"
 )
 
+func (s TransactionStatus) IsValid() bool {
+	switch s {
+	case TransactionStatusPending, TransactionStatusCompleted, TransactionStatusFailed, TransactionStatusRefunded:
+		return true
+	}
+	return false
+}
+
+// TransactionType distinguishes a booking deposit, taken while the sale is
+// still pending, from a payment against an approved sale. Both count towards
+// the sale balance. Refunds are negative transactions linked to the payment
+// they return money from.
+type TransactionType string
+
+const (
+	TransactionTypeDeposit TransactionType = "deposit"
+	TransactionTypePayment TransactionType = "payment"
+	TransactionTypeRefund  TransactionType = "refund"
+)
+
+func (t TransactionType) IsValid() bool {
+	switch t {
+	case TransactionTypeDeposit, TransactionTypePayment, TransactionTypeRefund:
+		return true
+	}
+	return false
+}
+
+// RefundReason is the mandatory reason code of a refund.
+type RefundReason string
+
+const (
+	RefundReasonVehicleReturned  RefundReason = "vehicle_returned"
+	RefundReasonDealCanceled     RefundReason = "deal_canceled"
+	RefundReasonFinancingDenied  RefundReason = "financing_denied"
+	RefundReasonOvercharge       RefundReason = "overcharge"
+	RefundReasonDuplicatePayment RefundReason = "duplicate_payment"
+	RefundReasonAccessoryFee     RefundReason = "accessory_fee"
+	RefundReasonGoodwill         RefundReason = "goodwill"
+	RefundReasonOther            RefundReason = "other"
+)
+
+func (r RefundReason) IsValid() bool {
+	switch r {
+	case RefundReasonVehicleReturned, RefundReasonDealCanceled, RefundReasonFinancingDenied,
+		RefundReasonOvercharge, RefundReasonDuplicatePayment, RefundReasonAccessoryFee,
+		RefundReasonGoodwill, RefundReasonOther:
+		return true
+	}
+	return false
+}
+
+// CancelsSale reports whether a refund for this reason cancels the sale and
+// returns the vehicle to stock unless the request says otherwise. Refunds
+// that only correct the amount paid leave the sale as it is.
+func (r RefundReason) CancelsSale() bool {
+	switch r {
+	case RefundReasonVehicleReturned, RefundReasonDealCanceled, RefundReasonFinancingDenied:
+		return true
+	}
+	return false
+}
+
 type Transaction struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
 	CreatedAt time.Time      `json:"created_at"`
@@ -162,17 +994,427 @@ type Transaction struct {
 	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 
 	SaleID          uint              `json:"sale_id" gorm:"not null"`
-	Amount          float64           `json:"amount" gorm:"not null"`
+	Type            TransactionType   `json:"type" gorm:"not null;default:'payment'"`
+	Amount          money.Money       `json:"amount" gorm:"embedded;embeddedPrefix:amount_"`
 	PaymentMethod   PaymentMethod     `json:"payment_method" gorm:"not null"`
+	// Tendered is the cash handed over and Change what was given back, so
+	// Amount = Tendered - Change. Both are zero for non-cash payments.
+	Tendered        money.Money       `json:"tendered" gorm:"embedded;embeddedPrefix:tendered_"`
+	Change          money.Money       `json:"change" gorm:"embedded;embeddedPrefix:change_"`
 	Status          TransactionStatus `json:"status" gorm:"default:'pending'"`
 	ProcessedByID   uint              `json:"processed_by_id" gorm:"not null"`
 	ProcessedAt     *time.Time        `json:"processed_at"`
 	TransactionRef  string            `json:"transaction_ref"`
 	Notes           string            `json:"notes"`
 
+	// Gateway that settled the payment, for card, bank transfer and
+	// financing payments; empty for cash
+	Provider        string            `json:"provider,omitempty"`
+	ProviderRef     string            `json:"provider_ref,omitempty"`
+	ProviderStatus  string            `json:"provider_status,omitempty"`
+	ProviderMessage string            `json:"provider_message,omitempty"`
+
+	// Cashier shift the transaction was taken in; required for cash
+	ShiftID         *uint             `json:"shift_id,omitempty" gorm:"index"`
+
+	// Set on refunds only
+	RefundOfID      *uint             `json:"refund_of_id,omitempty"`
+	ReasonCode      RefundReason      `json:"reason_code,omitempty" gorm:"default:null"`
+	ApprovedByID    *uint             `json:"approved_by_id,omitempty"`
+
+	// Relationships
+	Sale        Sale  `json:"sale,omitempty" gorm:"foreignKey:SaleID"`
+	ProcessedBy User  `json:"processed_by,omitempty" gorm:"foreignKey:ProcessedByID"`
+	ApprovedBy  *User `json:"approved_by,omitempty" gorm:"foreignKey:ApprovedByID"`
+}
+
+type FinancingStatus string
+
+const (
+	FinancingStatusSubmitted FinancingStatus = "submitted"
+	FinancingStatusApproved  FinancingStatus = "approved"
+	FinancingStatusDisbursed FinancingStatus = "disbursed"
+	FinancingStatusRejected  FinancingStatus = "rejected"
+	FinancingStatusCanceled  FinancingStatus = "canceled"
+)
+
+func (s FinancingStatus) IsValid() bool {
+	switch s {
+	case FinancingStatusSubmitted, FinancingStatusApproved, FinancingStatusDisbursed, FinancingStatusRejected, FinancingStatusCanceled:
+		return true
+	}
+	return false
+}
+
+// AmortizationMethod is how a loan's interest is charged: flat on the
+// original principal, or annuity on the outstanding balance.
+type AmortizationMethod string
+
+const (
+	AmortizationFlat    AmortizationMethod = "flat"
+	AmortizationAnnuity AmortizationMethod = "annuity"
+)
+
+func (m AmortizationMethod) IsValid() bool {
+	return m == AmortizationFlat || m == AmortizationAnnuity
+}
+
+type OfferStatus string
+
+const (
+	OfferStatusOffered  OfferStatus = "offered"
+	OfferStatusAccepted OfferStatus = "accepted"
+	OfferStatusDeclined OfferStatus = "declined"
+)
+
+// Lender is a bank or leasing company that finances vehicle purchases.
+type Lender struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+
+	Name         string `json:"name" gorm:"not null;uniqueIndex"`
+	ContactName  string `json:"contact_name"`
+	ContactPhone string `json:"contact_phone"`
+	ContactEmail string `json:"contact_email"`
+	IsActive     bool   `json:"is_active" gorm:"default:true"`
+}
+
+// FinancingApplication is a customer's application to finance part of a
+// sale. Lenders answer it with offers; accepting one opens a pending
+// financing transaction for the financed amount, which completes only when
+// the lender's disbursement is recorded.
+type FinancingApplication struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+
+	SaleID      uint            `json:"sale_id" gorm:"not null;index"`
+	CustomerID  uint            `json:"customer_id" gorm:"not null;index"`
+	CreatedByID uint            `json:"created_by_id" gorm:"not null"`
+	Status      FinancingStatus `json:"status" gorm:"not null;default:'submitted'"`
+	// ApplicantIncome is the applicant's monthly income
+	ApplicantIncome money.Money `json:"applicant_income" gorm:"embedded;embeddedPrefix:applicant_income_"`
+	// DownPayment is what the customer pays the dealer themselves, and
+	// Principal the rest of the sale price, which the lender pays
+	DownPayment money.Money `json:"down_payment" gorm:"embedded;embeddedPrefix:down_payment_"`
+	Principal   money.Money `json:"principal" gorm:"embedded;embeddedPrefix:principal_"`
+	TenorMonths int         `json:"tenor_months" gorm:"not null"`
+	// LenderID is the lender the customer asked for, if any
+	LenderID        *uint      `json:"lender_id,omitempty"`
+	AcceptedOfferID *uint      `json:"accepted_offer_id,omitempty"`
+	TransactionID   *uint      `json:"transaction_id,omitempty"`
+	DisbursedAt     *time.Time `json:"disbursed_at"`
+	// DisbursementRef is the lender's reference for the disbursement
+	DisbursementRef string     `json:"disbursement_ref,omitempty"`
+	DecisionReason  string     `json:"decision_reason,omitempty"`
+	Notes           string     `json:"notes"`
+	Version         uint       `json:"version" gorm:"not null;default:1"`
+
+	// Relationships
+	Sale        Sale             `json:"sale,omitempty" gorm:"foreignKey:SaleID"`
+	Customer    User             `json:"customer,omitempty" gorm:"foreignKey:CustomerID"`
+	Lender      *Lender          `json:"lender,omitempty" gorm:"foreignKey:LenderID"`
+	Transaction *Transaction     `json:"transaction,omitempty" gorm:"foreignKey:TransactionID"`
+	Offers      []FinancingOffer `json:"offers,omitempty" gorm:"foreignKey:ApplicationID"`
+}
+
+// FinancingOffer is a lender's answer to a financing application. The
+// repayment figures follow from the principal, rate, tenor and method and
+// are worked out when the offer is recorded. InstallmentRatio is the
+// installment in basis points of the applicant's monthly income.
+type FinancingOffer struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ApplicationID uint               `json:"application_id" gorm:"not null;index"`
+	LenderID      uint               `json:"lender_id" gorm:"not null"`
+	Status        OfferStatus        `json:"status" gorm:"not null;default:'offered'"`
+	Method        AmortizationMethod `json:"method" gorm:"not null"`
+	// InterestRate is the annual rate in basis points
+	InterestRate     int64       `json:"interest_rate" gorm:"not null"`
+	AdminFee         money.Money `json:"admin_fee" gorm:"embedded;embeddedPrefix:admin_fee_"`
+	Principal        money.Money `json:"principal" gorm:"embedded;embeddedPrefix:principal_"`
+	TenorMonths      int         `json:"tenor_months" gorm:"not null"`
+	Installment      money.Money `json:"installment" gorm:"embedded;embeddedPrefix:installment_"`
+	TotalInterest    money.Money `json:"total_interest" gorm:"embedded;embeddedPrefix:total_interest_"`
+	// TotalPayable is every installment plus the admin fee
+	TotalPayable     money.Money `json:"total_payable" gorm:"embedded;embeddedPrefix:total_payable_"`
+	InstallmentRatio int64       `json:"installment_ratio"`
+	LenderRef        string      `json:"lender_ref,omitempty"`
+	ValidUntil       *time.Time  `json:"valid_until"`
+	Notes            string      `json:"notes"`
+
+	// Relationships
+	Lender       Lender                 `json:"lender,omitempty" gorm:"foreignKey:LenderID"`
+	Installments []FinancingInstallment `json:"installments,omitempty" gorm:"foreignKey:OfferID"`
+}
+
+// FinancingInstallment is one monthly repayment of an offer's schedule.
+// Due dates are set when the lender disburses the loan.
+type FinancingInstallment struct {
+	ID      uint `json:"id" gorm:"primaryKey"`
+	OfferID uint `json:"offer_id" gorm:"not null;uniqueIndex:idx_financing_installments_offer_number"`
+	Number  int  `json:"number" gorm:"not null;uniqueIndex:idx_financing_installments_offer_number"`
+
+	DueDate   *time.Time  `json:"due_date"`
+	Payment   money.Money `json:"payment" gorm:"embedded;embeddedPrefix:payment_"`
+	Principal money.Money `json:"principal" gorm:"embedded;embeddedPrefix:principal_"`
+	Interest  money.Money `json:"interest" gorm:"embedded;embeddedPrefix:interest_"`
+	Balance   money.Money `json:"balance" gorm:"embedded;embeddedPrefix:balance_"`
+}
+
+// CommissionPlan sets what salespeople earn on the sales they complete. A
+// plan with a SalesPersonID applies to that salesperson; the active plan
+// without one applies to everyone else. A sale earns PerUnit plus
+// GrossProfitRate of its gross profit, or the amounts of the highest tier
+// the salesperson has reached that month, and the bonus for the make of the
+// vehicle sold.
+type CommissionPlan struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+
+	Name          string      `json:"name" gorm:"not null"`
+	SalesPersonID *uint       `json:"sales_person_id,omitempty" gorm:"index"`
+	IsActive      bool        `json:"is_active" gorm:"default:true"`
+	PerUnit       money.Money `json:"per_unit" gorm:"embedded;embeddedPrefix:per_unit_"`
+	// GrossProfitRate is in basis points, 1000 for 10%
+	GrossProfitRate int64 `json:"gross_profit_rate"`
+
+	// Relationships
+	SalesPerson *User                 `json:"sales_person,omitempty" gorm:"foreignKey:SalesPersonID"`
+	Tiers       []CommissionTier      `json:"tiers,omitempty" gorm:"foreignKey:PlanID"`
+	MakeBonuses []CommissionMakeBonus `json:"make_bonuses,omitempty" gorm:"foreignKey:PlanID"`
+}
+
+// CommissionTier replaces a plan's PerUnit and GrossProfitRate from the
+// MinUnits-th sale a salesperson completes in a calendar month.
+type CommissionTier struct {
+	ID              uint        `json:"id" gorm:"primaryKey"`
+	PlanID          uint        `json:"plan_id" gorm:"not null;index"`
+	MinUnits        int         `json:"min_units" gorm:"not null"`
+	PerUnit         money.Money `json:"per_unit" gorm:"embedded;embeddedPrefix:per_unit_"`
+	GrossProfitRate int64       `json:"gross_profit_rate"`
+}
+
+// CommissionMakeBonus is paid on top of a plan's commission for every sale
+// of a vehicle of Make.
+type CommissionMakeBonus struct {
+	ID     uint        `json:"id" gorm:"primaryKey"`
+	PlanID uint        `json:"plan_id" gorm:"not null;index"`
+	Make   string      `json:"make" gorm:"not null"`
+	Bonus  money.Money `json:"bonus" gorm:"embedded;embeddedPrefix:bonus_"`
+}
+
+type CommissionEntryType string
+
+const (
+	CommissionAccrual  CommissionEntryType = "accrual"
+	CommissionReversal CommissionEntryType = "reversal"
+)
+
+// CommissionEntry is a line of the commission ledger. A sale's commission is
+// accrued when it completes, itemised as the plan worked it out. Refunds
+// reverse it: in proportion to the amount refunded, or whatever is left of
+// it when the refund cancels the sale. Units is 1 for an accrual, -1 for a
+// reversal that cancels the sale and 0 otherwise.
+type CommissionEntry struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	SaleID        uint                `json:"sale_id" gorm:"not null;index"`
+	SalesPersonID uint                `json:"sales_person_id" gorm:"not null;index"`
+	PlanID        uint                `json:"plan_id" gorm:"not null"`
+	Type          CommissionEntryType `json:"type" gorm:"not null"`
+	Units         int                 `json:"units"`
+	// MonthUnit is the sale's place among the salesperson's sales completed
+	// that month, and Tier the MinUnits of the tier it reached, 0 for none
+	MonthUnit   int         `json:"month_unit,omitempty"`
+	Tier        int         `json:"tier,omitempty"`
+	GrossProfit money.Money `json:"gross_profit" gorm:"embedded;embeddedPrefix:gross_profit_"`
+	PerUnit     money.Money `json:"per_unit" gorm:"embedded;embeddedPrefix:per_unit_"`
+	ProfitShare money.Money `json:"profit_share" gorm:"embedded;embeddedPrefix:profit_share_"`
+	MakeBonus   money.Money `json:"make_bonus" gorm:"embedded;embeddedPrefix:make_bonus_"`
+	// Amount is the commission earned, negative for reversals
+	Amount money.Money `json:"amount" gorm:"embedded;embeddedPrefix:amount_"`
+	// RefundID is the refund that caused a reversal
+	RefundID *uint  `json:"refund_id,omitempty"`
+	Notes    string `json:"notes,omitempty"`
+
 	// Relationships
 	Sale        Sale `json:"sale,omitempty" gorm:"foreignKey:SaleID"`
-	ProcessedBy User `json:"processed_by,omitempty" gorm:"foreignKey:ProcessedByID"`
+	SalesPerson User `json:"sales_person,omitempty" gorm:"foreignKey:SalesPersonID"`
+}
+
+// CommissionStatement totals a salesperson's commission ledger for a
+// period in one currency. Accrued is the commission earned on sales
+// completed in it and Reversed, negative, what refunds took back; Net is
+// their sum.
+type CommissionStatement struct {
+	SalesPersonID uint              `json:"sales_person_id"`
+	SalesPerson   string            `json:"sales_person"`
+	From          time.Time         `json:"from"`
+	To            time.Time         `json:"to"`
+	Currency      money.Currency    `json:"currency"`
+	Units         int64             `json:"units"`
+	Accrued       money.Money       `json:"accrued"`
+	Reversed      money.Money       `json:"reversed"`
+	Net           money.Money       `json:"net"`
+	Entries       []CommissionEntry `json:"entries"`
+}
+
+type ShiftStatus string
+
+const (
+	ShiftStatusOpen   ShiftStatus = "open"
+	ShiftStatusClosed ShiftStatus = "closed"
+	// Finalized shifts have had their Z-report run; their transactions can
+	// no longer be edited.
+	ShiftStatusFinalized ShiftStatus = "finalized"
+)
+
+func (s ShiftStatus) IsValid() bool {
+	switch s {
+	case ShiftStatusOpen, ShiftStatusClosed, ShiftStatusFinalized:
+		return true
+	}
+	return false
+}
+
+// Shift is a cashier's session at the cash drawer, from the opening float to
+// the end-of-day count. A cashier has at most one open shift, and every
+// transaction they take while it is open belongs to it.
+type Shift struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	CashierID     uint        `json:"cashier_id" gorm:"not null;index"`
+	Status        ShiftStatus `json:"status" gorm:"not null;default:'open'"`
+	OpeningFloat  money.Money `json:"opening_float" gorm:"embedded;embeddedPrefix:opening_float_"`
+	OpenedAt      time.Time   `json:"opened_at"`
+	ClosedAt      *time.Time  `json:"closed_at"`
+	ClosedByID    *uint       `json:"closed_by_id,omitempty"`
+	// ZNumber numbers Z-reports consecutively across all shifts
+	ZNumber       *uint       `json:"z_number,omitempty" gorm:"uniqueIndex"`
+	FinalizedAt   *time.Time  `json:"finalized_at"`
+	FinalizedByID *uint       `json:"finalized_by_id,omitempty"`
+	Notes         string      `json:"notes"`
+
+	// Relationships
+	Cashier User         `json:"cashier,omitempty" gorm:"foreignKey:CashierID"`
+	Counts  []ShiftCount `json:"counts,omitempty" gorm:"foreignKey:ShiftID"`
+}
+
+// ShiftCount is what was counted for one payment method when a shift
+// closed, against what its transactions say should be there. Variance is
+// Counted - Expected, so a shortage is negative.
+type ShiftCount struct {
+	ID            uint          `json:"id" gorm:"primaryKey"`
+	ShiftID       uint          `json:"shift_id" gorm:"not null;uniqueIndex:idx_shift_counts_shift_method"`
+	PaymentMethod PaymentMethod `json:"payment_method" gorm:"not null;uniqueIndex:idx_shift_counts_shift_method"`
+	Expected      money.Money   `json:"expected" gorm:"embedded;embeddedPrefix:expected_"`
+	Counted       money.Money   `json:"counted" gorm:"embedded;embeddedPrefix:counted_"`
+	Variance      money.Money   `json:"variance" gorm:"embedded;embeddedPrefix:variance_"`
+}
+
+// ShiftReport is the close-out report of a shift: its takings per payment
+// method and, once closed, the counted amounts and variances. Run on a
+// finalized shift it is the Z-report.
+type ShiftReport struct {
+	Shift *Shift            `json:"shift"`
+	Lines []ShiftReportLine `json:"lines"`
+	// Pending transactions are not in any total yet
+	Pending  int64        `json:"pending"`
+	Variance *money.Money `json:"variance"`
+}
+
+// ShiftReportLine totals one payment method. Expected includes the opening
+// float for cash; Counted and Variance are set once the method was counted.
+type ShiftReportLine struct {
+	PaymentMethod PaymentMethod `json:"payment_method"`
+	Transactions  int64         `json:"transactions"`
+	Payments      money.Money   `json:"payments"`
+	Refunds       money.Money   `json:"refunds"`
+	Expected      money.Money   `json:"expected"`
+	Counted       *money.Money  `json:"counted"`
+	Variance      *money.Money  `json:"variance"`
+}
+
+type DocumentType string
+
+const (
+	DocumentTypeInvoice    DocumentType = "invoice"
+	DocumentTypeReceipt    DocumentType = "receipt"
+	DocumentTypeCreditNote DocumentType = "credit_note"
+)
+
+func (t DocumentType) IsValid() bool {
+	switch t {
+	case DocumentTypeInvoice, DocumentTypeReceipt, DocumentTypeCreditNote:
+		return true
+	}
+	return false
+}
+
+// Document is an issued invoice, payment receipt or credit note. Its PDF is
+// rendered once when the document is issued and never changes afterwards.
+type Document struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Type DocumentType `json:"type" gorm:"not null"`
+	// Number is gap-free per type and branch, e.g. INV-HQ-000042
+	Number   string `json:"number" gorm:"not null;uniqueIndex"`
+	Branch   string `json:"branch" gorm:"not null"`
+	Sequence uint   `json:"sequence" gorm:"not null"`
+
+	SaleID uint `json:"sale_id" gorm:"not null;index"`
+	// TransactionID is the payment of a receipt or refund of a credit note
+	TransactionID *uint       `json:"transaction_id,omitempty" gorm:"index"`
+	Total         money.Money `json:"total" gorm:"embedded;embeddedPrefix:total_"`
+	IssuedAt      time.Time   `json:"issued_at"`
+	// PDF is rendered from Content when the document is first downloaded
+	PDF []byte `json:"-" gorm:"column:pdf"`
+	// Content is the document as issued, for reprinting in other formats
+	Content []byte `json:"-" gorm:"type:jsonb"`
+}
+
+// DocumentSequence holds the last number issued for a document prefix.
+type DocumentSequence struct {
+	Prefix string `gorm:"primaryKey"`
+	Last   uint   `gorm:"not null;default:0"`
+}
+
+type LeadStatus string
+
+const (
+	LeadStatusNew       LeadStatus = "new"
+	LeadStatusAssigned  LeadStatus = "assigned"
+	LeadStatusContacted LeadStatus = "contacted"
+	LeadStatusQualified LeadStatus = "qualified"
+	LeadStatusConverted LeadStatus = "converted"
+	LeadStatusLost      LeadStatus = "lost"
+)
+
+// LeadStatuses lists every lead status in pipeline order.
+var LeadStatuses = []LeadStatus{
+	LeadStatusNew, LeadStatusAssigned, LeadStatusContacted,
+	LeadStatusQualified, LeadStatusConverted, LeadStatusLost,
+}
+
+func (s LeadStatus) IsValid() bool {
+	for _, status := range LeadStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
 }
 
 type Lead struct {
@@ -185,9 +1427,9 @@ type Lead struct {
 	Email          string `json:"email"`
 	Phone          string `json:"phone"`
 	InterestedIn   string `json:"interested_in"`
-	Budget         float64 `json:"budget"`
+	Budget         money.Money `json:"budget" gorm:"embedded;embeddedPrefix:budget_"`
 	AssignedToID   *uint   `json:"assigned_to_id"`
-	Status         string  `json:"status" gorm:"default:'new'"`
+	Status         LeadStatus `json:"status" gorm:"default:'new'"`
 	Notes          string  `json:"notes"`
 	LastContactAt  *time.Time `json:"last_contact_at"`
 
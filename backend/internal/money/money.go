@@ -0,0 +1,308 @@
+// Package money represents monetary amounts as integer minor units (e.g.
+// sen for IDR, cents for USD) together with an ISO 4217 currency code, so
+// prices and totals never go through float64.
+package money
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Currency is an ISO 4217 currency code.
+type Currency string
+
+const (
+	IDR Currency = "IDR"
+	USD Currency = "USD"
+	EUR Currency = "EUR"
+	SGD Currency = "SGD"
+	MYR Currency = "MYR"
+	JPY Currency = "JPY"
+)
+
+// exponents is the number of minor unit digits of each supported currency.
+var exponents = map[Currency]int{
+	IDR: 2,
+	USD: 2,
+	EUR: 2,
+	SGD: 2,
+	MYR: 2,
+	JPY: 0,
+}
+
+var (
+	ErrUnknownCurrency  = errors.New("unknown currency")
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	ErrInvalidAmount    = errors.New("invalid amount")
+)
+
+var defaultCurrency = IDR
+
+// SetDefaultCurrency sets the currency assumed for amounts sent without one.
+func SetDefaultCurrency(c Currency) error {
+	if !c.IsValid() {
+		return fmt.Errorf("%w: %q", ErrUnknownCurrency, c)
+	}
+	defaultCurrency = c
+	return nil
+}
+
+// DefaultCurrency is the dealership's operating currency.
+func DefaultCurrency() Currency {
+	return defaultCurrency
+}
+
+func (c Currency) IsValid() bool {
+	_, ok := exponents[c]
+	return ok
+}
+
+// Exponent is the number of minor unit digits, 2 for IDR and USD.
+func (c Currency) Exponent() int {
+	return exponents[c]
+}
+
+// Scan and Value store a currency as text. An unset currency is stored as
+// the default currency.
+func (c *Currency) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		*c = Currency(v)
+	case []byte:
+		*c = Currency(v)
+	case nil:
+		*c = ""
+	default:
+		return fmt.Errorf("cannot scan %T into money.Currency", value)
+	}
+	return nil
+}
+
+func (c Currency) Value() (driver.Value, error) {
+	if c == "" {
+		return string(defaultCurrency), nil
+	}
+	return string(c), nil
+}
+
+// Money is an amount in minor units of a currency. It is stored as two
+// columns, <prefix>minor and <prefix>currency, by embedding it in a model
+// with gorm:"embedded;embeddedPrefix:<prefix>".
+type Money struct {
+	Minor    int64    `gorm:"column:minor;not null;default:0"`
+	Currency Currency `gorm:"column:currency;type:varchar(3);not null"`
+}
+
+// New returns an amount of minor units.
+func New(minor int64, currency Currency) Money {
+	return Money{Minor: minor, Currency: currency}
+}
+
+// Zero returns a zero amount in the currency.
+func Zero(currency Currency) Money {
+	return Money{Currency: currency}
+}
+
+// Parse reads a decimal amount in major units such as "1500000.50". It
+// rejects more fraction digits than the currency has.
+func Parse(amount string, currency Currency) (Money, error) {
+	if !currency.IsValid() {
+		return Money{}, fmt.Errorf("%w: %q", ErrUnknownCurrency, currency)
+	}
+
+	s := strings.TrimSpace(amount)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+
+	whole, frac, _ := strings.Cut(s, ".")
+	exp := currency.Exponent()
+	if whole == "" || len(frac) > exp || strings.Trim(whole+frac, "0123456789") != "" {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+	frac += strings.Repeat("0", exp-len(frac))
+
+	minor, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+	if negative {
+		minor = -minor
+	}
+	return Money{Minor: minor, Currency: currency}, nil
+}
+
+// MustParse is Parse for constants known to be valid, such as seed data.
+func MustParse(amount string, currency Currency) Money {
+	m, err := Parse(amount, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// String formats the amount in major units with its currency, e.g.
+// "1500000.00 IDR".
+func (m Money) String() string {
+	return m.Decimal() + " " + string(m.Currency)
+}
+
+// Decimal formats the amount in major units without a currency, e.g.
+// "1500000.00".
+func (m Money) Decimal() string {
+	exp := m.Currency.Exponent()
+	minor := m.Minor
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+	}
+	digits := strconv.FormatUint(abs(minor), 10)
+	if exp == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
+
+func (m Money) IsZero() bool     { return m.Minor == 0 }
+func (m Money) IsPositive() bool { return m.Minor > 0 }
+func (m Money) IsNegative() bool { return m.Minor < 0 }
+
+// Neg returns the amount with the opposite sign.
+func (m Money) Neg() Money {
+	return Money{Minor: -m.Minor, Currency: m.Currency}
+}
+
+// Add returns m + o. Both amounts must share a currency; a zero amount
+// without a currency adopts the other's.
+func (m Money) Add(o Money) (Money, error) {
+	currency, err := m.common(o)
+	if err != nil {
+		return Money{}, err
+	}
+	if (o.Minor > 0 && m.Minor > math.MaxInt64-o.Minor) || (o.Minor < 0 && m.Minor < math.MinInt64-o.Minor) {
+		return Money{}, fmt.Errorf("%w: overflow", ErrInvalidAmount)
+	}
+	return Money{Minor: m.Minor + o.Minor, Currency: currency}, nil
+}
+
+// Sub returns m - o.
+func (m Money) Sub(o Money) (Money, error) {
+	return m.Add(o.Neg())
+}
+
+// Cmp compares two amounts of the same currency, returning -1, 0 or 1.
+func (m Money) Cmp(o Money) (int, error) {
+	if _, err := m.common(o); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Minor < o.Minor:
+		return -1, nil
+	case m.Minor > o.Minor:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (m Money) common(o Money) (Currency, error) {
+	switch {
+	case m.Currency == o.Currency:
+		return m.Currency, nil
+	case m.Currency == "" && m.Minor == 0:
+		return o.Currency, nil
+	case o.Currency == "" && o.Minor == 0:
+		return m.Currency, nil
+	}
+	return "", fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+}
+
+// jsonMoney is the wire format. Amount is an exact decimal string so that
+// clients never round, MinorUnits is provided for arithmetic.
+type jsonMoney struct {
+	Amount     json.RawMessage `json:"amount"`
+	Currency   Currency        `json:"currency"`
+	MinorUnits *int64          `json:"minor_units,omitempty"`
+}
+
+// MarshalJSON renders {"amount":"1500000.00","currency":"IDR","minor_units":150000000}.
+func (m Money) MarshalJSON() ([]byte, error) {
+	currency := m.Currency
+	if currency == "" {
+		currency = defaultCurrency
+	}
+	m.Currency = currency
+
+	amount, _ := json.Marshal(m.Decimal())
+	minor := m.Minor
+	return json.Marshal(jsonMoney{Amount: amount, Currency: currency, MinorUnits: &minor})
+}
+
+// UnmarshalJSON accepts the object written by MarshalJSON, with either
+// amount or minor_units, or a bare number or string in major units of the
+// default currency, e.g. "sale_price": 1500000.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*m = Money{}
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '{' {
+		var v jsonMoney
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		currency := v.Currency
+		if currency == "" {
+			currency = defaultCurrency
+		}
+		if v.MinorUnits != nil {
+			if !currency.IsValid() {
+				return fmt.Errorf("%w: %q", ErrUnknownCurrency, currency)
+			}
+			*m = Money{Minor: *v.MinorUnits, Currency: currency}
+			return nil
+		}
+		parsed, err := parseJSONAmount(v.Amount, currency)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	}
+
+	parsed, err := parseJSONAmount(data, defaultCurrency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+func parseJSONAmount(raw json.RawMessage, currency Currency) (Money, error) {
+	if len(raw) == 0 {
+		return Money{}, fmt.Errorf("%w: missing amount", ErrInvalidAmount)
+	}
+	text := string(raw)
+	if raw[0] == '"' {
+		if err := json.Unmarshal(raw, &text); err != nil {
+			return Money{}, err
+		}
+	}
+	return Parse(text, currency)
+}
+
+func abs(n int64) uint64 {
+	if n < 0 {
+		return uint64(-(n + 1)) + 1
+	}
+	return uint64(n)
+}
@@ -0,0 +1,207 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency Currency
+		want     int64
+		err      error
+	}{
+		{"1500000.50", IDR, 150000050, nil},
+		{"1500000.5", IDR, 150000050, nil},
+		{"1500000", IDR, 150000000, nil},
+		{" 12.34 ", USD, 1234, nil},
+		{"+12.34", USD, 1234, nil},
+		{"-12.34", USD, -1234, nil},
+		{"0.01", EUR, 1, nil},
+		{"0", IDR, 0, nil},
+		{"1500", JPY, 1500, nil},
+		{"92233720368547758.07", IDR, math.MaxInt64, nil},
+		{"92233720368547758.08", IDR, 0, ErrInvalidAmount},
+		{"1.234", IDR, 0, ErrInvalidAmount},
+		{"1.5", JPY, 0, ErrInvalidAmount},
+		{".50", IDR, 0, ErrInvalidAmount},
+		{"", IDR, 0, ErrInvalidAmount},
+		{"-", IDR, 0, ErrInvalidAmount},
+		{"1,500", IDR, 0, ErrInvalidAmount},
+		{"1e5", IDR, 0, ErrInvalidAmount},
+		{"--1", IDR, 0, ErrInvalidAmount},
+		{"1", "XXX", 0, ErrUnknownCurrency},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.amount, tt.currency)
+		if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+			t.Errorf("Parse(%q, %s) error = %v, want %v", tt.amount, tt.currency, err, tt.err)
+			continue
+		}
+		if err == nil && (got.Minor != tt.want || got.Currency != tt.currency) {
+			t.Errorf("Parse(%q, %s) = %v, want %d", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	tests := []struct {
+		m    Money
+		want string
+	}{
+		{New(150000050, IDR), "1500000.50"},
+		{New(5, USD), "0.05"},
+		{New(-5, USD), "-0.05"},
+		{New(0, USD), "0.00"},
+		{New(1500, JPY), "1500"},
+		{New(math.MinInt64, IDR), "-92233720368547758.08"},
+	}
+	for _, tt := range tests {
+		if got := tt.m.Decimal(); got != tt.want {
+			t.Errorf("Decimal(%d %s) = %q, want %q", tt.m.Minor, tt.m.Currency, got, tt.want)
+		}
+	}
+}
+
+func TestAddAndSub(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Money
+		sum  Money
+		err  error
+	}{
+		{"same currency", New(150, IDR), New(250, IDR), New(400, IDR), nil},
+		{"negative", New(150, IDR), New(-250, IDR), New(-100, IDR), nil},
+		{"zero without currency", Money{}, New(250, USD), New(250, USD), nil},
+		{"to zero without currency", New(250, USD), Money{}, New(250, USD), nil},
+		{"currency mismatch", New(1, IDR), New(1, USD), Money{}, ErrCurrencyMismatch},
+		{"amount without currency", New(1, IDR), New(1, ""), Money{}, ErrCurrencyMismatch},
+		{"overflow", New(math.MaxInt64, IDR), New(1, IDR), Money{}, ErrInvalidAmount},
+		{"underflow", New(math.MinInt64, IDR), New(-1, IDR), Money{}, ErrInvalidAmount},
+		{"up to the maximum", New(math.MaxInt64-1, IDR), New(1, IDR), New(math.MaxInt64, IDR), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.a.Add(tt.b)
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Fatalf("Add error = %v, want %v", err, tt.err)
+			}
+			if got != tt.sum {
+				t.Errorf("Add = %v, want %v", got, tt.sum)
+			}
+		})
+	}
+
+	if _, err := New(math.MinInt64+1, IDR).Sub(New(2, IDR)); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("Sub underflow error = %v, want ErrInvalidAmount", err)
+	}
+	if got, err := New(100, IDR).Sub(New(250, IDR)); err != nil || got != New(-150, IDR) {
+		t.Errorf("Sub = %v, %v, want -1.50 IDR", got, err)
+	}
+}
+
+func TestCmp(t *testing.T) {
+	for _, tt := range []struct {
+		a, b Money
+		want int
+	}{
+		{New(1, IDR), New(2, IDR), -1},
+		{New(2, IDR), New(2, IDR), 0},
+		{New(3, IDR), New(2, IDR), 1},
+	} {
+		if got, err := tt.a.Cmp(tt.b); err != nil || got != tt.want {
+			t.Errorf("Cmp(%v, %v) = %d, %v, want %d", tt.a, tt.b, got, err, tt.want)
+		}
+	}
+	if _, err := New(1, IDR).Cmp(New(1, USD)); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Cmp error = %v, want ErrCurrencyMismatch", err)
+	}
+}
+
+func TestScale(t *testing.T) {
+	tests := []struct {
+		n, m, d int64
+		want    int64
+	}{
+		{10000, 1100, 10000, 1100},
+		{15, 1, 10, 2},   // 1.5 rounds up
+		{14, 1, 10, 1},   // 1.4 rounds down
+		{-15, 1, 10, -2}, // -1.5 rounds away from zero
+		{-14, 1, 10, -1}, // -1.4 rounds towards it
+		{5, 1, 10, 1},    // 0.5
+		{1, 1, 3, 0},     // 0.33
+		{2, 1, 3, 1},     // 0.67
+		{100, 1, 3, 33},  // 33.33
+		{100, 2, 3, 67},  // 66.67
+		{0, 1100, 10000, 0},
+		// n*m overflows int64 on its own
+		{math.MaxInt64, 10000, 10000, math.MaxInt64},
+		{9_000_000_000_000_000_00, 1100, 10000, 990_000_000_000_000_00},
+	}
+	for _, tt := range tests {
+		if got := Scale(tt.n, tt.m, tt.d); got != tt.want {
+			t.Errorf("Scale(%d, %d, %d) = %d, want %d", tt.n, tt.m, tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestDivide(t *testing.T) {
+	tests := []struct {
+		n, d int64
+		want int64
+	}{
+		{10, 2, 5},
+		{15, 10, 2},
+		{14, 10, 1},
+		{-15, 10, -2},
+		{-14, 10, -1},
+		{1, 3, 0},
+		{2, 3, 1},
+		{0, 7, 0},
+	}
+	for _, tt := range tests {
+		if got := Divide(tt.n, tt.d); got != tt.want {
+			t.Errorf("Divide(%d, %d) = %d, want %d", tt.n, tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	data, err := json.Marshal(New(150000050, IDR))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"amount":"1500000.50","currency":"IDR","minor_units":150000050}`; string(data) != want {
+		t.Errorf("Marshal = %s, want %s", data, want)
+	}
+
+	tests := []struct {
+		json string
+		want Money
+		err  error
+	}{
+		{`{"amount":"1500000.50","currency":"IDR"}`, New(150000050, IDR), nil},
+		{`{"minor_units":1234,"currency":"USD"}`, New(1234, USD), nil},
+		{`{"amount":"12.50"}`, New(1250, IDR), nil},
+		{`1500000`, New(150000000, IDR), nil},
+		{`"1500000.5"`, New(150000050, IDR), nil},
+		{`null`, Money{}, nil},
+		{`{"amount":"1.5","currency":"JPY"}`, Money{}, ErrInvalidAmount},
+		{`{"minor_units":1,"currency":"XXX"}`, Money{}, ErrUnknownCurrency},
+		{`{"currency":"USD"}`, Money{}, ErrInvalidAmount},
+	}
+	for _, tt := range tests {
+		var got Money
+		err := json.Unmarshal([]byte(tt.json), &got)
+		if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+			t.Errorf("Unmarshal(%s) error = %v, want %v", tt.json, err, tt.err)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("Unmarshal(%s) = %v, want %v", tt.json, got, tt.want)
+		}
+	}
+}
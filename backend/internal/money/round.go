@@ -0,0 +1,27 @@
+package money
+
+import "math/big"
+
+// Scale returns n*m/d rounded half away from zero, without overflowing on
+// large amounts; d must be positive. It is how shares and rates of minor
+// unit amounts are worked out, e.g. Scale(amount, rate, 10000) for a rate in
+// basis points.
+func Scale(n, m, d int64) int64 {
+	product := new(big.Int).Mul(big.NewInt(n), big.NewInt(m))
+	negative := product.Sign() < 0
+	product.Abs(product)
+	product.Add(product, big.NewInt(d/2))
+	product.Quo(product, big.NewInt(d))
+	if negative {
+		product.Neg(product)
+	}
+	return product.Int64()
+}
+
+// Divide returns n/d rounded half away from zero; d must be positive.
+func Divide(n, d int64) int64 {
+	if n < 0 {
+		return -((-n + d/2) / d)
+	}
+	return (n + d/2) / d
+}
@@ -0,0 +1,253 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"vehicle-sales-backend/internal/money"
+
+	"github.com/google/uuid"
+)
+
+// FakeMode selects how the fake provider answers new payments.
+type FakeMode string
+
+const (
+	// FakeSucceed authorizes and captures every payment immediately.
+	FakeSucceed FakeMode = "succeed"
+	// FakeDecline declines every payment.
+	FakeDecline FakeMode = "decline"
+	// FakeTimeout never answers, so callers hit their timeout.
+	FakeTimeout FakeMode = "timeout"
+	// FakeAsync leaves payments pending and captures them after a delay,
+	// reporting the outcome through a signed webhook.
+	FakeAsync FakeMode = "async"
+	// FakeAsyncDecline is FakeAsync with a decline as the outcome.
+	FakeAsyncDecline FakeMode = "async_decline"
+)
+
+func (m FakeMode) IsValid() bool {
+	switch m {
+	case FakeSucceed, FakeDecline, FakeTimeout, FakeAsync, FakeAsyncDecline:
+		return true
+	}
+	return false
+}
+
+// FakeConfig configures the fake provider. WebhookURL and Secret are only
+// needed by the async modes.
+type FakeConfig struct {
+	Mode       FakeMode
+	Delay      time.Duration
+	WebhookURL string
+	Secret     string
+}
+
+// Fake is an in-process provider for development and testing that never
+// moves real money.
+type Fake struct {
+	config FakeConfig
+	client *http.Client
+
+	mu       sync.Mutex
+	payments map[string]*fakePayment
+	byRef    map[string]string
+	refunds  map[string]Result
+}
+
+type fakePayment struct {
+	reference         string
+	merchantReference string
+	amount            money.Money
+	refunded          money.Money
+	status            Status
+	message           string
+}
+
+func NewFake(config FakeConfig) (*Fake, error) {
+	if config.Mode == "" {
+		config.Mode = FakeSucceed
+	}
+	if !config.Mode.IsValid() {
+		return nil, fmt.Errorf("unknown fake payment mode %q", config.Mode)
+	}
+	return &Fake{
+		config:   config,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		payments: map[string]*fakePayment{},
+		byRef:    map[string]string{},
+		refunds:  map[string]Result{},
+	}, nil
+}
+
+func (f *Fake) Name() string { return "fake" }
+
+func (f *Fake) Authorize(ctx context.Context, req Request) (Result, error) {
+	f.mu.Lock()
+	if ref, ok := f.byRef[req.Reference]; ok {
+		defer f.mu.Unlock()
+		return f.payments[ref].result(), nil
+	}
+
+	if f.config.Mode == FakeTimeout {
+		f.mu.Unlock()
+		<-ctx.Done()
+		return Result{}, ctx.Err()
+	}
+	defer f.mu.Unlock()
+
+	p := &fakePayment{
+		reference:         "fake_" + uuid.New().String()[:12],
+		merchantReference: req.Reference,
+		amount:            req.Amount,
+		refunded:          money.Zero(req.Amount.Currency),
+	}
+	switch f.config.Mode {
+	case FakeSucceed:
+		p.status = StatusAuthorized
+	case FakeDecline:
+		p.status = StatusDeclined
+		p.message = "Declined by the simulated issuer"
+	case FakeAsync, FakeAsyncDecline:
+		p.status = StatusPending
+		go f.resolveLater(p.reference)
+	}
+	f.payments[p.reference] = p
+	f.byRef[req.Reference] = p.reference
+	return p.result(), nil
+}
+
+func (f *Fake) Capture(ctx context.Context, reference string, amount money.Money) (Result, error) {
+	return f.update(reference, func(p *fakePayment) error {
+		if p.status != StatusAuthorized {
+			return fmt.Errorf("cannot capture a %s payment", p.status)
+		}
+		if amount.Minor > p.amount.Minor {
+			return fmt.Errorf("capture exceeds the authorized amount")
+		}
+		p.status = StatusCaptured
+		return nil
+	})
+}
+
+func (f *Fake) Void(ctx context.Context, reference string) (Result, error) {
+	return f.update(reference, func(p *fakePayment) error {
+		if p.status != StatusAuthorized && p.status != StatusPending {
+			return fmt.Errorf("cannot void a %s payment", p.status)
+		}
+		p.status = StatusVoided
+		return nil
+	})
+}
+
+func (f *Fake) Refund(ctx context.Context, reference string, amount money.Money, key string) (Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if result, ok := f.refunds[key]; ok {
+		return result, nil
+	}
+
+	p, ok := f.payments[reference]
+	if !ok {
+		return Result{}, fmt.Errorf("unknown payment %q", reference)
+	}
+	if !p.status.Settled() {
+		return Result{}, fmt.Errorf("cannot refund a %s payment", p.status)
+	}
+	refunded, err := p.refunded.Add(amount)
+	if err != nil {
+		return Result{}, err
+	}
+	result := Result{Reference: "fake_rf_" + uuid.New().String()[:12], Status: StatusRefunded}
+	if refunded.Minor > p.amount.Minor {
+		result = Result{Status: StatusDeclined, Message: "Refund exceeds the captured amount"}
+	} else {
+		p.refunded = refunded
+	}
+	f.refunds[key] = result
+	return result, nil
+}
+
+func (f *Fake) Status(ctx context.Context, reference string) (Result, error) {
+	return f.update(reference, func(*fakePayment) error { return nil })
+}
+
+func (f *Fake) update(reference string, fn func(p *fakePayment) error) (Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, ok := f.payments[reference]
+	if !ok {
+		return Result{}, fmt.Errorf("unknown payment %q", reference)
+	}
+	if err := fn(p); err != nil {
+		return Result{}, err
+	}
+	return p.result(), nil
+}
+
+// resolveLater settles an async payment after the configured delay and
+// notifies the webhook endpoint.
+func (f *Fake) resolveLater(reference string) {
+	time.Sleep(f.config.Delay)
+
+	f.mu.Lock()
+	p := f.payments[reference]
+	if p.status == StatusPending {
+		if f.config.Mode == FakeAsyncDecline {
+			p.status = StatusDeclined
+			p.message = "Declined by the simulated issuer"
+		} else {
+			p.status = StatusCaptured
+		}
+	}
+	event := Event{
+		ID:                "evt_" + uuid.New().String()[:12],
+		Reference:         p.reference,
+		MerchantReference: p.merchantReference,
+		Status:            p.status,
+		Message:           p.message,
+	}
+	f.mu.Unlock()
+
+	if err := f.send(event); err != nil {
+		log.Printf("fake payment provider: webhook for %s failed: %v", reference, err)
+	}
+}
+
+func (f *Fake) send(event Event) error {
+	if f.config.WebhookURL == "" {
+		return fmt.Errorf("no webhook URL configured")
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, f.config.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(f.config.Secret, body, time.Now()))
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (p *fakePayment) result() Result {
+	return Result{Reference: p.reference, Status: p.status, Message: p.message}
+}
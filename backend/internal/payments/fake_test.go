@@ -0,0 +1,173 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+)
+
+var amount = money.New(10_000_000_00, money.IDR)
+
+func newFake(t *testing.T, config FakeConfig) *Fake {
+	t.Helper()
+	fake, err := NewFake(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fake
+}
+
+func TestFakeAuthorize(t *testing.T) {
+	tests := []struct {
+		mode FakeMode
+		want Status
+	}{
+		{"", StatusAuthorized},
+		{FakeSucceed, StatusAuthorized},
+		{FakeDecline, StatusDeclined},
+		{FakeAsync, StatusPending},
+		{FakeAsyncDecline, StatusPending},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			fake := newFake(t, FakeConfig{Mode: tt.mode, Delay: time.Hour})
+			result, err := fake.Authorize(context.Background(), Request{Reference: "TXN-1", Amount: amount, Method: models.PaymentMethodCard})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Status != tt.want || result.Reference == "" {
+				t.Errorf("result = %+v, want status %s", result, tt.want)
+			}
+
+			// the merchant reference is an idempotency key
+			again, err := fake.Authorize(context.Background(), Request{Reference: "TXN-1", Amount: amount, Method: models.PaymentMethodCard})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again != result {
+				t.Errorf("retry = %+v, want %+v", again, result)
+			}
+		})
+	}
+}
+
+func TestFakeInvalidMode(t *testing.T) {
+	if _, err := NewFake(FakeConfig{Mode: "sometimes"}); err == nil {
+		t.Error("NewFake accepted an unknown mode")
+	}
+}
+
+func TestFakeTimeout(t *testing.T) {
+	fake := newFake(t, FakeConfig{Mode: FakeTimeout})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := fake.Authorize(ctx, Request{Reference: "TXN-1", Amount: amount})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestFakeCaptureVoidAndRefund(t *testing.T) {
+	ctx := context.Background()
+	fake := newFake(t, FakeConfig{})
+	authorized, _ := fake.Authorize(ctx, Request{Reference: "TXN-1", Amount: amount})
+
+	if _, err := fake.Capture(ctx, authorized.Reference, money.New(amount.Minor+1, money.IDR)); err == nil {
+		t.Error("captured more than was authorized")
+	}
+	if _, err := fake.Refund(ctx, authorized.Reference, amount, "RFD-0"); err == nil {
+		t.Error("refunded a payment that was not captured")
+	}
+	captured, err := fake.Capture(ctx, authorized.Reference, amount)
+	if err != nil || captured.Status != StatusCaptured {
+		t.Fatalf("capture = %+v, %v", captured, err)
+	}
+	if _, err := fake.Void(ctx, authorized.Reference); err == nil {
+		t.Error("voided a captured payment")
+	}
+
+	half := money.New(amount.Minor/2, money.IDR)
+	tests := []struct {
+		name string
+		key  string
+		want Status
+	}{
+		{"first half", "RFD-1", StatusRefunded},
+		// retried with the same key, so refunded once
+		{"retry", "RFD-1", StatusRefunded},
+		{"second half", "RFD-2", StatusRefunded},
+		{"nothing left", "RFD-3", StatusDeclined},
+	}
+	refunds := map[string]Result{}
+	for _, tt := range tests {
+		result, err := fake.Refund(ctx, authorized.Reference, half, tt.key)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if result.Status != tt.want {
+			t.Errorf("%s: status = %s, want %s", tt.name, result.Status, tt.want)
+		}
+		if earlier, ok := refunds[tt.key]; ok && earlier != result {
+			t.Errorf("%s: = %+v, want the earlier %+v", tt.name, result, earlier)
+		}
+		refunds[tt.key] = result
+	}
+
+	other, _ := fake.Authorize(ctx, Request{Reference: "TXN-2", Amount: amount})
+	voided, err := fake.Void(ctx, other.Reference)
+	if err != nil || voided.Status != StatusVoided {
+		t.Errorf("void = %+v, %v", voided, err)
+	}
+}
+
+func TestFakeAsyncSendsSignedWebhook(t *testing.T) {
+	tests := []struct {
+		mode FakeMode
+		want Status
+	}{
+		{FakeAsync, StatusCaptured},
+		{FakeAsyncDecline, StatusDeclined},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			const secret = "whsec_test"
+			events := make(chan *Event, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				event, err := ParseWebhook(secret, r.Header.Get(SignatureHeader), body, time.Now())
+				if err != nil {
+					t.Error(err)
+					w.WriteHeader(http.StatusUnauthorized)
+				}
+				events <- event
+			}))
+			defer server.Close()
+
+			fake := newFake(t, FakeConfig{Mode: tt.mode, WebhookURL: server.URL, Secret: secret})
+			pending, _ := fake.Authorize(context.Background(), Request{Reference: "TXN-1", Amount: amount})
+
+			select {
+			case event := <-events:
+				if event == nil {
+					return
+				}
+				if event.Reference != pending.Reference || event.MerchantReference != "TXN-1" || event.Status != tt.want {
+					t.Errorf("event = %+v, want %s for %s", event, tt.want, pending.Reference)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("no webhook was sent")
+			}
+			status, _ := fake.Status(context.Background(), pending.Reference)
+			if status.Status != tt.want {
+				t.Errorf("status = %s, want %s", status.Status, tt.want)
+			}
+		})
+	}
+}
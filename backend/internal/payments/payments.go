@@ -0,0 +1,80 @@
+// Package payments abstracts the gateways that settle card, bank transfer and
+// financing payments. Providers are registered per payment method; payment
+// methods without a provider, such as cash, are settled at the counter.
+package payments
+
+import (
+	"context"
+	"time"
+
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+)
+
+// Status is the state of a payment at the provider.
+type Status string
+
+const (
+	StatusPending    Status = "pending"
+	StatusAuthorized Status = "authorized"
+	StatusCaptured   Status = "captured"
+	StatusDeclined   Status = "declined"
+	StatusFailed     Status = "failed"
+	StatusVoided     Status = "voided"
+	StatusRefunded   Status = "refunded"
+)
+
+// Settled reports whether the money has been collected.
+func (s Status) Settled() bool {
+	return s == StatusCaptured
+}
+
+// Failed reports whether the payment will never be collected. A payment
+// refunded before we saw it captured has not paid anything either.
+func (s Status) Failed() bool {
+	return s == StatusDeclined || s == StatusFailed || s == StatusVoided || s == StatusRefunded
+}
+
+// Request asks a provider to authorize a payment. Reference is our
+// transaction reference; providers treat it as an idempotency key, so
+// retrying after a timeout does not charge the customer twice.
+type Request struct {
+	Reference string
+	Amount    money.Money
+	Method    models.PaymentMethod
+}
+
+// Result is a provider's answer. Reference identifies the payment (or refund)
+// at the provider.
+type Result struct {
+	Reference string
+	Status    Status
+	Message   string
+}
+
+// Provider is a payment gateway. Calls may block on the network and must
+// honour ctx; a payment whose outcome is not known yet is reported as
+// StatusPending and resolved later by a webhook. Refund treats key as an
+// idempotency key, so retrying a refund returns money only once.
+type Provider interface {
+	Name() string
+	Authorize(ctx context.Context, req Request) (Result, error)
+	Capture(ctx context.Context, reference string, amount money.Money) (Result, error)
+	Void(ctx context.Context, reference string) (Result, error)
+	Refund(ctx context.Context, reference string, amount money.Money, key string) (Result, error)
+	Status(ctx context.Context, reference string) (Result, error)
+}
+
+var timeout = 15 * time.Second
+
+// SetTimeout limits how long a single provider call may take.
+func SetTimeout(d time.Duration) {
+	if d > 0 {
+		timeout = d
+	}
+}
+
+// WithTimeout returns a context for one provider call.
+func WithTimeout(parent context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, timeout)
+}
@@ -0,0 +1,40 @@
+package payments
+
+import (
+	"sync"
+
+	"vehicle-sales-backend/internal/models"
+)
+
+var registry = struct {
+	sync.RWMutex
+	byMethod map[models.PaymentMethod]Provider
+	byName   map[string]Provider
+}{
+	byMethod: map[models.PaymentMethod]Provider{},
+	byName:   map[string]Provider{},
+}
+
+// Register routes payments made with method to provider.
+func Register(method models.PaymentMethod, provider Provider) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.byMethod[method] = provider
+	registry.byName[provider.Name()] = provider
+}
+
+// ForMethod returns the provider that settles method, if any.
+func ForMethod(method models.PaymentMethod) (Provider, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	provider, ok := registry.byMethod[method]
+	return provider, ok
+}
+
+// ByName returns a registered provider, e.g. to route its webhooks.
+func ByName(name string) (Provider, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	provider, ok := registry.byName[name]
+	return provider, ok
+}
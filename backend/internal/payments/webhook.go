@@ -0,0 +1,79 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries the webhook signature: "t=<unix time>,v1=<hex
+// HMAC-SHA256 of "<unix time>.<body>">".
+const SignatureHeader = "Payment-Signature"
+
+// SignatureTolerance is how old a webhook may be before it is rejected as a
+// replay.
+const SignatureTolerance = 5 * time.Minute
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Event is a webhook notification that a payment changed state.
+// MerchantReference is our transaction reference, for payments whose provider
+// reference we never learned because the authorization timed out.
+type Event struct {
+	ID                string `json:"id"`
+	Reference         string `json:"reference"`
+	MerchantReference string `json:"merchant_reference"`
+	Status            Status `json:"status"`
+	Message           string `json:"message,omitempty"`
+}
+
+// Sign returns the signature header value for body.
+func Sign(secret string, body []byte, at time.Time) string {
+	ts := strconv.FormatInt(at.Unix(), 10)
+	return "t=" + ts + ",v1=" + mac(secret, ts, body)
+}
+
+// ParseWebhook verifies the signature of a webhook body and decodes it.
+func ParseWebhook(secret, signature string, body []byte, now time.Time) (*Event, error) {
+	var ts, sig string
+	for _, part := range strings.Split(signature, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			sig = value
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" || secret == "" {
+		return nil, ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > SignatureTolerance || age < -SignatureTolerance {
+		return nil, fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+	if !hmac.Equal([]byte(sig), []byte(mac(secret, ts, body))) {
+		return nil, ErrInvalidSignature
+	}
+
+	var event Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func mac(secret, ts string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
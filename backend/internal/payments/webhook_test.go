@@ -0,0 +1,74 @@
+package payments
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseWebhook(t *testing.T) {
+	const secret = "whsec_test"
+	body := []byte(`{"id":"evt_1","reference":"fake_1","merchant_reference":"TXN-1","status":"captured"}`)
+	now := time.Unix(1_700_000_000, 0)
+
+	tests := []struct {
+		name      string
+		secret    string
+		signature string
+		body      []byte
+		wantErr   bool
+	}{
+		{"valid", secret, Sign(secret, body, now), body, false},
+		{"valid within tolerance", secret, Sign(secret, body, now.Add(-SignatureTolerance+time.Second)), body, false},
+		{"clock skew within tolerance", secret, Sign(secret, body, now.Add(SignatureTolerance-time.Second)), body, false},
+		{"replayed after tolerance", secret, Sign(secret, body, now.Add(-SignatureTolerance-time.Second)), body, true},
+		{"timestamp in the future", secret, Sign(secret, body, now.Add(SignatureTolerance+time.Second)), body, true},
+		{"wrong secret", secret, Sign("other", body, now), body, true},
+		{"tampered body", secret, Sign(secret, body, now), []byte(`{"id":"evt_1","reference":"fake_1","status":"captured"}`), true},
+		{"timestamp changed", secret, "t=1700000001,v1=" + mac(secret, "1700000000", body), body, true},
+		{"no signature", secret, "", body, true},
+		{"no timestamp", secret, "v1=" + mac(secret, "1700000000", body), body, true},
+		{"no secret configured", "", Sign("", body, now), body, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := ParseWebhook(tt.secret, tt.signature, tt.body, now)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSignature) {
+					t.Fatalf("err = %v, want ErrInvalidSignature", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if event.ID != "evt_1" || event.Reference != "fake_1" || event.MerchantReference != "TXN-1" || event.Status != StatusCaptured {
+				t.Errorf("event = %+v", event)
+			}
+		})
+	}
+}
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		status          Status
+		settled, failed bool
+	}{
+		{StatusPending, false, false},
+		{StatusAuthorized, false, false},
+		{StatusCaptured, true, false},
+		{StatusDeclined, false, true},
+		{StatusFailed, false, true},
+		{StatusVoided, false, true},
+		// refunded before we saw it captured, so nothing was paid
+		{StatusRefunded, false, true},
+	}
+	for _, tt := range tests {
+		if got := tt.status.Settled(); got != tt.settled {
+			t.Errorf("%s.Settled() = %v, want %v", tt.status, got, tt.settled)
+		}
+		if got := tt.status.Failed(); got != tt.failed {
+			t.Errorf("%s.Failed() = %v, want %v", tt.status, got, tt.failed)
+		}
+	}
+}
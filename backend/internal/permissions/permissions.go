@@ -0,0 +1,200 @@
+package permissions
+
+import "vehicle-sales-backend/internal/models"
+
+// Permission names are "<resource>:<action>". Routes check them through
+// middleware.PermissionRequired; roles are granted them in the database.
+const (
+	VehicleCreate = "vehicle:create"
+	VehicleUpdate = "vehicle:update"
+	VehicleDelete = "vehicle:delete"
+	VehicleCost   = "vehicle:cost"
+
+	InventoryAging   = "inventory:aging"
+	InventoryReprice = "inventory:reprice"
+
+	SaleRead      = "sale:read"
+	SaleReadAll   = "sale:read_all"
+	SaleCreate    = "sale:create"
+	SaleUpdate    = "sale:update"
+	SaleApprove   = "sale:approve"
+	SaleDelete    = "sale:delete"
+	SaleAnalytics = "sale:analytics"
+
+	QuoteRead    = "quote:read"
+	QuoteReadAll = "quote:read_all"
+	QuoteManage  = "quote:manage"
+
+	TransactionRead      = "transaction:read"
+	TransactionReadAll   = "transaction:read_all"
+	TransactionCreate    = "transaction:create"
+	TransactionUpdate    = "transaction:update"
+	TransactionProcess   = "transaction:process"
+	TransactionRefund    = "transaction:refund"
+	TransactionAnalytics = "transaction:analytics"
+
+	TradeInRead    = "trade_in:read"
+	TradeInReadAll = "trade_in:read_all"
+	TradeInManage  = "trade_in:manage"
+
+	FinancingRead     = "financing:read"
+	FinancingReadAll  = "financing:read_all"
+	FinancingManage   = "financing:manage"
+	FinancingDisburse = "financing:disburse"
+
+	CommissionRead    = "commission:read"
+	CommissionReadAll = "commission:read_all"
+	CommissionManage  = "commission:manage"
+
+	TaxManage = "tax:manage"
+	TaxReport = "tax:report"
+
+	ShiftOperate  = "shift:operate"
+	ShiftManage   = "shift:manage"
+	ShiftFinalize = "shift:finalize"
+
+	TestDriveRead      = "test_drive:read"
+	TestDriveReadAll   = "test_drive:read_all"
+	TestDriveCreate    = "test_drive:create"
+	TestDriveUpdate    = "test_drive:update"
+	TestDriveCancel    = "test_drive:cancel"
+	TestDriveAnalytics = "test_drive:analytics"
+
+	LeadRead      = "lead:read"
+	LeadUpdate    = "lead:update"
+	LeadDelete    = "lead:delete"
+	LeadAssign    = "lead:assign"
+	LeadAnalytics = "lead:analytics"
+
+	DashboardAnalytics = "dashboard:analytics"
+
+	UserManage = "user:manage"
+	RoleManage = "role:manage"
+)
+
+type Definition struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Registry is every permission the application checks. Sync stores it in the
+// permissions table so roles can reference it.
+var Registry = []Definition{
+	{VehicleCreate, "Add vehicles to inventory"},
+	{VehicleUpdate, "Edit vehicle details and status"},
+	{VehicleDelete, "Remove vehicles from inventory"},
+	{VehicleCost, "Record vehicle costs and view gross profit"},
+	{InventoryAging, "View inventory aging and price history"},
+	{InventoryReprice, "Configure automatic repricing rules"},
+
+	{SaleRead, "View own purchases"},
+	{SaleReadAll, "View all sales"},
+	{SaleCreate, "Create sales"},
+	{SaleUpdate, "Edit sales"},
+	{SaleApprove, "Approve sales for payment"},
+	{SaleDelete, "Delete pending sales"},
+	{SaleAnalytics, "View sales analytics"},
+
+	{QuoteRead, "View own quotes"},
+	{QuoteReadAll, "View all quotes"},
+	{QuoteManage, "Prepare, revise and send quotes"},
+
+	{TransactionRead, "View own payments"},
+	{TransactionReadAll, "View all transactions"},
+	{TransactionCreate, "Record payments against sales"},
+	{TransactionUpdate, "Edit transactions"},
+	{TransactionProcess, "Process pending payments"},
+	{TransactionRefund, "Refund completed payments"},
+	{TransactionAnalytics, "View transaction analytics"},
+
+	{TradeInRead, "View own trade-in appraisals"},
+	{TradeInReadAll, "View all trade-ins"},
+	{TradeInManage, "Appraise trade-ins and credit them to sales"},
+
+	{FinancingRead, "View own financing applications"},
+	{FinancingReadAll, "View all financing applications"},
+	{FinancingManage, "Manage lenders, financing applications and offers"},
+	{FinancingDisburse, "Record lender disbursements"},
+	{CommissionRead, "View own commission statements"},
+	{CommissionReadAll, "View every salesperson's commission statement"},
+	{CommissionManage, "Configure commission plans"},
+
+	{TaxManage, "Configure tax and fee rules"},
+	{TaxReport, "View tax summaries"},
+
+	{ShiftOperate, "Open and close own cash drawer shifts"},
+	{ShiftManage, "View and close any cashier's shift"},
+	{ShiftFinalize, "Run Z-reports that lock closed shifts"},
+
+	{TestDriveRead, "View own test drive bookings"},
+	{TestDriveReadAll, "View all test drive bookings"},
+	{TestDriveCreate, "Book test drives"},
+	{TestDriveUpdate, "Reschedule and update test drives"},
+	{TestDriveCancel, "Cancel test drives"},
+	{TestDriveAnalytics, "View test drive analytics"},
+
+	{LeadRead, "View leads"},
+	{LeadUpdate, "Edit leads"},
+	{LeadDelete, "Delete leads"},
+	{LeadAssign, "Assign leads to sales staff"},
+	{LeadAnalytics, "View lead analytics"},
+
+	{DashboardAnalytics, "View detailed dashboard analytics"},
+
+	{UserManage, "Manage users and staff invitations"},
+	{RoleManage, "Manage roles and their permissions"},
+}
+
+// Defaults are granted to the built-in roles when a permission is first
+// registered. Admins hold every permission implicitly. Cashiers run their
+// shifts but do not finalize them: shift:finalize is left to admins and to
+// the supervisor roles they grant it to.
+var Defaults = map[models.UserRole][]string{
+	models.RoleSales: {
+		VehicleCreate, VehicleUpdate, VehicleCost,
+		InventoryAging,
+		SaleRead, SaleReadAll, SaleCreate, SaleUpdate, SaleApprove, SaleAnalytics,
+		QuoteRead, QuoteReadAll, QuoteManage,
+		TradeInRead, TradeInReadAll, TradeInManage,
+		FinancingRead, FinancingReadAll, FinancingManage,
+		CommissionRead,
+		TestDriveRead, TestDriveReadAll, TestDriveCreate, TestDriveUpdate, TestDriveCancel, TestDriveAnalytics,
+		LeadRead, LeadUpdate, LeadAssign, LeadAnalytics,
+		DashboardAnalytics,
+	},
+	models.RoleCashier: {
+		SaleRead, SaleReadAll,
+		TransactionRead, TransactionReadAll, TransactionCreate, TransactionUpdate, TransactionProcess, TransactionAnalytics,
+		TradeInRead, TradeInReadAll,
+		FinancingRead, FinancingReadAll, FinancingDisburse,
+		ShiftOperate,
+		TestDriveRead, TestDriveReadAll, TestDriveCreate, TestDriveCancel,
+	},
+	models.RoleCustomer: {
+		SaleRead,
+		QuoteRead,
+		TradeInRead,
+		FinancingRead,
+		TransactionRead,
+		TestDriveRead, TestDriveCreate, TestDriveCancel,
+	},
+}
+
+// DiscountLimits are the discounts, in basis points of a vehicle's price,
+// that the built-in roles may give without approval when the roles are first
+// created. Admins have no limit.
+var DiscountLimits = map[models.UserRole]int64{
+	models.RoleSales:    300,
+	models.RoleCashier:  0,
+	models.RoleCustomer: 0,
+}
+
+// IsRegistered reports whether name is a known permission.
+func IsRegistered(name string) bool {
+	for _, def := range Registry {
+		if def.Name == name {
+			return true
+		}
+	}
+	return false
+}
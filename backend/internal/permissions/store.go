@@ -0,0 +1,156 @@
+package permissions
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// cacheTTL bounds how long a replica keeps serving a role's permissions after
+// another replica changed them.
+const cacheTTL = time.Minute
+
+// syncLockID is the transaction advisory lock key held while Sync runs.
+const syncLockID int64 = 7_310_552_004_119
+
+var cache = struct {
+	sync.RWMutex
+	grants   map[models.UserRole]map[string]bool
+	loadedAt time.Time
+}{}
+
+// Sync stores the permission registry and the built-in roles. A permission
+// seen for the first time is granted to the built-in roles listed in
+// Defaults; existing grants are left alone so admin edits survive restarts.
+func Sync() error {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Serialise replicas starting at the same time
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", syncLockID).Error; err != nil {
+			return err
+		}
+
+		for _, role := range []models.UserRole{models.RoleAdmin, models.RoleSales, models.RoleCashier, models.RoleCustomer} {
+			r := models.Role{
+				Name:        role,
+				DisplayName: strings.ToUpper(string(role[:1])) + string(role[1:]),
+				IsSystem:    true,
+			}
+			if limit, ok := DiscountLimits[role]; ok {
+				r.MaxDiscountRate = &limit
+			}
+			if err := tx.Where(models.Role{Name: role}).FirstOrCreate(&r).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, def := range Registry {
+			var perm models.Permission
+			result := tx.Where(models.Permission{Name: def.Name}).
+				Attrs(models.Permission{Description: def.Description}).
+				FirstOrCreate(&perm)
+			if result.Error != nil {
+				return result.Error
+			}
+
+			// Only newly registered permissions receive default grants
+			if result.RowsAffected == 0 {
+				continue
+			}
+
+			for role, granted := range Defaults {
+				if !contains(granted, def.Name) {
+					continue
+				}
+				var r models.Role
+				if err := tx.Where("name = ?", role).First(&r).Error; err != nil {
+					return err
+				}
+				if err := tx.Model(&r).Association("Permissions").Append(&perm); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	Invalidate()
+	log.Println("Permissions synchronized")
+	return nil
+}
+
+// Has reports whether role holds permission. Admins hold every permission.
+func Has(role models.UserRole, permission string) bool {
+	if role == models.RoleAdmin {
+		return true
+	}
+
+	cache.RLock()
+	fresh := cache.grants != nil && time.Since(cache.loadedAt) < cacheTTL
+	if fresh {
+		granted := cache.grants[role][permission]
+		cache.RUnlock()
+		return granted
+	}
+	cache.RUnlock()
+
+	grants, err := load()
+	if err != nil {
+		log.Printf("Failed to load role permissions: %v", err)
+		return false
+	}
+	return grants[role][permission]
+}
+
+// Invalidate drops cached grants after roles or their permissions change.
+func Invalidate() {
+	cache.Lock()
+	cache.grants = nil
+	cache.Unlock()
+}
+
+// RoleExists reports whether a role with the given name has been defined.
+func RoleExists(role models.UserRole) bool {
+	var count int64
+	database.DB.Model(&models.Role{}).Where("name = ?", role).Count(&count)
+	return count > 0
+}
+
+func load() (map[models.UserRole]map[string]bool, error) {
+	var roles []models.Role
+	if err := database.DB.Preload("Permissions").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+
+	grants := make(map[models.UserRole]map[string]bool, len(roles))
+	for _, role := range roles {
+		grants[role.Name] = make(map[string]bool, len(role.Permissions))
+		for _, perm := range role.Permissions {
+			grants[role.Name][perm.Name] = true
+		}
+	}
+
+	cache.Lock()
+	cache.grants = grants
+	cache.loadedAt = time.Now()
+	cache.Unlock()
+
+	return grants, nil
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+// Package repricing works out how a repricing rule lowers the price of a
+// vehicle the longer it stays in stock. The services package picks the rule
+// and records the price changes; this package only does the arithmetic.
+package repricing
+
+import (
+	"errors"
+
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+)
+
+// ErrCurrencyMismatch is returned when a vehicle's price and cost are in
+// different currencies.
+var ErrCurrencyMismatch = errors.New("vehicle price and cost are in different currencies")
+
+// Due returns how many drops rule makes by the time a vehicle has been in
+// stock for daysInStock days: one every EveryDays days.
+func Due(rule models.RepricingRule, daysInStock int) int {
+	if rule.EveryDays <= 0 || daysInStock < 0 {
+		return 0
+	}
+	return daysInStock / rule.EveryDays
+}
+
+// Floor is the lowest price rule takes a vehicle that cost cost to:
+// FloorMarkup basis points over cost, rounded half away from zero.
+func Floor(rule models.RepricingRule, cost money.Money) money.Money {
+	return money.New(cost.Minor+money.Scale(cost.Minor, rule.FloorMarkup, 10000), cost.Currency)
+}
+
+// Drop lowers price once by rule's DropRate, but not below its floor over
+// cost. It reports false when price is already at or below the floor.
+func Drop(rule models.RepricingRule, price, cost money.Money) (money.Money, bool, error) {
+	if price.Currency != cost.Currency {
+		return money.Money{}, false, ErrCurrencyMismatch
+	}
+	floor := Floor(rule, cost)
+	if price.Minor <= floor.Minor {
+		return price, false, nil
+	}
+
+	lowered := money.New(price.Minor-money.Scale(price.Minor, rule.DropRate, 10000), price.Currency)
+	if lowered.Minor < floor.Minor {
+		lowered = floor
+	}
+	return lowered, lowered.Minor < price.Minor, nil
+}
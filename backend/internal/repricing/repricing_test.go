@@ -0,0 +1,93 @@
+package repricing
+
+import (
+	"errors"
+	"testing"
+
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+)
+
+func idr(minor int64) money.Money { return money.New(minor, money.IDR) }
+
+// rule drops the price 3% every 30 days, down to 5% over cost.
+var rule = models.RepricingRule{EveryDays: 30, DropRate: 300, FloorMarkup: 500}
+
+func TestDue(t *testing.T) {
+	tests := []struct {
+		name string
+		rule models.RepricingRule
+		days int
+		want int
+	}{
+		{"just listed", rule, 0, 0},
+		{"before the first drop", rule, 29, 0},
+		{"first drop", rule, 30, 1},
+		{"between drops", rule, 59, 1},
+		{"second drop", rule, 60, 2},
+		{"a year in stock", rule, 365, 12},
+		{"negative days", rule, -30, 0},
+		{"no interval", models.RepricingRule{DropRate: 300}, 365, 0},
+		{"negative interval", models.RepricingRule{EveryDays: -30}, 365, 0},
+	}
+	for _, tt := range tests {
+		if got := Due(tt.rule, tt.days); got != tt.want {
+			t.Errorf("%s: Due = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFloor(t *testing.T) {
+	tests := []struct {
+		name        string
+		markup      int64
+		cost, floor int64
+	}{
+		{"markup", 500, 100_000_000, 105_000_000},
+		{"rounded up", 500, 333, 350},   // 16.65
+		{"rounded down", 500, 329, 345}, // 16.45
+		{"no markup", 0, 100_000_000, 100_000_000},
+		{"no cost", 500, 0, 0},
+	}
+	for _, tt := range tests {
+		r := rule
+		r.FloorMarkup = tt.markup
+		if got := Floor(r, idr(tt.cost)); got != idr(tt.floor) {
+			t.Errorf("%s: Floor = %v, want %d", tt.name, got, tt.floor)
+		}
+	}
+}
+
+func TestDrop(t *testing.T) {
+	tests := []struct {
+		name        string
+		rule        models.RepricingRule
+		price, cost int64
+		want        int64
+		ok          bool
+	}{
+		{"drop", rule, 120_000_000, 100_000_000, 116_400_000, true},
+		{"rounded", rule, 1_000_001, 0, 970_001, true}, // 30,000.03 off
+		{"clamped to the floor", rule, 107_000_000, 100_000_000, 105_000_000, true},
+		{"just over the floor", rule, 105_000_001, 100_000_000, 105_000_000, true},
+		{"at the floor", rule, 105_000_000, 100_000_000, 105_000_000, false},
+		{"below the floor", rule, 104_000_000, 100_000_000, 104_000_000, false},
+		{"no drop rate", models.RepricingRule{EveryDays: 30, FloorMarkup: 500}, 120_000_000, 100_000_000, 120_000_000, false},
+		{"no markup", models.RepricingRule{EveryDays: 30, DropRate: 300}, 101_000_000, 100_000_000, 100_000_000, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := Drop(tt.rule, idr(tt.price), idr(tt.cost))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != idr(tt.want) || ok != tt.ok {
+				t.Errorf("Drop = %v, %t, want %d, %t", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+
+	if _, _, err := Drop(rule, idr(120_000_000), money.New(1_000_000, money.USD)); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("err = %v, want ErrCurrencyMismatch", err)
+	}
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"errors"
+
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/statemachine"
+
+	"gorm.io/gorm"
+)
+
+// discountReview is a sale's discount off its vehicle's price, checked
+// against the limit of whoever priced the sale.
+type discountReview struct {
+	ListPrice     money.Money
+	Discount      money.Money
+	Rate          int64
+	NeedsApproval bool
+}
+
+// ApproveDiscount lets a sale awaiting approval go ahead at its discount.
+// Only someone whose own limit covers the discount may approve it.
+func (s *SaleService) ApproveDiscount(actor Actor, id uint, comment string) (*models.Sale, error) {
+	return decideDiscount(actor, id, models.ApprovalActionApproved, comment)
+}
+
+// RejectDiscount turns down the discount of a sale awaiting approval, which
+// cancels the sale and releases its vehicle.
+func (s *SaleService) RejectDiscount(actor Actor, id uint, comment string) (*models.Sale, error) {
+	return decideDiscount(actor, id, models.ApprovalActionRejected, comment)
+}
+
+func decideDiscount(actor Actor, id uint, action models.ApprovalAction, comment string) (*models.Sale, error) {
+	var sale *models.Sale
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		sale, err = lockSale(tx, id)
+		if err != nil {
+			return err
+		}
+		if sale.Status != models.SaleStatusAwaitingApproval {
+			return invalidState("Sale is not awaiting discount approval")
+		}
+
+		limit, err := discountLimit(tx, actor)
+		if err != nil {
+			return err
+		}
+		if !withinLimit(limit, sale.Discount, sale.ListPrice) {
+			return forbidden("The discount exceeds your approval limit")
+		}
+
+		to := models.SaleStatusPending
+		var by statemachine.Actor = statemachine.System
+		if action == models.ApprovalActionRejected {
+			to, by = models.SaleStatusCanceled, actor
+		}
+		if err := SaleStates.Fire(tx, by, sale, sale.Status, to); err != nil {
+			return err
+		}
+		if err := updateVersioned(tx, &models.Sale{}, sale.ID, sale.Version, map[string]interface{}{"status": to}); err != nil {
+			return err
+		}
+		review := discountReview{ListPrice: sale.ListPrice, Discount: sale.Discount, Rate: sale.DiscountRate}
+		if err := recordApproval(tx, sale.ID, actor.UserID, action, review, comment); err != nil {
+			return err
+		}
+		return tx.First(sale, sale.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sale, nil
+}
+
+// reviewDiscount measures how far below the vehicle's price a deal sells the
+// vehicle, and whether that is more than actor may give. Add-ons and
+// trade-ins do not count towards the discount.
+func reviewDiscount(tx *gorm.DB, actor Actor, vehicle *models.Vehicle, pricing models.Pricing) (discountReview, error) {
+	currency := pricing.Total.Currency
+	review := discountReview{ListPrice: vehicle.Price, Discount: money.Zero(currency)}
+	if vehicle.Price.Currency != currency || !vehicle.Price.IsPositive() {
+		// a price in another currency cannot be compared
+		return review, nil
+	}
+
+	var sold int64
+	for _, line := range pricing.Lines {
+		if line.Type == models.LineTypeVehicle || line.Type == models.LineTypeDiscount {
+			sold += line.Amount.Minor
+		}
+	}
+	if sold >= vehicle.Price.Minor {
+		return review, nil
+	}
+	review.Discount = money.New(vehicle.Price.Minor-sold, currency)
+	review.Rate = (review.Discount.Minor*10000 + vehicle.Price.Minor/2) / vehicle.Price.Minor
+
+	limit, err := discountLimit(tx, actor)
+	if err != nil {
+		return review, err
+	}
+	review.NeedsApproval = !withinLimit(limit, review.Discount, review.ListPrice)
+	return review, nil
+}
+
+// discountLimit returns the largest discount, in basis points, that actor's
+// role may give or approve; nil means no limit.
+func discountLimit(tx *gorm.DB, actor Actor) (*int64, error) {
+	if actor.Role == models.RoleAdmin {
+		return nil, nil
+	}
+	var role models.Role
+	err := tx.Where("name = ?", actor.Role).First(&role).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		var none int64
+		return &none, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return role.MaxDiscountRate, nil
+}
+
+// withinLimit reports whether discount off listPrice is at most limit basis
+// points of it.
+func withinLimit(limit *int64, discount, listPrice money.Money) bool {
+	if limit == nil || !discount.IsPositive() {
+		return true
+	}
+	return discount.Minor*10000 <= *limit*listPrice.Minor
+}
+
+func recordApproval(tx *gorm.DB, saleID, userID uint, action models.ApprovalAction, review discountReview, comment string) error {
+	return tx.Create(&models.SaleApproval{
+		SaleID:       saleID,
+		UserID:       userID,
+		Action:       action,
+		ListPrice:    review.ListPrice,
+		Discount:     review.Discount,
+		DiscountRate: review.Rate,
+		Comment:      comment,
+	}).Error
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+
+	"gorm.io/gorm"
+)
+
+// saleBalance totals the transactions of a sale. Transactions always share
+// the sale's currency, so their minor units can be summed directly.
+func saleBalance(tx *gorm.DB, sale *models.Sale) (*models.SaleBalance, error) {
+	var rows []struct {
+		Status models.TransactionStatus
+		Total  int64
+	}
+	err := tx.Model(&models.Transaction{}).
+		Select("status, COALESCE(SUM(amount_minor), 0) AS total").
+		Where("sale_id = ? AND status IN ?", sale.ID,
+			[]models.TransactionStatus{models.TransactionStatusCompleted, models.TransactionStatusPending}).
+		// pending refunds have not left the till yet
+		Where("NOT (type = ? AND status = ?)", models.TransactionTypeRefund, models.TransactionStatusPending).
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	currency := sale.SalePrice.Currency
+	balance := &models.SaleBalance{
+		SaleID:  sale.ID,
+		TradeIn: orZero(sale.TradeIn, currency),
+		Due:     sale.SalePrice,
+		Paid:    money.Zero(currency),
+		Pending: money.Zero(currency),
+	}
+	for _, row := range rows {
+		switch row.Status {
+		case models.TransactionStatusCompleted:
+			balance.Paid = money.New(row.Total, currency)
+		case models.TransactionStatusPending:
+			balance.Pending = money.New(row.Total, currency)
+		}
+	}
+
+	balance.Outstanding, err = balance.Due.Sub(balance.Paid)
+	if err != nil {
+		return nil, err
+	}
+	balance.FullyPaid = !balance.Outstanding.IsPositive()
+	return balance, nil
+}
+
+// completePayment applies a pending transaction to its sale's balance and
+// completes the sale when that settles it. It rejects payments that would
+// take the sale past its price, e.g. after the price was lowered.
+func completePayment(tx *gorm.DB, transaction *models.Transaction) error {
+	sale, err := lockSale(tx, transaction.SaleID)
+	if err != nil {
+		return err
+	}
+	balance, err := checkPayable(tx, sale, transaction)
+	if err != nil {
+		return err
+	}
+
+	if sale.Status == models.SaleStatusApproved && transaction.Amount.Minor == balance.Outstanding.Minor {
+		return transitionSale(tx, sale.ID, models.SaleStatusCompleted)
+	}
+	return nil
+}
+
+// checkPayable reports whether transaction can still be applied to sale and
+// returns the sale's balance before it is.
+func checkPayable(tx *gorm.DB, sale *models.Sale, transaction *models.Transaction) (*models.SaleBalance, error) {
+	if sale.Status != models.SaleStatusPending && sale.Status != models.SaleStatusApproved {
+		return nil, invalidState("Payments can only be taken for pending or approved sales")
+	}
+
+	balance, err := saleBalance(tx, sale)
+	if err != nil {
+		return nil, err
+	}
+	if transaction.Amount.Minor > balance.Outstanding.Minor {
+		return nil, conflict("Payment exceeds the outstanding balance of the sale", map[string]interface{}{
+			"outstanding": balance.Outstanding,
+		})
+	}
+	return balance, nil
+}
+
+// settleSale completes an approved sale whose balance is already paid, e.g.
+// when deposits covered the price before the sale was approved.
+func settleSale(tx *gorm.DB, saleID uint) error {
+	sale, err := lockSale(tx, saleID)
+	if err != nil {
+		return err
+	}
+	if sale.Status != models.SaleStatusApproved {
+		return nil
+	}
+
+	balance, err := saleBalance(tx, sale)
+	if err != nil {
+		return err
+	}
+	if !balance.FullyPaid {
+		return nil
+	}
+	if err := transitionSale(tx, sale.ID, models.SaleStatusCompleted); err != nil {
+		return err
+	}
+	return issueInvoice(tx, sale.ID)
+}
@@ -0,0 +1,404 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"vehicle-sales-backend/internal/commission"
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+
+	"gorm.io/gorm"
+)
+
+// CommissionService configures commission plans and reports the commission
+// ledger. Commission is accrued as sales complete and reversed by refunds;
+// nothing here needs to be called for that.
+type CommissionService struct{}
+
+func NewCommissionService() *CommissionService {
+	return &CommissionService{}
+}
+
+type CommissionPlanInput struct {
+	Name string
+	// SalesPersonID limits the plan to one salesperson; nil makes it the
+	// plan for everyone without one
+	SalesPersonID   *uint
+	IsActive        bool
+	PerUnit         money.Money
+	GrossProfitRate int64
+	Tiers           []CommissionTierInput
+	MakeBonuses     []CommissionMakeBonusInput
+}
+
+type CommissionTierInput struct {
+	MinUnits        int
+	PerUnit         money.Money
+	GrossProfitRate int64
+}
+
+type CommissionMakeBonusInput struct {
+	Make  string
+	Bonus money.Money
+}
+
+// CreatePlan adds a commission plan.
+func (s *CommissionService) CreatePlan(input CommissionPlanInput) (*models.CommissionPlan, error) {
+	var plan models.CommissionPlan
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		input.apply(&plan)
+		if err := checkCommissionPlan(tx, &plan); err != nil {
+			return err
+		}
+		return tx.Create(&plan).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loadCommissionPlan(database.DB, plan.ID)
+}
+
+// UpdatePlan replaces a commission plan. Commission already accrued keeps
+// the amounts it was worked out with.
+func (s *CommissionService) UpdatePlan(id uint, input CommissionPlanInput) (*models.CommissionPlan, error) {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var plan models.CommissionPlan
+		if err := forUpdate(tx).First(&plan, id).Error; err != nil {
+			return notFoundOr(err, "Commission plan not found")
+		}
+		input.apply(&plan)
+		if err := checkCommissionPlan(tx, &plan); err != nil {
+			return err
+		}
+		for _, child := range []interface{}{&models.CommissionTier{}, &models.CommissionMakeBonus{}} {
+			if err := tx.Where("plan_id = ?", plan.ID).Delete(child).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(&plan).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loadCommissionPlan(database.DB, id)
+}
+
+// DeletePlan retires a commission plan. Its ledger entries are kept.
+func (s *CommissionService) DeletePlan(id uint) error {
+	result := database.DB.Delete(&models.CommissionPlan{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return notFound("Commission plan not found")
+	}
+	return nil
+}
+
+// Statements totals the commission ledger entries made in [from, to) in
+// currency per salesperson, or for salesPersonID only when it is set, in
+// which case a statement is returned even if there are no entries.
+func (s *CommissionService) Statements(from, to time.Time, currency money.Currency, salesPersonID *uint) ([]models.CommissionStatement, error) {
+	query := database.DB.Preload("Sale").
+		Where("created_at >= ? AND created_at < ? AND amount_currency = ?", from, to, currency)
+	if salesPersonID != nil {
+		query = query.Where("sales_person_id = ?", *salesPersonID)
+	}
+	var entries []models.CommissionEntry
+	if err := query.Order("sales_person_id, created_at, id").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	statements := map[uint]*models.CommissionStatement{}
+	statement := func(id uint) *models.CommissionStatement {
+		if statements[id] == nil {
+			statements[id] = &models.CommissionStatement{
+				SalesPersonID: id,
+				From:          from,
+				To:            to,
+				Currency:      currency,
+				Accrued:       money.Zero(currency),
+				Reversed:      money.Zero(currency),
+				Net:           money.Zero(currency),
+				Entries:       []models.CommissionEntry{},
+			}
+		}
+		return statements[id]
+	}
+	if salesPersonID != nil {
+		statement(*salesPersonID)
+	}
+	for _, entry := range entries {
+		total := statement(entry.SalesPersonID)
+		total.Units += int64(entry.Units)
+		if entry.Type == models.CommissionAccrual {
+			total.Accrued.Minor += entry.Amount.Minor
+		} else {
+			total.Reversed.Minor += entry.Amount.Minor
+		}
+		total.Net.Minor += entry.Amount.Minor
+		total.Entries = append(total.Entries, entry)
+	}
+
+	ids := make([]uint, 0, len(statements))
+	for id := range statements {
+		ids = append(ids, id)
+	}
+	var users []models.User
+	if err := database.DB.Unscoped().Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		statements[user.ID].SalesPerson = user.Name
+	}
+
+	result := make([]models.CommissionStatement, 0, len(statements))
+	for _, id := range ids {
+		result = append(result, *statements[id])
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].SalesPerson != result[j].SalesPerson {
+			return result[i].SalesPerson < result[j].SalesPerson
+		}
+		return result[i].SalesPersonID < result[j].SalesPersonID
+	})
+	return result, nil
+}
+
+// accrueCommission records the commission on a sale as it completes, under
+// the salesperson's plan or the plan for everyone, once its gross profit has
+// been recorded. Sales nobody has a plan
+// for earn nothing. A plan in another currency than the sale is recorded
+// with nothing earned, so the gap shows on the statement.
+func accrueCommission(tx *gorm.DB, sale *models.Sale) error {
+	plan, err := commissionPlanFor(tx, sale.SalesPersonID)
+	if err != nil || plan == nil {
+		return err
+	}
+
+	completedAt := time.Now()
+	if sale.CompletedAt != nil {
+		completedAt = *sale.CompletedAt
+	}
+	monthStart := time.Date(completedAt.Year(), completedAt.Month(), 1, 0, 0, 0, 0, completedAt.Location())
+	var earlier int64
+	err = tx.Model(&models.Sale{}).
+		Where("sales_person_id = ? AND status = ? AND completed_at >= ? AND id <> ?",
+			sale.SalesPersonID, models.SaleStatusCompleted, monthStart, sale.ID).
+		Count(&earlier).Error
+	if err != nil {
+		return err
+	}
+
+	var vehicle models.Vehicle
+	if err := tx.Unscoped().First(&vehicle, sale.VehicleID).Error; err != nil {
+		return notFoundOr(err, "Vehicle not found")
+	}
+	profit := sale.GrossProfit
+	currency := profit.Currency
+	entry := models.CommissionEntry{
+		SaleID:        sale.ID,
+		SalesPersonID: sale.SalesPersonID,
+		PlanID:        plan.ID,
+		Type:          models.CommissionAccrual,
+		Units:         1,
+		MonthUnit:     int(earlier) + 1,
+		GrossProfit:   profit,
+		PerUnit:       money.Zero(currency),
+		ProfitShare:   money.Zero(currency),
+		MakeBonus:     money.Zero(currency),
+		Amount:        money.Zero(currency),
+	}
+	earning, err := commission.Calculate(*plan, commission.Sale{
+		GrossProfit: profit,
+		Make:        vehicle.Make,
+		MonthUnit:   entry.MonthUnit,
+	})
+	switch {
+	case errors.Is(err, commission.ErrCurrencyMismatch):
+		entry.Notes = "Plan " + plan.Name + " does not pay commission in " + string(currency)
+	case err != nil:
+		return err
+	default:
+		entry.Tier = earning.Tier
+		entry.PerUnit = earning.PerUnit
+		entry.ProfitShare = earning.ProfitShare
+		entry.MakeBonus = earning.MakeBonus
+		entry.Amount = earning.Total
+	}
+	return tx.Create(&entry).Error
+}
+
+// reverseCommission takes back the commission on a sale for a refund
+// against it: the refund's share of the sale price, or all that is left
+// when the refund cancels the sale.
+func reverseCommission(tx *gorm.DB, sale *models.Sale, refund *models.Transaction, cancelsSale bool) error {
+	var accrual models.CommissionEntry
+	err := tx.Where("sale_id = ? AND type = ?", sale.ID, models.CommissionAccrual).First(&accrual).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var left struct {
+		Amount int64
+		Units  int64
+	}
+	err = tx.Model(&models.CommissionEntry{}).
+		Select("COALESCE(SUM(amount_minor), 0) AS amount, COALESCE(SUM(units), 0) AS units").
+		Where("sale_id = ?", sale.ID).
+		Scan(&left).Error
+	if err != nil {
+		return err
+	}
+
+	currency := accrual.Amount.Currency
+	amount := money.New(left.Amount, currency)
+	units := 0
+	if cancelsSale {
+		units = -int(left.Units)
+	} else {
+		share := commission.Share(accrual.Amount, refund.Amount.Neg(), sale.SalePrice)
+		if share.Minor < amount.Minor {
+			amount = share
+		}
+	}
+	if amount.IsZero() && units == 0 {
+		return nil
+	}
+
+	return tx.Create(&models.CommissionEntry{
+		SaleID:        sale.ID,
+		SalesPersonID: accrual.SalesPersonID,
+		PlanID:        accrual.PlanID,
+		Type:          models.CommissionReversal,
+		Units:         units,
+		GrossProfit:   money.Zero(currency),
+		PerUnit:       money.Zero(currency),
+		ProfitShare:   money.Zero(currency),
+		MakeBonus:     money.Zero(currency),
+		Amount:        amount.Neg(),
+		RefundID:      &refund.ID,
+		Notes:         "Refund " + refund.TransactionRef,
+	}).Error
+}
+
+// commissionPlanFor returns the active plan of a salesperson, falling back
+// to the active plan for everyone, or nil if there is neither.
+func commissionPlanFor(tx *gorm.DB, salesPersonID uint) (*models.CommissionPlan, error) {
+	var plan models.CommissionPlan
+	err := tx.Preload("Tiers").Preload("MakeBonuses").
+		Where("is_active AND (sales_person_id = ? OR sales_person_id IS NULL)", salesPersonID).
+		Order("sales_person_id IS NULL").
+		First(&plan).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// checkCommissionPlan verifies that a plan pays in one currency, that its
+// tiers and make bonuses are distinct, that it is for a salesperson and
+// that it is the only active plan for them.
+func checkCommissionPlan(tx *gorm.DB, plan *models.CommissionPlan) error {
+	currency := plan.PerUnit.Currency
+	units := map[int]bool{}
+	for _, tier := range plan.Tiers {
+		if tier.PerUnit.Currency != currency {
+			return invalidState("Tiers must pay in the plan currency " + string(currency))
+		}
+		if units[tier.MinUnits] {
+			return invalidState("Tiers must start at different numbers of units")
+		}
+		units[tier.MinUnits] = true
+	}
+	makes := map[string]bool{}
+	for _, bonus := range plan.MakeBonuses {
+		if bonus.Bonus.Currency != currency {
+			return invalidState("Make bonuses must pay in the plan currency " + string(currency))
+		}
+		key := strings.ToLower(bonus.Make)
+		if makes[key] {
+			return invalidState("Make " + bonus.Make + " has more than one bonus")
+		}
+		makes[key] = true
+	}
+
+	if plan.SalesPersonID != nil {
+		if err := checkSalesPerson(tx, *plan.SalesPersonID); err != nil {
+			return err
+		}
+	}
+	if !plan.IsActive {
+		return nil
+	}
+
+	query := tx.Where("is_active AND id <> ?", plan.ID)
+	if plan.SalesPersonID != nil {
+		query = query.Where("sales_person_id = ?", *plan.SalesPersonID)
+	} else {
+		query = query.Where("sales_person_id IS NULL")
+	}
+	var existing models.CommissionPlan
+	err := query.First(&existing).Error
+	if err == nil {
+		return conflict("Another commission plan is already active for this salesperson", map[string]interface{}{
+			"plan_id": existing.ID,
+		})
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (input CommissionPlanInput) apply(plan *models.CommissionPlan) {
+	plan.Name = input.Name
+	plan.SalesPersonID = input.SalesPersonID
+	plan.IsActive = input.IsActive
+	// a plan without a per-unit amount pays in the default currency
+	currency := input.PerUnit.Currency
+	if currency == "" {
+		currency = money.DefaultCurrency()
+	}
+	plan.PerUnit = orZero(input.PerUnit, currency)
+	plan.GrossProfitRate = input.GrossProfitRate
+	plan.Tiers, plan.MakeBonuses = nil, nil
+	for _, tier := range input.Tiers {
+		plan.Tiers = append(plan.Tiers, models.CommissionTier{
+			PlanID:          plan.ID,
+			MinUnits:        tier.MinUnits,
+			PerUnit:         orZero(tier.PerUnit, currency),
+			GrossProfitRate: tier.GrossProfitRate,
+		})
+	}
+	for _, bonus := range input.MakeBonuses {
+		plan.MakeBonuses = append(plan.MakeBonuses, models.CommissionMakeBonus{
+			PlanID: plan.ID,
+			Make:   strings.TrimSpace(bonus.Make),
+			Bonus:  bonus.Bonus,
+		})
+	}
+}
+
+func loadCommissionPlan(tx *gorm.DB, id uint) (*models.CommissionPlan, error) {
+	var plan models.CommissionPlan
+	err := tx.
+		Preload("SalesPerson").
+		Preload("Tiers", func(db *gorm.DB) *gorm.DB { return db.Order("min_units") }).
+		Preload("MakeBonuses", func(db *gorm.DB) *gorm.DB { return db.Order("make") }).
+		First(&plan, id).Error
+	if err != nil {
+		return nil, notFoundOr(err, "Commission plan not found")
+	}
+	return &plan, nil
+}
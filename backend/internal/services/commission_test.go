@@ -0,0 +1,62 @@
+package services_test
+
+import (
+	"errors"
+	"testing"
+
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/permissions"
+	"vehicle-sales-backend/internal/services"
+)
+
+// grantRole defines role with the given permissions.
+func grantRole(t *testing.T, role models.UserRole, granted ...string) {
+	t.Helper()
+	r := models.Role{Name: role, DisplayName: string(role)}
+	for _, name := range granted {
+		var perm models.Permission
+		if err := database.DB.Where(models.Permission{Name: name}).FirstOrCreate(&perm).Error; err != nil {
+			t.Fatal(err)
+		}
+		r.Permissions = append(r.Permissions, perm)
+	}
+	if err := database.DB.Where(models.Role{Name: role}).FirstOrCreate(&r).Error; err != nil {
+		t.Fatal(err)
+	}
+	permissions.Invalidate()
+}
+
+// TestPlanSalesPersonNeedsToSell checks that a plan can be made for anyone
+// whose role may create sales, custom roles included, and no one else.
+func TestPlanSalesPersonNeedsToSell(t *testing.T) {
+	grantRole(t, "sales_manager", permissions.SaleCreate, permissions.SaleApprove)
+	grantRole(t, "bookkeeper", permissions.SaleRead, permissions.SaleReadAll)
+
+	tests := []struct {
+		name string
+		role models.UserRole
+		ok   bool
+	}{
+		{"custom role that sells", "sales_manager", true},
+		{"custom role that does not sell", "bookkeeper", false},
+		{"customer", models.RoleCustomer, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := createUser(t, tt.role).ID
+			_, err := services.NewCommissionService().CreatePlan(services.CommissionPlanInput{
+				Name:          "Personal plan",
+				SalesPersonID: &id,
+				PerUnit:       money.New(1_000_000_00, money.IDR),
+			})
+			if tt.ok && err != nil {
+				t.Fatal(err)
+			}
+			if !tt.ok && !errors.Is(err, services.ErrNotFound) {
+				t.Errorf("err = %v, want sales person not found", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,463 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/documents"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/tax"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// documentPrefixes start the number of each document type; the branch code
+// follows, e.g. RCP-HQ-000007.
+var documentPrefixes = map[models.DocumentType]string{
+	models.DocumentTypeInvoice:    "INV",
+	models.DocumentTypeReceipt:    "RCP",
+	models.DocumentTypeCreditNote: "CN",
+}
+
+// DocumentService retrieves the invoices, receipts and credit notes issued
+// for sales and transactions. Documents are issued as part of the flow that
+// completes the sale or payment; sales and payments completed before
+// documents existed are issued theirs on first retrieval.
+type DocumentService struct{}
+
+func NewDocumentService() *DocumentService {
+	return &DocumentService{}
+}
+
+// Invoice returns the invoice of a completed sale.
+func (s *DocumentService) Invoice(saleID uint) (*models.Document, error) {
+	var document *models.Document
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		sale, err := lockSale(tx, saleID)
+		if err != nil {
+			return err
+		}
+		if sale.Status != models.SaleStatusCompleted {
+			if document, err = findDocument(tx, models.DocumentTypeInvoice, "sale_id = ?", sale.ID); err != nil || document != nil {
+				return err
+			}
+			return invalidState("An invoice is issued once the sale is completed")
+		}
+		if err := issueInvoice(tx, sale.ID); err != nil {
+			return err
+		}
+		document, err = findDocument(tx, models.DocumentTypeInvoice, "sale_id = ?", sale.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// Receipt returns the receipt of a completed payment or deposit, or the
+// credit note of a refund.
+func (s *DocumentService) Receipt(transactionID uint) (*models.Document, error) {
+	var document *models.Document
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		transaction, err := lockTransaction(tx, transactionID)
+		if err != nil {
+			return err
+		}
+		if transaction.Status != models.TransactionStatusCompleted {
+			return invalidState("A receipt is issued once the payment is completed")
+		}
+		if err := issueReceipt(tx, transaction); err != nil {
+			return err
+		}
+		document, err = findDocument(tx, receiptType(transaction), "transaction_id = ?", transaction.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// ReceiptContent returns the receipt or credit note of a transaction along
+// with its content as issued, for printing on a thermal printer.
+func (s *DocumentService) ReceiptContent(transactionID uint) (*models.Document, documents.Document, error) {
+	var content documents.Document
+	document, err := s.Receipt(transactionID)
+	if err != nil {
+		return nil, content, err
+	}
+	if len(document.Content) == 0 {
+		return nil, content, invalidState("Receipt " + document.Number + " was issued before receipts could be printed; download the PDF instead")
+	}
+	if err := json.Unmarshal(document.Content, &content); err != nil {
+		return nil, content, err
+	}
+	return document, content, nil
+}
+
+// PDF returns the PDF of an issued document. It is rendered from the
+// content as issued the first time it is requested and stored, so that
+// reprints match the first print.
+func (s *DocumentService) PDF(document *models.Document) ([]byte, error) {
+	if len(document.PDF) > 0 {
+		return document.PDF, nil
+	}
+	var content documents.Document
+	if err := json.Unmarshal(document.Content, &content); err != nil {
+		return nil, err
+	}
+	letterhead := documents.Current().Letterhead
+	if content.Letterhead != nil {
+		letterhead = *content.Letterhead
+	}
+	pdf, err := documents.RenderPDF(letterhead, content)
+	if err != nil {
+		return nil, err
+	}
+
+	// a concurrent request may have stored its print first; serve that one
+	result := database.DB.Model(&models.Document{}).Where("id = ? AND (pdf IS NULL OR length(pdf) = 0)", document.ID).Update("pdf", pdf)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		var stored models.Document
+		if err := database.DB.Select("pdf").First(&stored, document.ID).Error; err != nil {
+			return nil, err
+		}
+		pdf = stored.PDF
+	}
+	document.PDF = pdf
+	return pdf, nil
+}
+
+// SaleDocuments lists every document issued for a sale, oldest first.
+func (s *DocumentService) SaleDocuments(saleID uint) ([]models.Document, error) {
+	var documents []models.Document
+	err := database.DB.Omit("pdf", "content").Where("sale_id = ?", saleID).Order("issued_at, id").Find(&documents).Error
+	return documents, err
+}
+
+// issueTransactionDocuments issues the receipt or credit note of a
+// transaction that has just been saved as completed, and the invoice of its
+// sale if the transaction settled it.
+func issueTransactionDocuments(tx *gorm.DB, transaction *models.Transaction) error {
+	if transaction.Status != models.TransactionStatusCompleted {
+		return nil
+	}
+	if err := issueReceipt(tx, transaction); err != nil {
+		return err
+	}
+	return issueInvoice(tx, transaction.SaleID)
+}
+
+// issueInvoice issues the invoice of a completed sale unless it already has
+// one.
+func issueInvoice(tx *gorm.DB, saleID uint) error {
+	sale, err := loadDocumentSale(tx, saleID)
+	if err != nil {
+		return err
+	}
+	if sale.Status != models.SaleStatusCompleted {
+		return nil
+	}
+	if existing, err := findDocument(tx, models.DocumentTypeInvoice, "sale_id = ?", sale.ID); err != nil || existing != nil {
+		return err
+	}
+
+	content, err := invoiceContent(tx, sale)
+	if err != nil {
+		return err
+	}
+	return issueDocument(tx, &models.Document{
+		Type:   models.DocumentTypeInvoice,
+		SaleID: sale.ID,
+		Total:  sale.SalePrice,
+	}, content)
+}
+
+// issueReceipt issues the receipt of a completed payment or deposit, or the
+// credit note of a refund, unless it already has one.
+func issueReceipt(tx *gorm.DB, transaction *models.Transaction) error {
+	kind := receiptType(transaction)
+	if existing, err := findDocument(tx, kind, "transaction_id = ?", transaction.ID); err != nil || existing != nil {
+		return err
+	}
+
+	sale, err := loadDocumentSale(tx, transaction.SaleID)
+	if err != nil {
+		return err
+	}
+	var content documents.Document
+	if kind == models.DocumentTypeCreditNote {
+		content, err = creditNoteContent(tx, sale, transaction)
+	} else {
+		content, err = receiptContent(tx, sale, transaction)
+	}
+	if err != nil {
+		return err
+	}
+	return issueDocument(tx, &models.Document{
+		Type:          kind,
+		SaleID:        sale.ID,
+		TransactionID: &transaction.ID,
+		Total:         transaction.Amount,
+	}, content)
+}
+
+// issueDocument numbers and stores a document. The number is taken in the
+// caller's transaction, so a rolled back issue leaves no gap, and its
+// counter stays locked until that transaction ends: the PDF is rendered
+// later, by PDF, rather than under the lock.
+func issueDocument(tx *gorm.DB, document *models.Document, content documents.Document) error {
+	config := documents.Current()
+	prefix := documentPrefixes[document.Type] + "-" + config.Branch
+	sequence, err := nextDocumentSequence(tx, prefix)
+	if err != nil {
+		return err
+	}
+
+	document.Branch = config.Branch
+	document.Sequence = sequence
+	document.Number = fmt.Sprintf("%s-%06d", prefix, sequence)
+	document.IssuedAt = time.Now()
+
+	content.Number = document.Number
+	content.IssuedAt = document.IssuedAt
+	content.Letterhead = &config.Letterhead
+	if document.Content, err = json.Marshal(content); err != nil {
+		return err
+	}
+	return tx.Create(document).Error
+}
+
+// nextDocumentSequence increments and returns the counter of prefix. The
+// counter row stays locked until the caller's transaction ends.
+func nextDocumentSequence(tx *gorm.DB, prefix string) (uint, error) {
+	err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.DocumentSequence{Prefix: prefix}).Error
+	if err != nil {
+		return 0, err
+	}
+	var sequence models.DocumentSequence
+	if err := forUpdate(tx).Where("prefix = ?", prefix).First(&sequence).Error; err != nil {
+		return 0, err
+	}
+	sequence.Last++
+	if err := tx.Model(&sequence).Where("prefix = ?", prefix).Update("last", sequence.Last).Error; err != nil {
+		return 0, err
+	}
+	return sequence.Last, nil
+}
+
+func findDocument(tx *gorm.DB, kind models.DocumentType, query string, args ...interface{}) (*models.Document, error) {
+	var document models.Document
+	err := tx.Where("type = ?", kind).Where(query, args...).First(&document).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &document, nil
+}
+
+func receiptType(transaction *models.Transaction) models.DocumentType {
+	if transaction.Type == models.TransactionTypeRefund {
+		return models.DocumentTypeCreditNote
+	}
+	return models.DocumentTypeReceipt
+}
+
+func loadDocumentSale(tx *gorm.DB, saleID uint) (*models.Sale, error) {
+	var sale models.Sale
+	err := tx.Preload("Vehicle").Preload("Customer").Preload("SalesPerson").Preload("Lines", linesInOrder).
+		First(&sale, saleID).Error
+	if err != nil {
+		return nil, notFoundOr(err, "Sale not found")
+	}
+	return &sale, nil
+}
+
+func invoiceContent(tx *gorm.DB, sale *models.Sale) (documents.Document, error) {
+	balance, err := saleBalance(tx, sale)
+	if err != nil {
+		return documents.Document{}, err
+	}
+	lines, totals := itemise(lineItems(sale.Lines), sale.SalePrice)
+	totals = append(totals,
+		documents.Total{Label: "Total", Amount: sale.SalePrice, Emphasis: true},
+		documents.Total{Label: "Paid", Amount: balance.Paid},
+		documents.Total{Label: "Balance due", Amount: balance.Outstanding},
+	)
+	return documents.Document{
+		Title: "INVOICE",
+		Details: []documents.Field{
+			{Label: "Sale", Value: fmt.Sprintf("#%d", sale.ID)},
+			{Label: "Sales person", Value: sale.SalesPerson.Name},
+		},
+		PartyLabel: "Bill to",
+		Party:      customerLines(sale.Customer),
+		Lines:      lines,
+		Totals:     totals,
+		Notes:      []string{"Thank you for your purchase."},
+	}, nil
+}
+
+func receiptContent(tx *gorm.DB, sale *models.Sale, transaction *models.Transaction) (documents.Document, error) {
+	balance, err := saleBalance(tx, sale)
+	if err != nil {
+		return documents.Document{}, err
+	}
+
+	title, line := "PAYMENT RECEIPT", "Payment for "
+	if transaction.Type == models.TransactionTypeDeposit {
+		title, line = "DEPOSIT RECEIPT", "Booking deposit for "
+	}
+	totals := []documents.Total{{Label: "Amount received", Amount: transaction.Amount, Emphasis: true}}
+	if transaction.Tendered.IsPositive() {
+		totals = append(totals,
+			documents.Total{Label: "Cash tendered", Amount: transaction.Tendered},
+			documents.Total{Label: "Change", Amount: transaction.Change},
+		)
+	}
+	totals = append(totals, documents.Total{Label: "Balance outstanding", Amount: balance.Outstanding})
+
+	return documents.Document{
+		Title: title,
+		Details: []documents.Field{
+			{Label: "Reference", Value: transaction.TransactionRef},
+			{Label: "Payment method", Value: paymentMethodLabel(transaction.PaymentMethod)},
+			{Label: "Sale", Value: fmt.Sprintf("#%d", sale.ID)},
+		},
+		PartyLabel: "Received from",
+		Party:      customerLines(sale.Customer),
+		Lines: []documents.Line{
+			{Description: line + vehicleDescription(sale.Vehicle), Quantity: 1, UnitPrice: transaction.Amount, Amount: transaction.Amount},
+		},
+		Totals: totals,
+		Code:   transaction.TransactionRef,
+	}, nil
+}
+
+func creditNoteContent(tx *gorm.DB, sale *models.Sale, refund *models.Transaction) (documents.Document, error) {
+	details := []documents.Field{{Label: "Reference", Value: refund.TransactionRef}}
+	if refund.RefundOfID != nil {
+		var original models.Transaction
+		if err := tx.First(&original, *refund.RefundOfID).Error; err != nil {
+			return documents.Document{}, notFoundOr(err, "Transaction not found")
+		}
+		details = append(details, documents.Field{Label: "Refund of", Value: original.TransactionRef})
+		receipt, err := findDocument(tx, models.DocumentTypeReceipt, "transaction_id = ?", original.ID)
+		if err != nil {
+			return documents.Document{}, err
+		}
+		if receipt != nil {
+			details = append(details, documents.Field{Label: "Original receipt", Value: receipt.Number})
+		}
+	}
+	details = append(details,
+		documents.Field{Label: "Reason", Value: strings.ReplaceAll(string(refund.ReasonCode), "_", " ")},
+		documents.Field{Label: "Refunded via", Value: paymentMethodLabel(refund.PaymentMethod)},
+		documents.Field{Label: "Sale", Value: fmt.Sprintf("#%d", sale.ID)},
+	)
+
+	// refunds are stored as negative amounts
+	amount := refund.Amount.Neg()
+	lines, totals := itemise(tax.Allocate(lineItems(sale.Lines), amount), amount)
+	if len(lines) > 0 {
+		lines[0].Description = "Refund for " + lines[0].Description
+	}
+	return documents.Document{
+		Title:      "CREDIT NOTE",
+		Details:    details,
+		PartyLabel: "Credited to",
+		Party:      customerLines(sale.Customer),
+		Lines:      lines,
+		Totals:     append(totals, documents.Total{Label: "Total credited", Amount: amount, Emphasis: true}),
+		Code:       refund.TransactionRef,
+	}, nil
+}
+
+// itemise lays out the line items of a deal: the vehicle, discount, add-ons
+// and fees as document lines, then their subtotal, the taxes on the price
+// before tax (DPP), the included fees and any trade-in allowance. The caller
+// adds the grand total.
+func itemise(items []models.LineItem, total money.Money) ([]documents.Line, []documents.Total) {
+	if len(items) == 0 {
+		// sales are always priced, but a document must still add up
+		items = []models.LineItem{{Type: models.LineTypeVehicle, Description: "Vehicle", Amount: total}}
+	}
+	lines := []documents.Line{}
+	subtotal := money.Zero(total.Currency)
+	var taxes, others []documents.Total
+	for _, item := range items {
+		switch {
+		case item.Type == models.LineTypeTax:
+			if len(taxes) == 0 {
+				taxes = append(taxes, documents.Total{Label: "Price before tax (DPP)", Amount: item.Base})
+			}
+			taxes = append(taxes, documents.Total{Label: taxLabel(item), Amount: item.Amount})
+		case item.Type == models.LineTypeTradeIn:
+			others = append(others, documents.Total{Label: item.Description, Amount: item.Amount})
+		case item.Inclusive:
+			others = append(others, documents.Total{Label: item.Description + " (incl.)", Amount: item.Amount})
+		default:
+			lines = append(lines, documents.Line{
+				Description: item.Description,
+				Quantity:    1,
+				UnitPrice:   item.Amount,
+				Amount:      item.Amount,
+			})
+			subtotal.Minor += item.Amount.Minor
+		}
+	}
+	if len(taxes) == 0 && len(others) == 0 {
+		return lines, nil
+	}
+	totals := append([]documents.Total{{Label: "Subtotal", Amount: subtotal}}, taxes...)
+	return lines, append(totals, others...)
+}
+
+// taxLabel names a tax line with its rate, e.g. "VAT (PPN) 11%", and says
+// whether it was included in the agreed price.
+func taxLabel(item models.LineItem) string {
+	label := fmt.Sprintf("%s %d", item.Description, item.Rate/100)
+	if item.Rate%100 != 0 {
+		label = fmt.Sprintf("%s %d.%02d", item.Description, item.Rate/100, item.Rate%100)
+	}
+	label += "%"
+	if item.Inclusive {
+		label += " (incl.)"
+	}
+	return label
+}
+
+func vehicleDescription(vehicle models.Vehicle) string {
+	description := fmt.Sprintf("%d %s %s", vehicle.Year, vehicle.Make, vehicle.Model)
+	if vehicle.VIN != "" {
+		description += " (VIN " + vehicle.VIN + ")"
+	}
+	return description
+}
+
+func customerLines(customer models.User) []string {
+	lines := []string{customer.Name}
+	for _, value := range []string{customer.Email, customer.Phone} {
+		if value != "" {
+			lines = append(lines, value)
+		}
+	}
+	return lines
+}
+
+func paymentMethodLabel(method models.PaymentMethod) string {
+	label := strings.ReplaceAll(string(method), "_", " ")
+	return strings.ToUpper(label[:1]) + label[1:]
+}
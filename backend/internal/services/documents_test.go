@@ -0,0 +1,79 @@
+package services_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/documents"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/services"
+)
+
+func TestReceiptPDFIsRenderedOnFirstDownload(t *testing.T) {
+	saved := documents.Current()
+	t.Cleanup(func() { documents.Configure(saved) })
+	issuedWith := saved
+	issuedWith.Letterhead = documents.Letterhead{Name: "Dealer As Issued"}
+	documents.Configure(issuedWith)
+
+	payment := createPayment(t, createSale(t, price), models.PaymentMethodCash, money.New(50_000_000_00, money.IDR))
+	if _, err := services.NewTransactionService().ProcessPayment(admin, payment.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// issuing the receipt stores its content, not a PDF
+	documentService := services.NewDocumentService()
+	receipt, err := documentService.Receipt(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(receipt.PDF) != 0 {
+		t.Fatalf("receipt %s was rendered when issued", receipt.Number)
+	}
+	var content documents.Document
+	if err := json.Unmarshal(receipt.Content, &content); err != nil {
+		t.Fatal(err)
+	}
+	if content.Letterhead == nil || content.Letterhead.Name != "Dealer As Issued" {
+		t.Errorf("content letterhead = %+v, want the one issued with", content.Letterhead)
+	}
+
+	documents.Configure(saved)
+	pdf, err := documentService.PDF(receipt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(pdf, []byte("%PDF")) {
+		t.Fatalf("PDF starts with %q", pdf[:min(len(pdf), 8)])
+	}
+
+	// the first print is stored and served again
+	var stored models.Document
+	if err := database.DB.First(&stored, receipt.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stored.PDF, pdf) {
+		t.Error("the rendered PDF was not stored")
+	}
+	again, err := documentService.PDF(&stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again, pdf) {
+		t.Error("a reprint differs from the first print")
+	}
+
+	// a request that rendered it concurrently serves the stored print
+	stale := *receipt
+	stale.PDF = nil
+	raced, err := documentService.PDF(&stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(raced, pdf) {
+		t.Error("a concurrent render replaced the stored print")
+	}
+}
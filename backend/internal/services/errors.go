@@ -0,0 +1,62 @@
+package services
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// Sentinel error kinds returned by the services. Handlers map them to HTTP
+// status codes; the accompanying *Error carries the user facing message.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrInvalidState = errors.New("invalid state")
+	ErrConflict     = errors.New("conflict")
+	ErrForbidden    = errors.New("forbidden")
+	ErrGateway      = errors.New("payment gateway error")
+)
+
+// Error is a business rule failure with a message safe to show to clients
+// and optional details, such as the ID of a conflicting record.
+type Error struct {
+	Kind    error
+	Message string
+	Details map[string]interface{}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+func notFound(message string) *Error {
+	return &Error{Kind: ErrNotFound, Message: message}
+}
+
+func invalidState(message string) *Error {
+	return &Error{Kind: ErrInvalidState, Message: message}
+}
+
+func conflict(message string, details map[string]interface{}) *Error {
+	return &Error{Kind: ErrConflict, Message: message, Details: details}
+}
+
+func forbidden(message string) *Error {
+	return &Error{Kind: ErrForbidden, Message: message}
+}
+
+func gatewayError(message string) *Error {
+	return &Error{Kind: ErrGateway, Message: message}
+}
+
+// notFoundOr converts gorm's record-not-found error into a not found error
+// with the given message and passes any other error through.
+func notFoundOr(err error, message string) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return notFound(message)
+	}
+	return err
+}
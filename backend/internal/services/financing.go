@@ -0,0 +1,565 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/financing"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/statemachine"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// FinancingService implements vehicle financing: a customer applies to have
+// part of a sale financed, lenders answer with offers, and the accepted
+// offer's financed amount is paid to the dealer as a financing transaction.
+// That transaction completes only when the lender's disbursement is
+// recorded.
+type FinancingService struct{}
+
+func NewFinancingService() *FinancingService {
+	return &FinancingService{}
+}
+
+type LenderInput struct {
+	Name         string
+	ContactName  string
+	ContactPhone string
+	ContactEmail string
+	IsActive     bool
+}
+
+type ApplyForFinancingInput struct {
+	SaleID uint
+	// ApplicantIncome is the applicant's monthly income
+	ApplicantIncome money.Money
+	DownPayment     money.Money
+	TenorMonths     int
+	// LenderID is the lender the customer asked for, if any
+	LenderID *uint
+	Notes    string
+}
+
+type FinancingOfferInput struct {
+	LenderID     uint
+	Method       models.AmortizationMethod
+	InterestRate int64
+	AdminFee     money.Money
+	// Principal and TenorMonths default to the application's
+	Principal   money.Money
+	TenorMonths int
+	LenderRef   string
+	ValidUntil  *time.Time
+	Notes       string
+}
+
+type DisbursementInput struct {
+	// Reference is the lender's reference for the transfer
+	Reference string
+	// Amount must match the financed amount when given
+	Amount      money.Money
+	DisbursedAt *time.Time
+}
+
+// CreateLender adds a lender.
+func (s *FinancingService) CreateLender(input LenderInput) (*models.Lender, error) {
+	var lender models.Lender
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		input.apply(&lender)
+		if err := checkLenderName(tx, &lender); err != nil {
+			return err
+		}
+		return tx.Create(&lender).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &lender, nil
+}
+
+// UpdateLender changes a lender's details. Deactivated lenders keep their
+// offers but cannot make new ones.
+func (s *FinancingService) UpdateLender(id uint, input LenderInput) (*models.Lender, error) {
+	var lender models.Lender
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := forUpdate(tx).First(&lender, id).Error; err != nil {
+			return notFoundOr(err, "Lender not found")
+		}
+		input.apply(&lender)
+		if err := checkLenderName(tx, &lender); err != nil {
+			return err
+		}
+		return tx.Save(&lender).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &lender, nil
+}
+
+// Apply records a financing application for an active sale. The lender
+// finances the sale price less the down payment. A sale may only have one
+// application in progress.
+func (s *FinancingService) Apply(actor Actor, input ApplyForFinancingInput) (*models.FinancingApplication, error) {
+	var application *models.FinancingApplication
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		sale, err := lockSale(tx, input.SaleID)
+		if err != nil {
+			return err
+		}
+		if !sale.Status.IsActive() {
+			return invalidState("Financing can only be arranged for pending or approved sales")
+		}
+		currency := sale.SalePrice.Currency
+		if input.DownPayment.Currency != currency || input.ApplicantIncome.Currency != currency {
+			return invalidState("Income and down payment must be in the sale currency " + string(currency))
+		}
+		principal, err := sale.SalePrice.Sub(input.DownPayment)
+		if err != nil {
+			return err
+		}
+		if !principal.IsPositive() {
+			return invalidState("The down payment covers the whole sale price; there is nothing to finance")
+		}
+		if input.LenderID != nil {
+			if _, err := activeLender(tx, *input.LenderID); err != nil {
+				return err
+			}
+		}
+
+		var open models.FinancingApplication
+		err = tx.Where("sale_id = ? AND status IN ?", sale.ID,
+			[]models.FinancingStatus{models.FinancingStatusSubmitted, models.FinancingStatusApproved}).
+			First(&open).Error
+		if err == nil {
+			return conflict("Sale already has a financing application in progress", map[string]interface{}{
+				"application_id": open.ID,
+			})
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		application = &models.FinancingApplication{
+			SaleID:          sale.ID,
+			CustomerID:      sale.CustomerID,
+			CreatedByID:     actor.UserID,
+			Status:          models.FinancingStatusSubmitted,
+			ApplicantIncome: input.ApplicantIncome,
+			DownPayment:     input.DownPayment,
+			Principal:       principal,
+			TenorMonths:     input.TenorMonths,
+			LenderID:        input.LenderID,
+			Notes:           input.Notes,
+		}
+		return tx.Create(application).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loadFinancingApplication(database.DB, application.ID)
+}
+
+// AddOffer records a lender's offer on a submitted application and works
+// out its repayment schedule. A lender may finance less than was applied
+// for, leaving the customer a larger down payment.
+func (s *FinancingService) AddOffer(actor Actor, id uint, input FinancingOfferInput) (*models.FinancingOffer, error) {
+	var offer *models.FinancingOffer
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		application, err := lockFinancingApplication(tx, id)
+		if err != nil {
+			return err
+		}
+		if application.Status != models.FinancingStatusSubmitted {
+			return invalidState("Offers can only be added to submitted applications")
+		}
+		if _, err := activeLender(tx, input.LenderID); err != nil {
+			return err
+		}
+
+		currency := application.Principal.Currency
+		principal := input.Principal
+		if principal.IsZero() {
+			principal = application.Principal
+		}
+		tenor := input.TenorMonths
+		if tenor == 0 {
+			tenor = application.TenorMonths
+		}
+		if principal.Currency != currency || input.AdminFee.Currency != currency {
+			return invalidState("Offers must be in the sale currency " + string(currency))
+		}
+		if principal.Minor > application.Principal.Minor {
+			return invalidState("An offer cannot finance more than was applied for")
+		}
+
+		schedule, err := amortize(financing.Terms{
+			Principal: principal,
+			Rate:      input.InterestRate,
+			Months:    tenor,
+			Method:    input.Method,
+		})
+		if err != nil {
+			return err
+		}
+		totalPayable, err := schedule.TotalPayment.Add(input.AdminFee)
+		if err != nil {
+			return err
+		}
+
+		offer = &models.FinancingOffer{
+			ApplicationID: application.ID,
+			LenderID:      input.LenderID,
+			Status:        models.OfferStatusOffered,
+			Method:        input.Method,
+			InterestRate:  input.InterestRate,
+			AdminFee:      input.AdminFee,
+			Principal:     principal,
+			TenorMonths:   tenor,
+			Installment:   schedule.Installment,
+			TotalInterest: schedule.TotalInterest,
+			TotalPayable:  totalPayable,
+			LenderRef:     input.LenderRef,
+			ValidUntil:    input.ValidUntil,
+			Notes:         input.Notes,
+		}
+		if application.ApplicantIncome.IsPositive() {
+			offer.InstallmentRatio = (schedule.Installment.Minor*10000 + application.ApplicantIncome.Minor/2) /
+				application.ApplicantIncome.Minor
+		}
+		for _, installment := range schedule.Installments {
+			offer.Installments = append(offer.Installments, models.FinancingInstallment{
+				Number:    installment.Number,
+				Payment:   installment.Payment,
+				Principal: installment.Principal,
+				Interest:  installment.Interest,
+				Balance:   installment.Balance,
+			})
+		}
+		if err := tx.Create(offer).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.FinancingApplication{}).Where("id = ?", application.ID).
+			Update("updated_at", time.Now()).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loadFinancingOffer(database.DB, offer.ID)
+}
+
+// AcceptOffer approves an application on one of its offers and declines
+// the others. The financed amount is opened as a pending financing payment
+// against the sale, which must be approved and have that much outstanding.
+func (s *FinancingService) AcceptOffer(actor Actor, id, offerID uint) (*models.FinancingApplication, error) {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		application, err := lockFinancingApplication(tx, id)
+		if err != nil {
+			return err
+		}
+		var offer models.FinancingOffer
+		if err := tx.Where("application_id = ?", application.ID).First(&offer, offerID).Error; err != nil {
+			return notFoundOr(err, "Offer not found")
+		}
+		if offer.Status != models.OfferStatusOffered {
+			return invalidState("Offer is no longer open")
+		}
+		if offer.ValidUntil != nil && offer.ValidUntil.Before(time.Now()) {
+			return invalidState("Offer has expired")
+		}
+		if err := FinancingStates.Fire(tx, actor, application, application.Status, models.FinancingStatusApproved); err != nil {
+			return err
+		}
+
+		sale, err := lockSale(tx, application.SaleID)
+		if err != nil {
+			return err
+		}
+		if sale.Status != models.SaleStatusApproved {
+			return invalidState("Sale must be approved before its financing is accepted")
+		}
+		balance, err := saleBalance(tx, sale)
+		if err != nil {
+			return err
+		}
+		available, err := balance.Outstanding.Sub(balance.Pending)
+		if err != nil {
+			return err
+		}
+		if offer.Principal.Minor > available.Minor {
+			return conflict("Financed amount exceeds the outstanding balance of the sale", map[string]interface{}{
+				"outstanding": available,
+			})
+		}
+
+		var lender models.Lender
+		if err := tx.Unscoped().First(&lender, offer.LenderID).Error; err != nil {
+			return notFoundOr(err, "Lender not found")
+		}
+		transaction := models.Transaction{
+			SaleID:         sale.ID,
+			Type:           models.TransactionTypePayment,
+			Amount:         offer.Principal,
+			PaymentMethod:  models.PaymentMethodFinancing,
+			Tendered:       money.Zero(offer.Principal.Currency),
+			Change:         money.Zero(offer.Principal.Currency),
+			Status:         models.TransactionStatusPending,
+			ProcessedByID:  actor.UserID,
+			TransactionRef: "TXN-" + uuid.New().String()[:8],
+			Notes:          "Financed by " + lender.Name,
+		}
+		if err := tx.Create(&transaction).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.FinancingOffer{}).Where("id = ?", offer.ID).
+			Update("status", models.OfferStatusAccepted).Error; err != nil {
+			return err
+		}
+		if err := declineOffers(tx, application.ID); err != nil {
+			return err
+		}
+		return updateVersioned(tx, &models.FinancingApplication{}, application.ID, application.Version, map[string]interface{}{
+			"status":            models.FinancingStatusApproved,
+			"accepted_offer_id": offer.ID,
+			"transaction_id":    transaction.ID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loadFinancingApplication(database.DB, id)
+}
+
+// Reject records that no lender will finance a submitted application.
+func (s *FinancingService) Reject(actor Actor, id uint, reason string) (*models.FinancingApplication, error) {
+	return s.close(actor, id, models.FinancingStatusRejected, reason)
+}
+
+// Cancel withdraws an application that has not been disbursed. The pending
+// financing payment of an approved application fails.
+func (s *FinancingService) Cancel(actor Actor, id uint, reason string) (*models.FinancingApplication, error) {
+	return s.close(actor, id, models.FinancingStatusCanceled, reason)
+}
+
+func (s *FinancingService) close(actor Actor, id uint, to models.FinancingStatus, reason string) (*models.FinancingApplication, error) {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		application, err := lockFinancingApplication(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := FinancingStates.Fire(tx, actor, application, application.Status, to); err != nil {
+			return err
+		}
+		if err := declineOffers(tx, application.ID); err != nil {
+			return err
+		}
+		return updateVersioned(tx, &models.FinancingApplication{}, application.ID, application.Version, map[string]interface{}{
+			"status":          to,
+			"decision_reason": reason,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loadFinancingApplication(database.DB, id)
+}
+
+// RecordDisbursement records that the lender has paid the financed amount,
+// which completes the application's financing payment and, if that settles
+// the sale, the sale. The repayment schedule falls due monthly from the
+// disbursement date.
+func (s *FinancingService) RecordDisbursement(actor Actor, id uint, input DisbursementInput) (*models.FinancingApplication, error) {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		application, err := lockFinancingApplication(tx, id)
+		if err != nil {
+			return err
+		}
+		if application.Status != models.FinancingStatusApproved || application.TransactionID == nil {
+			return invalidState("Only approved applications can be disbursed")
+		}
+		if err := FinancingStates.Fire(tx, actor, application, application.Status, models.FinancingStatusDisbursed); err != nil {
+			return err
+		}
+
+		disbursedAt := time.Now()
+		if input.DisbursedAt != nil {
+			disbursedAt = *input.DisbursedAt
+		}
+		if err := updateVersioned(tx, &models.FinancingApplication{}, application.ID, application.Version, map[string]interface{}{
+			"status":           models.FinancingStatusDisbursed,
+			"disbursed_at":     disbursedAt,
+			"disbursement_ref": input.Reference,
+		}); err != nil {
+			return err
+		}
+		if err := scheduleInstallments(tx, *application.AcceptedOfferID, disbursedAt); err != nil {
+			return err
+		}
+
+		transaction, err := lockTransaction(tx, *application.TransactionID)
+		if err != nil {
+			return err
+		}
+		if transaction.Status != models.TransactionStatusPending {
+			return invalidState("The financing payment is no longer pending")
+		}
+		if !input.Amount.IsZero() && input.Amount != transaction.Amount {
+			return conflict("Disbursed amount does not match the financed amount", map[string]interface{}{
+				"financed": transaction.Amount,
+			})
+		}
+		// the lender's disbursement is the payment; no further permission
+		// to process it is needed
+		if err := TransactionStates.Fire(tx, statemachine.System, transaction, transaction.Status, models.TransactionStatusCompleted); err != nil {
+			return err
+		}
+		transaction.Status = models.TransactionStatusCompleted
+		transaction.ProviderRef = input.Reference
+		if err := tx.Save(transaction).Error; err != nil {
+			return err
+		}
+		return issueTransactionDocuments(tx, transaction)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loadFinancingApplication(database.DB, id)
+}
+
+// Schedule works out a loan's repayments without recording anything.
+func (s *FinancingService) Schedule(terms financing.Terms) (financing.Schedule, error) {
+	return amortize(terms)
+}
+
+// checkDisbursed rejects completing a financing payment whose lender has
+// not disbursed it.
+func checkDisbursed(tx *gorm.DB, transaction *models.Transaction) error {
+	if transaction.PaymentMethod != models.PaymentMethodFinancing {
+		return nil
+	}
+	var disbursed int64
+	err := tx.Model(&models.FinancingApplication{}).
+		Where("transaction_id = ? AND status = ?", transaction.ID, models.FinancingStatusDisbursed).
+		Count(&disbursed).Error
+	if err != nil {
+		return err
+	}
+	if disbursed == 0 {
+		return invalidState("Financing payments complete when the lender's disbursement is recorded")
+	}
+	return nil
+}
+
+// failFinancingPayment fails the pending financing payment of an
+// application that will not be disbursed.
+func failFinancingPayment(tx *gorm.DB, application *models.FinancingApplication) error {
+	if application.TransactionID == nil {
+		return nil
+	}
+	transaction, err := lockTransaction(tx, *application.TransactionID)
+	if err != nil {
+		return err
+	}
+	if transaction.Status != models.TransactionStatusPending {
+		return nil
+	}
+	if err := TransactionStates.Fire(tx, statemachine.System, transaction, transaction.Status, models.TransactionStatusFailed); err != nil {
+		return err
+	}
+	transaction.Status = models.TransactionStatusFailed
+	transaction.ProviderMessage = "Financing application canceled"
+	return tx.Save(transaction).Error
+}
+
+func scheduleInstallments(tx *gorm.DB, offerID uint, start time.Time) error {
+	var installments []models.FinancingInstallment
+	if err := tx.Where("offer_id = ?", offerID).Find(&installments).Error; err != nil {
+		return err
+	}
+	for _, installment := range installments {
+		due := start.AddDate(0, installment.Number, 0)
+		if err := tx.Model(&models.FinancingInstallment{}).Where("id = ?", installment.ID).
+			Update("due_date", due).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func declineOffers(tx *gorm.DB, applicationID uint) error {
+	return tx.Model(&models.FinancingOffer{}).
+		Where("application_id = ? AND status = ?", applicationID, models.OfferStatusOffered).
+		Update("status", models.OfferStatusDeclined).Error
+}
+
+func amortize(terms financing.Terms) (financing.Schedule, error) {
+	schedule, err := financing.Amortize(terms)
+	if errors.Is(err, financing.ErrInvalidTerms) {
+		return schedule, invalidState("Loan needs a positive principal and tenor, a rate of zero or more and a flat or annuity method")
+	}
+	return schedule, err
+}
+
+func activeLender(tx *gorm.DB, id uint) (*models.Lender, error) {
+	var lender models.Lender
+	if err := tx.First(&lender, id).Error; err != nil {
+		return nil, notFoundOr(err, "Lender not found")
+	}
+	if !lender.IsActive {
+		return nil, invalidState("Lender " + lender.Name + " is not active")
+	}
+	return &lender, nil
+}
+
+func checkLenderName(tx *gorm.DB, lender *models.Lender) error {
+	var existing models.Lender
+	err := tx.Where("name = ? AND id <> ?", lender.Name, lender.ID).First(&existing).Error
+	if err == nil {
+		return conflict("A lender with this name already exists", map[string]interface{}{"lender_id": existing.ID})
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (input LenderInput) apply(lender *models.Lender) {
+	lender.Name = input.Name
+	lender.ContactName = input.ContactName
+	lender.ContactPhone = input.ContactPhone
+	lender.ContactEmail = input.ContactEmail
+	lender.IsActive = input.IsActive
+}
+
+func loadFinancingApplication(tx *gorm.DB, id uint) (*models.FinancingApplication, error) {
+	var application models.FinancingApplication
+	err := tx.
+		Preload("Sale").
+		Preload("Customer").
+		Preload("Lender").
+		Preload("Transaction").
+		Preload("Offers", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Offers.Lender").
+		First(&application, id).Error
+	if err != nil {
+		return nil, notFoundOr(err, "Financing application not found")
+	}
+	return &application, nil
+}
+
+func loadFinancingOffer(tx *gorm.DB, id uint) (*models.FinancingOffer, error) {
+	var offer models.FinancingOffer
+	err := tx.
+		Preload("Lender").
+		Preload("Installments", func(db *gorm.DB) *gorm.DB { return db.Order("number") }).
+		First(&offer, id).Error
+	if err != nil {
+		return nil, notFoundOr(err, "Offer not found")
+	}
+	return &offer, nil
+}
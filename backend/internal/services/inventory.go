@@ -0,0 +1,346 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/repricing"
+
+	"gorm.io/gorm"
+)
+
+// InventoryService reports how long vehicles have been in stock and manages
+// the rules that lower the price of vehicles that stay too long.
+type InventoryService struct{}
+
+func NewInventoryService() *InventoryService {
+	return &InventoryService{}
+}
+
+// AgingFilter narrows the aging report; empty fields match every vehicle.
+type AgingFilter struct {
+	Status   models.VehicleStatus
+	Make     string
+	Category string
+}
+
+type RepricingRuleInput struct {
+	Name        string
+	Make        string
+	Model       string
+	Category    string
+	EveryDays   int
+	DropRate    int64
+	FloorMarkup int64
+	IsActive    bool
+}
+
+// agingBuckets are the aging report's buckets in days in stock; the last
+// has no upper bound.
+var agingBuckets = []struct {
+	label    string
+	min, max int
+}{
+	{"0-30", 0, 30},
+	{"31-60", 31, 60},
+	{"61-90", 61, 90},
+	{"90+", 91, -1},
+}
+
+// Aging buckets the unsold vehicles by their days in stock at now, oldest
+// first. Stock values cover prices in the default currency only.
+func (s *InventoryService) Aging(now time.Time, filter AgingFilter) (*models.AgingReport, error) {
+	query := database.DB.Where("status <> ?", models.VehicleStatusSold)
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Make != "" {
+		query = query.Where("make ILIKE ?", filter.Make)
+	}
+	if filter.Category != "" {
+		query = query.Where("category = ?", filter.Category)
+	}
+	var vehicles []models.Vehicle
+	if err := query.Order("stocked_at, id").Find(&vehicles).Error; err != nil {
+		return nil, err
+	}
+
+	currency := money.DefaultCurrency()
+	report := &models.AgingReport{
+		AsOf:     now,
+		Currency: currency,
+		Buckets:  make([]models.AgingBucket, len(agingBuckets)),
+		Vehicles: make([]models.AgingVehicle, 0, len(vehicles)),
+	}
+	for i, bucket := range agingBuckets {
+		report.Buckets[i] = models.AgingBucket{
+			Label:      bucket.label,
+			MinDays:    bucket.min,
+			StockValue: money.Zero(currency),
+		}
+		if bucket.max >= 0 {
+			max := bucket.max
+			report.Buckets[i].MaxDays = &max
+		}
+	}
+
+	for _, vehicle := range vehicles {
+		days := vehicle.DaysInStock(now)
+		bucket := &report.Buckets[agingBucket(days)]
+		bucket.Vehicles++
+		if vehicle.Price.Currency == currency {
+			bucket.StockValue.Minor += vehicle.Price.Minor
+		}
+		report.Vehicles = append(report.Vehicles, models.AgingVehicle{
+			ID:          vehicle.ID,
+			Make:        vehicle.Make,
+			Model:       vehicle.Model,
+			Year:        vehicle.Year,
+			VIN:         vehicle.VIN,
+			Status:      vehicle.Status,
+			Price:       vehicle.Price,
+			StockedAt:   vehicle.StockedAt,
+			DaysInStock: days,
+			Bucket:      bucket.Label,
+		})
+	}
+	sort.SliceStable(report.Vehicles, func(i, j int) bool {
+		return report.Vehicles[i].DaysInStock > report.Vehicles[j].DaysInStock
+	})
+	return report, nil
+}
+
+// PriceHistory lists the price changes of a vehicle, latest first.
+func (s *InventoryService) PriceHistory(vehicleID uint) ([]models.VehiclePriceChange, error) {
+	var vehicle models.Vehicle
+	if err := database.DB.First(&vehicle, vehicleID).Error; err != nil {
+		return nil, notFoundOr(err, "Vehicle not found")
+	}
+	changes := []models.VehiclePriceChange{}
+	err := database.DB.Preload("Rule", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Preload("ChangedBy").
+		Where("vehicle_id = ?", vehicle.ID).
+		Order("created_at DESC, id DESC").
+		Find(&changes).Error
+	return changes, err
+}
+
+// CreateRule adds a repricing rule.
+func (s *InventoryService) CreateRule(input RepricingRuleInput) (*models.RepricingRule, error) {
+	var rule models.RepricingRule
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		input.apply(&rule)
+		if err := checkRepricingRule(tx, &rule); err != nil {
+			return err
+		}
+		return tx.Create(&rule).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// UpdateRule replaces a repricing rule. Prices it already lowered stay
+// lowered.
+func (s *InventoryService) UpdateRule(id uint, input RepricingRuleInput) (*models.RepricingRule, error) {
+	var rule models.RepricingRule
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := forUpdate(tx).First(&rule, id).Error; err != nil {
+			return notFoundOr(err, "Repricing rule not found")
+		}
+		input.apply(&rule)
+		if err := checkRepricingRule(tx, &rule); err != nil {
+			return err
+		}
+		return tx.Save(&rule).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// DeleteRule retires a repricing rule.
+func (s *InventoryService) DeleteRule(id uint) error {
+	result := database.DB.Delete(&models.RepricingRule{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return notFound("Repricing rule not found")
+	}
+	return nil
+}
+
+// RepriceVehicles applies the active repricing rules to the available
+// vehicles at now and returns how many were repriced. A vehicle is lowered
+// once for every EveryDays days it has been in stock, less the drops already
+// made since it was stocked, so a vehicle that missed runs catches up.
+// The floor is over the vehicle's total cost, reconditioning included.
+// Vehicles without a purchase cost are left alone, as their floor is
+// unknown.
+func RepriceVehicles(now time.Time) (int, error) {
+	var rules []models.RepricingRule
+	if err := database.DB.Where("is_active = ?", true).Order("id").Find(&rules).Error; err != nil {
+		return 0, err
+	}
+	if len(rules) == 0 {
+		return 0, nil
+	}
+
+	var ids []uint
+	err := database.DB.Model(&models.Vehicle{}).
+		Where("status = ? AND purchase_cost_minor > 0", models.VehicleStatusAvailable).
+		Order("id").
+		Pluck("id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+
+	repriced := 0
+	for _, id := range ids {
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			vehicle, err := lockVehicle(tx, id)
+			if err != nil {
+				return err
+			}
+			// reserved or sold since it was listed
+			if vehicle.Status != models.VehicleStatusAvailable {
+				return nil
+			}
+			rule := repricingRuleFor(rules, vehicle)
+			if rule == nil {
+				return nil
+			}
+			changed, err := repriceVehicle(tx, vehicle, rule, now)
+			if err != nil || !changed {
+				return err
+			}
+			repriced++
+			return nil
+		})
+		if err != nil {
+			return repriced, err
+		}
+	}
+	return repriced, nil
+}
+
+// repriceVehicle makes the drops rule has made due for a locked vehicle,
+// recording each in its price history, and reports whether the price
+// changed.
+func repriceVehicle(tx *gorm.DB, vehicle *models.Vehicle, rule *models.RepricingRule, now time.Time) (bool, error) {
+	var made int64
+	err := tx.Model(&models.VehiclePriceChange{}).
+		Where("vehicle_id = ? AND source = ? AND created_at >= ?", vehicle.ID, models.PriceChangeRepricing, vehicle.StockedAt).
+		Count(&made).Error
+	if err != nil {
+		return false, err
+	}
+
+	// the floor is over everything the vehicle cost, reconditioning
+	// included; a purchase cost in another currency leaves it unknown
+	if vehicle.PurchaseCost.Currency != vehicle.Price.Currency {
+		return false, nil
+	}
+	costs, err := vehicleCosts(tx, vehicle, vehicle.Price.Currency)
+	if err != nil {
+		return false, err
+	}
+
+	days := vehicle.DaysInStock(now)
+	price := vehicle.Price
+	for drop := int(made); drop < repricing.Due(*rule, days); drop++ {
+		lowered, ok, err := repricing.Drop(*rule, price, costs.Total)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			break
+		}
+		change := models.VehiclePriceChange{
+			CreatedAt:   now,
+			VehicleID:   vehicle.ID,
+			Source:      models.PriceChangeRepricing,
+			OldPrice:    price,
+			NewPrice:    lowered,
+			DaysInStock: days,
+			RuleID:      &rule.ID,
+		}
+		if err := tx.Create(&change).Error; err != nil {
+			return false, err
+		}
+		price = lowered
+	}
+	if price == vehicle.Price {
+		return false, nil
+	}
+
+	return true, updateVersioned(tx, &models.Vehicle{}, vehicle.ID, vehicle.Version, map[string]interface{}{
+		"price_minor":    price.Minor,
+		"price_currency": price.Currency,
+	})
+}
+
+// repricingRuleFor picks the most specific of rules that matches vehicle,
+// the earliest on a tie, or nil when none does.
+func repricingRuleFor(rules []models.RepricingRule, vehicle *models.Vehicle) *models.RepricingRule {
+	var best *models.RepricingRule
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.Matches(vehicle) {
+			continue
+		}
+		if best == nil || rule.Specificity() > best.Specificity() {
+			best = rule
+		}
+	}
+	return best
+}
+
+func agingBucket(days int) int {
+	for i, bucket := range agingBuckets {
+		if bucket.max < 0 || days <= bucket.max {
+			return i
+		}
+	}
+	return len(agingBuckets) - 1
+}
+
+// checkRepricingRule rejects an active rule with the same filters as
+// another active rule, as it would be unclear which applies.
+func checkRepricingRule(tx *gorm.DB, rule *models.RepricingRule) error {
+	if !rule.IsActive {
+		return nil
+	}
+	var other models.RepricingRule
+	err := tx.Where("is_active = ? AND id <> ?", true, rule.ID).
+		Where("LOWER(make) = LOWER(?) AND LOWER(model) = LOWER(?) AND category = ?", rule.Make, rule.Model, rule.Category).
+		First(&other).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return nil
+	case err != nil:
+		return err
+	}
+	return conflict("Another active rule applies to the same vehicles", map[string]interface{}{
+		"rule_id": other.ID,
+	})
+}
+
+func (input RepricingRuleInput) apply(rule *models.RepricingRule) {
+	rule.Name = input.Name
+	rule.Make = strings.TrimSpace(input.Make)
+	rule.Model = strings.TrimSpace(input.Model)
+	rule.Category = input.Category
+	rule.EveryDays = input.EveryDays
+	rule.DropRate = input.DropRate
+	rule.FloorMarkup = input.FloorMarkup
+	rule.IsActive = input.IsActive
+}
@@ -0,0 +1,73 @@
+package services_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/services"
+)
+
+// TestRepricingFloorCoversReconditioning checks that repricing stops at the
+// markup over everything a vehicle cost, not just its purchase cost.
+func TestRepricingFloorCoversReconditioning(t *testing.T) {
+	now := time.Now()
+	rule := models.RepricingRule{Name: "Aggressive", Make: "Floortest", EveryDays: 30, DropRate: 2000, FloorMarkup: 500, IsActive: true}
+	if err := database.DB.Create(&rule).Error; err != nil {
+		t.Fatal(err)
+	}
+	recorder := createUser(t, models.RoleSales)
+
+	tests := []struct {
+		name           string
+		reconditioning int64
+		want           int64
+	}{
+		// 20% off 110m is 88m, below 105% of the 90m purchase cost
+		{"purchase cost only", 0, 94_500_000_00},
+		// 105% of 90m plus 10m of reconditioning
+		{"reconditioned", 10_000_000_00, 105_000_000_00},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vehicle := models.Vehicle{
+				Make:         "Floortest",
+				Model:        "Avanza",
+				Year:         2022,
+				VIN:          fmt.Sprintf("FLOOR%d-%d", i, now.UnixNano()),
+				Price:        money.New(110_000_000_00, money.IDR),
+				PurchaseCost: money.New(90_000_000_00, money.IDR),
+				Status:       models.VehicleStatusAvailable,
+				AcquiredAt:   now.AddDate(0, 0, -50),
+				StockedAt:    now.AddDate(0, 0, -40),
+			}
+			if err := database.DB.Create(&vehicle).Error; err != nil {
+				t.Fatal(err)
+			}
+			if tt.reconditioning > 0 {
+				err := database.DB.Create(&models.ReconditioningExpense{
+					VehicleID:    vehicle.ID,
+					RecordedByID: recorder.ID,
+					Category:     "parts",
+					Description:  "Brakes",
+					Amount:       money.New(tt.reconditioning, money.IDR),
+					IncurredAt:   now.AddDate(0, 0, -45),
+				}).Error
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if _, err := services.RepriceVehicles(now); err != nil {
+				t.Fatal(err)
+			}
+			reload(t, &vehicle, vehicle.ID)
+			if vehicle.Price.Minor != tt.want {
+				t.Errorf("price = %s, want %s", vehicle.Price, money.New(tt.want, money.IDR))
+			}
+		})
+	}
+}
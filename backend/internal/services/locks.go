@@ -0,0 +1,102 @@
+package services
+
+import (
+	"time"
+
+	"vehicle-sales-backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Rows are always locked in the order quote -> financing application ->
+// trade-in -> transaction -> sale -> vehicle -> shift -> document sequence
+// so that concurrent flows touching the same records cannot deadlock.
+
+func forUpdate(tx *gorm.DB) *gorm.DB {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"})
+}
+
+func lockQuote(tx *gorm.DB, id uint) (*models.Quote, error) {
+	var quote models.Quote
+	if err := forUpdate(tx).First(&quote, id).Error; err != nil {
+		return nil, notFoundOr(err, "Quote not found")
+	}
+	return &quote, nil
+}
+
+func lockFinancingApplication(tx *gorm.DB, id uint) (*models.FinancingApplication, error) {
+	var application models.FinancingApplication
+	if err := forUpdate(tx).First(&application, id).Error; err != nil {
+		return nil, notFoundOr(err, "Financing application not found")
+	}
+	return &application, nil
+}
+
+func lockTradeIn(tx *gorm.DB, id uint) (*models.TradeIn, error) {
+	var tradeIn models.TradeIn
+	if err := forUpdate(tx).First(&tradeIn, id).Error; err != nil {
+		return nil, notFoundOr(err, "Trade-in not found")
+	}
+	return &tradeIn, nil
+}
+
+func lockTransaction(tx *gorm.DB, id uint) (*models.Transaction, error) {
+	var transaction models.Transaction
+	if err := forUpdate(tx).First(&transaction, id).Error; err != nil {
+		return nil, notFoundOr(err, "Transaction not found")
+	}
+	return &transaction, nil
+}
+
+func lockSale(tx *gorm.DB, id uint) (*models.Sale, error) {
+	var sale models.Sale
+	if err := forUpdate(tx).First(&sale, id).Error; err != nil {
+		return nil, notFoundOr(err, "Sale not found")
+	}
+	return &sale, nil
+}
+
+func lockVehicle(tx *gorm.DB, id uint) (*models.Vehicle, error) {
+	var vehicle models.Vehicle
+	if err := forUpdate(tx).First(&vehicle, id).Error; err != nil {
+		return nil, notFoundOr(err, "Vehicle not found")
+	}
+	return &vehicle, nil
+}
+
+func lockShift(tx *gorm.DB, id uint) (*models.Shift, error) {
+	var shift models.Shift
+	if err := forUpdate(tx).First(&shift, id).Error; err != nil {
+		return nil, notFoundOr(err, "Shift not found")
+	}
+	return &shift, nil
+}
+
+func setVehicleStatus(tx *gorm.DB, vehicleID uint, status models.VehicleStatus) error {
+	vehicle, err := lockVehicle(tx, vehicleID)
+	if err != nil {
+		return err
+	}
+	updates := map[string]interface{}{"status": status}
+	if models.RestartsStockAge(vehicle.Status, status) {
+		updates["stocked_at"] = time.Now()
+	}
+	return updateVersioned(tx, &models.Vehicle{}, vehicle.ID, vehicle.Version, updates)
+}
+
+// updateVersioned applies updates to the row with the given id only if it is
+// still at version, and increments the version. It reports a conflict when
+// another writer changed the row after it was read.
+func updateVersioned(tx *gorm.DB, model interface{}, id, version uint, updates map[string]interface{}) error {
+	updates["version"] = gorm.Expr("version + 1")
+
+	result := tx.Model(model).Where("id = ? AND version = ?", id, version).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return conflict("Record was modified by another request", nil)
+	}
+	return nil
+}
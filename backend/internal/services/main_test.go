@@ -0,0 +1,117 @@
+package services_test
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// The service tests run against a throwaway SQLite database, like the
+// handler tests.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "services")
+	if err != nil {
+		log.Fatal(err)
+	}
+	code := func() int {
+		defer os.RemoveAll(dir)
+
+		dsn := filepath.Join(dir, "test.db") + "?_pragma=busy_timeout(5000)"
+		database.DB, err = gorm.Open(sqlite.Open(dsn), &gorm.Config{
+			Logger:                                   logger.Discard,
+			DisableForeignKeyConstraintWhenMigrating: true,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = database.DB.AutoMigrate(
+			&models.User{}, &models.Role{}, &models.Permission{},
+			&models.Vehicle{}, &models.ReconditioningExpense{}, &models.VehiclePriceChange{}, &models.RepricingRule{},
+			&models.Sale{}, &models.SaleLine{}, &models.SaleApproval{}, &models.TaxRule{}, &models.TradeIn{},
+			&models.Transaction{}, &models.FinancingApplication{}, &models.FinancingOffer{},
+			&models.CommissionPlan{}, &models.CommissionTier{}, &models.CommissionMakeBonus{}, &models.CommissionEntry{},
+			&models.Shift{}, &models.ShiftCount{}, &models.Document{}, &models.DocumentSequence{},
+		)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return m.Run()
+	}()
+	os.Exit(code)
+}
+
+func createUser(t *testing.T, role models.UserRole) *models.User {
+	t.Helper()
+	name := fmt.Sprintf("%s-%d", role, time.Now().UnixNano())
+	user := models.User{Email: name + "@example.com", Password: "x", Name: name, Role: role, IsActive: true}
+	if err := database.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &user
+}
+
+// createSale creates an approved sale of a new vehicle at price.
+func createSale(t *testing.T, price money.Money) *models.Sale {
+	t.Helper()
+	vehicle := models.Vehicle{
+		Make:         "Toyota",
+		Model:        "Avanza",
+		Year:         2022,
+		VIN:          fmt.Sprintf("VIN%d", time.Now().UnixNano()),
+		Price:        price,
+		PurchaseCost: money.Zero(price.Currency),
+		Status:       models.VehicleStatusReserved,
+	}
+	if err := database.DB.Create(&vehicle).Error; err != nil {
+		t.Fatal(err)
+	}
+	sale := models.Sale{
+		VehicleID:     vehicle.ID,
+		CustomerID:    createUser(t, models.RoleCustomer).ID,
+		SalesPersonID: createUser(t, models.RoleSales).ID,
+		AgreedPrice:   price,
+		SalePrice:     price,
+		ListPrice:     price,
+		Discount:      money.Zero(price.Currency),
+		TradeIn:       money.Zero(price.Currency),
+		NetRevenue:    money.Zero(price.Currency),
+		VehicleCost:   money.Zero(price.Currency),
+		GrossProfit:   money.Zero(price.Currency),
+		Status:        models.SaleStatusApproved,
+	}
+	if err := database.DB.Create(&sale).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &sale
+}
+
+// createPayment creates a pending payment of amount against sale.
+func createPayment(t *testing.T, sale *models.Sale, method models.PaymentMethod, amount money.Money) *models.Transaction {
+	t.Helper()
+	transaction := models.Transaction{
+		SaleID:         sale.ID,
+		Type:           models.TransactionTypePayment,
+		Amount:         amount,
+		PaymentMethod:  method,
+		Tendered:       money.Zero(amount.Currency),
+		Change:         money.Zero(amount.Currency),
+		Status:         models.TransactionStatusPending,
+		ProcessedByID:  createUser(t, models.RoleCashier).ID,
+		TransactionRef: fmt.Sprintf("TXN-%d", time.Now().UnixNano()),
+	}
+	if err := database.DB.Create(&transaction).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &transaction
+}
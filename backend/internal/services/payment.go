@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/payments"
+	"vehicle-sales-backend/internal/statemachine"
+
+	"gorm.io/gorm"
+)
+
+// ProcessPayment completes a pending transaction. Payment methods with a
+// registered provider are charged through it first: a declined payment
+// fails, and one whose outcome is not known yet stays pending until the
+// provider's webhook arrives or the call is retried. Cash completes
+// immediately; financing payments only once the lender's disbursement is
+// recorded. If the payment settles the sale's balance the sale completes
+// and the vehicle is marked sold.
+func (s *TransactionService) ProcessPayment(actor Actor, id uint) (*models.Transaction, error) {
+	var transaction models.Transaction
+	if err := database.DB.First(&transaction, id).Error; err != nil {
+		return nil, notFoundOr(err, "Transaction not found")
+	}
+	if transaction.Type == models.TransactionTypeRefund {
+		return nil, invalidState("Refunds are paid out when they are issued")
+	}
+
+	provider, ok := payments.ForMethod(transaction.PaymentMethod)
+	if !ok {
+		return s.transition(actor, id, models.TransactionStatusPending, models.TransactionStatusCompleted,
+			"Transaction is not pending")
+	}
+
+	if transaction.Status != models.TransactionStatusPending {
+		return nil, invalidState("Transaction is not pending")
+	}
+	if err := TransactionStates.Check(actor, transaction.Status, models.TransactionStatusCompleted); err != nil {
+		return nil, err
+	}
+	// don't take money the sale can no longer accept
+	var sale models.Sale
+	if err := database.DB.First(&sale, transaction.SaleID).Error; err != nil {
+		return nil, notFoundOr(err, "Sale not found")
+	}
+	if _, err := checkPayable(database.DB, &sale, &transaction); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := payments.WithTimeout(context.Background())
+	defer cancel()
+	result, err := charge(ctx, provider, &transaction)
+	if errors.Is(err, context.DeadlineExceeded) {
+		result.Status = payments.StatusPending
+		result.Message = "Timed out waiting for the payment provider"
+	} else if err != nil {
+		return nil, gatewayError("Payment provider error: " + err.Error())
+	}
+
+	return s.applyResult(actor, id, provider.Name(), result)
+}
+
+// ResolvePayment applies a provider's webhook to the pending transaction it
+// refers to. Events for transactions that are no longer pending are ignored,
+// so redelivered webhooks are harmless. An authorization is captured, as
+// nothing else would ever capture it.
+func (s *TransactionService) ResolvePayment(provider string, event payments.Event) (*models.Transaction, error) {
+	var transaction models.Transaction
+	err := database.DB.
+		Where("((provider = ? AND provider_ref = ? AND provider_ref <> '') OR (transaction_ref = ? AND payment_method IN ?))",
+			provider, event.Reference, event.MerchantReference, providerMethods(provider)).
+		Where("type <> ?", models.TransactionTypeRefund).
+		First(&transaction).Error
+	if err != nil {
+		return nil, notFoundOr(err, "Transaction not found")
+	}
+
+	result := payments.Result{
+		Reference: event.Reference,
+		Status:    event.Status,
+		Message:   event.Message,
+	}
+	if result.Status == payments.StatusAuthorized && transaction.Status == models.TransactionStatusPending {
+		result, err = captureAuthorized(provider, &transaction, result)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s.applyResult(statemachine.System, transaction.ID, provider, result)
+}
+
+// captureAuthorized captures a payment the provider reported authorized. A
+// capture that times out leaves it authorized for a retry to pick up.
+func captureAuthorized(name string, transaction *models.Transaction, authorized payments.Result) (payments.Result, error) {
+	provider, ok := payments.ByName(name)
+	if !ok {
+		return authorized, gatewayError("Payment provider " + name + " is not configured")
+	}
+	if authorized.Reference != "" {
+		transaction.ProviderRef = authorized.Reference
+	}
+
+	ctx, cancel := payments.WithTimeout(context.Background())
+	defer cancel()
+	result, err := charge(ctx, provider, transaction)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return authorized, nil
+	}
+	if err != nil {
+		return authorized, gatewayError("Payment provider error: " + err.Error())
+	}
+	return result, nil
+}
+
+// charge authorizes and captures a payment. A transaction that already has a
+// provider reference is looked up rather than charged again. It returns the
+// last known result alongside any error.
+func charge(ctx context.Context, provider payments.Provider, transaction *models.Transaction) (payments.Result, error) {
+	var result payments.Result
+	var err error
+	if transaction.ProviderRef != "" {
+		result, err = provider.Status(ctx, transaction.ProviderRef)
+	} else {
+		result, err = provider.Authorize(ctx, payments.Request{
+			Reference: transaction.TransactionRef,
+			Amount:    transaction.Amount,
+			Method:    transaction.PaymentMethod,
+		})
+	}
+	if err != nil || result.Status != payments.StatusAuthorized {
+		return result, err
+	}
+
+	captured, err := provider.Capture(ctx, result.Reference, transaction.Amount)
+	if err != nil {
+		return result, err
+	}
+	if captured.Status.Failed() {
+		// release the hold rather than leave the customer's funds reserved
+		provider.Void(ctx, result.Reference)
+	}
+	if captured.Reference == "" {
+		captured.Reference = result.Reference
+	}
+	return captured, nil
+}
+
+// applyResult records a provider result on a pending transaction and moves
+// it to completed or failed once the outcome is final.
+func (s *TransactionService) applyResult(actor statemachine.Actor, id uint, provider string, result payments.Result) (*models.Transaction, error) {
+	var transaction *models.Transaction
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		transaction, err = lockTransaction(tx, id)
+		if err != nil {
+			return err
+		}
+		if transaction.Status != models.TransactionStatusPending {
+			// already resolved, e.g. by a webhook racing the original call
+			return nil
+		}
+
+		transaction.Provider = provider
+		if result.Reference != "" {
+			transaction.ProviderRef = result.Reference
+		}
+		transaction.ProviderStatus = string(result.Status)
+		transaction.ProviderMessage = result.Message
+
+		to := transaction.Status
+		switch {
+		case result.Status.Settled():
+			to = models.TransactionStatusCompleted
+		case result.Status.Failed():
+			to = models.TransactionStatusFailed
+		}
+		if err := TransactionStates.Fire(tx, actor, transaction, transaction.Status, to); err != nil {
+			return err
+		}
+		transaction.Status = to
+		if err := tx.Save(transaction).Error; err != nil {
+			return err
+		}
+		return issueTransactionDocuments(tx, transaction)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
+
+// providerMethods lists the payment methods routed to the named provider.
+func providerMethods(name string) []models.PaymentMethod {
+	methods := []models.PaymentMethod{}
+	for _, method := range models.PaymentMethods {
+		if provider, ok := payments.ForMethod(method); ok && provider.Name() == name {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
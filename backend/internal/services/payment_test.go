@@ -0,0 +1,168 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/payments"
+	"vehicle-sales-backend/internal/services"
+)
+
+var (
+	price = money.New(200_000_000_00, money.IDR)
+	admin = services.Actor{UserID: 1, Role: models.RoleAdmin}
+)
+
+// useFake routes card payments to a new fake provider in mode.
+func useFake(t *testing.T, mode payments.FakeMode) *payments.Fake {
+	t.Helper()
+	fake, err := payments.NewFake(payments.FakeConfig{Mode: mode, Delay: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payments.Register(models.PaymentMethodCard, fake)
+	return fake
+}
+
+func reload(t *testing.T, model interface{}, id uint) {
+	t.Helper()
+	if err := database.DB.First(model, id).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProcessPayment(t *testing.T) {
+	payments.SetTimeout(50 * time.Millisecond)
+	defer payments.SetTimeout(15 * time.Second)
+
+	tests := []struct {
+		name       string
+		mode       payments.FakeMode
+		method     models.PaymentMethod
+		amount     money.Money
+		want       models.TransactionStatus
+		provider   payments.Status
+		saleStatus models.SaleStatus
+	}{
+		{"captured", payments.FakeSucceed, models.PaymentMethodCard, money.New(50_000_000_00, money.IDR),
+			models.TransactionStatusCompleted, payments.StatusCaptured, models.SaleStatusApproved},
+		{"captured in full completes the sale", payments.FakeSucceed, models.PaymentMethodCard, price,
+			models.TransactionStatusCompleted, payments.StatusCaptured, models.SaleStatusCompleted},
+		{"declined", payments.FakeDecline, models.PaymentMethodCard, price,
+			models.TransactionStatusFailed, payments.StatusDeclined, models.SaleStatusApproved},
+		{"timed out", payments.FakeTimeout, models.PaymentMethodCard, price,
+			models.TransactionStatusPending, payments.StatusPending, models.SaleStatusApproved},
+		{"awaiting the webhook", payments.FakeAsync, models.PaymentMethodCard, price,
+			models.TransactionStatusPending, payments.StatusPending, models.SaleStatusApproved},
+		{"cash", payments.FakeDecline, models.PaymentMethodCash, money.New(50_000_000_00, money.IDR),
+			models.TransactionStatusCompleted, "", models.SaleStatusApproved},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFake(t, tt.mode)
+			sale := createSale(t, price)
+			payment := createPayment(t, sale, tt.method, tt.amount)
+
+			transaction, err := services.NewTransactionService().ProcessPayment(admin, payment.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if transaction.Status != tt.want || payments.Status(transaction.ProviderStatus) != tt.provider {
+				t.Errorf("transaction is %s (provider %q), want %s (provider %q)",
+					transaction.Status, transaction.ProviderStatus, tt.want, tt.provider)
+			}
+			reload(t, sale, sale.ID)
+			if sale.Status != tt.saleStatus {
+				t.Errorf("sale is %s, want %s", sale.Status, tt.saleStatus)
+			}
+
+			// processing again is rejected once the outcome is final
+			_, err = services.NewTransactionService().ProcessPayment(admin, payment.ID)
+			if tt.want != models.TransactionStatusPending && err == nil {
+				t.Error("processed a payment that is no longer pending")
+			}
+		})
+	}
+}
+
+func TestResolvePayment(t *testing.T) {
+	tests := []struct {
+		name   string
+		status payments.Status
+		want   models.TransactionStatus
+	}{
+		{"captured", payments.StatusCaptured, models.TransactionStatusCompleted},
+		// a hold nobody else would capture
+		{"authorized", payments.StatusAuthorized, models.TransactionStatusCompleted},
+		{"declined", payments.StatusDeclined, models.TransactionStatusFailed},
+		{"voided", payments.StatusVoided, models.TransactionStatusFailed},
+		// refunded before it was seen captured: nothing was paid
+		{"refunded", payments.StatusRefunded, models.TransactionStatusFailed},
+		{"still pending", payments.StatusPending, models.TransactionStatusPending},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFake(t, payments.FakeSucceed)
+			sale := createSale(t, price)
+			payment := createPayment(t, sale, models.PaymentMethodCard, price)
+			// the authorization's answer was lost, so only the webhook knows
+			// the provider's reference
+			authorized, err := fake.Authorize(context.Background(), payments.Request{
+				Reference: payment.TransactionRef,
+				Amount:    payment.Amount,
+				Method:    payment.PaymentMethod,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			event := payments.Event{
+				ID:                "evt_1",
+				Reference:         authorized.Reference,
+				MerchantReference: payment.TransactionRef,
+				Status:            tt.status,
+			}
+			transaction, err := services.NewTransactionService().ResolvePayment("fake", event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if transaction.Status != tt.want || transaction.ProviderRef != authorized.Reference {
+				t.Errorf("transaction is %s with reference %q, want %s with %q",
+					transaction.Status, transaction.ProviderRef, tt.want, authorized.Reference)
+			}
+			if tt.status == payments.StatusAuthorized {
+				at, _ := fake.Status(context.Background(), authorized.Reference)
+				if at.Status != payments.StatusCaptured {
+					t.Errorf("payment is %s at the provider, want captured", at.Status)
+				}
+			}
+
+			// a redelivered webhook changes nothing
+			event.Status = payments.StatusDeclined
+			again, err := services.NewTransactionService().ResolvePayment("fake", event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want != models.TransactionStatusPending && again.Status != tt.want {
+				t.Errorf("redelivery moved the transaction to %s", again.Status)
+			}
+		})
+	}
+}
+
+func TestResolvePaymentOfUnknownTransaction(t *testing.T) {
+	useFake(t, payments.FakeSucceed)
+	_, err := services.NewTransactionService().ResolvePayment("fake", payments.Event{
+		Reference:         "fake_unknown",
+		MerchantReference: "TXN-unknown",
+		Status:            payments.StatusCaptured,
+	})
+	if !errors.Is(err, services.ErrNotFound) {
+		t.Errorf("err = %v, want not found", err)
+	}
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+
+	"gorm.io/gorm"
+)
+
+// recordGrossProfit fixes the revenue, vehicle cost and gross profit of a
+// sale as it completes. Costs recorded later leave it alone; refunds that
+// leave the sale completed reduce it (see reduceGrossProfit).
+func recordGrossProfit(tx *gorm.DB, sale *models.Sale) error {
+	revenue, err := netRevenue(tx, sale)
+	if err != nil {
+		return err
+	}
+	var vehicle models.Vehicle
+	if err := tx.Unscoped().First(&vehicle, sale.VehicleID).Error; err != nil {
+		return notFoundOr(err, "Vehicle not found")
+	}
+	costs, err := vehicleCosts(tx, &vehicle, revenue.Currency)
+	if err != nil {
+		return err
+	}
+	profit, err := revenue.Sub(costs.Total)
+	if err != nil {
+		return err
+	}
+
+	sale.NetRevenue, sale.VehicleCost, sale.GrossProfit = revenue, costs.Total, profit
+	return tx.Model(&models.Sale{}).Where("id = ?", sale.ID).Updates(map[string]interface{}{
+		"net_revenue_minor":     revenue.Minor,
+		"net_revenue_currency":  revenue.Currency,
+		"vehicle_cost_minor":    costs.Total.Minor,
+		"vehicle_cost_currency": costs.Total.Currency,
+		"gross_profit_minor":    profit.Minor,
+		"gross_profit_currency": profit.Currency,
+	}).Error
+}
+
+// reduceGrossProfit takes the share of the price of a completed sale that
+// refund returned off its net revenue and gross profit, as its commission is
+// reversed. The vehicle cost stays as it was.
+func reduceGrossProfit(tx *gorm.DB, sale *models.Sale, refund *models.Transaction) error {
+	if sale.Status != models.SaleStatusCompleted || !sale.SalePrice.IsPositive() {
+		return nil
+	}
+	revenue, err := netRevenue(tx, sale)
+	if err != nil {
+		return err
+	}
+	share := money.Scale(revenue.Minor, refund.Amount.Neg().Minor, sale.SalePrice.Minor)
+	if share > sale.NetRevenue.Minor {
+		share = sale.NetRevenue.Minor
+	}
+	if share <= 0 {
+		return nil
+	}
+
+	sale.NetRevenue.Minor -= share
+	sale.GrossProfit.Minor -= share
+	return tx.Model(&models.Sale{}).Where("id = ?", sale.ID).Updates(map[string]interface{}{
+		"net_revenue_minor":  sale.NetRevenue.Minor,
+		"gross_profit_minor": sale.GrossProfit.Minor,
+	}).Error
+}
+
+// netRevenue is what the dealership keeps of a sale: its taxable lines, the
+// vehicle, discount and add-ons, less the taxes and fees included in them.
+// Trade-ins are a purchase by the dealership and do not reduce it.
+func netRevenue(tx *gorm.DB, sale *models.Sale) (money.Money, error) {
+	var lines []models.SaleLine
+	if err := tx.Where("sale_id = ?", sale.ID).Find(&lines).Error; err != nil {
+		return money.Money{}, err
+	}
+
+	revenue := money.Zero(sale.AgreedPrice.Currency)
+	for _, line := range lines {
+		switch {
+		case line.Type.IsTaxable():
+			revenue.Minor += line.Amount.Minor
+		case line.Inclusive:
+			revenue.Minor -= line.Amount.Minor
+		}
+	}
+	return revenue, nil
+}
+
+// vehicleCosts totals what a vehicle has cost in currency since it last
+// came into stock. Costs in other currencies are left out.
+func vehicleCosts(tx *gorm.DB, vehicle *models.Vehicle, currency money.Currency) (*models.VehicleCosts, error) {
+	costs := &models.VehicleCosts{
+		VehicleID:         vehicle.ID,
+		AcquiredAt:        vehicle.AcquiredAt,
+		AcquisitionSource: vehicle.AcquisitionSource,
+		PurchaseCost:      money.Zero(currency),
+		Reconditioning:    money.Zero(currency),
+		Total:             money.Zero(currency),
+		Expenses:          []models.ReconditioningExpense{},
+	}
+	if vehicle.PurchaseCost.Currency == currency {
+		costs.PurchaseCost = vehicle.PurchaseCost
+	}
+
+	err := tx.Preload("RecordedBy").
+		Where("vehicle_id = ? AND created_at >= ?", vehicle.ID, vehicle.AcquiredAt).
+		Order("incurred_at, id").
+		Find(&costs.Expenses).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, expense := range costs.Expenses {
+		if expense.Amount.Currency == currency {
+			costs.Reconditioning.Minor += expense.Amount.Minor
+		}
+	}
+	costs.Total.Minor = costs.PurchaseCost.Minor + costs.Reconditioning.Minor
+	return costs, nil
+}
@@ -0,0 +1,564 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/documents"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/statemachine"
+
+	"gorm.io/gorm"
+)
+
+// quotePrefix starts every quote number; the branch code follows, e.g.
+// QUO-HQ-000042.
+const quotePrefix = "QUO"
+
+// defaultQuoteValidity is how long a quote stays open when no validity date
+// is given.
+const defaultQuoteValidity = 14 * 24 * time.Hour
+
+// QuoteService implements quotes: priced offers of a vehicle to a customer
+// that are revised while the deal is negotiated, shared with the customer
+// for acceptance and converted into a sale. A quote does not reserve its
+// vehicle; the sale it converts into does.
+type QuoteService struct{}
+
+func NewQuoteService() *QuoteService {
+	return &QuoteService{}
+}
+
+// QuoteTerms are the terms offered in one revision of a quote.
+type QuoteTerms struct {
+	// ListPrice defaults to the vehicle's advertised price
+	ListPrice        money.Money
+	Discount         money.Money
+	TradeInAllowance money.Money
+	AddOns           []AddOn
+	// ValidUntil defaults to 14 days from now
+	ValidUntil *time.Time
+	Notes      string
+}
+
+type CreateQuoteInput struct {
+	VehicleID     uint
+	CustomerID    uint
+	SalesPersonID uint
+	Terms         QuoteTerms
+}
+
+// CreateQuote prepares a draft quote at its first revision.
+func (s *QuoteService) CreateQuote(actor Actor, input CreateQuoteInput) (*models.Quote, error) {
+	var quote *models.Quote
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var vehicle models.Vehicle
+		if err := tx.First(&vehicle, input.VehicleID).Error; err != nil {
+			return notFoundOr(err, "Vehicle not found")
+		}
+		if vehicle.Status == models.VehicleStatusSold {
+			return invalidState("Vehicle has already been sold")
+		}
+		if err := checkSaleParties(tx, input.CustomerID, input.SalesPersonID); err != nil {
+			return err
+		}
+
+		revision, err := quoteRevision(tx, &vehicle, input.Terms)
+		if err != nil {
+			return err
+		}
+
+		prefix := quotePrefix + "-" + documents.Current().Branch
+		sequence, err := nextDocumentSequence(tx, prefix)
+		if err != nil {
+			return err
+		}
+		quote = &models.Quote{
+			Number:        fmt.Sprintf("%s-%06d", prefix, sequence),
+			VehicleID:     vehicle.ID,
+			CustomerID:    input.CustomerID,
+			SalesPersonID: input.SalesPersonID,
+			Status:        models.QuoteStatusDraft,
+			Revision:      1,
+			ValidUntil:    revision.ValidUntil,
+			Total:         revision.Total,
+		}
+		if err := tx.Create(quote).Error; err != nil {
+			return err
+		}
+
+		revision.QuoteID = quote.ID
+		revision.Revision = 1
+		revision.CreatedByID = actor.UserID
+		return tx.Create(revision).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loadQuote(database.DB, quote.ID)
+}
+
+// ReviseQuote offers new terms as the next revision of a quote. A sent,
+// accepted, declined or expired quote becomes a draft again and has to be
+// sent and accepted anew.
+func (s *QuoteService) ReviseQuote(actor Actor, id uint, terms QuoteTerms) (*models.Quote, error) {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		quote, err := lockQuote(tx, id)
+		if err != nil {
+			return err
+		}
+		if quote.Status == models.QuoteStatusConverted {
+			return invalidState("A converted quote cannot be revised")
+		}
+		if quote.Status != models.QuoteStatusDraft {
+			if err := QuoteStates.Fire(tx, actor, quote, quote.Status, models.QuoteStatusDraft); err != nil {
+				return err
+			}
+		}
+
+		var vehicle models.Vehicle
+		if err := tx.First(&vehicle, quote.VehicleID).Error; err != nil {
+			return notFoundOr(err, "Vehicle not found")
+		}
+		revision, err := quoteRevision(tx, &vehicle, terms)
+		if err != nil {
+			return err
+		}
+		revision.QuoteID = quote.ID
+		revision.Revision = quote.Revision + 1
+		revision.CreatedByID = actor.UserID
+		if err := tx.Create(revision).Error; err != nil {
+			return err
+		}
+
+		return updateVersioned(tx, &models.Quote{}, quote.ID, quote.Version, map[string]interface{}{
+			"status":            models.QuoteStatusDraft,
+			"revision":          revision.Revision,
+			"valid_until":       revision.ValidUntil,
+			"total_minor":       revision.Total.Minor,
+			"total_currency":    revision.Total.Currency,
+			"accepted_at":       nil,
+			"accepted_revision": nil,
+			"accepted_by_id":    nil,
+			"declined_at":       nil,
+			"decline_reason":    "",
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loadQuote(database.DB, id)
+}
+
+// SendQuote offers the latest revision of a draft quote to the customer and
+// returns the token of the link through which they can view and answer it.
+// The link stays the same across revisions.
+func (s *QuoteService) SendQuote(actor Actor, id uint) (*models.Quote, string, error) {
+	var token string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		quote, err := lockQuote(tx, id)
+		if err != nil {
+			return err
+		}
+		if quote.Status != models.QuoteStatusDraft {
+			return invalidState("Only draft quotes can be sent")
+		}
+		if !quote.ValidUntil.After(time.Now()) {
+			return invalidState("The quote is past its validity date; revise it first")
+		}
+		if err := QuoteStates.Fire(tx, actor, quote, quote.Status, models.QuoteStatusSent); err != nil {
+			return err
+		}
+
+		if quote.ShareToken != nil {
+			token = *quote.ShareToken
+		} else if token, err = shareToken(); err != nil {
+			return err
+		}
+		return updateVersioned(tx, &models.Quote{}, quote.ID, quote.Version, map[string]interface{}{
+			"status":      models.QuoteStatusSent,
+			"share_token": token,
+			"sent_at":     time.Now(),
+		})
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	quote, err := loadQuote(database.DB, id)
+	return quote, token, err
+}
+
+// AcceptQuote records that the customer accepted the given revision of a
+// quote. It fails with a conflict if the quote has been revised since, so a
+// customer never accepts terms they have not seen.
+func (s *QuoteService) AcceptQuote(actor Actor, id uint, revision int) (*models.Quote, error) {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		quote, err := lockQuote(tx, id)
+		if err != nil {
+			return err
+		}
+		acceptedBy := actor.UserID
+		return acceptQuote(tx, actor, quote, revision, &acceptedBy)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loadQuote(database.DB, id)
+}
+
+// AcceptSharedQuote is AcceptQuote for a customer answering through the
+// quote's shared link. Only sent quotes can be answered this way.
+func (s *QuoteService) AcceptSharedQuote(token string, revision int) (*models.Quote, error) {
+	var id uint
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		quote, err := lockSharedQuote(tx, token)
+		if err != nil {
+			return err
+		}
+		id = quote.ID
+		return acceptQuote(tx, statemachine.System, quote, revision, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return latestRevision(database.DB, id)
+}
+
+// DeclineQuote records that the customer turned a quote down.
+func (s *QuoteService) DeclineQuote(actor Actor, id uint, reason string) (*models.Quote, error) {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		quote, err := lockQuote(tx, id)
+		if err != nil {
+			return err
+		}
+		return declineQuote(tx, actor, quote, reason)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loadQuote(database.DB, id)
+}
+
+// DeclineSharedQuote is DeclineQuote through the quote's shared link.
+func (s *QuoteService) DeclineSharedQuote(token, reason string) (*models.Quote, error) {
+	var id uint
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		quote, err := lockSharedQuote(tx, token)
+		if err != nil {
+			return err
+		}
+		id = quote.ID
+		return declineQuote(tx, statemachine.System, quote, reason)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return latestRevision(database.DB, id)
+}
+
+// ConvertQuote turns an accepted quote into a pending sale carrying the line
+// items of the accepted revision, and reserves the vehicle. It fails with a
+// conflict naming the existing sale if the vehicle is already reserved.
+func (s *QuoteService) ConvertQuote(actor Actor, id uint) (*models.Sale, error) {
+	var sale *models.Sale
+	var vehicleID uint
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		quote, err := lockQuote(tx, id)
+		if err != nil {
+			return err
+		}
+		vehicleID = quote.VehicleID
+		if quote.Status != models.QuoteStatusAccepted || quote.AcceptedRevision == nil {
+			return invalidState("Only accepted quotes can be converted into a sale")
+		}
+		if err := QuoteStates.Fire(tx, actor, quote, quote.Status, models.QuoteStatusConverted); err != nil {
+			return err
+		}
+
+		var revision models.QuoteRevision
+		err = tx.Preload("Lines", linesInOrder).
+			Where("quote_id = ? AND revision = ?", quote.ID, *quote.AcceptedRevision).
+			First(&revision).Error
+		if err != nil {
+			return notFoundOr(err, "Quote revision not found")
+		}
+
+		input := CreateSaleInput{
+			VehicleID:     quote.VehicleID,
+			CustomerID:    quote.CustomerID,
+			SalesPersonID: quote.SalesPersonID,
+			Notes:         revision.Notes,
+		}
+		sale, err = openSale(tx, actor, input, &quote.ID, func(*models.Vehicle) (models.Pricing, error) {
+			return revisionPricing(&revision), nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return updateVersioned(tx, &models.Quote{}, quote.ID, quote.Version, map[string]interface{}{
+			"status":  models.QuoteStatusConverted,
+			"sale_id": sale.ID,
+		})
+	})
+	if err != nil {
+		return nil, activeSaleViolation(database.DB, err, vehicleID, 0)
+	}
+	return sale, nil
+}
+
+// SharedQuote returns the quote behind a shared link with its latest
+// revision. A quote being revised is not shown until it is sent again.
+func (s *QuoteService) SharedQuote(token string) (*models.Quote, error) {
+	var quote models.Quote
+	if err := database.DB.Where("share_token = ?", token).First(&quote).Error; err != nil {
+		return nil, notFoundOr(err, "Quote not found")
+	}
+	if quote.Status == models.QuoteStatusDraft {
+		return nil, invalidState("The quote is being revised")
+	}
+	return latestRevision(database.DB, quote.ID)
+}
+
+// QuotePDF renders the latest revision of a quote as a quotation. Quotes
+// change until accepted, so the PDF is rendered on every request rather
+// than stored.
+func (s *QuoteService) QuotePDF(id uint) ([]byte, error) {
+	quote, err := latestRevision(database.DB, id)
+	if err != nil {
+		return nil, err
+	}
+	revision := quote.Revisions[0]
+
+	lines, totals := itemise(quoteLineItems(revision.Lines), revision.Total)
+	totals = append(totals, documents.Total{Label: "Total", Amount: revision.Total, Emphasis: true})
+	notes := []string{
+		fmt.Sprintf("This quotation is valid until %s. It does not reserve the vehicle.", revision.ValidUntil.Format("2 January 2006")),
+	}
+	if revision.Notes != "" {
+		notes = append(notes, revision.Notes)
+	}
+
+	content := documents.Document{
+		Title:    "QUOTATION",
+		Number:   quote.Number,
+		IssuedAt: revision.CreatedAt,
+		Details: []documents.Field{
+			{Label: "Revision", Value: fmt.Sprintf("%d", revision.Revision)},
+			{Label: "Valid until", Value: revision.ValidUntil.Format("2006-01-02")},
+			{Label: "Sales person", Value: quote.SalesPerson.Name},
+		},
+		PartyLabel: "Prepared for",
+		Party:      customerLines(quote.Customer),
+		Lines:      lines,
+		Totals:     totals,
+		Notes:      notes,
+	}
+	return documents.RenderPDF(documents.Current().Letterhead, content)
+}
+
+// ExpireQuotes expires the draft and sent quotes whose validity ended
+// before now and returns how many were expired.
+func ExpireQuotes(now time.Time) (int, error) {
+	var ids []uint
+	err := database.DB.Model(&models.Quote{}).
+		Where("status IN ? AND valid_until < ?", []models.QuoteStatus{models.QuoteStatusDraft, models.QuoteStatusSent}, now).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, id := range ids {
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			quote, err := lockQuote(tx, id)
+			if err != nil {
+				return err
+			}
+			// revised or answered since it was listed
+			if !quote.Status.IsOpen() || !quote.ValidUntil.Before(now) {
+				return nil
+			}
+			if err := QuoteStates.Fire(tx, statemachine.System, quote, quote.Status, models.QuoteStatusExpired); err != nil {
+				return err
+			}
+			expired++
+			return updateVersioned(tx, &models.Quote{}, quote.ID, quote.Version, map[string]interface{}{
+				"status": models.QuoteStatusExpired,
+			})
+		})
+		if err != nil {
+			return expired, err
+		}
+	}
+	return expired, nil
+}
+
+func acceptQuote(tx *gorm.DB, actor statemachine.Actor, quote *models.Quote, revision int, acceptedBy *uint) error {
+	if !quote.Status.IsOpen() {
+		return invalidState("Only open quotes can be accepted")
+	}
+	if revision != quote.Revision {
+		return conflict("The quote has been revised; review the current revision", map[string]interface{}{
+			"current_revision": quote.Revision,
+		})
+	}
+	now := time.Now()
+	if !quote.ValidUntil.After(now) {
+		return invalidState("The quote has expired")
+	}
+	if err := QuoteStates.Fire(tx, actor, quote, quote.Status, models.QuoteStatusAccepted); err != nil {
+		return err
+	}
+	return updateVersioned(tx, &models.Quote{}, quote.ID, quote.Version, map[string]interface{}{
+		"status":            models.QuoteStatusAccepted,
+		"accepted_at":       now,
+		"accepted_revision": revision,
+		"accepted_by_id":    acceptedBy,
+	})
+}
+
+func declineQuote(tx *gorm.DB, actor statemachine.Actor, quote *models.Quote, reason string) error {
+	if !quote.Status.IsOpen() {
+		return invalidState("Only open quotes can be declined")
+	}
+	if err := QuoteStates.Fire(tx, actor, quote, quote.Status, models.QuoteStatusDeclined); err != nil {
+		return err
+	}
+	return updateVersioned(tx, &models.Quote{}, quote.ID, quote.Version, map[string]interface{}{
+		"status":         models.QuoteStatusDeclined,
+		"declined_at":    time.Now(),
+		"decline_reason": reason,
+	})
+}
+
+// lockSharedQuote locks the quote behind a shared link. Customers answer
+// the revision they were sent, so drafts cannot be answered.
+func lockSharedQuote(tx *gorm.DB, token string) (*models.Quote, error) {
+	var quote models.Quote
+	if err := forUpdate(tx).Where("share_token = ?", token).First(&quote).Error; err != nil {
+		return nil, notFoundOr(err, "Quote not found")
+	}
+	if quote.Status == models.QuoteStatusDraft {
+		return nil, invalidState("The quote is being revised")
+	}
+	return &quote, nil
+}
+
+// quoteRevision prices terms for a vehicle into an unsaved revision with its
+// line items.
+func quoteRevision(tx *gorm.DB, vehicle *models.Vehicle, terms QuoteTerms) (*models.QuoteRevision, error) {
+	listPrice := terms.ListPrice
+	if !listPrice.IsPositive() {
+		listPrice = vehicle.Price
+	}
+	currency := listPrice.Currency
+	discount := orZero(terms.Discount, currency)
+	tradeIn := orZero(terms.TradeInAllowance, currency)
+	if discount.Minor >= listPrice.Minor {
+		return nil, invalidState("Discount must be less than the list price")
+	}
+
+	now := time.Now()
+	validUntil := now.Add(defaultQuoteValidity)
+	if terms.ValidUntil != nil {
+		validUntil = *terms.ValidUntil
+	}
+	if !validUntil.After(now) {
+		return nil, invalidState("valid_until must be in the future")
+	}
+
+	pricing, err := priceDeal(tx, vehicle, dealTerms{
+		Price:    listPrice,
+		Discount: discount,
+		AddOns:   terms.AddOns,
+		TradeIn:  tradeIn,
+	}, now)
+	if err != nil {
+		return nil, err
+	}
+
+	revision := &models.QuoteRevision{
+		ListPrice:        listPrice,
+		Discount:         discount,
+		TradeInAllowance: tradeIn,
+		AgreedPrice:      pricing.AgreedPrice,
+		Taxes:            pricing.Taxes,
+		Fees:             pricing.Fees,
+		Total:            pricing.Total,
+		ValidUntil:       validUntil,
+		Notes:            terms.Notes,
+	}
+	for _, item := range pricing.Lines {
+		revision.Lines = append(revision.Lines, models.QuoteLine{LineItem: item})
+	}
+	return revision, nil
+}
+
+// revisionPricing restates a quote revision as the pricing of a sale.
+func revisionPricing(revision *models.QuoteRevision) models.Pricing {
+	return models.Pricing{
+		AgreedPrice: revision.AgreedPrice,
+		Lines:       quoteLineItems(revision.Lines),
+		Taxes:       revision.Taxes,
+		Fees:        revision.Fees,
+		TradeIn:     revision.TradeInAllowance,
+		Total:       revision.Total,
+	}
+}
+
+func quoteLineItems(lines []models.QuoteLine) []models.LineItem {
+	items := make([]models.LineItem, 0, len(lines))
+	for _, line := range lines {
+		items = append(items, line.LineItem)
+	}
+	return items
+}
+
+func loadQuote(db *gorm.DB, id uint) (*models.Quote, error) {
+	var quote models.Quote
+	err := db.Preload("Vehicle").Preload("Customer").Preload("SalesPerson").
+		Preload("Revisions", func(db *gorm.DB) *gorm.DB { return db.Order("revision DESC") }).
+		Preload("Revisions.Lines", linesInOrder).
+		First(&quote, id).Error
+	if err != nil {
+		return nil, notFoundOr(err, "Quote not found")
+	}
+	return &quote, nil
+}
+
+// latestRevision loads a quote with only its latest revision.
+func latestRevision(db *gorm.DB, id uint) (*models.Quote, error) {
+	var quote models.Quote
+	err := db.Preload("Vehicle").Preload("Customer").Preload("SalesPerson").
+		Preload("Revisions", "revision = (?)", db.Model(&models.Quote{}).Select("revision").Where("id = ?", id)).
+		Preload("Revisions.Lines", linesInOrder).
+		First(&quote, id).Error
+	if err != nil {
+		return nil, notFoundOr(err, "Quote not found")
+	}
+	if len(quote.Revisions) == 0 {
+		return nil, notFound("Quote revision not found")
+	}
+	return &quote, nil
+}
+
+func shareToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// orZero returns m, or zero in currency when m was not given.
+func orZero(m money.Money, currency money.Currency) money.Money {
+	if m.Currency == "" {
+		return money.Zero(currency)
+	}
+	return m
+}
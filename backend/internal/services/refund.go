@@ -0,0 +1,264 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/payments"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type RefundInput struct {
+	// Amount to refund; zero refunds whatever is left of the payment
+	Amount money.Money
+	// PaymentMethod must match the original payment when set
+	PaymentMethod models.PaymentMethod
+	ReasonCode    models.RefundReason
+	// CancelSale overrides ReasonCode.CancelsSale when set
+	CancelSale *bool
+	Notes      string
+}
+
+// RefundTransaction returns part or all of a completed payment as a separate,
+// negative refund transaction linked to it and approved by actor. Money goes
+// back through the original payment method. Whether the sale is canceled and
+// its vehicle returned to stock follows the reason code unless
+// input.CancelSale says otherwise. The salesperson's commission on the sale
+// is reversed with it, and a refund that leaves a completed sale standing
+// lowers its gross profit by the same share.
+//
+// Gateway payments are refunded in two steps so that the provider is never
+// called with locks held or ahead of our own record: the refund is first
+// committed as pending, then sent to the provider with its reference as the
+// idempotency key, and completed or failed once the provider answers. A
+// refund whose outcome is not known yet stays pending, and calling
+// RefundTransaction again resumes it rather than refunding twice.
+func (s *TransactionService) RefundTransaction(actor Actor, id uint, input RefundInput) (*models.Transaction, error) {
+	cancelSale := input.ReasonCode.CancelsSale()
+	if input.CancelSale != nil {
+		cancelSale = *input.CancelSale
+	}
+
+	var original, refund *models.Transaction
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		original, err = lockTransaction(tx, id)
+		if err != nil {
+			return err
+		}
+		if original.Type == models.TransactionTypeRefund {
+			return invalidState("A refund cannot be refunded")
+		}
+		if original.Status != models.TransactionStatusCompleted {
+			return invalidState("Only completed transactions can be refunded")
+		}
+		if input.PaymentMethod != "" && input.PaymentMethod != original.PaymentMethod {
+			return invalidState("Refunds must be paid back via the original payment method " + string(original.PaymentMethod))
+		}
+
+		// lock the sale now so the shift is locked after it
+		sale, err := lockSale(tx, original.SaleID)
+		if err != nil {
+			return err
+		}
+
+		refund, err = pendingRefund(tx, original)
+		if err != nil {
+			return err
+		}
+		if refund != nil {
+			if !input.Amount.IsZero() && input.Amount != refund.Amount.Neg() {
+				return conflict("A refund of this payment is still pending", map[string]interface{}{
+					"refund_id": refund.ID,
+				})
+			}
+			return nil
+		}
+
+		refundable, err := refundableAmount(tx, original)
+		if err != nil {
+			return err
+		}
+		amount := input.Amount
+		if amount.IsZero() {
+			amount = refundable
+		}
+		if amount.Currency != refundable.Currency {
+			return invalidState("Refund currency must match the payment currency " + string(refundable.Currency))
+		}
+		if !amount.IsPositive() || amount.Minor > refundable.Minor {
+			return conflict("Refund exceeds the refundable amount of the payment", map[string]interface{}{
+				"refundable": refundable,
+			})
+		}
+
+		refund = &models.Transaction{
+			SaleID:         original.SaleID,
+			Type:           models.TransactionTypeRefund,
+			Amount:         amount.Neg(),
+			PaymentMethod:  original.PaymentMethod,
+			Tendered:       money.Zero(amount.Currency),
+			Change:         money.Zero(amount.Currency),
+			Status:         models.TransactionStatusPending,
+			ProcessedByID:  actor.UserID,
+			TransactionRef: "RFD-" + uuid.New().String()[:8],
+			Notes:          input.Notes,
+			RefundOfID:     &original.ID,
+			ReasonCode:     input.ReasonCode,
+			ApprovedByID:   &actor.UserID,
+			Provider:       original.Provider,
+		}
+		if original.Provider == "" {
+			// paid back at the counter
+			now := time.Now()
+			refund.Status = models.TransactionStatusCompleted
+			refund.ProcessedAt = &now
+		}
+		if err := assignShift(tx, refund); err != nil {
+			return err
+		}
+		if err := tx.Create(refund).Error; err != nil {
+			return err
+		}
+		if refund.Status != models.TransactionStatusCompleted {
+			return nil
+		}
+		return settleRefund(tx, sale, refund, cancelSale)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if refund.Status != models.TransactionStatusPending {
+		return refund, nil
+	}
+
+	// money goes back through the gateway that collected it; the refund
+	// stays pending if the provider cannot be reached
+	result, err := refundAtProvider(original, refund)
+	if err != nil {
+		return nil, err
+	}
+	return s.finishRefund(refund.ID, result, cancelSale)
+}
+
+// finishRefund records the provider's answer on a pending refund, completing
+// it and applying it to the sale once the money has been returned.
+func (s *TransactionService) finishRefund(id uint, result payments.Result, cancelSale bool) (*models.Transaction, error) {
+	var refund *models.Transaction
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		refund, err = lockTransaction(tx, id)
+		if err != nil {
+			return err
+		}
+		if refund.Status != models.TransactionStatusPending {
+			// already finished by a retry racing this one
+			return nil
+		}
+
+		if result.Reference != "" {
+			refund.ProviderRef = result.Reference
+		}
+		refund.ProviderStatus = string(result.Status)
+		refund.ProviderMessage = result.Message
+
+		switch {
+		case result.Status == payments.StatusRefunded:
+			sale, err := lockSale(tx, refund.SaleID)
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			refund.Status = models.TransactionStatusCompleted
+			refund.ProcessedAt = &now
+			if err := tx.Save(refund).Error; err != nil {
+				return err
+			}
+			return settleRefund(tx, sale, refund, cancelSale)
+		case result.Status.Failed():
+			refund.Status = models.TransactionStatusFailed
+		}
+		return tx.Save(refund).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if refund.Status == models.TransactionStatusFailed {
+		return nil, invalidState("Refund was declined by the payment provider: " + refund.ProviderMessage)
+	}
+	return refund, nil
+}
+
+// settleRefund applies a completed refund to its locked sale.
+func settleRefund(tx *gorm.DB, sale *models.Sale, refund *models.Transaction, cancelSale bool) error {
+	if err := issueReceipt(tx, refund); err != nil {
+		return err
+	}
+	if err := reverseCommission(tx, sale, refund, cancelSale); err != nil {
+		return err
+	}
+	if !cancelSale {
+		return reduceGrossProfit(tx, sale, refund)
+	}
+	return transitionSale(tx, sale.ID, models.SaleStatusCanceled)
+}
+
+// refundAtProvider asks the original payment's provider to return the money
+// of refund. A timeout is reported as a pending result.
+func refundAtProvider(original, refund *models.Transaction) (payments.Result, error) {
+	provider, ok := payments.ByName(original.Provider)
+	if !ok {
+		return payments.Result{}, gatewayError("Payment provider " + original.Provider + " is not configured")
+	}
+
+	ctx, cancel := payments.WithTimeout(context.Background())
+	defer cancel()
+	result, err := provider.Refund(ctx, original.ProviderRef, refund.Amount.Neg(), refund.TransactionRef)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return payments.Result{
+			Status:  payments.StatusPending,
+			Message: "Timed out waiting for the payment provider",
+		}, nil
+	}
+	if err != nil {
+		return result, gatewayError("Payment provider error: " + err.Error())
+	}
+	return result, nil
+}
+
+// pendingRefund returns the refund of original still awaiting its provider,
+// if any.
+func pendingRefund(tx *gorm.DB, original *models.Transaction) (*models.Transaction, error) {
+	var refund models.Transaction
+	err := tx.Where("refund_of_id = ? AND status = ?", original.ID, models.TransactionStatusPending).
+		First(&refund).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// refundableAmount is what is left of a payment after earlier refunds,
+// including those still pending.
+func refundableAmount(tx *gorm.DB, original *models.Transaction) (money.Money, error) {
+	var refunded int64
+	err := tx.Model(&models.Transaction{}).
+		Select("COALESCE(SUM(amount_minor), 0)").
+		Where("refund_of_id = ? AND status IN ?", original.ID,
+			[]models.TransactionStatus{models.TransactionStatusCompleted, models.TransactionStatusPending}).
+		Scan(&refunded).Error
+	if err != nil {
+		return money.Money{}, err
+	}
+	// refunds are stored as negative amounts
+	return original.Amount.Add(money.New(refunded, original.Amount.Currency))
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"errors"
+
+	"vehicle-sales-backend/internal/models"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// activeSaleIndex is the partial unique index allowing at most one active
+// sale per vehicle.
+const activeSaleIndex = "idx_sales_active_vehicle"
+
+var activeSaleStatuses = []models.SaleStatus{models.SaleStatusAwaitingApproval, models.SaleStatusPending, models.SaleStatusApproved}
+
+// findActiveSale returns the active sale holding a vehicle,
+// ignoring excludeID, or nil if the vehicle is free.
+func findActiveSale(db *gorm.DB, vehicleID, excludeID uint) (*models.Sale, error) {
+	var sale models.Sale
+	err := db.Where("vehicle_id = ? AND id <> ? AND status IN ?", vehicleID, excludeID, activeSaleStatuses).
+		First(&sale).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sale, nil
+}
+
+// vehicleTaken builds the 409 returned when a vehicle already has an active sale.
+func vehicleTaken(sale *models.Sale) *Error {
+	return conflict("Vehicle already has an active sale", map[string]interface{}{
+		"conflicting_sale_id": sale.ID,
+	})
+}
+
+// activeSaleViolation translates a violation of activeSaleIndex, raised when
+// a concurrent request reserved the vehicle first, into a 409 naming the
+// winning sale. The failed transaction must have been rolled back already.
+func activeSaleViolation(db *gorm.DB, err error, vehicleID, excludeID uint) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23505" || pgErr.ConstraintName != activeSaleIndex {
+		return err
+	}
+
+	existing, lookupErr := findActiveSale(db, vehicleID, excludeID)
+	if lookupErr != nil || existing == nil {
+		return conflict("Vehicle already has an active sale", nil)
+	}
+	return vehicleTaken(existing)
+}
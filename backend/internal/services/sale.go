@@ -0,0 +1,312 @@
+package services
+
+import (
+	"time"
+
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/permissions"
+	"vehicle-sales-backend/internal/statemachine"
+
+	"gorm.io/gorm"
+)
+
+// SaleService implements sale flows that must update the sale and its
+// vehicle atomically.
+type SaleService struct{}
+
+func NewSaleService() *SaleService {
+	return &SaleService{}
+}
+
+// SalePrice is the price agreed with the customer; the sale is priced from
+// it with the tax rules in force.
+type CreateSaleInput struct {
+	VehicleID     uint
+	CustomerID    uint
+	SalesPersonID uint
+	SalePrice     money.Money
+	Notes         string
+}
+
+type UpdateSaleInput struct {
+	// SalePrice reprices the sale at a new price for the vehicle; add-ons and
+	// the trade-in are kept
+	SalePrice money.Money
+	Status    models.SaleStatus
+	Notes     string
+	Version   uint
+}
+
+// CreateSale records a pending sale, itemised into the vehicle, any discount
+// off its price, taxes and fees, and reserves the vehicle. A discount above
+// actor's limit leaves the sale awaiting approval. It fails with a conflict
+// naming the existing sale if the vehicle is already reserved.
+func (s *SaleService) CreateSale(actor Actor, input CreateSaleInput) (*models.Sale, error) {
+	var sale *models.Sale
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		sale, err = openSale(tx, actor, input, nil, func(vehicle *models.Vehicle) (models.Pricing, error) {
+			return priceDeal(tx, vehicle, sellingTerms(vehicle, input.SalePrice), time.Now())
+		})
+		return err
+	})
+	if err != nil {
+		return nil, activeSaleViolation(database.DB, err, input.VehicleID, 0)
+	}
+	return sale, nil
+}
+
+// UpdateSale changes a sale's price, status or notes. A new price is
+// repriced with the tax rules in force now; a discount above actor's limit
+// puts the sale back to awaiting approval. Status changes go
+// through SaleStates, so canceling a sale releases its vehicle, and an
+// approved sale whose balance is already paid completes. When
+// input.Version is set the update is rejected with a conflict if the sale has
+// changed since the client read it.
+func (s *SaleService) UpdateSale(actor Actor, id uint, input UpdateSaleInput) (*models.Sale, error) {
+	var sale *models.Sale
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		sale, err = lockSale(tx, id)
+		if err != nil {
+			return err
+		}
+		if input.Version != 0 && input.Version != sale.Version {
+			return conflict("Sale was modified by another request", map[string]interface{}{
+				"current_version": sale.Version,
+			})
+		}
+
+		updates := map[string]interface{}{}
+		if input.SalePrice.IsPositive() {
+			if !sale.Status.IsActive() {
+				return invalidState("Only pending or approved sales can be repriced")
+			}
+			var vehicle models.Vehicle
+			if err := tx.First(&vehicle, sale.VehicleID).Error; err != nil {
+				return notFoundOr(err, "Vehicle not found")
+			}
+			var lines []models.SaleLine
+			if err := tx.Where("sale_id = ?", sale.ID).Order("position").Find(&lines).Error; err != nil {
+				return err
+			}
+			pricing, err := priceDeal(tx, &vehicle, repriceTerms(&vehicle, lineItems(lines), input.SalePrice), time.Now())
+			if err != nil {
+				return err
+			}
+			review, err := reviewDiscount(tx, actor, &vehicle, pricing)
+			if err != nil {
+				return err
+			}
+			balance, err := saleBalance(tx, sale)
+			if err != nil {
+				return err
+			}
+			if pricing.Total.Currency != sale.SalePrice.Currency && !balance.Paid.IsZero() {
+				return invalidState("Cannot change the currency of a sale with payments")
+			}
+			if pricing.Total.Minor < balance.Paid.Minor {
+				return invalidState("Sale price cannot be lower than the amount already paid")
+			}
+			if err := setSaleLines(tx, sale.ID, pricing); err != nil {
+				return err
+			}
+			updates["agreed_price_minor"] = pricing.AgreedPrice.Minor
+			updates["agreed_price_currency"] = pricing.AgreedPrice.Currency
+			updates["sale_price_minor"] = pricing.Total.Minor
+			updates["sale_price_currency"] = pricing.Total.Currency
+			updates["list_price_minor"] = review.ListPrice.Minor
+			updates["list_price_currency"] = review.ListPrice.Currency
+			updates["discount_minor"] = review.Discount.Minor
+			updates["discount_currency"] = review.Discount.Currency
+			updates["discount_rate"] = review.Rate
+			updates["trade_in_minor"] = pricing.TradeIn.Minor
+			updates["trade_in_currency"] = pricing.TradeIn.Currency
+
+			// a discount above the limit needs approval again; repricing
+			// within it withdraws a pending request
+			action := models.ApprovalActionRequested
+			to := sale.Status
+			switch {
+			case review.NeedsApproval:
+				to = models.SaleStatusAwaitingApproval
+			case sale.Status == models.SaleStatusAwaitingApproval:
+				action, to = models.ApprovalActionWithdrawn, models.SaleStatusPending
+			}
+			if review.NeedsApproval || to != sale.Status {
+				if to != sale.Status {
+					if err := SaleStates.Fire(tx, statemachine.System, sale, sale.Status, to); err != nil {
+						return err
+					}
+					sale.Status = to
+					updates["status"] = to
+				}
+				if err := recordApproval(tx, sale.ID, actor.UserID, action, review, input.Notes); err != nil {
+					return err
+				}
+			}
+		}
+		if input.Notes != "" {
+			updates["notes"] = input.Notes
+		}
+
+		if input.Status != "" && input.Status != sale.Status {
+			if err := SaleStates.Fire(tx, actor, sale, sale.Status, input.Status); err != nil {
+				return err
+			}
+			updates["status"] = input.Status
+			updates["completed_at"] = sale.CompletedAt
+		}
+
+		if len(updates) == 0 {
+			return nil
+		}
+		if err := updateVersioned(tx, &models.Sale{}, sale.ID, sale.Version, updates); err != nil {
+			return err
+		}
+		// approving a sale or lowering its price may settle the balance
+		if err := settleSale(tx, sale.ID); err != nil {
+			return err
+		}
+		return tx.First(sale, sale.ID).Error
+	})
+	if err != nil {
+		if sale != nil {
+			err = activeSaleViolation(database.DB, err, sale.VehicleID, sale.ID)
+		}
+		return nil, err
+	}
+	return sale, nil
+}
+
+// DeleteSale removes a pending sale, or one awaiting approval, and makes its
+// vehicle available again.
+func (s *SaleService) DeleteSale(id uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		sale, err := lockSale(tx, id)
+		if err != nil {
+			return err
+		}
+		if sale.Status != models.SaleStatusPending && sale.Status != models.SaleStatusAwaitingApproval {
+			return invalidState("Can only delete pending sales")
+		}
+		balance, err := saleBalance(tx, sale)
+		if err != nil {
+			return err
+		}
+		if !balance.Paid.IsZero() || !balance.Pending.IsZero() {
+			return invalidState("Cannot delete a sale with deposits")
+		}
+
+		if err := setVehicleStatus(tx, sale.VehicleID, models.VehicleStatusAvailable); err != nil {
+			return err
+		}
+		return tx.Delete(sale).Error
+	})
+}
+
+// Balance returns the payment ledger of a sale.
+func (s *SaleService) Balance(id uint) (*models.SaleBalance, error) {
+	var sale models.Sale
+	if err := database.DB.First(&sale, id).Error; err != nil {
+		return nil, notFoundOr(err, "Sale not found")
+	}
+	return saleBalance(database.DB, &sale)
+}
+
+// openSale records a pending sale priced by price and reserves its vehicle.
+// A discount above actor's limit leaves the sale awaiting approval.
+// input.SalePrice is not used. It fails with a conflict naming the existing
+// sale if the vehicle is already reserved.
+func openSale(tx *gorm.DB, actor Actor, input CreateSaleInput, quoteID *uint, price func(*models.Vehicle) (models.Pricing, error)) (*models.Sale, error) {
+	vehicle, err := lockVehicle(tx, input.VehicleID)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := findActiveSale(tx, vehicle.ID, 0)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, vehicleTaken(existing)
+	}
+	if vehicle.Status != models.VehicleStatusAvailable {
+		return nil, invalidState("Vehicle is not available for sale")
+	}
+
+	if err := checkSaleParties(tx, input.CustomerID, input.SalesPersonID); err != nil {
+		return nil, err
+	}
+
+	pricing, err := price(vehicle)
+	if err != nil {
+		return nil, err
+	}
+	review, err := reviewDiscount(tx, actor, vehicle, pricing)
+	if err != nil {
+		return nil, err
+	}
+
+	sale := &models.Sale{
+		VehicleID:     input.VehicleID,
+		CustomerID:    input.CustomerID,
+		SalesPersonID: input.SalesPersonID,
+		AgreedPrice:   pricing.AgreedPrice,
+		SalePrice:     pricing.Total,
+		ListPrice:     review.ListPrice,
+		Discount:      review.Discount,
+		DiscountRate:  review.Rate,
+		TradeIn:       pricing.TradeIn,
+		Status:        models.SaleStatusPending,
+		Notes:         input.Notes,
+		QuoteID:       quoteID,
+	}
+	if review.NeedsApproval {
+		sale.Status = models.SaleStatusAwaitingApproval
+	}
+	if err := tx.Create(sale).Error; err != nil {
+		return nil, err
+	}
+	if err := setSaleLines(tx, sale.ID, pricing); err != nil {
+		return nil, err
+	}
+	if review.NeedsApproval {
+		if err := recordApproval(tx, sale.ID, actor.UserID, models.ApprovalActionRequested, review, input.Notes); err != nil {
+			return nil, err
+		}
+	}
+
+	err = updateVersioned(tx, &models.Vehicle{}, vehicle.ID, vehicle.Version, map[string]interface{}{
+		"status": models.VehicleStatusReserved,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sale, nil
+}
+
+// checkSaleParties verifies that a sale or quote is made to a customer by a
+// sales person.
+func checkSaleParties(tx *gorm.DB, customerID, salesPersonID uint) error {
+	var customer models.User
+	if err := tx.Where("id = ? AND role = ?", customerID, models.RoleCustomer).First(&customer).Error; err != nil {
+		return notFoundOr(err, "Customer not found")
+	}
+	return checkSalesPerson(tx, salesPersonID)
+}
+
+// checkSalesPerson verifies that a user may sell, i.e. that their role,
+// built-in or not, holds sale:create.
+func checkSalesPerson(tx *gorm.DB, id uint) error {
+	var salesPerson models.User
+	if err := tx.First(&salesPerson, id).Error; err != nil {
+		return notFoundOr(err, "Sales person not found")
+	}
+	if !permissions.Has(salesPerson.Role, permissions.SaleCreate) {
+		return notFound("Sales person not found")
+	}
+	return nil
+}
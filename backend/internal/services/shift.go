@@ -0,0 +1,403 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/permissions"
+
+	"gorm.io/gorm"
+)
+
+// zReportLockID is the transaction advisory lock key held while a Z-report
+// number is assigned.
+const zReportLockID int64 = 7_310_552_004_120
+
+// ShiftService implements cashier shifts: opening the cash drawer with a
+// float, the close-out count and the Z-report that locks the shift.
+type ShiftService struct{}
+
+func NewShiftService() *ShiftService {
+	return &ShiftService{}
+}
+
+type OpenShiftInput struct {
+	// OpeningFloat is the cash in the drawer when the shift starts
+	OpeningFloat money.Money
+	Notes        string
+}
+
+type CloseShiftInput struct {
+	// Counts is what was counted per payment method; cash is required
+	Counts map[models.PaymentMethod]money.Money
+	Notes  string
+}
+
+// OpenShift starts a shift for actor. A cashier can only have one open
+// shift at a time.
+func (s *ShiftService) OpenShift(actor Actor, input OpenShiftInput) (*models.Shift, error) {
+	float := input.OpeningFloat
+	if float.Currency == "" {
+		float = money.Zero(money.DefaultCurrency())
+	}
+
+	var shift models.Shift
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		existing, err := openShift(tx, actor.UserID)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return conflict("You already have an open shift", map[string]interface{}{
+				"shift_id": existing.ID,
+			})
+		}
+
+		shift = models.Shift{
+			CashierID:    actor.UserID,
+			Status:       models.ShiftStatusOpen,
+			OpeningFloat: float,
+			OpenedAt:     time.Now(),
+			Notes:        input.Notes,
+		}
+		return tx.Create(&shift).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &shift, nil
+}
+
+// CurrentShift returns actor's open shift.
+func (s *ShiftService) CurrentShift(actor Actor) (*models.Shift, error) {
+	shift, err := openShift(database.DB, actor.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if shift == nil {
+		return nil, notFound("No open shift")
+	}
+	return shift, nil
+}
+
+// Report builds the close-out report of a shift. While the shift is open it
+// shows the takings so far.
+func (s *ShiftService) Report(actor Actor, id uint) (*models.ShiftReport, error) {
+	var shift models.Shift
+	if err := database.DB.Preload("Counts").First(&shift, id).Error; err != nil {
+		return nil, notFoundOr(err, "Shift not found")
+	}
+	if err := authorizeShift(actor, &shift); err != nil {
+		return nil, err
+	}
+	return shiftReport(database.DB, &shift)
+}
+
+// CloseShift ends a shift with the amounts counted per payment method and
+// records each method's variance against its transactions. Pending cash
+// transactions must be processed or failed first, since the drawer count
+// could not account for them.
+func (s *ShiftService) CloseShift(actor Actor, id uint, input CloseShiftInput) (*models.ShiftReport, error) {
+	var report *models.ShiftReport
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		shift, err := lockShift(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := authorizeShift(actor, shift); err != nil {
+			return err
+		}
+		if shift.Status != models.ShiftStatusOpen {
+			return invalidState("Shift is not open")
+		}
+		if err := ShiftStates.Fire(tx, actor, shift, shift.Status, models.ShiftStatusClosed); err != nil {
+			return err
+		}
+
+		if _, ok := input.Counts[models.PaymentMethodCash]; !ok {
+			return invalidState("The cash drawer must be counted to close a shift")
+		}
+		var pendingCash int64
+		err = tx.Model(&models.Transaction{}).
+			Where("shift_id = ? AND payment_method = ? AND status = ?",
+				shift.ID, models.PaymentMethodCash, models.TransactionStatusPending).
+			Count(&pendingCash).Error
+		if err != nil {
+			return err
+		}
+		if pendingCash > 0 {
+			return conflict("Process or fail the shift's pending cash transactions before closing it", map[string]interface{}{
+				"pending": pendingCash,
+			})
+		}
+
+		expected, err := shiftReport(tx, shift)
+		if err != nil {
+			return err
+		}
+		currency := shift.OpeningFloat.Currency
+		for _, method := range models.PaymentMethods {
+			counted, ok := input.Counts[method]
+			if !ok {
+				continue
+			}
+			if counted.Currency != currency {
+				return invalidState("Counts must be in the drawer currency " + string(currency))
+			}
+			count := models.ShiftCount{
+				ShiftID:       shift.ID,
+				PaymentMethod: method,
+				Expected:      expectedFor(expected, method, currency),
+				Counted:       counted,
+			}
+			if count.Variance, err = counted.Sub(count.Expected); err != nil {
+				return err
+			}
+			if err := tx.Create(&count).Error; err != nil {
+				return err
+			}
+			shift.Counts = append(shift.Counts, count)
+		}
+
+		now := time.Now()
+		shift.Status = models.ShiftStatusClosed
+		shift.ClosedAt = &now
+		shift.ClosedByID = &actor.UserID
+		if input.Notes != "" {
+			shift.Notes = input.Notes
+		}
+		if err := tx.Omit("Counts").Save(shift).Error; err != nil {
+			return err
+		}
+
+		report, err = shiftReport(tx, shift)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// FinalizeShift runs the Z-report of a closed shift: it assigns the next Z
+// number, fixes the counted variances against the final takings and locks
+// the shift's transactions from further edits. Every transaction of the
+// shift must have been resolved.
+func (s *ShiftService) FinalizeShift(actor Actor, id uint) (*models.ShiftReport, error) {
+	var report *models.ShiftReport
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		shift, err := lockShift(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := authorizeShift(actor, shift); err != nil {
+			return err
+		}
+		if shift.Status != models.ShiftStatusClosed {
+			return invalidState("Only closed shifts can be finalized")
+		}
+		if err := ShiftStates.Fire(tx, actor, shift, shift.Status, models.ShiftStatusFinalized); err != nil {
+			return err
+		}
+		if err := tx.Where("shift_id = ?", shift.ID).Find(&shift.Counts).Error; err != nil {
+			return err
+		}
+
+		final, err := shiftReport(tx, shift)
+		if err != nil {
+			return err
+		}
+		if final.Pending > 0 {
+			return conflict("Every transaction of the shift must be completed or failed before its Z-report", map[string]interface{}{
+				"pending": final.Pending,
+			})
+		}
+
+		// takings may have changed since the close if non-cash payments
+		// were still pending at the provider
+		currency := shift.OpeningFloat.Currency
+		for i := range shift.Counts {
+			count := &shift.Counts[i]
+			count.Expected = expectedFor(final, count.PaymentMethod, currency)
+			if count.Variance, err = count.Counted.Sub(count.Expected); err != nil {
+				return err
+			}
+			if err := tx.Save(count).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", zReportLockID).Error; err != nil {
+			return err
+		}
+		var last uint
+		if err := tx.Model(&models.Shift{}).Select("COALESCE(MAX(z_number), 0)").Scan(&last).Error; err != nil {
+			return err
+		}
+		zNumber := last + 1
+
+		now := time.Now()
+		shift.Status = models.ShiftStatusFinalized
+		shift.ZNumber = &zNumber
+		shift.FinalizedAt = &now
+		shift.FinalizedByID = &actor.UserID
+		if err := tx.Omit("Counts").Save(shift).Error; err != nil {
+			return err
+		}
+
+		report, err = shiftReport(tx, shift)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// authorizeShift hides other cashiers' shifts from callers who may not
+// manage them.
+func authorizeShift(actor Actor, shift *models.Shift) error {
+	if shift.CashierID != actor.UserID && !actor.Can(permissions.ShiftManage) {
+		return notFound("Shift not found")
+	}
+	return nil
+}
+
+// shiftReport totals the completed transactions of a shift per payment
+// method and compares them with the counts recorded in shift.Counts.
+func shiftReport(tx *gorm.DB, shift *models.Shift) (*models.ShiftReport, error) {
+	var rows []struct {
+		PaymentMethod models.PaymentMethod
+		Count         int64
+		Payments      int64
+		Refunds       int64
+	}
+	err := tx.Model(&models.Transaction{}).
+		Select("payment_method, COUNT(*) AS count, "+
+			"COALESCE(SUM(CASE WHEN amount_minor > 0 THEN amount_minor END), 0) AS payments, "+
+			"COALESCE(SUM(CASE WHEN amount_minor < 0 THEN amount_minor END), 0) AS refunds").
+		Where("shift_id = ? AND status = ?", shift.ID, models.TransactionStatusCompleted).
+		Group("payment_method").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.ShiftReport{Shift: shift, Lines: []models.ShiftReportLine{}}
+	err = tx.Model(&models.Transaction{}).
+		Where("shift_id = ? AND status = ?", shift.ID, models.TransactionStatusPending).
+		Count(&report.Pending).Error
+	if err != nil {
+		return nil, err
+	}
+
+	currency := shift.OpeningFloat.Currency
+	counts := map[models.PaymentMethod]models.ShiftCount{}
+	for _, count := range shift.Counts {
+		counts[count.PaymentMethod] = count
+	}
+	for _, method := range models.PaymentMethods {
+		line := models.ShiftReportLine{
+			PaymentMethod: method,
+			Payments:      money.Zero(currency),
+			Refunds:       money.Zero(currency),
+		}
+		for _, row := range rows {
+			if row.PaymentMethod == method {
+				line.Transactions = row.Count
+				line.Payments = money.New(row.Payments, currency)
+				line.Refunds = money.New(row.Refunds, currency)
+			}
+		}
+		count, counted := counts[method]
+		if line.Transactions == 0 && !counted && method != models.PaymentMethodCash {
+			continue
+		}
+
+		if line.Expected, err = line.Payments.Add(line.Refunds); err != nil {
+			return nil, err
+		}
+		if method == models.PaymentMethodCash {
+			if line.Expected, err = line.Expected.Add(shift.OpeningFloat); err != nil {
+				return nil, err
+			}
+		}
+		if counted {
+			line.Counted = &count.Counted
+			line.Variance = &count.Variance
+			total := count.Variance
+			if report.Variance != nil {
+				if total, err = report.Variance.Add(count.Variance); err != nil {
+					return nil, err
+				}
+			}
+			report.Variance = &total
+		}
+		report.Lines = append(report.Lines, line)
+	}
+	return report, nil
+}
+
+func expectedFor(report *models.ShiftReport, method models.PaymentMethod, currency money.Currency) money.Money {
+	for _, line := range report.Lines {
+		if line.PaymentMethod == method {
+			return line.Expected
+		}
+	}
+	return money.Zero(currency)
+}
+
+// openShift returns the cashier's open shift, locked, or nil if they have
+// none.
+func openShift(tx *gorm.DB, cashierID uint) (*models.Shift, error) {
+	var shift models.Shift
+	err := forUpdate(tx).Where("cashier_id = ? AND status = ?", cashierID, models.ShiftStatusOpen).First(&shift).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &shift, nil
+}
+
+// assignShift ties a new transaction to the open shift of the cashier taking
+// it. Cash can only be taken or paid out during a shift, in the drawer's
+// currency.
+func assignShift(tx *gorm.DB, transaction *models.Transaction) error {
+	shift, err := openShift(tx, transaction.ProcessedByID)
+	if err != nil {
+		return err
+	}
+	isCash := transaction.PaymentMethod == models.PaymentMethodCash
+	if shift == nil {
+		if isCash {
+			return invalidState("Open a cashier shift before taking or paying out cash")
+		}
+		return nil
+	}
+	if isCash && transaction.Amount.Currency != shift.OpeningFloat.Currency {
+		return invalidState("Cash must be in the drawer currency " + string(shift.OpeningFloat.Currency))
+	}
+	transaction.ShiftID = &shift.ID
+	return nil
+}
+
+// checkShiftUnlocked rejects edits to a transaction whose shift has had its
+// Z-report run.
+func checkShiftUnlocked(tx *gorm.DB, transaction *models.Transaction) error {
+	if transaction.ShiftID == nil {
+		return nil
+	}
+	shift, err := lockShift(tx, *transaction.ShiftID)
+	if err != nil {
+		return err
+	}
+	if shift.Status == models.ShiftStatusFinalized {
+		return invalidState("Transaction belongs to a finalized shift and can no longer be edited")
+	}
+	return nil
+}
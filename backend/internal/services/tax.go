@@ -0,0 +1,428 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/tax"
+
+	"gorm.io/gorm"
+)
+
+// taxRulesLockID is the transaction advisory lock key held while a tax rule
+// is checked for overlaps and saved.
+const taxRulesLockID int64 = 7_310_552_004_121
+
+// TaxService manages the tax and fee rules, prices sales with them and
+// reports the taxes invoiced per period.
+type TaxService struct{}
+
+func NewTaxService() *TaxService {
+	return &TaxService{}
+}
+
+// AddOn is an accessory or service sold with a vehicle, such as window film
+// or an extended warranty. Add-ons are taxed like the vehicle.
+type AddOn struct {
+	Description string
+	Price       money.Money
+}
+
+// dealTerms are what is negotiated for a vehicle: its price, the discount
+// off it, the add-ons and the trade-in allowance. TradeIns are credits for
+// appraised vehicles, each deducted as a line of its own after TradeIn.
+type dealTerms struct {
+	Price    money.Money
+	Discount money.Money
+	AddOns   []AddOn
+	TradeIn  money.Money
+	TradeIns []tradeInCredit
+}
+
+// tradeInCredit is the value of a vehicle taken in trade, described as it
+// appears on the deal.
+type tradeInCredit struct {
+	Description string
+	Allowance   money.Money
+}
+
+type TaxRuleInput struct {
+	Code            string
+	Name            string
+	Kind            models.TaxKind
+	Rate            int64
+	Amount          money.Money
+	Inclusive       bool
+	VehicleCategory string
+	EffectiveFrom   time.Time
+	EffectiveTo     *time.Time
+}
+
+// CreateRule adds a tax or fee rule. A rule may not overlap another with the
+// same code and vehicle category; to change a rate, end the current rule and
+// add one taking effect the same day.
+func (s *TaxService) CreateRule(input TaxRuleInput) (*models.TaxRule, error) {
+	var rule models.TaxRule
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		input.apply(&rule)
+		if err := checkTaxRule(tx, &rule); err != nil {
+			return err
+		}
+		return tx.Create(&rule).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// UpdateRule changes a rule. Sales already priced keep the lines they were
+// priced with.
+func (s *TaxService) UpdateRule(id uint, input TaxRuleInput) (*models.TaxRule, error) {
+	var rule models.TaxRule
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := forUpdate(tx).First(&rule, id).Error; err != nil {
+			return notFoundOr(err, "Tax rule not found")
+		}
+		input.apply(&rule)
+		if err := checkTaxRule(tx, &rule); err != nil {
+			return err
+		}
+		return tx.Save(&rule).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// DeleteRule removes a rule from future pricing.
+func (s *TaxService) DeleteRule(id uint) error {
+	result := database.DB.Delete(&models.TaxRule{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return notFound("Tax rule not found")
+	}
+	return nil
+}
+
+// Calculate prices a vehicle at an agreed price with the rules in force at
+// the given time, without recording anything.
+func (s *TaxService) Calculate(vehicleID uint, agreed money.Money, at time.Time) (*models.Pricing, error) {
+	var vehicle models.Vehicle
+	if err := database.DB.First(&vehicle, vehicleID).Error; err != nil {
+		return nil, notFoundOr(err, "Vehicle not found")
+	}
+	pricing, err := priceDeal(database.DB, &vehicle, dealTerms{Price: agreed}, at)
+	if err != nil {
+		return nil, err
+	}
+	return &pricing, nil
+}
+
+// Summary totals the taxes and fees on the invoices issued in [from, to),
+// less the share of them returned by credit notes issued in the period.
+// Credit notes of sales that were never invoiced returned deposits, which
+// carried no tax, and are left out.
+func (s *TaxService) Summary(from, to time.Time, currency money.Currency) (*models.TaxSummary, error) {
+	summary := &models.TaxSummary{
+		From:     from,
+		To:       to,
+		Currency: currency,
+		Lines:    []models.TaxSummaryLine{},
+		Revenue:  money.Zero(currency),
+		Taxes:    money.Zero(currency),
+		Fees:     money.Zero(currency),
+	}
+	index := map[string]int{}
+	add := func(line models.LineItem, sign int64) {
+		amount := sign * line.Amount.Minor
+		switch {
+		case line.Type == models.LineTypeTax:
+			summary.Taxes.Minor += amount
+		case line.Type == models.LineTypeFee:
+			summary.Fees.Minor += amount
+		case line.Type.IsTaxable():
+			summary.Revenue.Minor += amount
+			return
+		default:
+			// trade-ins are a purchase by the dealership, not a sale
+			return
+		}
+		if line.Inclusive {
+			// included taxes and fees were counted in the revenue
+			summary.Revenue.Minor -= amount
+		}
+
+		key := fmt.Sprintf("%s|%s|%d", line.Type, line.Code, line.Rate)
+		i, ok := index[key]
+		if !ok {
+			i = len(summary.Lines)
+			index[key] = i
+			summary.Lines = append(summary.Lines, models.TaxSummaryLine{
+				Type:        line.Type,
+				Code:        line.Code,
+				Description: line.Description,
+				Rate:        line.Rate,
+				Base:        money.Zero(currency),
+				Invoiced:    money.Zero(currency),
+				Credited:    money.Zero(currency),
+				Net:         money.Zero(currency),
+			})
+		}
+		total := &summary.Lines[i]
+		total.Base.Minor += sign * line.Base.Minor
+		total.Net.Minor += amount
+		if sign > 0 {
+			total.Invoiced.Minor += line.Amount.Minor
+		} else {
+			total.Credited.Minor += line.Amount.Minor
+		}
+	}
+
+	inPeriod := func(query *gorm.DB, kind models.DocumentType) *gorm.DB {
+		return query.Where("documents.type = ? AND documents.issued_at >= ? AND documents.issued_at < ? AND documents.total_currency = ?",
+			kind, from, to, currency)
+	}
+
+	var invoiced []models.SaleLine
+	err := inPeriod(database.DB.Model(&models.SaleLine{}).
+		Joins("JOIN documents ON documents.sale_id = sale_lines.sale_id"), models.DocumentTypeInvoice).
+		Order("sale_lines.sale_id, sale_lines.position").
+		Find(&invoiced).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range invoiced {
+		add(line.LineItem, 1)
+	}
+	if err := inPeriod(database.DB.Model(&models.Document{}), models.DocumentTypeInvoice).Count(&summary.Invoices).Error; err != nil {
+		return nil, err
+	}
+
+	var creditNotes []models.Document
+	err = inPeriod(database.DB.Omit("pdf", "content"), models.DocumentTypeCreditNote).
+		Where("EXISTS (SELECT 1 FROM documents invoices WHERE invoices.sale_id = documents.sale_id AND invoices.type = ? AND invoices.issued_at <= documents.issued_at)",
+			models.DocumentTypeInvoice).
+		Order("issued_at, id").
+		Find(&creditNotes).Error
+	if err != nil {
+		return nil, err
+	}
+	sales := map[uint]*models.Sale{}
+	for _, note := range creditNotes {
+		sale, ok := sales[note.SaleID]
+		if !ok {
+			sale = &models.Sale{}
+			if err := database.DB.Unscoped().Preload("Lines", linesInOrder).First(sale, note.SaleID).Error; err != nil {
+				return nil, err
+			}
+			sales[note.SaleID] = sale
+		}
+		// credit notes carry the refund's negative amount
+		for _, line := range tax.Allocate(lineItems(sale.Lines), note.Total.Neg()) {
+			add(line, -1)
+		}
+	}
+	summary.CreditNotes = int64(len(creditNotes))
+
+	return summary, nil
+}
+
+// priceDeal applies the tax rules in force at the given time to the terms
+// agreed for a vehicle.
+func priceDeal(tx *gorm.DB, vehicle *models.Vehicle, terms dealTerms, at time.Time) (models.Pricing, error) {
+	rules, err := rulesInForce(tx, vehicle.Category, at)
+	if err != nil {
+		return models.Pricing{}, err
+	}
+
+	items := []models.LineItem{{
+		Type:        models.LineTypeVehicle,
+		Description: vehicleDescription(*vehicle),
+		Amount:      terms.Price,
+	}}
+	if terms.Discount.IsPositive() {
+		items = append(items, models.LineItem{
+			Type:        models.LineTypeDiscount,
+			Description: "Discount",
+			Amount:      terms.Discount.Neg(),
+		})
+	}
+	for _, addOn := range terms.AddOns {
+		items = append(items, models.LineItem{
+			Type:        models.LineTypeAddOn,
+			Description: addOn.Description,
+			Amount:      addOn.Price,
+		})
+	}
+
+	pricing, err := tax.Price(items, rules)
+	if err == nil {
+		err = tax.TradeIn(&pricing, "Trade-in allowance", terms.TradeIn)
+	}
+	for _, credit := range terms.TradeIns {
+		if err == nil {
+			err = tax.TradeIn(&pricing, credit.Description, credit.Allowance)
+		}
+	}
+	switch {
+	case errors.Is(err, tax.ErrPriceTooLow):
+		return pricing, invalidState("The price must be more than the fees included in it")
+	case errors.Is(err, tax.ErrTradeInTooHigh):
+		return pricing, invalidState("Trade-in allowance cannot exceed the total")
+	case err != nil:
+		return pricing, invalidState(err.Error())
+	}
+	return pricing, nil
+}
+
+// sellingTerms returns the terms of selling a vehicle at price: below the
+// vehicle's own price, that price less a discount, so that the discount
+// shows on the deal.
+func sellingTerms(vehicle *models.Vehicle, price money.Money) dealTerms {
+	list := vehicle.Price
+	if list.Currency != price.Currency || price.Minor >= list.Minor {
+		return dealTerms{Price: price}
+	}
+	return dealTerms{Price: list, Discount: money.New(list.Minor-price.Minor, price.Currency)}
+}
+
+// repriceTerms returns the terms of a priced deal with a new price for the
+// vehicle, which replaces any discount. Add-ons and trade-ins stay.
+func repriceTerms(vehicle *models.Vehicle, lines []models.LineItem, price money.Money) dealTerms {
+	terms := dealTermsOf(lines)
+	selling := sellingTerms(vehicle, price)
+	terms.Price, terms.Discount = selling.Price, selling.Discount
+	return terms
+}
+
+// dealTermsOf recovers the terms of a priced deal from its lines. Every
+// trade-in line is kept as a credit of its own.
+func dealTermsOf(lines []models.LineItem) dealTerms {
+	var terms dealTerms
+	for _, line := range lines {
+		switch line.Type {
+		case models.LineTypeVehicle:
+			terms.Price = line.Amount
+		case models.LineTypeDiscount:
+			terms.Discount = money.New(terms.Discount.Minor-line.Amount.Minor, line.Amount.Currency)
+		case models.LineTypeAddOn:
+			terms.AddOns = append(terms.AddOns, AddOn{Description: line.Description, Price: line.Amount})
+		case models.LineTypeTradeIn:
+			terms.TradeIns = append(terms.TradeIns, tradeInCredit{Description: line.Description, Allowance: line.Amount.Neg()})
+		}
+	}
+	return terms
+}
+
+// rulesInForce returns the rules that apply to a vehicle category at the
+// given time. A rule for the category replaces a general rule with the same
+// code.
+func rulesInForce(tx *gorm.DB, category string, at time.Time) ([]models.TaxRule, error) {
+	var rules []models.TaxRule
+	err := tx.Where("effective_from <= ? AND (effective_to IS NULL OR effective_to > ?)", at, at).
+		Where("(vehicle_category = '' OR vehicle_category = ?)", category).
+		Order("id").
+		Find(&rules).Error
+	if err != nil {
+		return nil, err
+	}
+
+	specific := map[string]bool{}
+	for _, rule := range rules {
+		if rule.VehicleCategory != "" {
+			specific[rule.Code] = true
+		}
+	}
+	applicable := rules[:0]
+	for _, rule := range rules {
+		if rule.VehicleCategory == "" && specific[rule.Code] {
+			continue
+		}
+		applicable = append(applicable, rule)
+	}
+	return applicable, nil
+}
+
+// setSaleLines replaces the line items of a sale with those of pricing.
+func setSaleLines(tx *gorm.DB, saleID uint, pricing models.Pricing) error {
+	if err := tx.Where("sale_id = ?", saleID).Delete(&models.SaleLine{}).Error; err != nil {
+		return err
+	}
+	lines := make([]models.SaleLine, 0, len(pricing.Lines))
+	for _, item := range pricing.Lines {
+		lines = append(lines, models.SaleLine{SaleID: saleID, LineItem: item})
+	}
+	return tx.Create(&lines).Error
+}
+
+func lineItems(lines []models.SaleLine) []models.LineItem {
+	items := make([]models.LineItem, 0, len(lines))
+	for _, line := range lines {
+		items = append(items, line.LineItem)
+	}
+	return items
+}
+
+func linesInOrder(db *gorm.DB) *gorm.DB {
+	return db.Order("position")
+}
+
+func (input TaxRuleInput) apply(rule *models.TaxRule) {
+	rule.Code = input.Code
+	rule.Name = input.Name
+	rule.Kind = input.Kind
+	rule.Rate = input.Rate
+	rule.Amount = input.Amount
+	rule.Inclusive = input.Inclusive
+	rule.VehicleCategory = input.VehicleCategory
+	rule.EffectiveFrom = input.EffectiveFrom
+	rule.EffectiveTo = input.EffectiveTo
+}
+
+// checkTaxRule validates a rule and rejects it if it overlaps another rule
+// with the same code and category. It holds taxRulesLockID until the
+// caller's transaction ends, so concurrent changes are checked in turn.
+func checkTaxRule(tx *gorm.DB, rule *models.TaxRule) error {
+	if rule.Kind.IsPercentage() {
+		if rule.Rate <= 0 {
+			return invalidState("A tax needs a rate")
+		}
+		rule.Amount = money.Zero(money.DefaultCurrency())
+	} else {
+		if !rule.Amount.IsPositive() {
+			return invalidState("A fee needs an amount")
+		}
+		rule.Rate = 0
+	}
+	if rule.EffectiveTo != nil && !rule.EffectiveTo.After(rule.EffectiveFrom) {
+		return invalidState("effective_to must be after effective_from")
+	}
+
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", taxRulesLockID).Error; err != nil {
+		return err
+	}
+	query := tx.Where("code = ? AND vehicle_category = ? AND id <> ?", rule.Code, rule.VehicleCategory, rule.ID).
+		Where("(effective_to IS NULL OR effective_to > ?)", rule.EffectiveFrom)
+	if rule.EffectiveTo != nil {
+		query = query.Where("effective_from < ?", *rule.EffectiveTo)
+	}
+	var existing models.TaxRule
+	err := query.First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return conflict("Another "+rule.Code+" rule is in force during this period", map[string]interface{}{
+		"tax_rule_id": existing.ID,
+	})
+}
@@ -0,0 +1,436 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/tax"
+
+	"gorm.io/gorm"
+)
+
+// TradeInService implements trade-ins: appraisals of a customer's vehicle
+// that, once the customer accepts the offered value, take the vehicle into
+// stock and credit the value against one of the customer's sales.
+type TradeInService struct{}
+
+func NewTradeInService() *TradeInService {
+	return &TradeInService{}
+}
+
+// ConditionCheck is one item of an appraisal's condition checklist.
+type ConditionCheck struct {
+	Item      string
+	Condition models.Condition
+	Notes     string
+}
+
+type TradeInPhotoInput struct {
+	URL     string
+	Caption string
+}
+
+// AppraisalInput describes the vehicle offered in trade and what the
+// dealership offers for it.
+type AppraisalInput struct {
+	CustomerID   uint
+	Make         string
+	Model        string
+	Year         int
+	Color        string
+	VIN          string
+	LicensePlate string
+	Mileage      int
+	Category     string
+	Checklist    []ConditionCheck
+	Photos       []TradeInPhotoInput
+	OfferedValue money.Money
+	ValidUntil   *time.Time
+	Notes        string
+}
+
+// Appraise records actor's appraisal of a customer's vehicle.
+func (s *TradeInService) Appraise(actor Actor, input AppraisalInput) (*models.TradeIn, error) {
+	var tradeIn models.TradeIn
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var customer models.User
+		if err := tx.Where("id = ? AND role = ?", input.CustomerID, models.RoleCustomer).First(&customer).Error; err != nil {
+			return notFoundOr(err, "Customer not found")
+		}
+		if err := input.apply(&tradeIn); err != nil {
+			return err
+		}
+		tradeIn.AppraiserID = actor.UserID
+		tradeIn.Status = models.TradeInStatusAppraised
+		return tx.Create(&tradeIn).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loadTradeIn(database.DB, tradeIn.ID)
+}
+
+// Reappraise replaces the appraisal of a trade-in that has not been
+// accepted. A declined trade-in is offered again.
+func (s *TradeInService) Reappraise(actor Actor, id uint, input AppraisalInput) (*models.TradeIn, error) {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		tradeIn, err := lockTradeIn(tx, id)
+		if err != nil {
+			return err
+		}
+		if tradeIn.Status == models.TradeInStatusAccepted {
+			return invalidState("An accepted trade-in cannot be reappraised")
+		}
+		if err := TradeInStates.Fire(tx, actor, tradeIn, tradeIn.Status, models.TradeInStatusAppraised); err != nil {
+			return err
+		}
+
+		// the customer stays the same
+		input.CustomerID = tradeIn.CustomerID
+		appraisal := *tradeIn
+		appraisal.Checklist, appraisal.Photos = nil, nil
+		if err := input.apply(&appraisal); err != nil {
+			return err
+		}
+		for _, child := range []interface{}{&models.TradeInCheck{}, &models.TradeInPhoto{}} {
+			if err := tx.Where("trade_in_id = ?", tradeIn.ID).Delete(child).Error; err != nil {
+				return err
+			}
+		}
+		for i := range appraisal.Checklist {
+			appraisal.Checklist[i].TradeInID = tradeIn.ID
+		}
+		for i := range appraisal.Photos {
+			appraisal.Photos[i].TradeInID = tradeIn.ID
+		}
+		if len(appraisal.Checklist) > 0 {
+			if err := tx.Create(&appraisal.Checklist).Error; err != nil {
+				return err
+			}
+		}
+		if len(appraisal.Photos) > 0 {
+			if err := tx.Create(&appraisal.Photos).Error; err != nil {
+				return err
+			}
+		}
+
+		return updateVersioned(tx, &models.TradeIn{}, tradeIn.ID, tradeIn.Version, map[string]interface{}{
+			"status":                 models.TradeInStatusAppraised,
+			"appraiser_id":           actor.UserID,
+			"make":                   appraisal.Make,
+			"model":                  appraisal.Model,
+			"year":                   appraisal.Year,
+			"color":                  appraisal.Color,
+			"vin":                    appraisal.VIN,
+			"license_plate":          appraisal.LicensePlate,
+			"mileage":                appraisal.Mileage,
+			"category":               appraisal.Category,
+			"offered_value_minor":    appraisal.OfferedValue.Minor,
+			"offered_value_currency": appraisal.OfferedValue.Currency,
+			"valid_until":            appraisal.ValidUntil,
+			"notes":                  appraisal.Notes,
+			"declined_at":            nil,
+			"decline_reason":         "",
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loadTradeIn(database.DB, id)
+}
+
+// Accept records that the customer accepted the offered value. The vehicle
+// is taken into stock in intake status, priced at that value until it is
+// prepared for sale; a vehicle with the same VIN that was sold or deleted
+// earlier is brought back rather than duplicated. When saleID is given the
+// value is credited to that sale.
+func (s *TradeInService) Accept(actor Actor, id uint, saleID *uint) (*models.TradeIn, error) {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		tradeIn, err := lockTradeIn(tx, id)
+		if err != nil {
+			return err
+		}
+		if tradeIn.Status == models.TradeInStatusAppraised && tradeIn.ValidUntil != nil && tradeIn.ValidUntil.Before(time.Now()) {
+			return invalidState("The appraisal has expired; reappraise the vehicle")
+		}
+		if err := TradeInStates.Fire(tx, actor, tradeIn, tradeIn.Status, models.TradeInStatusAccepted); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{
+			"status":      models.TradeInStatusAccepted,
+			"accepted_at": now,
+		}
+		// the sale is locked before the vehicle
+		if saleID != nil {
+			if err := creditTradeIn(tx, tradeIn, *saleID); err != nil {
+				return err
+			}
+			updates["sale_id"] = *saleID
+			updates["applied_at"] = now
+		}
+		vehicle, err := takeIntoStock(tx, tradeIn)
+		if err != nil {
+			return err
+		}
+		updates["vehicle_id"] = vehicle.ID
+		return updateVersioned(tx, &models.TradeIn{}, tradeIn.ID, tradeIn.Version, updates)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loadTradeIn(database.DB, id)
+}
+
+// Decline records that the customer turned the offered value down.
+func (s *TradeInService) Decline(actor Actor, id uint, reason string) (*models.TradeIn, error) {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		tradeIn, err := lockTradeIn(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := TradeInStates.Fire(tx, actor, tradeIn, tradeIn.Status, models.TradeInStatusDeclined); err != nil {
+			return err
+		}
+		return updateVersioned(tx, &models.TradeIn{}, tradeIn.ID, tradeIn.Version, map[string]interface{}{
+			"status":         models.TradeInStatusDeclined,
+			"declined_at":    time.Now(),
+			"decline_reason": reason,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loadTradeIn(database.DB, id)
+}
+
+// Apply credits an accepted trade-in to one of the customer's pending or
+// approved sales. A trade-in credited to a sale that was later canceled may
+// be credited to another.
+func (s *TradeInService) Apply(actor Actor, id, saleID uint) (*models.TradeIn, error) {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		tradeIn, err := lockTradeIn(tx, id)
+		if err != nil {
+			return err
+		}
+		if tradeIn.Status != models.TradeInStatusAccepted {
+			return invalidState("Only accepted trade-ins can be credited to a sale")
+		}
+		if tradeIn.SaleID != nil {
+			var credited models.Sale
+			if err := tx.Unscoped().First(&credited, *tradeIn.SaleID).Error; err != nil {
+				return err
+			}
+			if credited.Status != models.SaleStatusCanceled && !credited.DeletedAt.Valid {
+				return conflict("Trade-in is already credited to a sale", map[string]interface{}{
+					"sale_id": credited.ID,
+				})
+			}
+		}
+
+		if err := creditTradeIn(tx, tradeIn, saleID); err != nil {
+			return err
+		}
+		return updateVersioned(tx, &models.TradeIn{}, tradeIn.ID, tradeIn.Version, map[string]interface{}{
+			"sale_id":    saleID,
+			"applied_at": time.Now(),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loadTradeIn(database.DB, id)
+}
+
+// creditTradeIn deducts a trade-in's value from a sale as a trade-in line.
+// The credit is not a discount: the sale's taxes stay as they were. The
+// sale completes if the credit settles its balance.
+func creditTradeIn(tx *gorm.DB, tradeIn *models.TradeIn, saleID uint) error {
+	sale, err := lockSale(tx, saleID)
+	if err != nil {
+		return err
+	}
+	if !sale.Status.IsActive() {
+		return invalidState("Trade-ins can only be credited to pending or approved sales")
+	}
+	if sale.CustomerID != tradeIn.CustomerID {
+		return invalidState("The trade-in belongs to another customer")
+	}
+
+	var lines []models.SaleLine
+	if err := tx.Where("sale_id = ?", sale.ID).Order("position").Find(&lines).Error; err != nil {
+		return err
+	}
+	pricing := models.Pricing{
+		AgreedPrice: sale.AgreedPrice,
+		Lines:       lineItems(lines),
+		TradeIn:     orZero(sale.TradeIn, sale.SalePrice.Currency),
+		Total:       sale.SalePrice,
+	}
+	err = tax.TradeIn(&pricing, "Trade-in: "+tradeInDescription(tradeIn), tradeIn.OfferedValue)
+	switch {
+	case errors.Is(err, tax.ErrTradeInTooHigh):
+		return invalidState("Trade-in value cannot exceed the sale total")
+	case err != nil:
+		return invalidState(err.Error())
+	}
+
+	balance, err := saleBalance(tx, sale)
+	if err != nil {
+		return err
+	}
+	if pricing.Total.Minor < balance.Paid.Minor {
+		return invalidState("Trade-in value cannot take the sale price below the amount already paid")
+	}
+	if err := setSaleLines(tx, sale.ID, pricing); err != nil {
+		return err
+	}
+	if err := updateVersioned(tx, &models.Sale{}, sale.ID, sale.Version, map[string]interface{}{
+		"sale_price_minor":  pricing.Total.Minor,
+		"trade_in_minor":    pricing.TradeIn.Minor,
+		"trade_in_currency": pricing.TradeIn.Currency,
+	}); err != nil {
+		return err
+	}
+	return settleSale(tx, sale.ID)
+}
+
+// takeIntoStock adds an accepted trade-in's vehicle to inventory in intake
+// status, with the appraisal photos as its images.
+func takeIntoStock(tx *gorm.DB, tradeIn *models.TradeIn) (*models.Vehicle, error) {
+	now := time.Now()
+	vehicle := models.Vehicle{
+		Make:         tradeIn.Make,
+		Model:        tradeIn.Model,
+		Year:         tradeIn.Year,
+		Color:        tradeIn.Color,
+		VIN:          tradeIn.VIN,
+		LicensePlate: tradeIn.LicensePlate,
+		Price:        tradeIn.OfferedValue,
+		Mileage:      tradeIn.Mileage,
+		Status:       models.VehicleStatusIntake,
+		Description:  fmt.Sprintf("Taken in trade (trade-in #%d)", tradeIn.ID),
+		Category:     tradeIn.Category,
+		// the dealership pays for the vehicle with the credit it gives
+		AcquiredAt:        now,
+		StockedAt:         now,
+		PurchaseCost:      tradeIn.OfferedValue,
+		AcquisitionSource: models.AcquisitionTradeIn,
+	}
+
+	var existing models.Vehicle
+	err := forUpdate(tx.Unscoped()).Where("vin = ?", tradeIn.VIN).First(&existing).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if err := tx.Create(&vehicle).Error; err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	case existing.DeletedAt.Valid || existing.Status == models.VehicleStatusSold:
+		// a vehicle sold or removed earlier comes back into stock
+		err := tx.Unscoped().Model(&models.Vehicle{}).Where("id = ?", existing.ID).Updates(map[string]interface{}{
+			"deleted_at":             nil,
+			"make":                   vehicle.Make,
+			"model":                  vehicle.Model,
+			"year":                   vehicle.Year,
+			"color":                  vehicle.Color,
+			"license_plate":          vehicle.LicensePlate,
+			"price_minor":            vehicle.Price.Minor,
+			"price_currency":         vehicle.Price.Currency,
+			"mileage":                vehicle.Mileage,
+			"status":                 vehicle.Status,
+			"description":            vehicle.Description,
+			"category":               vehicle.Category,
+			"acquired_at":            vehicle.AcquiredAt,
+			"stocked_at":             vehicle.StockedAt,
+			"purchase_cost_minor":    vehicle.PurchaseCost.Minor,
+			"purchase_cost_currency": vehicle.PurchaseCost.Currency,
+			"acquisition_source":     vehicle.AcquisitionSource,
+			"version":                gorm.Expr("version + 1"),
+		}).Error
+		if err != nil {
+			return nil, err
+		}
+		vehicle.ID = existing.ID
+	default:
+		return nil, conflict("A vehicle with this VIN is already in inventory", map[string]interface{}{
+			"vehicle_id": existing.ID,
+		})
+	}
+
+	var photos []models.TradeInPhoto
+	if err := tx.Where("trade_in_id = ?", tradeIn.ID).Order("id").Find(&photos).Error; err != nil {
+		return nil, err
+	}
+	var hasPrimary int64
+	if err := tx.Model(&models.VehicleImage{}).Where("vehicle_id = ? AND is_primary", vehicle.ID).Count(&hasPrimary).Error; err != nil {
+		return nil, err
+	}
+	for i, photo := range photos {
+		image := models.VehicleImage{VehicleID: vehicle.ID, URL: photo.URL, IsPrimary: i == 0 && hasPrimary == 0}
+		if err := tx.Create(&image).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &vehicle, nil
+}
+
+// tradeInDescription names a trade-in vehicle as it appears on the sale,
+// e.g. "2015 Toyota Avanza (B 1234 XY)".
+func tradeInDescription(tradeIn *models.TradeIn) string {
+	description := fmt.Sprintf("%d %s %s", tradeIn.Year, tradeIn.Make, tradeIn.Model)
+	if tradeIn.LicensePlate != "" {
+		return description + " (" + tradeIn.LicensePlate + ")"
+	}
+	return description + " (VIN " + tradeIn.VIN + ")"
+}
+
+func (input AppraisalInput) apply(tradeIn *models.TradeIn) error {
+	if input.ValidUntil != nil && !input.ValidUntil.After(time.Now()) {
+		return invalidState("valid_until must be in the future")
+	}
+	tradeIn.CustomerID = input.CustomerID
+	tradeIn.Make = input.Make
+	tradeIn.Model = input.Model
+	tradeIn.Year = input.Year
+	tradeIn.Color = input.Color
+	tradeIn.VIN = input.VIN
+	tradeIn.LicensePlate = input.LicensePlate
+	tradeIn.Mileage = input.Mileage
+	tradeIn.Category = input.Category
+	tradeIn.OfferedValue = input.OfferedValue
+	tradeIn.ValidUntil = input.ValidUntil
+	tradeIn.Notes = input.Notes
+	for _, check := range input.Checklist {
+		tradeIn.Checklist = append(tradeIn.Checklist, models.TradeInCheck{
+			Item:      check.Item,
+			Condition: check.Condition,
+			Notes:     check.Notes,
+		})
+	}
+	for _, photo := range input.Photos {
+		tradeIn.Photos = append(tradeIn.Photos, models.TradeInPhoto{URL: photo.URL, Caption: photo.Caption})
+	}
+	return nil
+}
+
+func loadTradeIn(tx *gorm.DB, id uint) (*models.TradeIn, error) {
+	var tradeIn models.TradeIn
+	err := tx.
+		Preload("Customer").
+		Preload("Appraiser").
+		Preload("Vehicle").
+		Preload("Checklist", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Photos", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		First(&tradeIn, id).Error
+	if err != nil {
+		return nil, notFoundOr(err, "Trade-in not found")
+	}
+	return &tradeIn, nil
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TransactionService implements payment flows that must update the
+// transaction, its sale and the sold vehicle atomically.
+type TransactionService struct{}
+
+func NewTransactionService() *TransactionService {
+	return &TransactionService{}
+}
+
+type CreateTransactionInput struct {
+	SaleID        uint
+	Type          models.TransactionType
+	Amount        money.Money
+	PaymentMethod models.PaymentMethod
+	// Tendered is the cash handed over, if more than Amount
+	Tendered      money.Money
+	ProcessedByID uint
+	Notes         string
+}
+
+type UpdateTransactionInput struct {
+	Status         models.TransactionStatus
+	TransactionRef string
+	Notes          string
+}
+
+// CreateTransaction records a pending deposit or payment against a sale. A
+// sale may be paid in several transactions across payment methods, but they
+// may not add up to more than its outstanding balance; cash beyond it is
+// given back as change. Cash needs an open shift to be recorded against.
+// Financing payments are opened by the financing application instead.
+func (s *TransactionService) CreateTransaction(input CreateTransactionInput) (*models.Transaction, error) {
+	var transaction models.Transaction
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		sale, err := lockSale(tx, input.SaleID)
+		if err != nil {
+			return err
+		}
+
+		kind := input.Type
+		switch {
+		case kind == models.TransactionTypeRefund:
+			return invalidState("Refunds are recorded against the original payment")
+		case input.PaymentMethod == models.PaymentMethodFinancing:
+			return invalidState("Financing payments are opened by accepting a lender's offer on a financing application")
+		case sale.Status == models.SaleStatusPending && kind != models.TransactionTypePayment:
+			kind = models.TransactionTypeDeposit
+		case sale.Status == models.SaleStatusApproved:
+			if kind == "" {
+				kind = models.TransactionTypePayment
+			}
+		case sale.Status == models.SaleStatusPending:
+			return invalidState("Sale must be approved before processing payment; record a deposit instead")
+		default:
+			return invalidState("Payments can only be taken for pending or approved sales")
+		}
+
+		if input.Amount.Currency != sale.SalePrice.Currency {
+			return invalidState("Payment currency must match the sale currency " + string(sale.SalePrice.Currency))
+		}
+
+		balance, err := saleBalance(tx, sale)
+		if err != nil {
+			return err
+		}
+		// money already in flight is not available to new payments
+		available, err := balance.Outstanding.Sub(balance.Pending)
+		if err != nil {
+			return err
+		}
+		if !available.IsPositive() {
+			return conflict("Sale has no outstanding balance", map[string]interface{}{"balance": balance})
+		}
+
+		amount, tendered, change, err := tender(input, available)
+		if err != nil {
+			return err
+		}
+
+		transaction = models.Transaction{
+			SaleID:         input.SaleID,
+			Type:           kind,
+			Amount:         amount,
+			PaymentMethod:  input.PaymentMethod,
+			Tendered:       tendered,
+			Change:         change,
+			Status:         models.TransactionStatusPending,
+			ProcessedByID:  input.ProcessedByID,
+			TransactionRef: "TXN-" + uuid.New().String()[:8],
+			Notes:          input.Notes,
+		}
+		if err := assignShift(tx, &transaction); err != nil {
+			return err
+		}
+		return tx.Create(&transaction).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// tender works out the amount applied to the sale and, for cash, the amount
+// tendered and the change due. Other payment methods must not exceed the
+// available balance.
+func tender(input CreateTransactionInput, available money.Money) (amount, tendered, change money.Money, err error) {
+	currency := available.Currency
+	amount = input.Amount
+	if input.PaymentMethod != models.PaymentMethodCash {
+		if amount.Minor > available.Minor {
+			err = conflict("Payment exceeds the outstanding balance of the sale", map[string]interface{}{
+				"outstanding": available,
+			})
+		}
+		return amount, money.Zero(currency), money.Zero(currency), err
+	}
+
+	tendered = amount
+	if input.Tendered.Minor > tendered.Minor {
+		if input.Tendered.Currency != currency {
+			return amount, tendered, change, invalidState("Tendered currency must match the sale currency " + string(currency))
+		}
+		tendered = input.Tendered
+	}
+	if amount.Minor > available.Minor {
+		amount = available
+	}
+	change, err = tendered.Sub(amount)
+	return amount, tendered, change, err
+}
+
+// UpdateTransaction changes a transaction's status, reference or notes.
+// Status changes go through TransactionStates, so completing a payment may
+// complete its sale; completed payments are final and are refunded with
+// RefundTransaction instead. The status of a refund cannot be changed here.
+// Transactions of a finalized shift cannot be changed.
+func (s *TransactionService) UpdateTransaction(actor Actor, id uint, input UpdateTransactionInput) (*models.Transaction, error) {
+	var transaction *models.Transaction
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		transaction, err = lockTransaction(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if input.Status != "" {
+			if transaction.Type == models.TransactionTypeRefund {
+				return invalidState("The status of a refund follows its payment provider")
+			}
+			if err := TransactionStates.Fire(tx, actor, transaction, transaction.Status, input.Status); err != nil {
+				return err
+			}
+			transaction.Status = input.Status
+		}
+		if input.TransactionRef != "" {
+			transaction.TransactionRef = input.TransactionRef
+		}
+		if input.Notes != "" {
+			transaction.Notes = input.Notes
+		}
+		// checked last to keep the lock order
+		if err := checkShiftUnlocked(tx, transaction); err != nil {
+			return err
+		}
+
+		if err := tx.Save(transaction).Error; err != nil {
+			return err
+		}
+		return issueTransactionDocuments(tx, transaction)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
+
+// transition moves a transaction that must currently be in status from to
+// status to, reporting notInState otherwise.
+func (s *TransactionService) transition(actor Actor, id uint, from, to models.TransactionStatus, notInState string) (*models.Transaction, error) {
+	var transaction *models.Transaction
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		transaction, err = lockTransaction(tx, id)
+		if err != nil {
+			return err
+		}
+		if transaction.Status != from {
+			return invalidState(notInState)
+		}
+
+		if err := TransactionStates.Fire(tx, actor, transaction, from, to); err != nil {
+			return err
+		}
+		transaction.Status = to
+		if err := tx.Save(transaction).Error; err != nil {
+			return err
+		}
+		return issueTransactionDocuments(tx, transaction)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
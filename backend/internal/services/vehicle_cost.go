@@ -0,0 +1,145 @@
+package services
+
+import (
+	"time"
+
+	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+
+	"gorm.io/gorm"
+)
+
+// VehicleCostService records what vehicles cost the dealership: what was
+// paid for them and what was spent reconditioning them. A sale's gross
+// profit is worked out from these costs when it completes, so the costs of
+// a sold vehicle are fixed.
+type VehicleCostService struct{}
+
+func NewVehicleCostService() *VehicleCostService {
+	return &VehicleCostService{}
+}
+
+type AcquisitionInput struct {
+	PurchaseCost money.Money
+	Source       models.AcquisitionSource
+	// AcquiredAt is left alone when nil
+	AcquiredAt *time.Time
+}
+
+type ExpenseInput struct {
+	Category    models.ExpenseCategory
+	Description string
+	Vendor      string
+	InvoiceRef  string
+	Amount      money.Money
+	// IncurredAt defaults to now
+	IncurredAt *time.Time
+}
+
+// Costs reports what a vehicle has cost since it last came into stock, in
+// the currency of its price.
+func (s *VehicleCostService) Costs(vehicleID uint) (*models.VehicleCosts, error) {
+	var vehicle models.Vehicle
+	if err := database.DB.First(&vehicle, vehicleID).Error; err != nil {
+		return nil, notFoundOr(err, "Vehicle not found")
+	}
+	return vehicleCosts(database.DB, &vehicle, vehicle.Price.Currency)
+}
+
+// SetAcquisition records what the dealership paid for a vehicle and where
+// it came from.
+func (s *VehicleCostService) SetAcquisition(vehicleID uint, input AcquisitionInput) (*models.VehicleCosts, error) {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		vehicle, err := lockUnsoldVehicle(tx, vehicleID)
+		if err != nil {
+			return err
+		}
+		if err := checkCostCurrency(vehicle, input.PurchaseCost); err != nil {
+			return err
+		}
+		updates := map[string]interface{}{
+			"purchase_cost_minor":    input.PurchaseCost.Minor,
+			"purchase_cost_currency": input.PurchaseCost.Currency,
+			"acquisition_source":     input.Source,
+		}
+		if input.AcquiredAt != nil {
+			updates["acquired_at"] = *input.AcquiredAt
+		}
+		return updateVersioned(tx, &models.Vehicle{}, vehicle.ID, vehicle.Version, updates)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Costs(vehicleID)
+}
+
+// AddExpense records a reconditioning expense against a vehicle.
+func (s *VehicleCostService) AddExpense(actor Actor, vehicleID uint, input ExpenseInput) (*models.ReconditioningExpense, error) {
+	var expense models.ReconditioningExpense
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		vehicle, err := lockUnsoldVehicle(tx, vehicleID)
+		if err != nil {
+			return err
+		}
+		if err := checkCostCurrency(vehicle, input.Amount); err != nil {
+			return err
+		}
+		expense = models.ReconditioningExpense{
+			VehicleID:    vehicle.ID,
+			RecordedByID: actor.UserID,
+			Category:     input.Category,
+			Description:  input.Description,
+			Vendor:       input.Vendor,
+			InvoiceRef:   input.InvoiceRef,
+			Amount:       input.Amount,
+			IncurredAt:   time.Now(),
+		}
+		if input.IncurredAt != nil {
+			expense.IncurredAt = *input.IncurredAt
+		}
+		return tx.Create(&expense).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &expense, nil
+}
+
+// DeleteExpense removes an expense recorded in error.
+func (s *VehicleCostService) DeleteExpense(vehicleID, expenseID uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		vehicle, err := lockUnsoldVehicle(tx, vehicleID)
+		if err != nil {
+			return err
+		}
+		result := tx.Where("vehicle_id = ?", vehicle.ID).Delete(&models.ReconditioningExpense{}, expenseID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return notFound("Expense not found")
+		}
+		return nil
+	})
+}
+
+// lockUnsoldVehicle locks a vehicle whose costs are about to change. The
+// costs of a sold vehicle went into its sale's gross profit.
+func lockUnsoldVehicle(tx *gorm.DB, vehicleID uint) (*models.Vehicle, error) {
+	vehicle, err := lockVehicle(tx, vehicleID)
+	if err != nil {
+		return nil, err
+	}
+	if vehicle.Status == models.VehicleStatusSold {
+		return nil, invalidState("The costs of a sold vehicle are fixed")
+	}
+	return vehicle, nil
+}
+
+func checkCostCurrency(vehicle *models.Vehicle, amount money.Money) error {
+	if amount.Currency != vehicle.Price.Currency {
+		return invalidState("Costs must be in the vehicle's currency " + string(vehicle.Price.Currency))
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+package services
+
+import (
+	"time"
+
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/permissions"
+	"vehicle-sales-backend/internal/statemachine"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Actor is the authenticated user performing an operation.
+type Actor struct {
+	UserID uint
+	Role   models.UserRole
+}
+
+// Can reports whether the actor's role holds permission.
+func (a Actor) Can(permission string) bool {
+	return permissions.Has(a.Role, permission)
+}
+
+// SaleStates governs Sale.Status. Sales priced at a discount above the
+// limit of whoever priced them await approval, and go ahead once it is
+// approved or they are repriced within the limit. Users with the permission
+// may approve open sales or cancel them. Sales complete when their balance
+// is paid in full, fixing their gross profit and accruing the salesperson's
+// commission; a completed sale can only be canceled by a refund.
+var SaleStates = statemachine.New[models.SaleStatus, *models.Sale]("sale",
+	statemachine.Transition[models.SaleStatus, *models.Sale]{
+		From:   []models.SaleStatus{models.SaleStatusPending, models.SaleStatusApproved},
+		To:     models.SaleStatusAwaitingApproval,
+		System: true,
+	},
+	statemachine.Transition[models.SaleStatus, *models.Sale]{
+		From:   []models.SaleStatus{models.SaleStatusAwaitingApproval},
+		To:     models.SaleStatusPending,
+		System: true,
+	},
+	statemachine.Transition[models.SaleStatus, *models.Sale]{
+		From:       []models.SaleStatus{models.SaleStatusAwaitingApproval},
+		To:         models.SaleStatusCanceled,
+		Permission: permissions.SaleApprove,
+		Effect:     releaseSaleVehicle,
+	},
+	statemachine.Transition[models.SaleStatus, *models.Sale]{
+		From:       []models.SaleStatus{models.SaleStatusPending},
+		To:         models.SaleStatusApproved,
+		Permission: permissions.SaleApprove,
+	},
+	statemachine.Transition[models.SaleStatus, *models.Sale]{
+		From:       []models.SaleStatus{models.SaleStatusPending},
+		To:         models.SaleStatusCanceled,
+		Permission: permissions.SaleUpdate,
+		Effect:     releaseSaleVehicle,
+	},
+	statemachine.Transition[models.SaleStatus, *models.Sale]{
+		From:       []models.SaleStatus{models.SaleStatusApproved},
+		To:         models.SaleStatusCanceled,
+		Permission: permissions.SaleApprove,
+		Effect:     releaseSaleVehicle,
+	},
+	statemachine.Transition[models.SaleStatus, *models.Sale]{
+		From:   []models.SaleStatus{models.SaleStatusApproved},
+		To:     models.SaleStatusCompleted,
+		System: true,
+		Effect: func(tx *gorm.DB, sale *models.Sale) error {
+			now := time.Now()
+			sale.CompletedAt = &now
+			if err := setVehicleStatus(tx, sale.VehicleID, models.VehicleStatusSold); err != nil {
+				return err
+			}
+			if err := recordGrossProfit(tx, sale); err != nil {
+				return err
+			}
+			return accrueCommission(tx, sale)
+		},
+	},
+	statemachine.Transition[models.SaleStatus, *models.Sale]{
+		From:   []models.SaleStatus{models.SaleStatusCompleted},
+		To:     models.SaleStatusCanceled,
+		System: true,
+		Effect: func(tx *gorm.DB, sale *models.Sale) error {
+			sale.CompletedAt = nil
+			return releaseSaleVehicle(tx, sale)
+		},
+	},
+)
+
+// QuoteStates governs Quote.Status. Revising a sent, accepted, declined or
+// expired quote makes it a draft again; open quotes past their validity
+// expire.
+var QuoteStates = statemachine.New[models.QuoteStatus, *models.Quote]("quote",
+	statemachine.Transition[models.QuoteStatus, *models.Quote]{
+		From:       []models.QuoteStatus{models.QuoteStatusDraft},
+		To:         models.QuoteStatusSent,
+		Permission: permissions.QuoteManage,
+	},
+	statemachine.Transition[models.QuoteStatus, *models.Quote]{
+		From:       []models.QuoteStatus{models.QuoteStatusDraft, models.QuoteStatusSent},
+		To:         models.QuoteStatusAccepted,
+		Permission: permissions.QuoteRead,
+	},
+	statemachine.Transition[models.QuoteStatus, *models.Quote]{
+		From:       []models.QuoteStatus{models.QuoteStatusDraft, models.QuoteStatusSent},
+		To:         models.QuoteStatusDeclined,
+		Permission: permissions.QuoteRead,
+	},
+	statemachine.Transition[models.QuoteStatus, *models.Quote]{
+		From:       []models.QuoteStatus{models.QuoteStatusAccepted},
+		To:         models.QuoteStatusConverted,
+		Permission: permissions.SaleCreate,
+	},
+	statemachine.Transition[models.QuoteStatus, *models.Quote]{
+		From:   []models.QuoteStatus{models.QuoteStatusDraft, models.QuoteStatusSent},
+		To:     models.QuoteStatusExpired,
+		System: true,
+	},
+	statemachine.Transition[models.QuoteStatus, *models.Quote]{
+		From:       []models.QuoteStatus{models.QuoteStatusSent, models.QuoteStatusAccepted, models.QuoteStatusDeclined, models.QuoteStatusExpired},
+		To:         models.QuoteStatusDraft,
+		Permission: permissions.QuoteManage,
+	},
+)
+
+// TradeInStates governs TradeIn.Status. A declined trade-in may be
+// reappraised; an accepted one is final.
+var TradeInStates = statemachine.New[models.TradeInStatus, *models.TradeIn]("trade-in",
+	statemachine.Transition[models.TradeInStatus, *models.TradeIn]{
+		From:       []models.TradeInStatus{models.TradeInStatusAppraised},
+		To:         models.TradeInStatusAccepted,
+		Permission: permissions.TradeInManage,
+	},
+	statemachine.Transition[models.TradeInStatus, *models.TradeIn]{
+		From:       []models.TradeInStatus{models.TradeInStatusAppraised},
+		To:         models.TradeInStatusDeclined,
+		Permission: permissions.TradeInManage,
+	},
+	statemachine.Transition[models.TradeInStatus, *models.TradeIn]{
+		From:       []models.TradeInStatus{models.TradeInStatusDeclined},
+		To:         models.TradeInStatusAppraised,
+		Permission: permissions.TradeInManage,
+	},
+)
+
+// TransactionStates governs Transaction.Status. Completing the payment that
+// settles a sale's balance completes the sale. Financing payments complete
+// only once the lender has disbursed them. Completed transactions are final;
+// money is returned with a separate refund transaction.
+var TransactionStates = statemachine.New[models.TransactionStatus, *models.Transaction]("transaction",
+	statemachine.Transition[models.TransactionStatus, *models.Transaction]{
+		From:       []models.TransactionStatus{models.TransactionStatusPending},
+		To:         models.TransactionStatusCompleted,
+		Permission: permissions.TransactionProcess,
+		Effect: func(tx *gorm.DB, transaction *models.Transaction) error {
+			if err := checkDisbursed(tx, transaction); err != nil {
+				return err
+			}
+			now := time.Now()
+			transaction.ProcessedAt = &now
+			return completePayment(tx, transaction)
+		},
+	},
+	statemachine.Transition[models.TransactionStatus, *models.Transaction]{
+		From:       []models.TransactionStatus{models.TransactionStatusPending},
+		To:         models.TransactionStatusFailed,
+		Permission: permissions.TransactionProcess,
+	},
+	statemachine.Transition[models.TransactionStatus, *models.Transaction]{
+		From:       []models.TransactionStatus{models.TransactionStatusFailed},
+		To:         models.TransactionStatusPending,
+		Permission: permissions.TransactionUpdate,
+		// a retry is a new attempt at the provider
+		Effect: func(tx *gorm.DB, transaction *models.Transaction) error {
+			transaction.TransactionRef = "TXN-" + uuid.New().String()[:8]
+			transaction.ProviderRef = ""
+			transaction.ProviderStatus = ""
+			transaction.ProviderMessage = ""
+			return nil
+		},
+	},
+)
+
+// FinancingStates governs FinancingApplication.Status. Accepting a lender's
+// offer approves an application, and recording the lender's disbursement
+// completes it. Canceling an approved application fails its pending
+// financing payment.
+var FinancingStates = statemachine.New[models.FinancingStatus, *models.FinancingApplication]("financing application",
+	statemachine.Transition[models.FinancingStatus, *models.FinancingApplication]{
+		From:       []models.FinancingStatus{models.FinancingStatusSubmitted},
+		To:         models.FinancingStatusApproved,
+		Permission: permissions.FinancingManage,
+	},
+	statemachine.Transition[models.FinancingStatus, *models.FinancingApplication]{
+		From:       []models.FinancingStatus{models.FinancingStatusSubmitted},
+		To:         models.FinancingStatusRejected,
+		Permission: permissions.FinancingManage,
+	},
+	statemachine.Transition[models.FinancingStatus, *models.FinancingApplication]{
+		From:       []models.FinancingStatus{models.FinancingStatusSubmitted, models.FinancingStatusApproved},
+		To:         models.FinancingStatusCanceled,
+		Permission: permissions.FinancingManage,
+		Effect:     failFinancingPayment,
+	},
+	statemachine.Transition[models.FinancingStatus, *models.FinancingApplication]{
+		From:       []models.FinancingStatus{models.FinancingStatusApproved},
+		To:         models.FinancingStatusDisbursed,
+		Permission: permissions.FinancingDisburse,
+	},
+)
+
+// ShiftStates governs Shift.Status. A cashier closes their shift with the
+// drawer count and the Z-report then finalizes it.
+var ShiftStates = statemachine.New[models.ShiftStatus, *models.Shift]("shift",
+	statemachine.Transition[models.ShiftStatus, *models.Shift]{
+		From:       []models.ShiftStatus{models.ShiftStatusOpen},
+		To:         models.ShiftStatusClosed,
+		Permission: permissions.ShiftOperate,
+	},
+	statemachine.Transition[models.ShiftStatus, *models.Shift]{
+		From:       []models.ShiftStatus{models.ShiftStatusClosed},
+		To:         models.ShiftStatusFinalized,
+		Permission: permissions.ShiftFinalize,
+	},
+)
+
+// TestDriveStates governs TestDrive.Status.
+var TestDriveStates = statemachine.New[models.TestDriveStatus, *models.TestDrive]("test drive",
+	statemachine.Transition[models.TestDriveStatus, *models.TestDrive]{
+		From:       []models.TestDriveStatus{models.TestDriveStatusPending},
+		To:         models.TestDriveStatusApproved,
+		Permission: permissions.TestDriveUpdate,
+	},
+	statemachine.Transition[models.TestDriveStatus, *models.TestDrive]{
+		From:       []models.TestDriveStatus{models.TestDriveStatusApproved},
+		To:         models.TestDriveStatusCompleted,
+		Permission: permissions.TestDriveUpdate,
+	},
+	statemachine.Transition[models.TestDriveStatus, *models.TestDrive]{
+		From:       []models.TestDriveStatus{models.TestDriveStatusPending, models.TestDriveStatusApproved},
+		To:         models.TestDriveStatusCanceled,
+		Permission: permissions.TestDriveCancel,
+	},
+)
+
+// LeadStates governs Lead.Status. Assignment moves a new lead to assigned;
+// lost leads may be reopened by contacting them again.
+var LeadStates = statemachine.New[models.LeadStatus, *models.Lead]("lead",
+	statemachine.Transition[models.LeadStatus, *models.Lead]{
+		From:       []models.LeadStatus{models.LeadStatusNew},
+		To:         models.LeadStatusAssigned,
+		Permission: permissions.LeadAssign,
+	},
+	statemachine.Transition[models.LeadStatus, *models.Lead]{
+		From:       []models.LeadStatus{models.LeadStatusNew, models.LeadStatusAssigned, models.LeadStatusLost},
+		To:         models.LeadStatusContacted,
+		Permission: permissions.LeadUpdate,
+	},
+	statemachine.Transition[models.LeadStatus, *models.Lead]{
+		From:       []models.LeadStatus{models.LeadStatusContacted},
+		To:         models.LeadStatusQualified,
+		Permission: permissions.LeadUpdate,
+	},
+	statemachine.Transition[models.LeadStatus, *models.Lead]{
+		From:       []models.LeadStatus{models.LeadStatusQualified},
+		To:         models.LeadStatusConverted,
+		Permission: permissions.LeadUpdate,
+	},
+	statemachine.Transition[models.LeadStatus, *models.Lead]{
+		From:       []models.LeadStatus{models.LeadStatusNew, models.LeadStatusAssigned, models.LeadStatusContacted, models.LeadStatusQualified},
+		To:         models.LeadStatusLost,
+		Permission: permissions.LeadUpdate,
+	},
+)
+
+func releaseSaleVehicle(tx *gorm.DB, sale *models.Sale) error {
+	return setVehicleStatus(tx, sale.VehicleID, models.VehicleStatusAvailable)
+}
+
+// transitionSale moves a sale as a side effect of a payment or refund.
+func transitionSale(tx *gorm.DB, saleID uint, to models.SaleStatus) error {
+	sale, err := lockSale(tx, saleID)
+	if err != nil {
+		return err
+	}
+
+	from := sale.Status
+	if err := SaleStates.Fire(tx, statemachine.System, sale, from, to); err != nil {
+		return err
+	}
+	return updateVersioned(tx, &models.Sale{}, sale.ID, sale.Version, map[string]interface{}{
+		"status":       to,
+		"completed_at": sale.CompletedAt,
+	})
+}
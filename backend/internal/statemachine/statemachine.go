@@ -0,0 +1,146 @@
+// Package statemachine declares the allowed status transitions of an entity,
+// who may perform each one and what happens when it fires.
+package statemachine
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidTransition means the target status cannot be reached from
+	// the current one.
+	ErrInvalidTransition = errors.New("invalid status transition")
+	// ErrNotPermitted means the transition exists but the actor may not
+	// perform it.
+	ErrNotPermitted = errors.New("status transition not permitted")
+)
+
+// Actor is whoever requests a transition. Guards ask it for permissions.
+type Actor interface {
+	Can(permission string) bool
+}
+
+type systemActor struct{}
+
+func (systemActor) Can(string) bool { return true }
+
+// System is the actor used by service flows, e.g. a sale completing because
+// its payment settled. Only System may fire transitions marked System.
+var System Actor = systemActor{}
+
+// Transition is an allowed edge between statuses.
+type Transition[S ~string, E any] struct {
+	From []S
+	To   S
+
+	// Permission the actor must hold; empty allows any caller that reached
+	// the endpoint.
+	Permission string
+
+	// System transitions happen as a consequence of another operation and
+	// can never be requested directly by a client.
+	System bool
+
+	// Effect runs inside the caller's database transaction before the new
+	// status is written. It may modify the entity and other rows.
+	Effect func(tx *gorm.DB, entity E) error
+}
+
+// Machine holds the transitions of one entity type.
+type Machine[S ~string, E any] struct {
+	entity string
+	edges  map[S]map[S]Transition[S, E]
+}
+
+// New builds a machine for the named entity ("sale", "lead", ...).
+func New[S ~string, E any](entity string, transitions ...Transition[S, E]) *Machine[S, E] {
+	m := &Machine[S, E]{entity: entity, edges: map[S]map[S]Transition[S, E]{}}
+	for _, t := range transitions {
+		for _, from := range t.From {
+			if m.edges[from] == nil {
+				m.edges[from] = map[S]Transition[S, E]{}
+			}
+			m.edges[from][t.To] = t
+		}
+	}
+	return m
+}
+
+// Error describes a rejected transition.
+type Error struct {
+	Entity  string
+	From    string
+	To      string
+	Allowed []string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if errors.Is(e.Err, ErrNotPermitted) {
+		return fmt.Sprintf("Not permitted to change %s status from %s to %s", e.Entity, e.From, e.To)
+	}
+	return fmt.Sprintf("Cannot change %s status from %s to %s", e.Entity, e.From, e.To)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Next lists the statuses reachable from the given one, ignoring guards.
+func (m *Machine[S, E]) Next(from S) []S {
+	next := make([]S, 0, len(m.edges[from]))
+	for to := range m.edges[from] {
+		next = append(next, to)
+	}
+	sort.Slice(next, func(i, j int) bool { return next[i] < next[j] })
+	return next
+}
+
+// Check reports whether actor may move an entity from one status to another.
+// Staying in the same status is always allowed.
+func (m *Machine[S, E]) Check(actor Actor, from, to S) error {
+	_, err := m.lookup(actor, from, to)
+	return err
+}
+
+// Fire checks the transition and runs its effect. The caller persists the
+// new status afterwards, in the same transaction.
+func (m *Machine[S, E]) Fire(tx *gorm.DB, actor Actor, entity E, from, to S) error {
+	t, err := m.lookup(actor, from, to)
+	if err != nil || t == nil || t.Effect == nil {
+		return err
+	}
+	return t.Effect(tx, entity)
+}
+
+func (m *Machine[S, E]) lookup(actor Actor, from, to S) (*Transition[S, E], error) {
+	if from == to {
+		return nil, nil
+	}
+
+	t, ok := m.edges[from][to]
+	if !ok {
+		return nil, m.reject(ErrInvalidTransition, from, to)
+	}
+	if t.System && actor != System {
+		return nil, m.reject(ErrInvalidTransition, from, to)
+	}
+	if t.Permission != "" && !actor.Can(t.Permission) {
+		return nil, m.reject(ErrNotPermitted, from, to)
+	}
+	return &t, nil
+}
+
+func (m *Machine[S, E]) reject(err error, from, to S) *Error {
+	allowed := []string{}
+	for _, next := range m.Next(from) {
+		if !m.edges[from][next].System {
+			allowed = append(allowed, string(next))
+		}
+	}
+	return &Error{Entity: m.entity, From: string(from), To: string(to), Allowed: allowed, Err: err}
+}
@@ -0,0 +1,152 @@
+package statemachine
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+type status string
+
+type door struct {
+	status status
+	opened int
+}
+
+// actor holds the listed permissions.
+type actor []string
+
+func (a actor) Can(permission string) bool {
+	for _, p := range a {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+var doors = New[status, *door]("door",
+	Transition[status, *door]{
+		From:   []status{"closed"},
+		To:     "open",
+		Effect: func(tx *gorm.DB, d *door) error { d.opened++; return nil },
+	},
+	Transition[status, *door]{
+		From: []status{"open"},
+		To:   "closed",
+	},
+	Transition[status, *door]{
+		From:       []status{"closed"},
+		To:         "locked",
+		Permission: "door:lock",
+	},
+	Transition[status, *door]{
+		From:       []status{"locked"},
+		To:         "closed",
+		Permission: "door:lock",
+	},
+	Transition[status, *door]{
+		From:   []status{"open", "closed", "locked"},
+		To:     "broken",
+		System: true,
+	},
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		actor    Actor
+		from, to status
+		want     error
+	}{
+		{"open", actor{}, "closed", "open", nil},
+		{"close", actor{}, "open", "closed", nil},
+		{"stay", actor{}, "open", "open", nil},
+		{"stay in an unknown status", actor{}, "ajar", "ajar", nil},
+		{"no edge", actor{}, "open", "locked", ErrInvalidTransition},
+		{"from an unknown status", actor{}, "ajar", "open", ErrInvalidTransition},
+		{"lock with permission", actor{"door:lock"}, "closed", "locked", nil},
+		{"lock without permission", actor{"door:open"}, "closed", "locked", ErrNotPermitted},
+		{"unlock without permission", actor{}, "locked", "closed", ErrNotPermitted},
+		{"system edge requested by a user", actor{"door:lock"}, "open", "broken", ErrInvalidTransition},
+		{"system edge fired by the system", System, "open", "broken", nil},
+		{"system holds every permission", System, "closed", "locked", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := doors.Check(tt.actor, tt.from, tt.to)
+			if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+				t.Fatalf("Check(%s -> %s) = %v, want %v", tt.from, tt.to, err, tt.want)
+			}
+			if err == nil {
+				return
+			}
+			var rejected *Error
+			if !errors.As(err, &rejected) || rejected.Entity != "door" || rejected.From != string(tt.from) || rejected.To != string(tt.to) {
+				t.Errorf("error = %#v", err)
+			}
+		})
+	}
+}
+
+func TestRejectionListsRequestableStatuses(t *testing.T) {
+	err := doors.Check(actor{}, "closed", "broken")
+	var rejected *Error
+	if !errors.As(err, &rejected) {
+		t.Fatalf("err = %v", err)
+	}
+	// broken is reachable, but only by the system
+	if want := []string{"locked", "open"}; !reflect.DeepEqual(rejected.Allowed, want) {
+		t.Errorf("Allowed = %v, want %v", rejected.Allowed, want)
+	}
+	if got := err.Error(); got != "Cannot change door status from closed to broken" {
+		t.Errorf("message = %q", got)
+	}
+	if got := doors.Check(actor{}, "closed", "locked").Error(); got != "Not permitted to change door status from closed to locked" {
+		t.Errorf("message = %q", got)
+	}
+}
+
+func TestNext(t *testing.T) {
+	if got, want := doors.Next("closed"), []status{"broken", "locked", "open"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Next(closed) = %v, want %v", got, want)
+	}
+	if got := doors.Next("broken"); len(got) != 0 {
+		t.Errorf("Next(broken) = %v, want none", got)
+	}
+}
+
+func TestFire(t *testing.T) {
+	d := &door{status: "closed"}
+	if err := doors.Fire(nil, actor{}, d, "closed", "open"); err != nil {
+		t.Fatal(err)
+	}
+	if d.opened != 1 {
+		t.Errorf("effect ran %d times, want once", d.opened)
+	}
+
+	// rejected and effectless transitions run nothing
+	if err := doors.Fire(nil, actor{}, d, "open", "locked"); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("err = %v, want ErrInvalidTransition", err)
+	}
+	if err := doors.Fire(nil, actor{}, d, "closed", "closed"); err != nil {
+		t.Error(err)
+	}
+	if err := doors.Fire(nil, actor{}, d, "open", "closed"); err != nil {
+		t.Error(err)
+	}
+	if d.opened != 1 {
+		t.Errorf("effect ran %d times, want once", d.opened)
+	}
+
+	failing := New[status, *door]("door", Transition[status, *door]{
+		From:   []status{"closed"},
+		To:     "open",
+		Effect: func(*gorm.DB, *door) error { return errors.New("stuck") },
+	})
+	if err := failing.Fire(nil, actor{}, d, "closed", "open"); err == nil || err.Error() != "stuck" {
+		t.Errorf("err = %v, want the effect's error", err)
+	}
+}
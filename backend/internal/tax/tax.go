@@ -0,0 +1,216 @@
+// Package tax itemises the price of a vehicle sale into the vehicle's price
+// before tax, the taxes on it and the fees charged with it, according to the
+// tax rules in force. The services package picks the rules; this package only
+// does the arithmetic.
+package tax
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+)
+
+// ErrPriceTooLow is returned when the agreed price does not cover the fees
+// included in it.
+var ErrPriceTooLow = errors.New("agreed price does not cover the fees included in it")
+
+// ErrTradeInTooHigh is returned when a trade-in allowance exceeds what the
+// customer would pay.
+var ErrTradeInTooHigh = errors.New("trade-in allowance exceeds the total")
+
+// kindOrder lists taxes before fees on a priced sale.
+var kindOrder = map[models.TaxKind]int{
+	models.TaxKindVAT:    0,
+	models.TaxKindLuxury: 1,
+	models.TaxKindFee:    2,
+}
+
+// Price applies rules to the taxable lines of a deal: the vehicle, any
+// discount and add-ons, at the values agreed with the customer. Their sum is
+// the agreed price. Fees included in it are deducted and the inclusive taxes
+// backed out of the rest, leaving the price before tax (DPP), which is the
+// base of every percentage tax. Exclusive taxes and fees are added to the
+// total. Tax lines follow the taxable lines, and fee lines the taxes.
+func Price(items []models.LineItem, rules []models.TaxRule) (models.Pricing, error) {
+	currency := money.DefaultCurrency()
+	if len(items) > 0 {
+		currency = items[0].Amount.Currency
+	}
+	pricing := models.Pricing{
+		AgreedPrice: money.Zero(currency),
+		Taxes:       money.Zero(currency),
+		Fees:        money.Zero(currency),
+		TradeIn:     money.Zero(currency),
+	}
+	for _, item := range items {
+		if item.Amount.Currency != currency {
+			return pricing, fmt.Errorf("%s is in %s but the price is in %s", item.Description, item.Amount.Currency, currency)
+		}
+		item.Base = money.Zero(currency)
+		pricing.AgreedPrice.Minor += item.Amount.Minor
+		pricing.Lines = append(pricing.Lines, item)
+	}
+
+	rules = append([]models.TaxRule(nil), rules...)
+	sort.SliceStable(rules, func(i, j int) bool {
+		if kindOrder[rules[i].Kind] != kindOrder[rules[j].Kind] {
+			return kindOrder[rules[i].Kind] < kindOrder[rules[j].Kind]
+		}
+		return rules[i].Code < rules[j].Code
+	})
+
+	// what is left of the agreed price once the included fees are taken out
+	gross := pricing.AgreedPrice.Minor
+	var inclusiveRate int64
+	for _, rule := range rules {
+		if !rule.Kind.IsPercentage() && rule.Amount.Currency != currency {
+			return pricing, fmt.Errorf("%s is charged in %s but the price is in %s", rule.Name, rule.Amount.Currency, currency)
+		}
+		if !rule.Inclusive {
+			continue
+		}
+		if rule.Kind.IsPercentage() {
+			inclusiveRate += rule.Rate
+		} else {
+			gross -= rule.Amount.Minor
+		}
+	}
+	if gross <= 0 {
+		return pricing, ErrPriceTooLow
+	}
+
+	// back the inclusive taxes out of the gross; the base takes the
+	// rounding so that base and inclusive taxes add up to the gross
+	taxes := make([]int64, len(rules))
+	net := gross
+	estimate := money.Divide(gross*10000, 10000+inclusiveRate)
+	for i, rule := range rules {
+		if rule.Inclusive && rule.Kind.IsPercentage() {
+			taxes[i] = percent(estimate, rule.Rate)
+			net -= taxes[i]
+		}
+	}
+	base := money.New(net, currency)
+
+	total := pricing.AgreedPrice.Minor
+	for i, rule := range rules {
+		id := rule.ID
+		line := models.LineItem{
+			Code:        rule.Code,
+			Description: rule.Name,
+			Inclusive:   rule.Inclusive,
+			Base:        money.Zero(currency),
+			TaxRuleID:   &id,
+		}
+		if rule.Kind.IsPercentage() {
+			amount := taxes[i]
+			if !rule.Inclusive {
+				amount = percent(net, rule.Rate)
+			}
+			line.Type = models.LineTypeTax
+			line.Rate = rule.Rate
+			line.Base = base
+			line.Amount = money.New(amount, currency)
+			pricing.Taxes.Minor += amount
+		} else {
+			line.Type = models.LineTypeFee
+			line.Amount = rule.Amount
+			pricing.Fees.Minor += rule.Amount.Minor
+		}
+		if line.AddsToTotal() {
+			total += line.Amount.Minor
+		}
+		pricing.Lines = append(pricing.Lines, line)
+	}
+	pricing.Total = money.New(total, currency)
+	number(pricing.Lines)
+	return pricing, nil
+}
+
+// TradeIn deducts a trade-in allowance from a priced deal as a line of its
+// own. The allowance is a credit, not a discount: the taxes stay as they
+// were.
+func TradeIn(pricing *models.Pricing, description string, allowance money.Money) error {
+	if !allowance.IsPositive() {
+		return nil
+	}
+	if allowance.Currency != pricing.Total.Currency {
+		return fmt.Errorf("%s is in %s but the price is in %s", description, allowance.Currency, pricing.Total.Currency)
+	}
+	if allowance.Minor > pricing.Total.Minor {
+		return ErrTradeInTooHigh
+	}
+	pricing.Lines = append(pricing.Lines, models.LineItem{
+		Type:        models.LineTypeTradeIn,
+		Description: description,
+		Base:        money.Zero(allowance.Currency),
+		Amount:      allowance.Neg(),
+	})
+	pricing.TradeIn.Minor += allowance.Minor
+	pricing.Total.Minor -= allowance.Minor
+	number(pricing.Lines)
+	return nil
+}
+
+// Allocate spreads part of what was paid for a deal, such as a refund, over
+// its lines in proportion to their amounts. Trade-in lines are left out: the
+// allowance was not paid in money. Inclusive lines are scaled with the rest,
+// and the vehicle line takes the rounding difference, so the lines that add
+// to the total add up to amount.
+func Allocate(lines []models.LineItem, amount money.Money) []models.LineItem {
+	allocated := []models.LineItem{}
+	var paid int64
+	for _, line := range lines {
+		if line.Type == models.LineTypeTradeIn {
+			continue
+		}
+		if line.AddsToTotal() {
+			paid += line.Amount.Minor
+		}
+		allocated = append(allocated, line)
+	}
+	if paid <= 0 || len(allocated) == 0 {
+		return allocated
+	}
+
+	vehicle := -1
+	remaining := amount.Minor
+	for i, line := range allocated {
+		if line.Type == models.LineTypeVehicle && vehicle < 0 {
+			vehicle = i
+			continue
+		}
+		allocated[i].Amount = money.New(money.Scale(line.Amount.Minor, amount.Minor, paid), amount.Currency)
+		allocated[i].Base = money.New(money.Scale(line.Base.Minor, amount.Minor, paid), amount.Currency)
+		if line.AddsToTotal() {
+			remaining -= allocated[i].Amount.Minor
+		}
+	}
+	if vehicle < 0 {
+		// without a vehicle line the first line that counts takes the rounding
+		for i, line := range allocated {
+			if line.AddsToTotal() {
+				vehicle = i
+				remaining += allocated[i].Amount.Minor
+				break
+			}
+		}
+	}
+	allocated[vehicle].Amount = money.New(remaining, amount.Currency)
+	return allocated
+}
+
+// number sets the position of every line from its order.
+func number(lines []models.LineItem) {
+	for i := range lines {
+		lines[i].Position = i + 1
+	}
+}
+
+// percent returns rate basis points of amount, rounded half away from zero.
+func percent(amount, rate int64) int64 {
+	return money.Divide(amount*rate, 10000)
+}
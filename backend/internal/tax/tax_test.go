@@ -0,0 +1,295 @@
+package tax
+
+import (
+	"errors"
+	"testing"
+
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+)
+
+func idr(minor int64) money.Money { return money.New(minor, money.IDR) }
+
+func vehicle(minor int64) models.LineItem {
+	return models.LineItem{Type: models.LineTypeVehicle, Description: "Toyota Avanza", Amount: idr(minor)}
+}
+
+func discount(minor int64) models.LineItem {
+	return models.LineItem{Type: models.LineTypeDiscount, Description: "Discount", Amount: idr(-minor)}
+}
+
+var (
+	ppn = models.TaxRule{ID: 1, Code: "PPN", Name: "PPN 11%", Kind: models.TaxKindVAT, Rate: 1100}
+	lux = models.TaxRule{ID: 2, Code: "PPNBM", Name: "PPnBM 10%", Kind: models.TaxKindLuxury, Rate: 1000}
+	bbn = models.TaxRule{ID: 3, Code: "BBN", Name: "BBN", Kind: models.TaxKindFee, Amount: idr(500_000)}
+)
+
+func inclusive(rule models.TaxRule) models.TaxRule {
+	rule.Inclusive = true
+	return rule
+}
+
+// line is what a priced line is checked against.
+type line struct {
+	typ       models.LineType
+	code      string
+	base      int64
+	amount    int64
+	inclusive bool
+}
+
+func TestPrice(t *testing.T) {
+	tests := []struct {
+		name        string
+		items       []models.LineItem
+		rules       []models.TaxRule
+		taxes, fees int64
+		total       int64
+		lines       []line
+	}{
+		{
+			name:  "no rules",
+			items: []models.LineItem{vehicle(10_000_000)},
+			total: 10_000_000,
+			lines: []line{{models.LineTypeVehicle, "", 0, 10_000_000, false}},
+		},
+		{
+			name:  "inclusive VAT",
+			items: []models.LineItem{vehicle(11_100_000)},
+			rules: []models.TaxRule{inclusive(ppn)},
+			taxes: 1_100_000,
+			total: 11_100_000,
+			lines: []line{
+				{models.LineTypeVehicle, "", 0, 11_100_000, false},
+				{models.LineTypeTax, "PPN", 10_000_000, 1_100_000, true},
+			},
+		},
+		{
+			// 10,000,000 / 1.11 = 9,009,009.009: the base takes the rounding
+			name:  "inclusive VAT rounded",
+			items: []models.LineItem{vehicle(10_000_000)},
+			rules: []models.TaxRule{inclusive(ppn)},
+			taxes: 990_991,
+			total: 10_000_000,
+			lines: []line{
+				{models.LineTypeVehicle, "", 0, 10_000_000, false},
+				{models.LineTypeTax, "PPN", 9_009_009, 990_991, true},
+			},
+		},
+		{
+			name:  "exclusive VAT",
+			items: []models.LineItem{vehicle(10_000_000)},
+			rules: []models.TaxRule{ppn},
+			taxes: 1_100_000,
+			total: 11_100_000,
+			lines: []line{
+				{models.LineTypeVehicle, "", 0, 10_000_000, false},
+				{models.LineTypeTax, "PPN", 10_000_000, 1_100_000, false},
+			},
+		},
+		{
+			// the fee comes out of the agreed price before VAT is charged
+			name:  "exclusive VAT on a discounted price with a fee included",
+			items: []models.LineItem{vehicle(10_000_000), discount(1_000_000)},
+			rules: []models.TaxRule{inclusive(bbn), ppn},
+			taxes: 935_000,
+			fees:  500_000,
+			total: 9_935_000,
+			lines: []line{
+				{models.LineTypeVehicle, "", 0, 10_000_000, false},
+				{models.LineTypeDiscount, "", 0, -1_000_000, false},
+				{models.LineTypeTax, "PPN", 8_500_000, 935_000, false},
+				{models.LineTypeFee, "BBN", 0, 500_000, true},
+			},
+		},
+		{
+			// taxes are listed before fees, VAT before luxury tax
+			name:  "inclusive VAT and luxury tax with a fee on top",
+			items: []models.LineItem{vehicle(12_100_000)},
+			rules: []models.TaxRule{bbn, inclusive(lux), inclusive(ppn)},
+			taxes: 2_100_000,
+			fees:  500_000,
+			total: 12_600_000,
+			lines: []line{
+				{models.LineTypeVehicle, "", 0, 12_100_000, false},
+				{models.LineTypeTax, "PPN", 10_000_000, 1_100_000, true},
+				{models.LineTypeTax, "PPNBM", 10_000_000, 1_000_000, true},
+				{models.LineTypeFee, "BBN", 0, 500_000, false},
+			},
+		},
+		{
+			// 10,000,001 / 1.21 = 8,264,463.6
+			name:  "inclusive taxes rounded",
+			items: []models.LineItem{vehicle(10_000_001)},
+			rules: []models.TaxRule{inclusive(ppn), inclusive(lux)},
+			taxes: 1_735_537,
+			total: 10_000_001,
+			lines: []line{
+				{models.LineTypeVehicle, "", 0, 10_000_001, false},
+				{models.LineTypeTax, "PPN", 8_264_464, 909_091, true},
+				{models.LineTypeTax, "PPNBM", 8_264_464, 826_446, true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pricing, err := Price(tt.items, tt.rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pricing.Taxes.Minor != tt.taxes || pricing.Fees.Minor != tt.fees || pricing.Total.Minor != tt.total {
+				t.Errorf("taxes, fees, total = %d, %d, %d, want %d, %d, %d",
+					pricing.Taxes.Minor, pricing.Fees.Minor, pricing.Total.Minor, tt.taxes, tt.fees, tt.total)
+			}
+			if len(pricing.Lines) != len(tt.lines) {
+				t.Fatalf("%d lines, want %d: %+v", len(pricing.Lines), len(tt.lines), pricing.Lines)
+			}
+			var total int64
+			for i, got := range pricing.Lines {
+				want := tt.lines[i]
+				if got.Position != i+1 || got.Type != want.typ || got.Code != want.code ||
+					got.Base.Minor != want.base || got.Amount.Minor != want.amount || got.Inclusive != want.inclusive {
+					t.Errorf("line %d = %+v, want %+v", i+1, got, want)
+				}
+				if got.AddsToTotal() {
+					total += got.Amount.Minor
+				}
+			}
+			if total != pricing.Total.Minor {
+				t.Errorf("lines add up to %d, total is %d", total, pricing.Total.Minor)
+			}
+		})
+	}
+}
+
+func TestPriceErrors(t *testing.T) {
+	usdFee := bbn
+	usdFee.Amount = money.New(100, money.USD)
+	tests := []struct {
+		name  string
+		items []models.LineItem
+		rules []models.TaxRule
+		err   error
+	}{
+		{"fee swallows the price", []models.LineItem{vehicle(500_000)}, []models.TaxRule{inclusive(bbn)}, ErrPriceTooLow},
+		{"discount swallows the price", []models.LineItem{vehicle(500_000), discount(500_000)}, []models.TaxRule{ppn}, ErrPriceTooLow},
+		{"no lines", nil, nil, ErrPriceTooLow},
+		{"lines in two currencies", []models.LineItem{vehicle(500_000), {Description: "Mats", Amount: money.New(100, money.USD)}}, nil, nil},
+		{"fee in another currency", []models.LineItem{vehicle(500_000)}, []models.TaxRule{usdFee}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Price(tt.items, tt.rules)
+			if err == nil || (tt.err != nil && !errors.Is(err, tt.err)) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestTradeIn(t *testing.T) {
+	pricing, err := Price([]models.LineItem{vehicle(10_000_000)}, []models.TaxRule{ppn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := TradeIn(&pricing, "Honda Jazz", idr(3_000_000)); err != nil {
+		t.Fatal(err)
+	}
+	if pricing.Total.Minor != 8_100_000 || pricing.TradeIn.Minor != 3_000_000 || pricing.Taxes.Minor != 1_100_000 {
+		t.Errorf("total, trade-in, taxes = %v, %v, %v", pricing.Total, pricing.TradeIn, pricing.Taxes)
+	}
+	last := pricing.Lines[len(pricing.Lines)-1]
+	if last.Type != models.LineTypeTradeIn || last.Amount.Minor != -3_000_000 || last.Position != 3 {
+		t.Errorf("trade-in line = %+v", last)
+	}
+
+	if err := TradeIn(&pricing, "Honda Jazz", idr(8_100_001)); !errors.Is(err, ErrTradeInTooHigh) {
+		t.Errorf("err = %v, want ErrTradeInTooHigh", err)
+	}
+	if err := TradeIn(&pricing, "Honda Jazz", money.New(1, money.USD)); err == nil {
+		t.Error("a trade-in in another currency was accepted")
+	}
+	if err := TradeIn(&pricing, "Nothing", idr(0)); err != nil || len(pricing.Lines) != 3 {
+		t.Errorf("a zero allowance added a line: %v", err)
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	pricing, err := Price([]models.LineItem{vehicle(10_000_000), discount(1_000_000)}, []models.TaxRule{inclusive(bbn), ppn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := TradeIn(&pricing, "Honda Jazz", idr(2_000_000)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		amount int64
+		lines  []line
+	}{
+		{
+			// paid 9,935,000 in money; the trade-in is left out
+			name:   "all of it",
+			amount: 9_935_000,
+			lines: []line{
+				{models.LineTypeVehicle, "", 0, 10_000_000, false},
+				{models.LineTypeDiscount, "", 0, -1_000_000, false},
+				{models.LineTypeTax, "PPN", 8_500_000, 935_000, false},
+				{models.LineTypeFee, "BBN", 0, 500_000, true},
+			},
+		},
+		{
+			// the vehicle line takes what the other lines round away
+			name:   "an uneven part",
+			amount: 1_000_001,
+			lines: []line{
+				{models.LineTypeVehicle, "", 0, 1_006_543, false},
+				{models.LineTypeDiscount, "", 0, -100_654, false},
+				{models.LineTypeTax, "PPN", 855_562, 94_112, false},
+				{models.LineTypeFee, "BBN", 0, 50_327, true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allocated := Allocate(pricing.Lines, idr(tt.amount))
+			if len(allocated) != len(tt.lines) {
+				t.Fatalf("%d lines, want %d: %+v", len(allocated), len(tt.lines), allocated)
+			}
+			var total int64
+			for i, got := range allocated {
+				want := tt.lines[i]
+				if got.Type != want.typ || got.Code != want.code || got.Base.Minor != want.base || got.Amount.Minor != want.amount {
+					t.Errorf("line %d = %+v, want %+v", i+1, got, want)
+				}
+				if got.AddsToTotal() {
+					total += got.Amount.Minor
+				}
+			}
+			if total != tt.amount {
+				t.Errorf("lines add up to %d, want %d", total, tt.amount)
+			}
+		})
+	}
+
+	// the lines priced are left as they were
+	if pricing.Lines[0].Amount.Minor != 10_000_000 {
+		t.Errorf("Allocate changed the vehicle line to %v", pricing.Lines[0].Amount)
+	}
+}
+
+func TestAllocateWithoutVehicle(t *testing.T) {
+	lines := []models.LineItem{
+		{Type: models.LineTypeAddOn, Amount: idr(1_000_000), Base: idr(0)},
+		{Type: models.LineTypeAddOn, Amount: idr(2_000_000), Base: idr(0)},
+	}
+	allocated := Allocate(lines, idr(1_000_000))
+	// 333,333.33 and 666,666.67: the first line takes the rounding
+	if allocated[0].Amount.Minor != 333_333 || allocated[1].Amount.Minor != 666_667 {
+		t.Errorf("allocated %v and %v", allocated[0].Amount, allocated[1].Amount)
+	}
+	if got := Allocate(nil, idr(1_000_000)); len(got) != 0 {
+		t.Errorf("allocated %+v over no lines", got)
+	}
+}
@@ -0,0 +1,189 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"vehicle-sales-backend/internal/money"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+// vinPattern matches a 17 character ISO 3779 VIN, which never contains the
+// letters I, O or Q.
+var vinPattern = regexp.MustCompile(`^[A-HJ-NPR-Z0-9]{17}$`)
+
+// slugPattern matches lower snake_case identifiers such as role names.
+var slugPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+var validate = newValidator()
+
+// Enum is implemented by the typed string constants in the models package
+// (VehicleStatus, PaymentMethod, ...) and is checked by the "enum" tag.
+type Enum interface {
+	IsValid() bool
+}
+
+// FieldError describes a single failed rule on a request field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// Error is returned by BindBody when the request body fails validation and
+// is rendered by the application error handler as a 422 response.
+type Error struct {
+	Fields []FieldError `json:"errors"`
+}
+
+func (e *Error) Error() string {
+	return "validation failed"
+}
+
+// Response is the uniform 422 body returned for validation failures.
+func (e *Error) Response() fiber.Map {
+	return fiber.Map{
+		"status":  "error",
+		"message": "Validation failed",
+		"errors":  e.Fields,
+	}
+}
+
+// BindBody parses the request body into out and evaluates its `validate`
+// struct tags. Handlers should return the error unchanged.
+func BindBody(c *fiber.Ctx, out interface{}) error {
+	if err := c.BodyParser(out); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	return Struct(out)
+}
+
+// Struct validates an already populated struct.
+func Struct(s interface{}) error {
+	err := validate.Struct(s)
+	if err == nil {
+		return nil
+	}
+
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return err
+	}
+
+	fields := make([]FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		fields = append(fields, FieldError{
+			Field:   fieldPath(fe.Namespace()),
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: message(fe),
+		})
+	}
+	return &Error{Fields: fields}
+}
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// Report fields by their JSON name so clients can map errors to inputs.
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return f.Name
+		}
+		return name
+	})
+
+	// Validate money by its minor units, so "required,gt=0" means a positive
+	// amount.
+	v.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		if m, ok := field.Interface().(money.Money); ok {
+			return m.Minor
+		}
+		return nil
+	}, money.Money{})
+
+	v.RegisterValidation("vin", func(fl validator.FieldLevel) bool {
+		return vinPattern.MatchString(strings.ToUpper(fl.Field().String()))
+	})
+
+	v.RegisterValidation("slug", func(fl validator.FieldLevel) bool {
+		return slugPattern.MatchString(fl.Field().String())
+	})
+
+	// A vehicle's model year can run a year ahead of the calendar, never more.
+	v.RegisterValidation("year_max", func(fl validator.FieldLevel) bool {
+		return fl.Field().Int() <= int64(maxModelYear())
+	})
+
+	v.RegisterValidation("enum", func(fl validator.FieldLevel) bool {
+		if e, ok := fl.Field().Interface().(Enum); ok {
+			return e.IsValid()
+		}
+		return false
+	})
+
+	return v
+}
+
+// maxModelYear is the latest model year a vehicle can have today.
+func maxModelYear() int {
+	return time.Now().Year() + 1
+}
+
+// fieldPath drops the root struct name from a validator namespace, so
+// "CreateSaleRequest.sale_price" becomes "sale_price".
+func fieldPath(namespace string) string {
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+func message(fe validator.FieldError) string {
+	field := fieldPath(fe.Namespace())
+
+	switch fe.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", field)
+	case "min":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("%s must be at least %s characters", field, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at least %s", field, fe.Param())
+	case "max":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("%s must be at most %s characters", field, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at most %s", field, fe.Param())
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", field, fe.Param())
+	case "year_max":
+		return fmt.Sprintf("%s must be at most %d", field, maxModelYear())
+	case "email":
+		return fmt.Sprintf("%s must be a valid email address", field)
+	case "e164":
+		return fmt.Sprintf("%s must be an E.164 phone number, e.g. +6281234567890", field)
+	case "vin":
+		return fmt.Sprintf("%s must be a 17 character VIN", field)
+	case "slug":
+		return fmt.Sprintf("%s must be lower snake_case, e.g. sales_manager", field)
+	case "enum":
+		return fmt.Sprintf("%s has an unsupported value %q", field, fe.Value())
+	case "url":
+		return fmt.Sprintf("%s must be a valid URL", field)
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", field, fe.Param())
+	}
+	return fmt.Sprintf("%s failed the %s rule", field, fe.Tag())
+}
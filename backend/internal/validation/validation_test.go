@@ -0,0 +1,46 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestYearMax(t *testing.T) {
+	type request struct {
+		Year int `json:"year" validate:"required,min=1900,year_max"`
+	}
+	next := time.Now().Year() + 1
+	tests := []struct {
+		year int
+		ok   bool
+	}{
+		{1900, true},
+		{time.Now().Year(), true},
+		{next, true},
+		{next + 1, false},
+		{1899, false},
+	}
+	for _, tt := range tests {
+		err := Struct(&request{Year: tt.year})
+		if tt.ok {
+			if err != nil {
+				t.Errorf("year %d: %v", tt.year, err)
+			}
+			continue
+		}
+		var verr *Error
+		if !errors.As(err, &verr) || len(verr.Fields) != 1 || verr.Fields[0].Field != "year" {
+			t.Errorf("year %d: err = %v, want a year field error", tt.year, err)
+		}
+	}
+
+	err := Struct(&request{Year: next + 1})
+	var verr *Error
+	if errors.As(err, &verr) {
+		if want := fmt.Sprintf("year must be at most %d", next); verr.Fields[0].Message != want {
+			t.Errorf("message = %q, want %q", verr.Fields[0].Message, want)
+		}
+	}
+}
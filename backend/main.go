@@ -1,12 +1,27 @@
 package main
 
 import (
+	"context"
+	"image"
+	"image/png"
 	"log"
+	"os"
+	"time"
 
 	"vehicle-sales-backend/internal/api"
+	"vehicle-sales-backend/internal/auth"
 	"vehicle-sales-backend/internal/config"
 	"vehicle-sales-backend/internal/database"
+	"vehicle-sales-backend/internal/documents"
+	"vehicle-sales-backend/internal/escpos"
+	"vehicle-sales-backend/internal/idempotency"
+	"vehicle-sales-backend/internal/jobs"
 	"vehicle-sales-backend/internal/middleware"
+	"vehicle-sales-backend/internal/models"
+	"vehicle-sales-backend/internal/money"
+	"vehicle-sales-backend/internal/payments"
+	"vehicle-sales-backend/internal/permissions"
+	"vehicle-sales-backend/internal/services"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
@@ -14,33 +29,35 @@ import (
 )
 
 func main() {
-	// Load configuration
-	cfg, err := config.Load()
-	if err != nil {
-		log.Fatal("Failed to load configuration:", err)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
 	}
 
-	// Connect to database
-	if err := database.Connect(cfg); err != nil {
-		log.Fatal("Failed to connect to database:", err)
+	cfg := connect()
+
+	// Refuse to serve against an outdated schema
+	if err := database.CheckSchema(); err != nil {
+		log.Fatal("Database schema check failed: ", err)
 	}
 
-	// Run migrations
-	if err := database.Migrate(); err != nil {
-		log.Fatal("Failed to migrate database:", err)
+	// Register permissions and built-in roles
+	if err := permissions.Sync(); err != nil {
+		log.Fatal("Failed to sync permissions:", err)
 	}
 
+	// Route card, bank transfer and financing payments to their gateway
+	setupPayments(cfg)
+
+	// Letterhead and branch of invoices and receipts
+	setupDocuments(cfg)
+
+	// Prune expired idempotency keys and tokens in the background
+	startJobs()
+
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			code := fiber.StatusInternalServerError
-			if e, ok := err.(*fiber.Error); ok {
-				code = e.Code
-			}
-			return c.Status(code).JSON(fiber.Map{
-				"error": err.Error(),
-			})
-		},
+		ErrorHandler: middleware.ErrorHandler,
 	})
 
 	// Middleware
@@ -56,4 +73,121 @@ func main() {
 	if err := app.Listen(":" + cfg.Server.Port); err != nil {
 		log.Fatal("Failed to start server:", err)
 	}
+}
+
+// connect loads configuration and opens the database connection
+func connect() *config.Config {
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Failed to load configuration:", err)
+	}
+	if err := money.SetDefaultCurrency(money.Currency(cfg.Dealer.Currency)); err != nil {
+		log.Fatal("Invalid CURRENCY:", err)
+	}
+
+	// Connect to database
+	if err := database.Connect(cfg); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	return cfg
+}
+
+// setupDocuments configures the documents issued for sales and payments and
+// the thermal receipt defaults.
+func setupDocuments(cfg *config.Config) {
+	if _, ok := escpos.PaperFor(cfg.Receipts.PaperWidth); !ok {
+		log.Fatal("Invalid RECEIPT_PAPER_WIDTH: must be 58 or 80")
+	}
+	if _, ok := documents.ReceiptTemplates[cfg.Receipts.Template]; !ok {
+		log.Fatal("Invalid RECEIPT_TEMPLATE: ", cfg.Receipts.Template)
+	}
+	var logo image.Image
+	if cfg.Receipts.LogoPath != "" {
+		file, err := os.Open(cfg.Receipts.LogoPath)
+		if err != nil {
+			log.Fatal("Failed to open RECEIPT_LOGO:", err)
+		}
+		defer file.Close()
+		if logo, err = png.Decode(file); err != nil {
+			log.Fatal("Failed to read RECEIPT_LOGO:", err)
+		}
+	}
+
+	documents.Configure(documents.Config{
+		Letterhead: documents.Letterhead{
+			Name:    cfg.Dealer.Name,
+			Address: cfg.Dealer.Address,
+			Phone:   cfg.Dealer.Phone,
+			Email:   cfg.Dealer.Email,
+			TaxID:   cfg.Dealer.TaxID,
+		},
+		Branch:          cfg.Dealer.Branch,
+		PaperWidth:      cfg.Receipts.PaperWidth,
+		ReceiptTemplate: cfg.Receipts.Template,
+		Logo:            logo,
+		ReceiptFooter:   cfg.Receipts.Footer,
+	})
+}
+
+// startJobs schedules the periodic cleanup, expiry and repricing jobs.
+func startJobs() {
+	jobs.Start(context.Background(),
+		jobs.Job{
+			Name:     "prune-idempotency-keys",
+			Interval: time.Hour,
+			Run: func(ctx context.Context) error {
+				_, err := idempotency.Prune(time.Now())
+				return err
+			},
+		},
+		jobs.Job{
+			Name:     "prune-expired-tokens",
+			Interval: time.Hour,
+			Run: func(ctx context.Context) error {
+				_, err := auth.PruneExpiredTokens(time.Now())
+				return err
+			},
+		},
+		jobs.Job{
+			Name:     "expire-quotes",
+			Interval: time.Hour,
+			Run: func(ctx context.Context) error {
+				_, err := services.ExpireQuotes(time.Now())
+				return err
+			},
+		},
+		jobs.Job{
+			Name:     "reprice-vehicles",
+			Interval: time.Hour,
+			Run: func(ctx context.Context) error {
+				_, err := services.RepriceVehicles(time.Now())
+				return err
+			},
+		},
+	)
+}
+
+// setupPayments registers the payment providers. Until a real gateway is
+// integrated card and bank transfer payments go through the bundled fake
+// provider. Financing is paid by the lender, not through a gateway.
+func setupPayments(cfg *config.Config) {
+	payments.SetTimeout(cfg.Payments.Timeout)
+
+	fake, err := payments.NewFake(payments.FakeConfig{
+		Mode:       payments.FakeMode(cfg.Payments.FakeMode),
+		Delay:      cfg.Payments.FakeDelay,
+		WebhookURL: cfg.Payments.WebhookURL,
+		Secret:     cfg.Payments.WebhookSecret,
+	})
+	if err != nil {
+		log.Fatal("Invalid PAYMENT_FAKE_MODE:", err)
+	}
+	for _, method := range []models.PaymentMethod{
+		models.PaymentMethodCard,
+		models.PaymentMethodBankTransfer,
+	} {
+		payments.Register(method, fake)
+	}
 }
\ No newline at end of file
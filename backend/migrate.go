@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"vehicle-sales-backend/internal/database"
+)
+
+const migrateUsage = `usage: main migrate <command>
+
+commands:
+  up             apply all pending migrations
+  down [n]       revert the last n applied migrations (default 1)
+  status         list migrations and whether they are applied
+  create <name>  add an empty up/down migration pair to ./migrations`
+
+// runMigrate implements the `migrate` subcommand.
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+
+	// create only touches the filesystem
+	if args[0] == "create" {
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			os.Exit(2)
+		}
+		paths, err := database.CreateMigration(database.MigrationsDir, args[1])
+		if err != nil {
+			log.Fatal("Failed to create migration:", err)
+		}
+		for _, path := range paths {
+			fmt.Println("Created", path)
+		}
+		return
+	}
+
+	connect()
+
+	switch args[0] {
+	case "up":
+		if err := database.MigrateUp(); err != nil {
+			log.Fatal("Failed to migrate database:", err)
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				log.Fatal("Invalid step count: ", args[1])
+			}
+			steps = n
+		}
+		if err := database.MigrateDown(steps); err != nil {
+			log.Fatal("Failed to revert migrations:", err)
+		}
+
+	case "status":
+		states, err := database.MigrationStatus()
+		if err != nil {
+			log.Fatal("Failed to read migration status:", err)
+		}
+		for _, s := range states {
+			status := "pending"
+			if s.AppliedAt != nil {
+				status = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if s.Modified {
+				status += " (file modified since applied)"
+			}
+			fmt.Printf("%06d  %-32s %s\n", s.Version, s.Name, status)
+		}
+
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+}
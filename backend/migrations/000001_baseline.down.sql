@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS leads;
+DROP TABLE IF EXISTS transactions;
+DROP TABLE IF EXISTS sales;
+DROP TABLE IF EXISTS test_drives;
+DROP TABLE IF EXISTS vehicle_images;
+DROP TABLE IF EXISTS vehicles;
+DROP TABLE IF EXISTS users;
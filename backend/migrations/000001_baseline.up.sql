@@ -0,0 +1,111 @@
+-- Baseline schema, equivalent to what GORM AutoMigrate created before
+-- versioned migrations were introduced. Every statement is guarded so the
+-- migration can be applied to databases that were created by AutoMigrate.
+
+CREATE TABLE IF NOT EXISTS users (
+    id          BIGSERIAL PRIMARY KEY,
+    created_at  TIMESTAMPTZ,
+    updated_at  TIMESTAMPTZ,
+    deleted_at  TIMESTAMPTZ,
+    email       TEXT NOT NULL,
+    password    TEXT NOT NULL,
+    name        TEXT NOT NULL,
+    phone       TEXT,
+    role        TEXT NOT NULL DEFAULT 'customer',
+    is_active   BOOLEAN DEFAULT true,
+    avatar_url  TEXT
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS vehicles (
+    id             BIGSERIAL PRIMARY KEY,
+    created_at     TIMESTAMPTZ,
+    updated_at     TIMESTAMPTZ,
+    deleted_at     TIMESTAMPTZ,
+    make           TEXT NOT NULL,
+    model          TEXT NOT NULL,
+    year           BIGINT NOT NULL,
+    color          TEXT,
+    vin            TEXT,
+    license_plate  TEXT,
+    price          NUMERIC NOT NULL,
+    mileage        BIGINT,
+    status         TEXT DEFAULT 'available',
+    description    TEXT
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_vehicles_vin ON vehicles (vin);
+CREATE INDEX IF NOT EXISTS idx_vehicles_deleted_at ON vehicles (deleted_at);
+
+CREATE TABLE IF NOT EXISTS vehicle_images (
+    id          BIGSERIAL PRIMARY KEY,
+    created_at  TIMESTAMPTZ,
+    updated_at  TIMESTAMPTZ,
+    deleted_at  TIMESTAMPTZ,
+    vehicle_id  BIGINT NOT NULL REFERENCES vehicles (id),
+    url         TEXT NOT NULL,
+    is_primary  BOOLEAN DEFAULT false
+);
+CREATE INDEX IF NOT EXISTS idx_vehicle_images_deleted_at ON vehicle_images (deleted_at);
+
+CREATE TABLE IF NOT EXISTS test_drives (
+    id                 BIGSERIAL PRIMARY KEY,
+    created_at         TIMESTAMPTZ,
+    updated_at         TIMESTAMPTZ,
+    deleted_at         TIMESTAMPTZ,
+    vehicle_id         BIGINT NOT NULL REFERENCES vehicles (id),
+    customer_id        BIGINT NOT NULL REFERENCES users (id),
+    scheduled_time     TIMESTAMPTZ NOT NULL,
+    status             TEXT DEFAULT 'pending',
+    notes              TEXT,
+    customer_feedback  TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_test_drives_deleted_at ON test_drives (deleted_at);
+
+CREATE TABLE IF NOT EXISTS sales (
+    id               BIGSERIAL PRIMARY KEY,
+    created_at       TIMESTAMPTZ,
+    updated_at       TIMESTAMPTZ,
+    deleted_at       TIMESTAMPTZ,
+    vehicle_id       BIGINT NOT NULL REFERENCES vehicles (id),
+    customer_id      BIGINT NOT NULL REFERENCES users (id),
+    sales_person_id  BIGINT NOT NULL REFERENCES users (id),
+    sale_price       NUMERIC NOT NULL,
+    status           TEXT DEFAULT 'pending',
+    notes            TEXT,
+    completed_at     TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_sales_deleted_at ON sales (deleted_at);
+
+CREATE TABLE IF NOT EXISTS transactions (
+    id               BIGSERIAL PRIMARY KEY,
+    created_at       TIMESTAMPTZ,
+    updated_at       TIMESTAMPTZ,
+    deleted_at       TIMESTAMPTZ,
+    sale_id          BIGINT NOT NULL REFERENCES sales (id),
+    amount           NUMERIC NOT NULL,
+    payment_method   TEXT NOT NULL,
+    status           TEXT DEFAULT 'pending',
+    processed_by_id  BIGINT NOT NULL REFERENCES users (id),
+    processed_at     TIMESTAMPTZ,
+    transaction_ref  TEXT,
+    notes            TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_transactions_deleted_at ON transactions (deleted_at);
+
+CREATE TABLE IF NOT EXISTS leads (
+    id               BIGSERIAL PRIMARY KEY,
+    created_at       TIMESTAMPTZ,
+    updated_at       TIMESTAMPTZ,
+    deleted_at       TIMESTAMPTZ,
+    name             TEXT NOT NULL,
+    email            TEXT,
+    phone            TEXT,
+    interested_in    TEXT,
+    budget           NUMERIC,
+    assigned_to_id   BIGINT REFERENCES users (id),
+    status           TEXT DEFAULT 'new',
+    notes            TEXT,
+    last_contact_at  TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_leads_deleted_at ON leads (deleted_at);
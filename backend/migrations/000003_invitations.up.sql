@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS invitations (
+    id             BIGSERIAL PRIMARY KEY,
+    created_at     TIMESTAMPTZ,
+    updated_at     TIMESTAMPTZ,
+    deleted_at     TIMESTAMPTZ,
+    email          TEXT NOT NULL,
+    name           TEXT,
+    role           TEXT NOT NULL,
+    invited_by_id  BIGINT NOT NULL REFERENCES users (id),
+    expires_at     TIMESTAMPTZ NOT NULL,
+    accepted_at    TIMESTAMPTZ,
+    revoked_at     TIMESTAMPTZ,
+    user_id        BIGINT REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_invitations_email ON invitations (email);
+CREATE INDEX IF NOT EXISTS idx_invitations_deleted_at ON invitations (deleted_at);
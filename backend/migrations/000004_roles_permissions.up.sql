@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS roles (
+    id            BIGSERIAL PRIMARY KEY,
+    created_at    TIMESTAMPTZ,
+    updated_at    TIMESTAMPTZ,
+    name          TEXT NOT NULL,
+    display_name  TEXT NOT NULL,
+    description   TEXT,
+    is_system     BOOLEAN DEFAULT false
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_roles_name ON roles (name);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    id           BIGSERIAL PRIMARY KEY,
+    created_at   TIMESTAMPTZ,
+    name         TEXT NOT NULL,
+    description  TEXT
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_permissions_name ON permissions (name);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id        BIGINT NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    permission_id  BIGINT NOT NULL REFERENCES permissions (id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
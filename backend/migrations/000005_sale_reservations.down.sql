@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_sales_active_vehicle;
+ALTER TABLE sales DROP COLUMN IF EXISTS version;
+ALTER TABLE vehicles DROP COLUMN IF EXISTS version;
@@ -0,0 +1,22 @@
+-- Optimistic locking columns, bumped on every update of the row.
+ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE sales ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+
+-- A vehicle may have at most one active (pending or approved) sale. Refuse
+-- to guess which duplicate should win if the data already violates that.
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT vehicle_id
+        FROM sales
+        WHERE status IN ('pending', 'approved') AND deleted_at IS NULL
+        GROUP BY vehicle_id
+        HAVING count(*) > 1
+    ) THEN
+        RAISE EXCEPTION 'some vehicles have more than one active sale; cancel the duplicates and re-run the migration';
+    END IF;
+END $$;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sales_active_vehicle
+    ON sales (vehicle_id)
+    WHERE status IN ('pending', 'approved') AND deleted_at IS NULL;
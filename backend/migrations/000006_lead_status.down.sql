@@ -0,0 +1,2 @@
+ALTER TABLE leads DROP CONSTRAINT IF EXISTS chk_leads_status;
+ALTER TABLE leads ALTER COLUMN status DROP NOT NULL;
@@ -0,0 +1,14 @@
+-- Lead.Status used to be free text. Normalise casing and whitespace, keep
+-- any other value in the notes, then restrict the column to the LeadStatus
+-- values.
+UPDATE leads SET status = lower(trim(status)) WHERE status IS NOT NULL;
+
+UPDATE leads
+SET notes = concat_ws(E'\n', NULLIF(notes, ''), 'Previous status: ' || status),
+    status = 'new'
+WHERE status IS NULL
+   OR status NOT IN ('new', 'assigned', 'contacted', 'qualified', 'converted', 'lost');
+
+ALTER TABLE leads ALTER COLUMN status SET NOT NULL;
+ALTER TABLE leads ADD CONSTRAINT chk_leads_status
+    CHECK (status IN ('new', 'assigned', 'contacted', 'qualified', 'converted', 'lost'));
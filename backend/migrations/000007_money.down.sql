@@ -0,0 +1,27 @@
+-- Converting back drops the currency; amounts are restored in major units.
+ALTER TABLE vehicles ADD COLUMN price NUMERIC;
+UPDATE vehicles SET price = price_minor / 100.0;
+ALTER TABLE vehicles
+    ALTER COLUMN price SET NOT NULL,
+    DROP COLUMN price_minor,
+    DROP COLUMN price_currency;
+
+ALTER TABLE sales ADD COLUMN sale_price NUMERIC;
+UPDATE sales SET sale_price = sale_price_minor / 100.0;
+ALTER TABLE sales
+    ALTER COLUMN sale_price SET NOT NULL,
+    DROP COLUMN sale_price_minor,
+    DROP COLUMN sale_price_currency;
+
+ALTER TABLE transactions ADD COLUMN amount NUMERIC;
+UPDATE transactions SET amount = amount_minor / 100.0;
+ALTER TABLE transactions
+    ALTER COLUMN amount SET NOT NULL,
+    DROP COLUMN amount_minor,
+    DROP COLUMN amount_currency;
+
+ALTER TABLE leads ADD COLUMN budget NUMERIC;
+UPDATE leads SET budget = budget_minor / 100.0;
+ALTER TABLE leads
+    DROP COLUMN budget_minor,
+    DROP COLUMN budget_currency;
@@ -0,0 +1,43 @@
+-- Monetary columns move from NUMERIC (read into float64) to integer minor
+-- units plus an ISO 4217 currency code. Existing amounts predate multiple
+-- currencies and are taken to be IDR, which has two minor unit digits.
+ALTER TABLE vehicles
+    ADD COLUMN price_minor    BIGINT,
+    ADD COLUMN price_currency VARCHAR(3);
+UPDATE vehicles SET price_minor = round(price * 100)::bigint, price_currency = 'IDR';
+ALTER TABLE vehicles
+    ALTER COLUMN price_minor SET NOT NULL,
+    ALTER COLUMN price_minor SET DEFAULT 0,
+    ALTER COLUMN price_currency SET NOT NULL,
+    DROP COLUMN price;
+
+ALTER TABLE sales
+    ADD COLUMN sale_price_minor    BIGINT,
+    ADD COLUMN sale_price_currency VARCHAR(3);
+UPDATE sales SET sale_price_minor = round(sale_price * 100)::bigint, sale_price_currency = 'IDR';
+ALTER TABLE sales
+    ALTER COLUMN sale_price_minor SET NOT NULL,
+    ALTER COLUMN sale_price_minor SET DEFAULT 0,
+    ALTER COLUMN sale_price_currency SET NOT NULL,
+    DROP COLUMN sale_price;
+
+ALTER TABLE transactions
+    ADD COLUMN amount_minor    BIGINT,
+    ADD COLUMN amount_currency VARCHAR(3);
+UPDATE transactions SET amount_minor = round(amount * 100)::bigint, amount_currency = 'IDR';
+ALTER TABLE transactions
+    ALTER COLUMN amount_minor SET NOT NULL,
+    ALTER COLUMN amount_minor SET DEFAULT 0,
+    ALTER COLUMN amount_currency SET NOT NULL,
+    DROP COLUMN amount;
+
+-- A missing lead budget becomes zero.
+ALTER TABLE leads
+    ADD COLUMN budget_minor    BIGINT,
+    ADD COLUMN budget_currency VARCHAR(3);
+UPDATE leads SET budget_minor = COALESCE(round(budget * 100)::bigint, 0), budget_currency = 'IDR';
+ALTER TABLE leads
+    ALTER COLUMN budget_minor SET NOT NULL,
+    ALTER COLUMN budget_minor SET DEFAULT 0,
+    ALTER COLUMN budget_currency SET NOT NULL,
+    DROP COLUMN budget;
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_transactions_sale_status;
+
+ALTER TABLE transactions
+    DROP CONSTRAINT chk_transactions_type,
+    DROP COLUMN type,
+    DROP COLUMN tendered_minor,
+    DROP COLUMN tendered_currency,
+    DROP COLUMN change_minor,
+    DROP COLUMN change_currency;
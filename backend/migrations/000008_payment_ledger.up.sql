@@ -0,0 +1,21 @@
+-- Sales may be paid in several transactions: booking deposits while the sale
+-- is pending, then payments once approved. Cash transactions record what was
+-- tendered and the change given back. Existing transactions are payments.
+ALTER TABLE transactions
+    ADD COLUMN type              VARCHAR(20) NOT NULL DEFAULT 'payment',
+    ADD COLUMN tendered_minor    BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN tendered_currency VARCHAR(3),
+    ADD COLUMN change_minor      BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN change_currency   VARCHAR(3);
+
+UPDATE transactions
+SET tendered_minor = CASE WHEN payment_method = 'cash' THEN amount_minor ELSE 0 END,
+    tendered_currency = amount_currency,
+    change_currency = amount_currency;
+
+ALTER TABLE transactions
+    ALTER COLUMN tendered_currency SET NOT NULL,
+    ALTER COLUMN change_currency SET NOT NULL,
+    ADD CONSTRAINT chk_transactions_type CHECK (type IN ('deposit', 'payment'));
+
+CREATE INDEX idx_transactions_sale_status ON transactions (sale_id, status) WHERE deleted_at IS NULL;
@@ -0,0 +1,12 @@
+DELETE FROM transactions WHERE type = 'refund';
+
+DROP INDEX IF EXISTS idx_transactions_refund_of;
+
+ALTER TABLE transactions
+    DROP CONSTRAINT chk_transactions_refund,
+    DROP CONSTRAINT chk_transactions_type;
+ALTER TABLE transactions
+    ADD CONSTRAINT chk_transactions_type CHECK (type IN ('deposit', 'payment')),
+    DROP COLUMN refund_of_id,
+    DROP COLUMN reason_code,
+    DROP COLUMN approved_by_id;
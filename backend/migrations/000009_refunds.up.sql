@@ -0,0 +1,16 @@
+-- Refunds become separate negative transactions linked to the payment they
+-- return money from, with a mandatory reason code and approver.
+ALTER TABLE transactions
+    ADD COLUMN refund_of_id   BIGINT REFERENCES transactions (id),
+    ADD COLUMN reason_code    VARCHAR(30),
+    ADD COLUMN approved_by_id BIGINT REFERENCES users (id);
+
+ALTER TABLE transactions DROP CONSTRAINT chk_transactions_type;
+ALTER TABLE transactions
+    ADD CONSTRAINT chk_transactions_type CHECK (type IN ('deposit', 'payment', 'refund')),
+    ADD CONSTRAINT chk_transactions_refund CHECK (
+        type <> 'refund'
+        OR (refund_of_id IS NOT NULL AND reason_code IS NOT NULL AND approved_by_id IS NOT NULL AND amount_minor < 0)
+    );
+
+CREATE INDEX idx_transactions_refund_of ON transactions (refund_of_id) WHERE refund_of_id IS NOT NULL;
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_transactions_transaction_ref;
+DROP INDEX IF EXISTS idx_transactions_provider_ref;
+
+ALTER TABLE transactions
+    DROP COLUMN provider,
+    DROP COLUMN provider_ref,
+    DROP COLUMN provider_status,
+    DROP COLUMN provider_message;
@@ -0,0 +1,10 @@
+-- Card, bank transfer and financing payments are settled through a payment
+-- provider, which identifies them by its own reference.
+ALTER TABLE transactions
+    ADD COLUMN provider         VARCHAR(50),
+    ADD COLUMN provider_ref     VARCHAR(100),
+    ADD COLUMN provider_status  VARCHAR(20),
+    ADD COLUMN provider_message TEXT;
+
+CREATE INDEX idx_transactions_provider_ref ON transactions (provider, provider_ref) WHERE provider_ref IS NOT NULL;
+CREATE INDEX idx_transactions_transaction_ref ON transactions (transaction_ref);
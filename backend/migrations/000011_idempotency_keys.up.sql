@@ -0,0 +1,18 @@
+-- Responses to POST requests sent with an Idempotency-Key, kept until
+-- expires_at so retries are replayed instead of executed again.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    id              BIGSERIAL PRIMARY KEY,
+    created_at      TIMESTAMPTZ,
+    scope           TEXT NOT NULL,
+    key             TEXT NOT NULL,
+    method          TEXT NOT NULL,
+    path            TEXT NOT NULL,
+    fingerprint     TEXT NOT NULL,
+    status_code     BIGINT,
+    content_type    TEXT,
+    response_body   BYTEA,
+    completed_at    TIMESTAMPTZ,
+    expires_at      TIMESTAMPTZ NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_idempotency_keys_scope_key ON idempotency_keys (scope, key);
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
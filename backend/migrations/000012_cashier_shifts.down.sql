@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_transactions_shift_id;
+ALTER TABLE transactions DROP COLUMN shift_id;
+
+DROP TABLE IF EXISTS shift_counts;
+DROP TABLE IF EXISTS shifts;
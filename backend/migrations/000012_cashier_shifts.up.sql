@@ -0,0 +1,40 @@
+-- Cashier shifts: the cash drawer is opened with a float, every transaction
+-- the cashier takes while the shift is open belongs to it, and the shift is
+-- closed with a count per payment method. The Z-report finalizes it.
+CREATE TABLE IF NOT EXISTS shifts (
+    id                     BIGSERIAL PRIMARY KEY,
+    created_at             TIMESTAMPTZ,
+    updated_at             TIMESTAMPTZ,
+    cashier_id             BIGINT NOT NULL REFERENCES users (id),
+    status                 VARCHAR(20) NOT NULL DEFAULT 'open',
+    opening_float_minor    BIGINT NOT NULL DEFAULT 0,
+    opening_float_currency VARCHAR(3) NOT NULL,
+    opened_at              TIMESTAMPTZ NOT NULL,
+    closed_at              TIMESTAMPTZ,
+    closed_by_id           BIGINT REFERENCES users (id),
+    z_number               BIGINT,
+    finalized_at           TIMESTAMPTZ,
+    finalized_by_id        BIGINT REFERENCES users (id),
+    notes                  TEXT,
+    CONSTRAINT chk_shifts_status CHECK (status IN ('open', 'closed', 'finalized'))
+);
+CREATE INDEX IF NOT EXISTS idx_shifts_cashier_id ON shifts (cashier_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_shifts_z_number ON shifts (z_number);
+-- at most one open shift per cashier
+CREATE UNIQUE INDEX IF NOT EXISTS idx_shifts_open_cashier ON shifts (cashier_id) WHERE status = 'open';
+
+CREATE TABLE IF NOT EXISTS shift_counts (
+    id                BIGSERIAL PRIMARY KEY,
+    shift_id          BIGINT NOT NULL REFERENCES shifts (id),
+    payment_method    VARCHAR(20) NOT NULL,
+    expected_minor    BIGINT NOT NULL,
+    expected_currency VARCHAR(3) NOT NULL,
+    counted_minor     BIGINT NOT NULL,
+    counted_currency  VARCHAR(3) NOT NULL,
+    variance_minor    BIGINT NOT NULL,
+    variance_currency VARCHAR(3) NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_shift_counts_shift_method ON shift_counts (shift_id, payment_method);
+
+ALTER TABLE transactions ADD COLUMN shift_id BIGINT REFERENCES shifts (id);
+CREATE INDEX idx_transactions_shift_id ON transactions (shift_id);
@@ -0,0 +1,29 @@
+-- Invoices, payment receipts and credit notes, numbered without gaps per
+-- document type and branch (INV-HQ-000001). The rendered PDF is stored so
+-- that reprints are identical to the original.
+CREATE TABLE IF NOT EXISTS document_sequences (
+    prefix  VARCHAR(40) PRIMARY KEY,
+    last    BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS documents (
+    id              BIGSERIAL PRIMARY KEY,
+    created_at      TIMESTAMPTZ,
+    type            VARCHAR(20) NOT NULL,
+    number          VARCHAR(60) NOT NULL,
+    branch          VARCHAR(20) NOT NULL,
+    sequence        BIGINT NOT NULL,
+    sale_id         BIGINT NOT NULL REFERENCES sales (id),
+    transaction_id  BIGINT REFERENCES transactions (id),
+    total_minor     BIGINT NOT NULL,
+    total_currency  VARCHAR(3) NOT NULL,
+    issued_at       TIMESTAMPTZ NOT NULL,
+    pdf             BYTEA NOT NULL,
+    CONSTRAINT chk_documents_type CHECK (type IN ('invoice', 'receipt', 'credit_note'))
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_documents_number ON documents (number);
+CREATE INDEX IF NOT EXISTS idx_documents_sale_id ON documents (sale_id);
+CREATE INDEX IF NOT EXISTS idx_documents_transaction_id ON documents (transaction_id);
+-- one invoice per sale and one receipt or credit note per transaction
+CREATE UNIQUE INDEX IF NOT EXISTS idx_documents_invoice_sale ON documents (sale_id) WHERE type = 'invoice';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_documents_transaction_type ON documents (transaction_id, type) WHERE transaction_id IS NOT NULL;
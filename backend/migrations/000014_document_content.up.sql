@@ -0,0 +1,3 @@
+-- Keep the content of each document as issued so receipts can be reprinted
+-- on thermal printers exactly as they were first issued.
+ALTER TABLE documents ADD COLUMN content JSONB;
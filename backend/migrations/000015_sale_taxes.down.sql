@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS sale_lines;
+ALTER TABLE sales
+    DROP COLUMN agreed_price_minor,
+    DROP COLUMN agreed_price_currency;
+DROP TABLE IF EXISTS tax_rules;
+ALTER TABLE vehicles DROP COLUMN category;
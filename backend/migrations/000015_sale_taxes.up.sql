@@ -0,0 +1,81 @@
+-- Taxes and fees of vehicle sales. Rules hold the rates and fixed fees in
+-- force between two dates; pricing a sale applies them to the agreed price
+-- and stores the result as the sale's line items.
+ALTER TABLE vehicles ADD COLUMN category VARCHAR(40) NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS tax_rules (
+    id               BIGSERIAL PRIMARY KEY,
+    created_at       TIMESTAMPTZ,
+    updated_at       TIMESTAMPTZ,
+    deleted_at       TIMESTAMPTZ,
+    code             VARCHAR(20) NOT NULL,
+    name             TEXT NOT NULL,
+    kind             VARCHAR(20) NOT NULL,
+    rate             BIGINT NOT NULL DEFAULT 0,
+    amount_minor     BIGINT NOT NULL DEFAULT 0,
+    amount_currency  VARCHAR(3) NOT NULL,
+    inclusive        BOOLEAN NOT NULL DEFAULT FALSE,
+    vehicle_category VARCHAR(40) NOT NULL DEFAULT '',
+    effective_from   TIMESTAMPTZ NOT NULL,
+    effective_to     TIMESTAMPTZ,
+    CONSTRAINT chk_tax_rules_kind CHECK (kind IN ('vat', 'luxury', 'fee'))
+);
+CREATE INDEX IF NOT EXISTS idx_tax_rules_code ON tax_rules (code);
+CREATE INDEX IF NOT EXISTS idx_tax_rules_deleted_at ON tax_rules (deleted_at);
+
+-- Prices have so far been taken to include VAT (PPN): 11% since 1 April
+-- 2022 and 10% before.
+INSERT INTO tax_rules (created_at, updated_at, code, name, kind, rate, amount_currency, inclusive, effective_from, effective_to)
+VALUES (NOW(), NOW(), 'PPN', 'VAT (PPN)', 'vat', 1000, 'IDR', TRUE, '1985-04-01', '2022-04-01'),
+       (NOW(), NOW(), 'PPN', 'VAT (PPN)', 'vat', 1100, 'IDR', TRUE, '2022-04-01', NULL);
+
+-- sale_price stays the total due; agreed_price is the negotiated price the
+-- taxes and fees were applied to.
+ALTER TABLE sales
+    ADD COLUMN agreed_price_minor    BIGINT,
+    ADD COLUMN agreed_price_currency VARCHAR(3);
+UPDATE sales SET agreed_price_minor = sale_price_minor, agreed_price_currency = sale_price_currency;
+ALTER TABLE sales
+    ALTER COLUMN agreed_price_minor SET NOT NULL,
+    ALTER COLUMN agreed_price_minor SET DEFAULT 0,
+    ALTER COLUMN agreed_price_currency SET NOT NULL;
+
+CREATE TABLE IF NOT EXISTS sale_lines (
+    id              BIGSERIAL PRIMARY KEY,
+    sale_id         BIGINT NOT NULL REFERENCES sales (id),
+    position        INTEGER NOT NULL,
+    type            VARCHAR(20) NOT NULL,
+    code            VARCHAR(20) NOT NULL DEFAULT '',
+    description     TEXT NOT NULL,
+    rate            BIGINT NOT NULL DEFAULT 0,
+    inclusive       BOOLEAN NOT NULL DEFAULT FALSE,
+    base_minor      BIGINT NOT NULL DEFAULT 0,
+    base_currency   VARCHAR(3) NOT NULL,
+    amount_minor    BIGINT NOT NULL DEFAULT 0,
+    amount_currency VARCHAR(3) NOT NULL,
+    tax_rule_id     BIGINT REFERENCES tax_rules (id),
+    CONSTRAINT chk_sale_lines_type CHECK (type IN ('vehicle', 'tax', 'fee'))
+);
+CREATE INDEX IF NOT EXISTS idx_sale_lines_sale_id ON sale_lines (sale_id);
+
+-- Itemise existing sales the way tax.Price does: the vehicle at the price
+-- paid and the VAT included in it, at the rate in force when the sale was
+-- made, backed out of that price.
+WITH rated AS (
+    SELECT s.id, s.sale_price_currency AS currency, s.sale_price_minor AS gross,
+           CASE WHEN s.created_at < '2022-04-01' THEN 1000 ELSE 1100 END AS rate,
+           concat_ws(' ', v.year, v.make, v.model) ||
+               CASE WHEN COALESCE(v.vin, '') <> '' THEN ' (VIN ' || v.vin || ')' ELSE '' END AS description
+    FROM sales s
+    JOIN vehicles v ON v.id = s.vehicle_id
+), priced AS (
+    SELECT id, currency, gross, rate, description,
+           round(round(gross * 10000 / (10000 + rate)::numeric) * rate / 10000)::bigint AS vat
+    FROM rated
+)
+INSERT INTO sale_lines (sale_id, position, type, code, description, rate, inclusive,
+                        base_minor, base_currency, amount_minor, amount_currency, tax_rule_id)
+SELECT id, 1, 'vehicle', '', description, 0, FALSE, 0, currency, gross, currency, NULL FROM priced
+UNION ALL
+SELECT id, 2, 'tax', 'PPN', 'VAT (PPN)', rate, TRUE, gross - vat, currency, vat, currency,
+       (SELECT r.id FROM tax_rules r WHERE r.code = 'PPN' AND r.rate = priced.rate) FROM priced;
@@ -0,0 +1,17 @@
+DROP INDEX IF EXISTS idx_sales_quote_id;
+ALTER TABLE sales DROP COLUMN quote_id;
+
+DROP TABLE IF EXISTS quote_lines;
+DROP TABLE IF EXISTS quote_revisions;
+DROP TABLE IF EXISTS quotes;
+
+-- Fold discounts and add-ons back into the vehicle line, before tax. Trade-in
+-- lines are dropped; the sale price keeps the allowance deducted.
+UPDATE sale_lines v
+SET amount_minor = (SELECT SUM(t.amount_minor) FROM sale_lines t
+                    WHERE t.sale_id = v.sale_id AND t.type IN ('vehicle', 'discount', 'add_on'))
+    - COALESCE((SELECT SUM(t.amount_minor) FROM sale_lines t WHERE t.sale_id = v.sale_id AND t.inclusive), 0)
+WHERE v.type = 'vehicle';
+DELETE FROM sale_lines WHERE type IN ('discount', 'add_on', 'trade_in');
+ALTER TABLE sale_lines DROP CONSTRAINT chk_sale_lines_type;
+ALTER TABLE sale_lines ADD CONSTRAINT chk_sale_lines_type CHECK (type IN ('vehicle', 'tax', 'fee'));
@@ -0,0 +1,92 @@
+-- Quotes: priced offers made to a customer before a sale reserves the
+-- vehicle. Every change is a new revision with its own line items; the
+-- accepted revision's lines are copied to the sale it converts into.
+
+-- Line items now carry the value agreed with the customer: the vehicle line
+-- includes the taxes included in the price, which are listed for
+-- information, and discounts, add-ons and trade-ins get lines of their own.
+UPDATE sale_lines v
+SET amount_minor = v.amount_minor + COALESCE(
+    (SELECT SUM(t.amount_minor) FROM sale_lines t WHERE t.sale_id = v.sale_id AND t.inclusive), 0)
+WHERE v.type = 'vehicle';
+ALTER TABLE sale_lines DROP CONSTRAINT chk_sale_lines_type;
+ALTER TABLE sale_lines ADD CONSTRAINT chk_sale_lines_type
+    CHECK (type IN ('vehicle', 'discount', 'add_on', 'trade_in', 'tax', 'fee'));
+
+CREATE TABLE IF NOT EXISTS quotes (
+    id                BIGSERIAL PRIMARY KEY,
+    created_at        TIMESTAMPTZ,
+    updated_at        TIMESTAMPTZ,
+    deleted_at        TIMESTAMPTZ,
+    number            VARCHAR(60) NOT NULL,
+    vehicle_id        BIGINT NOT NULL REFERENCES vehicles (id),
+    customer_id       BIGINT NOT NULL REFERENCES users (id),
+    sales_person_id   BIGINT NOT NULL REFERENCES users (id),
+    status            VARCHAR(20) NOT NULL DEFAULT 'draft',
+    revision          INTEGER NOT NULL,
+    valid_until       TIMESTAMPTZ NOT NULL,
+    total_minor       BIGINT NOT NULL DEFAULT 0,
+    total_currency    VARCHAR(3) NOT NULL,
+    share_token       VARCHAR(64),
+    sent_at           TIMESTAMPTZ,
+    accepted_at       TIMESTAMPTZ,
+    accepted_revision INTEGER,
+    accepted_by_id    BIGINT REFERENCES users (id),
+    declined_at       TIMESTAMPTZ,
+    decline_reason    TEXT,
+    sale_id           BIGINT REFERENCES sales (id),
+    version           INTEGER NOT NULL DEFAULT 1,
+    CONSTRAINT chk_quotes_status CHECK (status IN ('draft', 'sent', 'accepted', 'declined', 'expired', 'converted'))
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_quotes_number ON quotes (number);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_quotes_share_token ON quotes (share_token);
+CREATE INDEX IF NOT EXISTS idx_quotes_vehicle_id ON quotes (vehicle_id);
+CREATE INDEX IF NOT EXISTS idx_quotes_customer_id ON quotes (customer_id);
+CREATE INDEX IF NOT EXISTS idx_quotes_deleted_at ON quotes (deleted_at);
+
+CREATE TABLE IF NOT EXISTS quote_revisions (
+    id                          BIGSERIAL PRIMARY KEY,
+    created_at                  TIMESTAMPTZ,
+    quote_id                    BIGINT NOT NULL REFERENCES quotes (id),
+    revision                    INTEGER NOT NULL,
+    created_by_id               BIGINT NOT NULL REFERENCES users (id),
+    list_price_minor            BIGINT NOT NULL DEFAULT 0,
+    list_price_currency         VARCHAR(3) NOT NULL,
+    discount_minor              BIGINT NOT NULL DEFAULT 0,
+    discount_currency           VARCHAR(3) NOT NULL,
+    trade_in_allowance_minor    BIGINT NOT NULL DEFAULT 0,
+    trade_in_allowance_currency VARCHAR(3) NOT NULL,
+    agreed_price_minor          BIGINT NOT NULL DEFAULT 0,
+    agreed_price_currency       VARCHAR(3) NOT NULL,
+    taxes_minor                 BIGINT NOT NULL DEFAULT 0,
+    taxes_currency              VARCHAR(3) NOT NULL,
+    fees_minor                  BIGINT NOT NULL DEFAULT 0,
+    fees_currency               VARCHAR(3) NOT NULL,
+    total_minor                 BIGINT NOT NULL DEFAULT 0,
+    total_currency              VARCHAR(3) NOT NULL,
+    valid_until                 TIMESTAMPTZ NOT NULL,
+    notes                       TEXT
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_quote_revisions_quote_revision ON quote_revisions (quote_id, revision);
+
+CREATE TABLE IF NOT EXISTS quote_lines (
+    id                BIGSERIAL PRIMARY KEY,
+    quote_revision_id BIGINT NOT NULL REFERENCES quote_revisions (id),
+    position          INTEGER NOT NULL,
+    type              VARCHAR(20) NOT NULL,
+    code              VARCHAR(20) NOT NULL DEFAULT '',
+    description       TEXT NOT NULL,
+    rate              BIGINT NOT NULL DEFAULT 0,
+    inclusive         BOOLEAN NOT NULL DEFAULT FALSE,
+    base_minor        BIGINT NOT NULL DEFAULT 0,
+    base_currency     VARCHAR(3) NOT NULL,
+    amount_minor      BIGINT NOT NULL DEFAULT 0,
+    amount_currency   VARCHAR(3) NOT NULL,
+    tax_rule_id       BIGINT REFERENCES tax_rules (id),
+    CONSTRAINT chk_quote_lines_type CHECK (type IN ('vehicle', 'discount', 'add_on', 'trade_in', 'tax', 'fee'))
+);
+CREATE INDEX IF NOT EXISTS idx_quote_lines_quote_revision_id ON quote_lines (quote_revision_id);
+
+-- a quote converts into at most one sale
+ALTER TABLE sales ADD COLUMN quote_id BIGINT REFERENCES quotes (id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sales_quote_id ON sales (quote_id);
@@ -0,0 +1,16 @@
+DROP TABLE IF EXISTS sale_approvals;
+
+-- sales still awaiting approval go back to pending
+UPDATE sales SET status = 'pending' WHERE status = 'awaiting_approval';
+DROP INDEX IF EXISTS idx_sales_active_vehicle;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sales_active_vehicle
+    ON sales (vehicle_id)
+    WHERE status IN ('pending', 'approved') AND deleted_at IS NULL;
+
+ALTER TABLE sales
+    DROP COLUMN list_price_minor,
+    DROP COLUMN list_price_currency,
+    DROP COLUMN discount_minor,
+    DROP COLUMN discount_currency,
+    DROP COLUMN discount_rate;
+ALTER TABLE roles DROP COLUMN max_discount_rate;
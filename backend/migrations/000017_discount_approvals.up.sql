@@ -0,0 +1,44 @@
+-- Discount approval: each role may sell below a vehicle's price by up to
+-- max_discount_rate basis points (NULL for no limit). Larger discounts wait
+-- for approval by someone whose limit covers them.
+ALTER TABLE roles ADD COLUMN max_discount_rate BIGINT;
+UPDATE roles SET max_discount_rate = 300 WHERE name = 'sales';
+UPDATE roles SET max_discount_rate = 0 WHERE name NOT IN ('admin', 'sales');
+
+-- Sales record the vehicle's price when they were priced and the discount
+-- given off it. Existing sales are taken to be at the vehicle's price.
+ALTER TABLE sales
+    ADD COLUMN list_price_minor    BIGINT,
+    ADD COLUMN list_price_currency VARCHAR(3),
+    ADD COLUMN discount_minor      BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN discount_currency   VARCHAR(3),
+    ADD COLUMN discount_rate       BIGINT NOT NULL DEFAULT 0;
+UPDATE sales SET list_price_minor = agreed_price_minor, list_price_currency = agreed_price_currency,
+                 discount_currency = agreed_price_currency;
+ALTER TABLE sales
+    ALTER COLUMN list_price_minor SET NOT NULL,
+    ALTER COLUMN list_price_minor SET DEFAULT 0,
+    ALTER COLUMN list_price_currency SET NOT NULL,
+    ALTER COLUMN discount_currency SET NOT NULL;
+
+-- sales awaiting approval hold their vehicle like pending ones
+DROP INDEX IF EXISTS idx_sales_active_vehicle;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sales_active_vehicle
+    ON sales (vehicle_id)
+    WHERE status IN ('awaiting_approval', 'pending', 'approved') AND deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS sale_approvals (
+    id                  BIGSERIAL PRIMARY KEY,
+    created_at          TIMESTAMPTZ,
+    sale_id             BIGINT NOT NULL REFERENCES sales (id),
+    user_id             BIGINT NOT NULL REFERENCES users (id),
+    action              VARCHAR(20) NOT NULL,
+    list_price_minor    BIGINT NOT NULL,
+    list_price_currency VARCHAR(3) NOT NULL,
+    discount_minor      BIGINT NOT NULL,
+    discount_currency   VARCHAR(3) NOT NULL,
+    discount_rate       BIGINT NOT NULL,
+    comment             TEXT,
+    CONSTRAINT chk_sale_approvals_action CHECK (action IN ('requested', 'approved', 'rejected', 'withdrawn'))
+);
+CREATE INDEX IF NOT EXISTS idx_sale_approvals_sale_id ON sale_approvals (sale_id);
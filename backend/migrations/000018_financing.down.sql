@@ -0,0 +1,5 @@
+ALTER TABLE financing_applications DROP CONSTRAINT IF EXISTS fk_financing_applications_accepted_offer;
+DROP TABLE IF EXISTS financing_installments;
+DROP TABLE IF EXISTS financing_offers;
+DROP TABLE IF EXISTS financing_applications;
+DROP TABLE IF EXISTS lenders;
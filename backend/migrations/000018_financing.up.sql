@@ -0,0 +1,102 @@
+-- Financing: lenders answer a customer's application with offers, and the
+-- accepted offer's financed amount is paid as a financing transaction that
+-- completes when the lender disburses it.
+CREATE TABLE IF NOT EXISTS lenders (
+    id            BIGSERIAL PRIMARY KEY,
+    created_at    TIMESTAMPTZ,
+    updated_at    TIMESTAMPTZ,
+    deleted_at    TIMESTAMPTZ,
+    name          VARCHAR(100) NOT NULL,
+    contact_name  VARCHAR(100),
+    contact_phone VARCHAR(30),
+    contact_email VARCHAR(255),
+    is_active     BOOLEAN NOT NULL DEFAULT TRUE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_lenders_name ON lenders (name);
+CREATE INDEX IF NOT EXISTS idx_lenders_deleted_at ON lenders (deleted_at);
+
+CREATE TABLE IF NOT EXISTS financing_applications (
+    id                        BIGSERIAL PRIMARY KEY,
+    created_at                TIMESTAMPTZ,
+    updated_at                TIMESTAMPTZ,
+    deleted_at                TIMESTAMPTZ,
+    sale_id                   BIGINT NOT NULL REFERENCES sales (id),
+    customer_id               BIGINT NOT NULL REFERENCES users (id),
+    created_by_id             BIGINT NOT NULL REFERENCES users (id),
+    status                    VARCHAR(20) NOT NULL DEFAULT 'submitted',
+    applicant_income_minor    BIGINT NOT NULL DEFAULT 0,
+    applicant_income_currency VARCHAR(3) NOT NULL,
+    down_payment_minor        BIGINT NOT NULL DEFAULT 0,
+    down_payment_currency     VARCHAR(3) NOT NULL,
+    principal_minor           BIGINT NOT NULL DEFAULT 0,
+    principal_currency        VARCHAR(3) NOT NULL,
+    tenor_months              INTEGER NOT NULL,
+    lender_id                 BIGINT REFERENCES lenders (id),
+    accepted_offer_id         BIGINT,
+    transaction_id            BIGINT REFERENCES transactions (id),
+    disbursed_at              TIMESTAMPTZ,
+    disbursement_ref          VARCHAR(100),
+    decision_reason           TEXT,
+    notes                     TEXT,
+    version                   INTEGER NOT NULL DEFAULT 1,
+    CONSTRAINT chk_financing_applications_status CHECK (status IN ('submitted', 'approved', 'disbursed', 'rejected', 'canceled')),
+    CONSTRAINT chk_financing_applications_tenor CHECK (tenor_months > 0)
+);
+CREATE INDEX IF NOT EXISTS idx_financing_applications_sale_id ON financing_applications (sale_id);
+CREATE INDEX IF NOT EXISTS idx_financing_applications_customer_id ON financing_applications (customer_id);
+CREATE INDEX IF NOT EXISTS idx_financing_applications_deleted_at ON financing_applications (deleted_at);
+-- a sale has at most one application in progress
+CREATE UNIQUE INDEX IF NOT EXISTS idx_financing_applications_open_sale
+    ON financing_applications (sale_id)
+    WHERE status IN ('submitted', 'approved') AND deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_financing_applications_transaction_id ON financing_applications (transaction_id);
+
+CREATE TABLE IF NOT EXISTS financing_offers (
+    id                      BIGSERIAL PRIMARY KEY,
+    created_at              TIMESTAMPTZ,
+    updated_at              TIMESTAMPTZ,
+    application_id          BIGINT NOT NULL REFERENCES financing_applications (id),
+    lender_id               BIGINT NOT NULL REFERENCES lenders (id),
+    status                  VARCHAR(20) NOT NULL DEFAULT 'offered',
+    method                  VARCHAR(20) NOT NULL,
+    interest_rate           BIGINT NOT NULL,
+    admin_fee_minor         BIGINT NOT NULL DEFAULT 0,
+    admin_fee_currency      VARCHAR(3) NOT NULL,
+    principal_minor         BIGINT NOT NULL DEFAULT 0,
+    principal_currency      VARCHAR(3) NOT NULL,
+    tenor_months            INTEGER NOT NULL,
+    installment_minor       BIGINT NOT NULL DEFAULT 0,
+    installment_currency    VARCHAR(3) NOT NULL,
+    total_interest_minor    BIGINT NOT NULL DEFAULT 0,
+    total_interest_currency VARCHAR(3) NOT NULL,
+    total_payable_minor     BIGINT NOT NULL DEFAULT 0,
+    total_payable_currency  VARCHAR(3) NOT NULL,
+    installment_ratio       BIGINT NOT NULL DEFAULT 0,
+    lender_ref              VARCHAR(100),
+    valid_until             TIMESTAMPTZ,
+    notes                   TEXT,
+    CONSTRAINT chk_financing_offers_status CHECK (status IN ('offered', 'accepted', 'declined')),
+    CONSTRAINT chk_financing_offers_method CHECK (method IN ('flat', 'annuity')),
+    CONSTRAINT chk_financing_offers_interest_rate CHECK (interest_rate >= 0)
+);
+CREATE INDEX IF NOT EXISTS idx_financing_offers_application_id ON financing_offers (application_id);
+
+ALTER TABLE financing_applications
+    ADD CONSTRAINT fk_financing_applications_accepted_offer
+    FOREIGN KEY (accepted_offer_id) REFERENCES financing_offers (id);
+
+CREATE TABLE IF NOT EXISTS financing_installments (
+    id                 BIGSERIAL PRIMARY KEY,
+    offer_id           BIGINT NOT NULL REFERENCES financing_offers (id),
+    number             INTEGER NOT NULL,
+    due_date           TIMESTAMPTZ,
+    payment_minor      BIGINT NOT NULL,
+    payment_currency   VARCHAR(3) NOT NULL,
+    principal_minor    BIGINT NOT NULL,
+    principal_currency VARCHAR(3) NOT NULL,
+    interest_minor     BIGINT NOT NULL,
+    interest_currency  VARCHAR(3) NOT NULL,
+    balance_minor      BIGINT NOT NULL,
+    balance_currency   VARCHAR(3) NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_financing_installments_offer_number ON financing_installments (offer_id, number);
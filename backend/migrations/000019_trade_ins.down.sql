@@ -0,0 +1,7 @@
+ALTER TABLE sales
+    DROP COLUMN trade_in_minor,
+    DROP COLUMN trade_in_currency;
+DROP TABLE IF EXISTS trade_in_photos;
+DROP TABLE IF EXISTS trade_in_checks;
+DROP TABLE IF EXISTS trade_ins;
+UPDATE vehicles SET status = 'service' WHERE status = 'intake';
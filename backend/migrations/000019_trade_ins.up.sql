@@ -0,0 +1,62 @@
+-- Trade-ins: appraisals of customers' vehicles, which are taken into stock
+-- when accepted and credited against one of the customer's sales.
+CREATE TABLE IF NOT EXISTS trade_ins (
+    id                     BIGSERIAL PRIMARY KEY,
+    created_at             TIMESTAMPTZ,
+    updated_at             TIMESTAMPTZ,
+    deleted_at             TIMESTAMPTZ,
+    customer_id            BIGINT NOT NULL REFERENCES users (id),
+    appraiser_id           BIGINT NOT NULL REFERENCES users (id),
+    status                 VARCHAR(20) NOT NULL DEFAULT 'appraised',
+    make                   TEXT NOT NULL,
+    model                  TEXT NOT NULL,
+    year                   INTEGER NOT NULL,
+    color                  TEXT,
+    vin                    TEXT NOT NULL,
+    license_plate          TEXT,
+    mileage                INTEGER,
+    category               TEXT,
+    offered_value_minor    BIGINT NOT NULL DEFAULT 0,
+    offered_value_currency VARCHAR(3) NOT NULL,
+    valid_until            TIMESTAMPTZ,
+    notes                  TEXT,
+    accepted_at            TIMESTAMPTZ,
+    declined_at            TIMESTAMPTZ,
+    decline_reason         TEXT,
+    vehicle_id             BIGINT REFERENCES vehicles (id),
+    sale_id                BIGINT REFERENCES sales (id),
+    applied_at             TIMESTAMPTZ,
+    version                INTEGER NOT NULL DEFAULT 1,
+    CONSTRAINT chk_trade_ins_status CHECK (status IN ('appraised', 'accepted', 'declined'))
+);
+CREATE INDEX IF NOT EXISTS idx_trade_ins_customer_id ON trade_ins (customer_id);
+CREATE INDEX IF NOT EXISTS idx_trade_ins_sale_id ON trade_ins (sale_id);
+CREATE INDEX IF NOT EXISTS idx_trade_ins_deleted_at ON trade_ins (deleted_at);
+
+CREATE TABLE IF NOT EXISTS trade_in_checks (
+    id          BIGSERIAL PRIMARY KEY,
+    trade_in_id BIGINT NOT NULL REFERENCES trade_ins (id),
+    item        TEXT NOT NULL,
+    condition   VARCHAR(10) NOT NULL,
+    notes       TEXT,
+    CONSTRAINT chk_trade_in_checks_condition CHECK (condition IN ('good', 'fair', 'poor'))
+);
+CREATE INDEX IF NOT EXISTS idx_trade_in_checks_trade_in_id ON trade_in_checks (trade_in_id);
+
+CREATE TABLE IF NOT EXISTS trade_in_photos (
+    id          BIGSERIAL PRIMARY KEY,
+    trade_in_id BIGINT NOT NULL REFERENCES trade_ins (id),
+    url         TEXT NOT NULL,
+    caption     TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_trade_in_photos_trade_in_id ON trade_in_photos (trade_in_id);
+
+-- Sales record the trade-in credit deducted from their price, taken from
+-- the trade-in lines of existing sales.
+ALTER TABLE sales
+    ADD COLUMN trade_in_minor    BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN trade_in_currency VARCHAR(3);
+UPDATE sales SET trade_in_currency = sale_price_currency,
+                 trade_in_minor = COALESCE((SELECT -SUM(amount_minor) FROM sale_lines
+                                            WHERE sale_lines.sale_id = sales.id AND sale_lines.type = 'trade_in'), 0);
+ALTER TABLE sales ALTER COLUMN trade_in_currency SET NOT NULL;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS commission_entries;
+DROP TABLE IF EXISTS commission_make_bonuses;
+DROP TABLE IF EXISTS commission_tiers;
+DROP TABLE IF EXISTS commission_plans;
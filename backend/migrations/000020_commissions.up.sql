@@ -0,0 +1,78 @@
+-- Commissions: plans set what salespeople earn on the sales they complete,
+-- and the ledger records the commission accrued on completion and reversed
+-- by refunds.
+CREATE TABLE IF NOT EXISTS commission_plans (
+    id                BIGSERIAL PRIMARY KEY,
+    created_at        TIMESTAMPTZ,
+    updated_at        TIMESTAMPTZ,
+    deleted_at        TIMESTAMPTZ,
+    name              VARCHAR(100) NOT NULL,
+    sales_person_id   BIGINT REFERENCES users (id),
+    is_active         BOOLEAN NOT NULL DEFAULT TRUE,
+    per_unit_minor    BIGINT NOT NULL DEFAULT 0,
+    per_unit_currency VARCHAR(3) NOT NULL,
+    gross_profit_rate BIGINT NOT NULL DEFAULT 0,
+    CONSTRAINT chk_commission_plans_per_unit CHECK (per_unit_minor >= 0),
+    CONSTRAINT chk_commission_plans_gross_profit_rate CHECK (gross_profit_rate BETWEEN 0 AND 10000)
+);
+CREATE INDEX IF NOT EXISTS idx_commission_plans_sales_person_id ON commission_plans (sales_person_id);
+CREATE INDEX IF NOT EXISTS idx_commission_plans_deleted_at ON commission_plans (deleted_at);
+-- one active plan per salesperson, and one active plan for everyone else
+CREATE UNIQUE INDEX IF NOT EXISTS idx_commission_plans_active_sales_person
+    ON commission_plans (sales_person_id)
+    WHERE is_active AND deleted_at IS NULL AND sales_person_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_commission_plans_active_default
+    ON commission_plans ((sales_person_id IS NULL))
+    WHERE is_active AND deleted_at IS NULL AND sales_person_id IS NULL;
+
+CREATE TABLE IF NOT EXISTS commission_tiers (
+    id                BIGSERIAL PRIMARY KEY,
+    plan_id           BIGINT NOT NULL REFERENCES commission_plans (id),
+    min_units         INTEGER NOT NULL,
+    per_unit_minor    BIGINT NOT NULL DEFAULT 0,
+    per_unit_currency VARCHAR(3) NOT NULL,
+    gross_profit_rate BIGINT NOT NULL DEFAULT 0,
+    CONSTRAINT chk_commission_tiers_min_units CHECK (min_units > 0),
+    CONSTRAINT chk_commission_tiers_gross_profit_rate CHECK (gross_profit_rate BETWEEN 0 AND 10000)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_commission_tiers_plan_min_units ON commission_tiers (plan_id, min_units);
+
+CREATE TABLE IF NOT EXISTS commission_make_bonuses (
+    id             BIGSERIAL PRIMARY KEY,
+    plan_id        BIGINT NOT NULL REFERENCES commission_plans (id),
+    make           TEXT NOT NULL,
+    bonus_minor    BIGINT NOT NULL DEFAULT 0,
+    bonus_currency VARCHAR(3) NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_commission_make_bonuses_plan_make ON commission_make_bonuses (plan_id, LOWER(make));
+
+CREATE TABLE IF NOT EXISTS commission_entries (
+    id                    BIGSERIAL PRIMARY KEY,
+    created_at            TIMESTAMPTZ,
+    sale_id               BIGINT NOT NULL REFERENCES sales (id),
+    sales_person_id       BIGINT NOT NULL REFERENCES users (id),
+    plan_id               BIGINT NOT NULL REFERENCES commission_plans (id),
+    type                  VARCHAR(20) NOT NULL,
+    units                 INTEGER NOT NULL DEFAULT 0,
+    month_unit            INTEGER NOT NULL DEFAULT 0,
+    tier                  INTEGER NOT NULL DEFAULT 0,
+    gross_profit_minor    BIGINT NOT NULL DEFAULT 0,
+    gross_profit_currency VARCHAR(3) NOT NULL,
+    per_unit_minor        BIGINT NOT NULL DEFAULT 0,
+    per_unit_currency     VARCHAR(3) NOT NULL,
+    profit_share_minor    BIGINT NOT NULL DEFAULT 0,
+    profit_share_currency VARCHAR(3) NOT NULL,
+    make_bonus_minor      BIGINT NOT NULL DEFAULT 0,
+    make_bonus_currency   VARCHAR(3) NOT NULL,
+    amount_minor          BIGINT NOT NULL DEFAULT 0,
+    amount_currency       VARCHAR(3) NOT NULL,
+    refund_id             BIGINT REFERENCES transactions (id),
+    notes                 TEXT,
+    CONSTRAINT chk_commission_entries_type CHECK (type IN ('accrual', 'reversal'))
+);
+CREATE INDEX IF NOT EXISTS idx_commission_entries_sale_id ON commission_entries (sale_id);
+CREATE INDEX IF NOT EXISTS idx_commission_entries_sales_person_created_at ON commission_entries (sales_person_id, created_at);
+-- a sale's commission is accrued once
+CREATE UNIQUE INDEX IF NOT EXISTS idx_commission_entries_sale_accrual
+    ON commission_entries (sale_id)
+    WHERE type = 'accrual';
@@ -0,0 +1,16 @@
+DROP INDEX IF EXISTS idx_sales_status_completed_at;
+ALTER TABLE sales
+    DROP COLUMN IF EXISTS gross_profit_currency,
+    DROP COLUMN IF EXISTS gross_profit_minor,
+    DROP COLUMN IF EXISTS vehicle_cost_currency,
+    DROP COLUMN IF EXISTS vehicle_cost_minor,
+    DROP COLUMN IF EXISTS net_revenue_currency,
+    DROP COLUMN IF EXISTS net_revenue_minor;
+DROP TABLE IF EXISTS reconditioning_expenses;
+ALTER TABLE vehicles
+    DROP CONSTRAINT IF EXISTS chk_vehicles_acquisition_source,
+    DROP CONSTRAINT IF EXISTS chk_vehicles_purchase_cost,
+    DROP COLUMN IF EXISTS acquisition_source,
+    DROP COLUMN IF EXISTS purchase_cost_currency,
+    DROP COLUMN IF EXISTS purchase_cost_minor,
+    DROP COLUMN IF EXISTS acquired_at;
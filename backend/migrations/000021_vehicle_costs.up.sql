@@ -0,0 +1,71 @@
+-- Vehicle costs: what the dealership paid for a vehicle and spent
+-- reconditioning it since it last came into stock. Existing vehicles are
+-- taken to have come into stock when they were created, and vehicles taken
+-- in trade to have cost what was offered for them.
+ALTER TABLE vehicles
+    ADD COLUMN acquired_at            TIMESTAMPTZ,
+    ADD COLUMN purchase_cost_minor    BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN purchase_cost_currency VARCHAR(3),
+    ADD COLUMN acquisition_source     VARCHAR(20),
+    ADD CONSTRAINT chk_vehicles_purchase_cost CHECK (purchase_cost_minor >= 0),
+    ADD CONSTRAINT chk_vehicles_acquisition_source
+        CHECK (acquisition_source IN ('trade_in', 'auction', 'dealer', 'private', 'manufacturer'));
+UPDATE vehicles SET acquired_at = COALESCE(created_at, NOW()), purchase_cost_currency = price_currency;
+UPDATE vehicles v
+SET purchase_cost_minor = t.offered_value_minor,
+    purchase_cost_currency = t.offered_value_currency,
+    acquisition_source = 'trade_in',
+    acquired_at = COALESCE(t.accepted_at, v.acquired_at)
+FROM trade_ins t
+WHERE t.vehicle_id = v.id AND t.status = 'accepted' AND t.deleted_at IS NULL;
+ALTER TABLE vehicles ALTER COLUMN acquired_at SET DEFAULT NOW();
+ALTER TABLE vehicles ALTER COLUMN acquired_at SET NOT NULL;
+ALTER TABLE vehicles ALTER COLUMN purchase_cost_currency SET NOT NULL;
+
+CREATE TABLE IF NOT EXISTS reconditioning_expenses (
+    id              BIGSERIAL PRIMARY KEY,
+    created_at      TIMESTAMPTZ,
+    updated_at      TIMESTAMPTZ,
+    deleted_at      TIMESTAMPTZ,
+    vehicle_id      BIGINT NOT NULL REFERENCES vehicles (id),
+    recorded_by_id  BIGINT NOT NULL REFERENCES users (id),
+    category        VARCHAR(20) NOT NULL,
+    description     TEXT NOT NULL,
+    vendor          TEXT,
+    invoice_ref     TEXT,
+    amount_minor    BIGINT NOT NULL DEFAULT 0,
+    amount_currency VARCHAR(3) NOT NULL,
+    incurred_at     TIMESTAMPTZ NOT NULL,
+    CONSTRAINT chk_reconditioning_expenses_amount CHECK (amount_minor > 0),
+    CONSTRAINT chk_reconditioning_expenses_category
+        CHECK (category IN ('parts', 'labor', 'detailing', 'inspection', 'transport', 'other'))
+);
+CREATE INDEX IF NOT EXISTS idx_reconditioning_expenses_vehicle_id ON reconditioning_expenses (vehicle_id);
+CREATE INDEX IF NOT EXISTS idx_reconditioning_expenses_deleted_at ON reconditioning_expenses (deleted_at);
+
+-- Sales record their net revenue, the vehicle's cost and the gross profit
+-- between them when they complete. Completed sales are backfilled from
+-- their taxable lines less the taxes and fees included in them.
+ALTER TABLE sales
+    ADD COLUMN net_revenue_minor     BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN net_revenue_currency  VARCHAR(3),
+    ADD COLUMN vehicle_cost_minor    BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN vehicle_cost_currency VARCHAR(3),
+    ADD COLUMN gross_profit_minor    BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN gross_profit_currency VARCHAR(3);
+UPDATE sales SET net_revenue_currency = sale_price_currency,
+                 vehicle_cost_currency = sale_price_currency,
+                 gross_profit_currency = sale_price_currency;
+UPDATE sales s
+SET net_revenue_minor = COALESCE((SELECT SUM(CASE WHEN l.type IN ('vehicle', 'discount', 'add_on') THEN l.amount_minor
+                                                  WHEN l.inclusive THEN -l.amount_minor
+                                                  ELSE 0 END)
+                                  FROM sale_lines l WHERE l.sale_id = s.id), 0),
+    vehicle_cost_minor = COALESCE((SELECT v.purchase_cost_minor FROM vehicles v
+                                   WHERE v.id = s.vehicle_id AND v.purchase_cost_currency = s.sale_price_currency), 0)
+WHERE s.status = 'completed';
+UPDATE sales SET gross_profit_minor = net_revenue_minor - vehicle_cost_minor WHERE status = 'completed';
+ALTER TABLE sales ALTER COLUMN net_revenue_currency SET NOT NULL;
+ALTER TABLE sales ALTER COLUMN vehicle_cost_currency SET NOT NULL;
+ALTER TABLE sales ALTER COLUMN gross_profit_currency SET NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_sales_status_completed_at ON sales (status, completed_at);
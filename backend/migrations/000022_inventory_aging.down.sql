@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS vehicle_price_changes;
+DROP TABLE IF EXISTS repricing_rules;
+DROP INDEX IF EXISTS idx_vehicles_status_stocked_at;
+ALTER TABLE vehicles DROP COLUMN IF EXISTS stocked_at;
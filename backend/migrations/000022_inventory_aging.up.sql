@@ -0,0 +1,49 @@
+-- Inventory aging: vehicles record when their days in stock started, which
+-- for existing vehicles is when they were acquired.
+ALTER TABLE vehicles ADD COLUMN stocked_at TIMESTAMPTZ;
+UPDATE vehicles SET stocked_at = acquired_at;
+ALTER TABLE vehicles ALTER COLUMN stocked_at SET DEFAULT NOW();
+ALTER TABLE vehicles ALTER COLUMN stocked_at SET NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_vehicles_status_stocked_at ON vehicles (status, stocked_at);
+
+-- Repricing rules lower the price of available vehicles the longer they stay
+-- in stock, down to a floor over their purchase cost.
+CREATE TABLE IF NOT EXISTS repricing_rules (
+    id           BIGSERIAL PRIMARY KEY,
+    created_at   TIMESTAMPTZ,
+    updated_at   TIMESTAMPTZ,
+    deleted_at   TIMESTAMPTZ,
+    name         VARCHAR(100) NOT NULL,
+    make         TEXT NOT NULL DEFAULT '',
+    model        TEXT NOT NULL DEFAULT '',
+    category     TEXT NOT NULL DEFAULT '',
+    every_days   INTEGER NOT NULL,
+    drop_rate    BIGINT NOT NULL,
+    floor_markup BIGINT NOT NULL DEFAULT 0,
+    is_active    BOOLEAN NOT NULL DEFAULT TRUE,
+    CONSTRAINT chk_repricing_rules_every_days CHECK (every_days > 0),
+    CONSTRAINT chk_repricing_rules_drop_rate CHECK (drop_rate BETWEEN 1 AND 10000),
+    CONSTRAINT chk_repricing_rules_floor_markup CHECK (floor_markup >= 0)
+);
+CREATE INDEX IF NOT EXISTS idx_repricing_rules_deleted_at ON repricing_rules (deleted_at);
+-- one active rule per set of filters
+CREATE UNIQUE INDEX IF NOT EXISTS idx_repricing_rules_active_filters
+    ON repricing_rules (LOWER(make), LOWER(model), category)
+    WHERE is_active AND deleted_at IS NULL;
+
+-- Price history: every change to a vehicle's price, by hand or by a rule.
+CREATE TABLE IF NOT EXISTS vehicle_price_changes (
+    id                 BIGSERIAL PRIMARY KEY,
+    created_at         TIMESTAMPTZ,
+    vehicle_id         BIGINT NOT NULL REFERENCES vehicles (id),
+    source             VARCHAR(20) NOT NULL,
+    old_price_minor    BIGINT NOT NULL DEFAULT 0,
+    old_price_currency VARCHAR(3) NOT NULL,
+    new_price_minor    BIGINT NOT NULL DEFAULT 0,
+    new_price_currency VARCHAR(3) NOT NULL,
+    days_in_stock      INTEGER NOT NULL DEFAULT 0,
+    rule_id            BIGINT REFERENCES repricing_rules (id),
+    changed_by_id      BIGINT REFERENCES users (id),
+    CONSTRAINT chk_vehicle_price_changes_source CHECK (source IN ('manual', 'repricing'))
+);
+CREATE INDEX IF NOT EXISTS idx_vehicle_price_changes_vehicle_created_at ON vehicle_price_changes (vehicle_id, created_at);
@@ -0,0 +1,4 @@
+-- Documents never downloaded are left with an empty PDF, rendered from their
+-- content when next downloaded once this migration is applied again.
+UPDATE documents SET pdf = '' WHERE pdf IS NULL;
+ALTER TABLE documents ALTER COLUMN pdf SET NOT NULL;
@@ -0,0 +1,3 @@
+-- Documents are rendered to PDF on first download rather than while their
+-- number is locked; until then only their content is stored.
+ALTER TABLE documents ALTER COLUMN pdf DROP NOT NULL;
@@ -0,0 +1,11 @@
+// Package migrations embeds the versioned SQL schema migrations.
+//
+// Each migration is a pair of files named <version>_<name>.up.sql and
+// <version>_<name>.down.sql. Versions are applied in ascending order by
+// `main migrate up` and recorded in the schema_migrations table.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
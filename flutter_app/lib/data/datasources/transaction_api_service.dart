@@ -1,4 +1,7 @@
+import 'package:dio/dio.dart';
+
 import '../../core/network/network_service.dart';
+import '../models/money.dart';
 import '../models/transaction.dart';
 
 class TransactionApiService {
@@ -88,22 +91,71 @@ class TransactionApiService {
     throw Exception('Failed to update transaction');
   }
 
+  /// Charges a pending transaction. Card, bank transfer and financing
+  /// payments may come back still pending (202) while the provider confirms
+  /// them; check the returned status.
   Future<Transaction> processPayment(int id) async {
     final response = await _networkService.post('/transactions/$id/process');
 
-    if (response.statusCode == 200 && response.data['status'] == 'success') {
+    if ((response.statusCode == 200 || response.statusCode == 202) &&
+        response.data['status'] == 'success') {
       return Transaction.fromJson(response.data['data']);
     }
 
-    throw Exception('Failed to process payment');
+    throw Exception(response.data?['message'] ?? 'Failed to process payment');
   }
 
-  Future<void> refundTransaction(int id) async {
-    final response = await _networkService.post('/transactions/$id/refund');
+  /// Refunds [amount] of a completed payment, or all that is left of it when
+  /// omitted. [reasonCode] is required, e.g. 'vehicle_returned' or
+  /// 'accessory_fee'; [cancelSale] overrides whether that reason cancels the
+  /// sale.
+  Future<Transaction> refundTransaction(
+    int id, {
+    required String reasonCode,
+    double? amount,
+    bool? cancelSale,
+    String? notes,
+  }) async {
+    final response = await _networkService.post(
+      '/transactions/$id/refund',
+      data: {
+        'reason_code': reasonCode,
+        if (amount != null) 'amount': amount,
+        if (cancelSale != null) 'cancel_sale': cancelSale,
+        if (notes != null) 'notes': notes,
+      },
+    );
 
-    if (response.statusCode != 200 || response.data['status'] != 'success') {
-      throw Exception('Failed to refund transaction');
+    if (response.statusCode == 201 && response.data['status'] == 'success') {
+      return Transaction.fromJson(response.data['data']);
     }
+
+    throw Exception('Failed to refund transaction');
+  }
+
+  /// Fetches the receipt (or credit note for a refund) of a completed
+  /// transaction as raw ESC/POS bytes, ready to be written to a 58 or 80 mm
+  /// thermal printer over Bluetooth or USB. [width] and [template]
+  /// ('standard' or 'compact') default to the server's configuration.
+  Future<List<int>> getReceiptEscPos(
+    int id, {
+    int? width,
+    String? template,
+  }) async {
+    final response = await _networkService.get<List<int>>(
+      '/transactions/$id/receipt.escpos',
+      queryParameters: {
+        if (width != null) 'width': width,
+        if (template != null) 'template': template,
+      },
+      options: Options(responseType: ResponseType.bytes),
+    );
+
+    if (response.statusCode == 200 && response.data != null) {
+      return response.data!;
+    }
+
+    throw Exception('Failed to fetch receipt');
   }
 
   Future<Map<String, dynamic>> getTransactionAnalytics() async {
@@ -135,7 +187,7 @@ class TransactionApiService {
     );
 
     if (response.statusCode == 200 && response.data['status'] == 'success') {
-      return (response.data['data']['total_revenue'] ?? 0.0).toDouble();
+      return Money.fromJson(response.data['data']['total_revenue']).value;
     }
 
     throw Exception('Failed to fetch revenue data');
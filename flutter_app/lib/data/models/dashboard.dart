@@ -1,3 +1,4 @@
+import 'money.dart';
 import 'sale.dart';
 import 'test_drive.dart';
 import 'lead.dart';
@@ -6,11 +7,11 @@ class DashboardSummary {
   final int totalVehicles;
   final int availableVehicles;
   final int totalSales;
-  final double totalRevenue;
+  final Money totalRevenue;
   final int totalCustomers;
   final int pendingTestDrives;
   final int newLeads;
-  final double monthlyRevenue;
+  final Money monthlyRevenue;
 
   const DashboardSummary({
     required this.totalVehicles,
@@ -28,16 +29,16 @@ class DashboardSummary {
       totalVehicles: json['total_vehicles'] ?? 0,
       availableVehicles: json['available_vehicles'] ?? 0,
       totalSales: json['total_sales'] ?? 0,
-      totalRevenue: (json['total_revenue'] ?? 0.0).toDouble(),
+      totalRevenue: Money.fromJson(json['total_revenue']),
       totalCustomers: json['total_customers'] ?? 0,
       pendingTestDrives: json['pending_test_drives'] ?? 0,
       newLeads: json['new_leads'] ?? 0,
-      monthlyRevenue: (json['monthly_revenue'] ?? 0.0).toDouble(),
+      monthlyRevenue: Money.fromJson(json['monthly_revenue']),
     );
   }
 
-  String get formattedTotalRevenue => '\$${totalRevenue.toStringAsFixed(2)}';
-  String get formattedMonthlyRevenue => '\$${monthlyRevenue.toStringAsFixed(2)}';
+  String get formattedTotalRevenue => totalRevenue.formatted;
+  String get formattedMonthlyRevenue => monthlyRevenue.formatted;
 }
 
 class SalesChartData {
@@ -55,7 +56,7 @@ class SalesChartData {
     return SalesChartData(
       date: json['date'] ?? '',
       sales: json['sales'] ?? 0,
-      revenue: (json['revenue'] ?? 0.0).toDouble(),
+      revenue: Money.fromJson(json['revenue']).value,
     );
   }
 }
@@ -106,7 +107,7 @@ class MonthlyRevenueData {
   factory MonthlyRevenueData.fromJson(Map<String, dynamic> json) {
     return MonthlyRevenueData(
       month: json['month'] ?? '',
-      revenue: (json['revenue'] ?? 0.0).toDouble(),
+      revenue: Money.fromJson(json['revenue']).value,
     );
   }
 }
@@ -193,11 +194,11 @@ class DashboardData {
         'total_vehicles': summary.totalVehicles,
         'available_vehicles': summary.availableVehicles,
         'total_sales': summary.totalSales,
-        'total_revenue': summary.totalRevenue,
+        'total_revenue': summary.totalRevenue.toJson(),
         'total_customers': summary.totalCustomers,
         'pending_test_drives': summary.pendingTestDrives,
         'new_leads': summary.newLeads,
-        'monthly_revenue': summary.monthlyRevenue,
+        'monthly_revenue': summary.monthlyRevenue.toJson(),
       },
       'charts': {
         'sales_chart': charts.salesChart.map((item) => {
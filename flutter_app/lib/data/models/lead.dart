@@ -1,3 +1,4 @@
+import 'money.dart';
 import 'user.dart';
 
 class Lead {
@@ -6,7 +7,7 @@ class Lead {
   final String? email;
   final String? phone;
   final String? interestedIn;
-  final double budget;
+  final Money budget;
   final int? assignedToId;
   final String status;
   final String? notes;
@@ -38,7 +39,7 @@ class Lead {
       email: json['email'],
       phone: json['phone'],
       interestedIn: json['interested_in'],
-      budget: (json['budget'] ?? 0.0).toDouble(),
+      budget: Money.fromJson(json['budget']),
       assignedToId: json['assigned_to_id'],
       status: json['status'] ?? 'new',
       notes: json['notes'],
@@ -62,7 +63,7 @@ class Lead {
       'email': email,
       'phone': phone,
       'interested_in': interestedIn,
-      'budget': budget,
+      'budget': budget.toJson(),
       'assigned_to_id': assignedToId,
       'status': status,
       'notes': notes,
@@ -73,7 +74,7 @@ class Lead {
     };
   }
 
-  String get formattedBudget => '\$${budget.toStringAsFixed(2)}';
+  String get formattedBudget => budget.formatted;
   String get statusDisplayName => status.toUpperCase();
   
   String get initials {
@@ -94,8 +95,8 @@ class Lead {
   bool get isLost => status == 'lost';
 
   String get priorityLevel {
-    if (budget > 50000) return 'High';
-    if (budget > 25000) return 'Medium';
+    if (budget.value > 50000) return 'High';
+    if (budget.value > 25000) return 'Medium';
     return 'Low';
   }
 
@@ -122,7 +123,7 @@ class Lead {
     String? email,
     String? phone,
     String? interestedIn,
-    double? budget,
+    Money? budget,
     int? assignedToId,
     String? status,
     String? notes,
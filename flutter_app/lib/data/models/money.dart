@@ -0,0 +1,75 @@
+/// A monetary amount as sent by the backend:
+/// {"amount": "1500000.00", "currency": "IDR", "minor_units": 150000000}.
+/// The amount string is already exact, so it is displayed as-is rather than
+/// rounded on the client.
+class Money {
+  final String amount;
+  final String currency;
+  final int minorUnits;
+
+  const Money({
+    required this.amount,
+    required this.currency,
+    required this.minorUnits,
+  });
+
+  const Money.zero([this.currency = 'IDR'])
+      : amount = '0',
+        minorUnits = 0;
+
+  factory Money.fromJson(dynamic json) {
+    if (json is Map<String, dynamic>) {
+      return Money(
+        amount: json['amount']?.toString() ?? '0',
+        currency: json['currency'] ?? 'IDR',
+        minorUnits: (json['minor_units'] ?? 0) as int,
+      );
+    }
+    // Plain numbers come from cached data written before amounts carried a
+    // currency.
+    final value = json is num ? json.toDouble() : double.tryParse('${json ?? ''}');
+    if (value == null) return const Money.zero();
+    return Money(
+      amount: value.toStringAsFixed(2),
+      currency: 'IDR',
+      minorUnits: (value * 100).round(),
+    );
+  }
+
+  Map<String, dynamic> toJson() {
+    return {
+      'amount': amount,
+      'currency': currency,
+      'minor_units': minorUnits,
+    };
+  }
+
+  /// Approximate value in major units, for charts and comparisons only.
+  double get value => double.tryParse(amount) ?? 0.0;
+
+  String get formatted => '$currency $amount';
+
+  /// Adds amounts of the same currency using their minor units.
+  Money operator +(Money other) {
+    final total = minorUnits + other.minorUnits;
+    final digits = _digits(minorUnits == 0 ? other.amount : amount);
+    return Money(
+      amount: _format(total, digits),
+      currency: minorUnits == 0 ? other.currency : currency,
+      minorUnits: total,
+    );
+  }
+
+  static int _digits(String amount) =>
+      amount.contains('.') ? amount.split('.')[1].length : 0;
+
+  static String _format(int minor, int digits) {
+    if (digits == 0) return minor.toString();
+    final sign = minor < 0 ? '-' : '';
+    final text = minor.abs().toString().padLeft(digits + 1, '0');
+    return '$sign${text.substring(0, text.length - digits)}.${text.substring(text.length - digits)}';
+  }
+
+  @override
+  String toString() => formatted;
+}
@@ -1,3 +1,4 @@
+import 'money.dart';
 import 'user.dart';
 import 'vehicle.dart';
 
@@ -23,7 +24,7 @@ class Sale {
   final int vehicleId;
   final int customerId;
   final int salesPersonId;
-  final double salePrice;
+  final Money salePrice;
   final SaleStatus status;
   final String? notes;
   final DateTime? completedAt;
@@ -55,7 +56,7 @@ class Sale {
       vehicleId: json['vehicle_id'] ?? 0,
       customerId: json['customer_id'] ?? 0,
       salesPersonId: json['sales_person_id'] ?? 0,
-      salePrice: (json['sale_price'] ?? 0.0).toDouble(),
+      salePrice: Money.fromJson(json['sale_price']),
       status: SaleStatus.fromString(json['status'] ?? 'pending'),
       notes: json['notes'],
       completedAt: json['completed_at'] != null
@@ -83,7 +84,7 @@ class Sale {
       'vehicle_id': vehicleId,
       'customer_id': customerId,
       'sales_person_id': salesPersonId,
-      'sale_price': salePrice,
+      'sale_price': salePrice.toJson(),
       'status': status.value,
       'notes': notes,
       'completed_at': completedAt?.toIso8601String(),
@@ -95,7 +96,7 @@ class Sale {
     };
   }
 
-  String get formattedPrice => '\$${salePrice.toStringAsFixed(2)}';
+  String get formattedPrice => salePrice.formatted;
   String get statusDisplayName => status.value.toUpperCase();
 
   Sale copyWith({
@@ -103,7 +104,7 @@ class Sale {
     int? vehicleId,
     int? customerId,
     int? salesPersonId,
-    double? salePrice,
+    Money? salePrice,
     SaleStatus? status,
     String? notes,
     DateTime? completedAt,
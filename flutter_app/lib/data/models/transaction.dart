@@ -1,3 +1,4 @@
+import 'money.dart';
 import 'sale.dart';
 import 'user.dart';
 
@@ -51,7 +52,7 @@ enum TransactionStatus {
 class Transaction {
   final int id;
   final int saleId;
-  final double amount;
+  final Money amount;
   final PaymentMethod paymentMethod;
   final TransactionStatus status;
   final int processedById;
@@ -83,7 +84,7 @@ class Transaction {
     return Transaction(
       id: json['id'] ?? 0,
       saleId: json['sale_id'] ?? 0,
-      amount: (json['amount'] ?? 0.0).toDouble(),
+      amount: Money.fromJson(json['amount']),
       paymentMethod: PaymentMethod.fromString(json['payment_method'] ?? 'cash'),
       status: TransactionStatus.fromString(json['status'] ?? 'pending'),
       processedById: json['processed_by_id'] ?? 0,
@@ -109,7 +110,7 @@ class Transaction {
     return {
       'id': id,
       'sale_id': saleId,
-      'amount': amount,
+      'amount': amount.toJson(),
       'payment_method': paymentMethod.value,
       'status': status.value,
       'processed_by_id': processedById,
@@ -123,7 +124,7 @@ class Transaction {
     };
   }
 
-  String get formattedAmount => '\$${amount.toStringAsFixed(2)}';
+  String get formattedAmount => amount.formatted;
   String get statusDisplayName => status.value.toUpperCase();
   String get paymentMethodDisplayName => paymentMethod.displayName;
 
@@ -149,7 +150,7 @@ class Transaction {
   Transaction copyWith({
     int? id,
     int? saleId,
-    double? amount,
+    Money? amount,
     PaymentMethod? paymentMethod,
     TransactionStatus? status,
     int? processedById,
@@ -1,3 +1,5 @@
+import 'money.dart';
+
 enum VehicleStatus {
   available('available'),
   sold('sold'),
@@ -55,7 +57,7 @@ class Vehicle {
   final String? color;
   final String? vin;
   final String? licensePlate;
-  final double price;
+  final Money price;
   final int mileage;
   final VehicleStatus status;
   final String? description;
@@ -94,7 +96,7 @@ class Vehicle {
       color: json['color'],
       vin: json['vin'],
       licensePlate: json['license_plate'],
-      price: (json['price'] ?? 0.0).toDouble(),
+      price: Money.fromJson(json['price']),
       mileage: json['mileage'] ?? 0,
       status: VehicleStatus.fromString(json['status'] ?? 'available'),
       description: json['description'],
@@ -113,7 +115,7 @@ class Vehicle {
       'color': color,
       'vin': vin,
       'license_plate': licensePlate,
-      'price': price,
+      'price': price.toJson(),
       'mileage': mileage,
       'status': status.value,
       'description': description,
@@ -125,7 +127,7 @@ class Vehicle {
 
   String get displayName => '$make $model';
   String get yearString => year.toString();
-  String get priceFormatted => '\$${price.toStringAsFixed(2)}';
+  String get priceFormatted => price.formatted;
   
   VehicleImage? get primaryImage {
     final primary = images.where((img) => img.isPrimary).firstOrNull;
@@ -140,7 +142,7 @@ class Vehicle {
     String? color,
     String? vin,
     String? licensePlate,
-    double? price,
+    Money? price,
     int? mileage,
     VehicleStatus? status,
     String? description,
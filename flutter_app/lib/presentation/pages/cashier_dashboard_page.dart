@@ -1,5 +1,6 @@
 import 'package:flutter/material.dart';
 import 'package:flutter_bloc/flutter_bloc.dart';
+import '../../data/models/money.dart';
 import '../../data/models/transaction.dart';
 import '../../data/models/sale.dart';
 import '../bloc/dashboard/dashboard_bloc.dart';
@@ -333,14 +334,14 @@ class _CashierDashboardPageState extends State<CashierDashboardPage>
             ),
             DashboardSummaryCard(
               title: 'Revenue Today',
-              value: '\$${(_analytics!['total_revenue'] ?? 0.0).toStringAsFixed(0)}',
+              value: Money.fromJson(_analytics!['total_revenue']).formatted,
               subtitle: 'Total processed',
               icon: Icons.attach_money,
               color: Colors.blue,
             ),
             DashboardSummaryCard(
               title: 'Avg Transaction',
-              value: '\$${(_analytics!['avg_transaction_amount'] ?? 0.0).toStringAsFixed(0)}',
+              value: Money.fromJson(_analytics!['avg_transaction_amount']).formatted,
               subtitle: 'Average amount',
               icon: Icons.trending_up,
               color: Colors.purple,
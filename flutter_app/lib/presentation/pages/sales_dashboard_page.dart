@@ -2,6 +2,7 @@ import 'package:flutter/material.dart';
 import 'package:flutter_bloc/flutter_bloc.dart';
 import '../../data/models/dashboard.dart';
 import '../../data/models/lead.dart';
+import '../../data/models/money.dart';
 import '../../data/models/sale.dart';
 import '../../data/models/test_drive.dart';
 import '../bloc/dashboard/dashboard_bloc.dart';
@@ -545,8 +546,8 @@ class _SalesDashboardPageState extends State<SalesDashboardPage>
   // Helper methods
   String _calculateMyRevenue() {
     final completedSales = _mySales.where((sale) => sale.status == SaleStatus.completed);
-    final total = completedSales.fold(0.0, (sum, sale) => sum + sale.salePrice);
-    return '\$${total.toStringAsFixed(0)}';
+    final total = completedSales.fold(const Money.zero(), (sum, sale) => sum + sale.salePrice);
+    return total.formatted;
   }
 
   int _getActiveLeadsCount() {
@@ -565,11 +566,11 @@ class _SalesDashboardPageState extends State<SalesDashboardPage>
 
   String _calculateAverageDealSize() {
     final completedSales = _mySales.where((sale) => sale.status == SaleStatus.completed);
-    if (completedSales.isEmpty) return '\$0';
+    if (completedSales.isEmpty) return const Money.zero().formatted;
     
-    final total = completedSales.fold(0.0, (sum, sale) => sum + sale.salePrice);
-    final average = total / completedSales.length;
-    return '\$${average.toStringAsFixed(0)}';
+    final total = completedSales.fold(const Money.zero(), (sum, sale) => sum + sale.salePrice);
+    final average = total.value / completedSales.length;
+    return '${total.currency} ${average.toStringAsFixed(0)}';
   }
 
   Color _getLeadPriorityColor(Lead lead) {
@@ -1,4 +1,5 @@
 import 'package:flutter/material.dart';
+import '../../data/models/money.dart';
 
 class PaymentMethodChart extends StatelessWidget {
   final List<dynamic> data;
@@ -43,7 +44,7 @@ class PaymentMethodChart extends StatelessWidget {
       children: data.map<Widget>((item) {
         final method = item['method'] ?? '';
         final count = item['count'] ?? 0;
-        final total = (item['total'] ?? 0.0).toDouble();
+        final total = Money.fromJson(item['total']).value;
         final percentage = totalTransactions > 0 ? (count / totalTransactions) * 100 : 0.0;
         
         return Container(
@@ -117,7 +118,7 @@ class PaymentMethodChart extends StatelessWidget {
 
   Widget _buildTotalsSummary(BuildContext context) {
     final totalTransactions = data.fold(0, (sum, item) => sum + (item['count'] ?? 0));
-    final totalAmount = data.fold(0.0, (sum, item) => sum + ((item['total'] ?? 0.0).toDouble()));
+    final totalAmount = data.fold(0.0, (sum, item) => sum + Money.fromJson(item['total']).value);
     final averageTransaction = totalTransactions > 0 ? totalAmount / totalTransactions : 0.0;
 
     return Container(
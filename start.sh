@@ -29,6 +29,9 @@ echo "🔧 Building backend..."
 cd backend
 go build .
 
+echo "🗄️  Running migrations..."
+./vehicle-sales-backend migrate up
+
 echo "🌱 Seeding database..."
 go run cmd/seed/main.go
 